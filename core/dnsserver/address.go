@@ -10,12 +10,15 @@ type zoneAddr struct {
 	Zone      string
 	Port      string
 	Transport string // dns, tls or grpc
-	Address   string // used for bound zoneAddr - validation of overlapping
+	Address   string // used for bound zoneAddr - validation of overlapping, or the path of a Unix socket
 }
 
 // String returns the string representation of z.
 func (z zoneAddr) String() string {
-	s := z.Transport + "://" + z.Zone + ":" + z.Port
+	s := z.Transport + "://" + z.Zone
+	if z.Port != "" {
+		s += ":" + z.Port
+	}
 	if z.Address != "" {
 		s += " on " + z.Address
 	}
@@ -49,7 +52,19 @@ func newOverlapZone() *zoneOverlap {
 // registerAndCheck adds a new zoneAddr for validation, it returns information about existing or overlapping with already registered
 // we consider that an unbound address is overlapping all bound addresses for same zone, same port
 func (zo *zoneOverlap) registerAndCheck(z zoneAddr) (existingZone *zoneAddr, overlappingZone *zoneAddr) {
+	existingZone, overlappingZone = zo.check(z)
+	if existingZone != nil || overlappingZone != nil {
+		return existingZone, overlappingZone
+	}
+	// there is no overlap, keep the current zoneAddr for future checks
+	uz := zoneAddr{Zone: z.Zone, Address: "", Port: z.Port, Transport: z.Transport}
+	zo.registeredAddr[z] = z
+	zo.unboundOverlap[uz] = z
+	return nil, nil
+}
 
+// check checks z against the registered zoneAddrs, without registering z.
+func (zo *zoneOverlap) check(z zoneAddr) (existingZone *zoneAddr, overlappingZone *zoneAddr) {
 	if exist, ok := zo.registeredAddr[z]; ok {
 		// exact same zone already registered
 		return &exist, nil
@@ -65,8 +80,5 @@ func (zo *zoneOverlap) registerAndCheck(z zoneAddr) (existingZone *zoneAddr, ove
 			return nil, &uz
 		}
 	}
-	// there is no overlap, keep the current zoneAddr for future checks
-	zo.registeredAddr[z] = z
-	zo.unboundOverlap[uz] = z
 	return nil, nil
 }
@@ -0,0 +1,150 @@
+package dnsserver
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Budget is the time a plugin may spend on a query.
+type Budget struct {
+	Timeout time.Duration
+	// Fallthrough passes the query on to the next plugin when the budget is exceeded, instead of
+	// answering with SERVFAIL.
+	Fallthrough bool
+}
+
+// budgetContext is the context of a plugin with a budget. It is done when the budget is used up, or
+// when the context of the query is done. The budget is paused while the plugin waits on the plugins
+// after it, so only the time of the plugin itself counts.
+type budgetContext struct {
+	context.Context // the context of the query
+
+	mu       sync.Mutex
+	left     time.Duration // budget left, as of start
+	start    time.Time     // when the budget was last resumed, zero while paused
+	timer    *time.Timer
+	stop     func() bool // stops watching the context of the query
+	done     chan struct{}
+	err      error
+	exceeded bool
+}
+
+func newBudgetContext(ctx context.Context, d time.Duration) *budgetContext {
+	b := &budgetContext{Context: ctx, left: d, start: time.Now(), done: make(chan struct{})}
+	b.timer = time.AfterFunc(d, func() { b.cancel(context.DeadlineExceeded, true) })
+	b.stop = context.AfterFunc(ctx, func() { b.cancel(ctx.Err(), false) })
+	return b
+}
+
+func (b *budgetContext) cancel(err error, exceeded bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return
+	}
+	b.err, b.exceeded = err, exceeded
+	close(b.done)
+}
+
+// Deadline implements context.Context. It is the end of the budget, if it isn't paused, or the
+// deadline of the query when that is earlier.
+func (b *budgetContext) Deadline() (time.Time, bool) {
+	b.mu.Lock()
+	start, left := b.start, b.left
+	b.mu.Unlock()
+	if start.IsZero() {
+		start = time.Now()
+	}
+	deadline := start.Add(left)
+	if d, ok := b.Context.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	return deadline, true
+}
+
+// Done implements context.Context.
+func (b *budgetContext) Done() <-chan struct{} { return b.done }
+
+// Err implements context.Context.
+func (b *budgetContext) Err() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.err
+}
+
+// pause stops the budget from running out.
+func (b *budgetContext) pause() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.start.IsZero() || b.err != nil {
+		return
+	}
+	if b.timer.Stop() {
+		b.left -= time.Since(b.start)
+		b.start = time.Time{}
+	}
+}
+
+// resume continues a paused budget.
+func (b *budgetContext) resume() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.start.IsZero() || b.err != nil {
+		return
+	}
+	b.start = time.Now()
+	b.timer.Reset(b.left)
+}
+
+// release releases the resources of b, it must be called when the plugin returns.
+func (b *budgetContext) release() {
+	b.timer.Stop()
+	b.stop()
+}
+
+// budgetExceeded returns true if b is done because the budget was used up.
+func (b *budgetContext) budgetExceeded() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.exceeded
+}
+
+// unbudgeted has the values of a context of a plugin with a budget, but the deadline and
+// cancellation of the query: it is the context for the plugins after it.
+type unbudgeted struct {
+	context.Context
+	query context.Context
+}
+
+// Deadline implements context.Context.
+func (u unbudgeted) Deadline() (time.Time, bool) { return u.query.Deadline() }
+
+// Done implements context.Context.
+func (u unbudgeted) Done() <-chan struct{} { return u.query.Done() }
+
+// Err implements context.Context.
+func (u unbudgeted) Err() error { return u.query.Err() }
+
+// budgetWriter records if a response was written.
+type budgetWriter struct {
+	dns.ResponseWriter
+	written bool
+}
+
+// WriteMsg implements dns.ResponseWriter.
+func (w *budgetWriter) WriteMsg(m *dns.Msg) error {
+	w.written = true
+	return w.ResponseWriter.WriteMsg(m)
+}
+
+// Write implements dns.ResponseWriter.
+func (w *budgetWriter) Write(buf []byte) (int, error) {
+	w.written = true
+	return w.ResponseWriter.Write(buf)
+}
+
+var errBudgetExceeded = errors.New("time budget exceeded")
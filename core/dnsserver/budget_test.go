@@ -0,0 +1,217 @@
+package dnsserver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// slowPlugin answers after delay, or gives up without answering when the context is done first.
+type slowPlugin struct {
+	delay time.Duration
+	rcode int
+}
+
+func (s slowPlugin) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return dns.RcodeServerFailure, ctx.Err()
+	}
+	m := new(dns.Msg)
+	m.SetRcode(r, s.rcode)
+	w.WriteMsg(m)
+	return s.rcode, nil
+}
+
+func (s slowPlugin) Name() string { return "slow" }
+
+func TestBudget(t *testing.T) {
+	tests := []struct {
+		delay         time.Duration
+		fall          bool
+		expectedRcode int
+		expectedErr   bool
+	}{
+		{0, false, dns.RcodeSuccess, false},                    // in budget
+		{10 * time.Millisecond, true, dns.RcodeSuccess, false}, // in budget, with fallthrough
+		{time.Second, false, dns.RcodeServerFailure, true},     // exceeded, SERVFAIL
+		{time.Second, true, dns.RcodeNameError, false},         // exceeded, the next plugin answers
+	}
+
+	next := slowPlugin{rcode: dns.RcodeNameError}
+	for i, tc := range tests {
+		b := newChainHandler(slowPlugin{delay: tc.delay, rcode: dns.RcodeSuccess}, next, "", &Budget{Timeout: 200 * time.Millisecond, Fallthrough: tc.fall})
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+
+		rcode, err := b.ServeDNS(context.TODO(), rec, m)
+		if rcode != tc.expectedRcode {
+			t.Errorf("Test %d: expected rcode %d, got %d", i, tc.expectedRcode, rcode)
+		}
+		if (err != nil) != tc.expectedErr {
+			t.Errorf("Test %d: expected error %t, got %v", i, tc.expectedErr, err)
+		}
+		if plugin.ClientWrite(rcode) && rec.Rcode != rcode {
+			t.Errorf("Test %d: expected response with rcode %d, got %d", i, rcode, rec.Rcode)
+		}
+	}
+}
+
+// nextPlugin calls the plugin after it, and then waits for delay.
+type nextPlugin struct {
+	next  plugin.Handler
+	delay time.Duration
+	name  string
+}
+
+func (n nextPlugin) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	rcode, err := plugin.NextOrFailure(n.Name(), n.next, ctx, w, r)
+	select {
+	case <-time.After(n.delay):
+	case <-ctx.Done():
+		return dns.RcodeServerFailure, ctx.Err()
+	}
+	return rcode, err
+}
+
+func (n nextPlugin) Name() string { return n.name }
+
+func TestBudgetDownstream(t *testing.T) {
+	// The plugins after the one with a budget take longer than the budget, that must neither
+	// cancel them nor use up the budget.
+	slow := newChainHandler(slowPlugin{delay: 300 * time.Millisecond, rcode: dns.RcodeSuccess}, nil, "", nil)
+	b := newChainHandler(nextPlugin{next: slow, delay: 50 * time.Millisecond, name: "next"}, slow, "", &Budget{Timeout: 200 * time.Millisecond})
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	rcode, err := b.ServeDNS(context.TODO(), rec, m)
+	if rcode != dns.RcodeSuccess || err != nil {
+		t.Errorf("Expected rcode %d and no error, got %d and %v", dns.RcodeSuccess, rcode, err)
+	}
+	if rec.Msg == nil || rec.Rcode != dns.RcodeSuccess {
+		t.Errorf("Expected a response from the plugin after the one with a budget")
+	}
+}
+
+func TestBudgetContext(t *testing.T) {
+	b := newBudgetContext(context.TODO(), 100*time.Millisecond)
+	defer b.release()
+
+	b.pause()
+	time.Sleep(200 * time.Millisecond)
+	if b.Err() != nil {
+		t.Fatalf("Expected no error while the budget is paused, got %s", b.Err())
+	}
+	b.resume()
+	select {
+	case <-b.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Expected the budget to be used up")
+	}
+	if !b.budgetExceeded() || b.Err() != context.DeadlineExceeded {
+		t.Errorf("Expected the budget to be exceeded, got %v", b.Err())
+	}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	b1 := newBudgetContext(ctx, time.Minute)
+	defer b1.release()
+	cancel()
+	<-b1.Done()
+	if b1.budgetExceeded() || b1.Err() != context.Canceled {
+		t.Errorf("Expected the budget to be canceled with the query, got %v", b1.Err())
+	}
+}
+
+// deadlinePlugin records if the context of the query has a deadline.
+type deadlinePlugin struct{ deadline *bool }
+
+func (d deadlinePlugin) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	_, *d.deadline = ctx.Deadline()
+	m := new(dns.Msg)
+	m.SetReply(r)
+	w.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
+func (d deadlinePlugin) Name() string { return "deadline" }
+
+func TestServerBudgets(t *testing.T) {
+	deadline := false
+	c := testConfig("dns", deadlinePlugin{&deadline})
+	c.QueryTimeout = time.Second
+	c.Budgets = map[string]Budget{"deadline": {Timeout: time.Second}}
+
+	s, err := NewServer("127.0.0.1:53", []*Config{c})
+	if err != nil {
+		t.Fatalf("Expected no error for NewServer, got %s", err)
+	}
+	if ch, ok := c.pluginChain.(*chainHandler); !ok || ch.budget == nil {
+		t.Errorf("Expected the plugin to run with a budget")
+	}
+	if _, ok := c.registry["deadline"].(deadlinePlugin); !ok {
+		t.Errorf("Expected the plugin itself in the registry")
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+	s.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), m)
+	if !deadline {
+		t.Errorf("Expected a deadline on the context of the query")
+	}
+
+	// Without budgets the plugins aren't wrapped.
+	c = testConfig("dns", deadlinePlugin{&deadline})
+	if _, err := NewServer("127.0.0.1:53", []*Config{c}); err != nil {
+		t.Fatalf("Expected no error for NewServer, got %s", err)
+	}
+	if _, ok := c.pluginChain.(*chainHandler); ok {
+		t.Errorf("Expected the plugin not to be wrapped without budgets")
+	}
+}
+
+func TestPluginDuration(t *testing.T) {
+	const server = "dns://127.0.0.1:1053"
+	inner := newChainHandler(slowPlugin{delay: 100 * time.Millisecond, rcode: dns.RcodeSuccess}, nil, server, nil)
+	outer := newChainHandler(nextPlugin{next: inner, name: "outer"}, inner, server, nil)
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	outer.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), m)
+
+	mfs, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	durations := map[string]float64{}
+	for _, mf := range mfs {
+		if mf.GetName() != "coredns_plugin_request_duration_seconds" {
+			continue
+		}
+		for _, metric := range mf.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range metric.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["server"] == server {
+				durations[labels["name"]] = metric.GetHistogram().GetSampleSum()
+			}
+		}
+	}
+	if d, ok := durations["slow"]; !ok || d < 0.1 {
+		t.Errorf("Expected a duration of at least 100ms for the inner plugin, got %v", durations)
+	}
+	// The first plugin is measured too, without the time of the plugin after it.
+	if d, ok := durations["outer"]; !ok || d >= 0.05 {
+		t.Errorf("Expected a duration below 50ms for the outer plugin, got %v", durations)
+	}
+}
@@ -0,0 +1,85 @@
+package dnsserver
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics/vars"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// chainHandler wraps each plugin in the plugin chain of a Config with budgets. It records the time
+// the plugin itself takes on a query, without the time of the plugins after it, and runs the plugin
+// with its time budget, if it has one. The plugins without a budget are wrapped too: they pause the
+// budget of the plugin before them.
+type chainHandler struct {
+	plugin.Handler
+	next   plugin.Handler // the plugin after this one, for fallthrough
+	budget *Budget
+
+	duration prometheus.Observer
+	exceeded prometheus.Counter
+}
+
+func newChainHandler(h, next plugin.Handler, server string, budget *Budget) *chainHandler {
+	return &chainHandler{
+		Handler:  h,
+		next:     next,
+		budget:   budget,
+		duration: vars.PluginDuration.WithLabelValues(server, h.Name()),
+		exceeded: vars.PluginBudgetExceeded.WithLabelValues(server, h.Name()),
+	}
+}
+
+// hop is passed by a plugin, in the context, to the plugin after it.
+type hop struct {
+	downstream int64          // nanoseconds spent in the plugins after it, accessed atomically.
+	budget     *budgetContext // nil when the plugin has no budget.
+}
+
+type hopKey struct{}
+
+// ServeDNS implements the plugin.Handler interface.
+func (c *chainHandler) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	start := time.Now()
+	if prev, ok := ctx.Value(hopKey{}).(*hop); ok {
+		// The plugin before us waits, its budget doesn't run out while it does.
+		defer func() { atomic.AddInt64(&prev.downstream, int64(time.Since(start))) }()
+		if prev.budget != nil {
+			prev.budget.pause()
+			defer prev.budget.resume()
+			ctx = unbudgeted{Context: ctx, query: prev.budget.Context}
+		}
+	}
+
+	h := &hop{}
+	pctx := ctx
+	var bw *budgetWriter
+	if c.budget != nil {
+		h.budget = newBudgetContext(ctx, c.budget.Timeout)
+		defer h.budget.release()
+		pctx = h.budget
+		bw = &budgetWriter{ResponseWriter: w}
+		w = bw
+	}
+
+	rcode, err := c.Handler.ServeDNS(context.WithValue(pctx, hopKey{}, h), w, r)
+
+	self := time.Since(start) - time.Duration(atomic.LoadInt64(&h.downstream))
+	c.duration.Observe(self.Seconds())
+
+	if h.budget == nil || !h.budget.budgetExceeded() || bw.written {
+		return rcode, err
+	}
+
+	c.exceeded.Inc()
+	if !c.budget.Fallthrough {
+		return dns.RcodeServerFailure, plugin.Error(c.Name(), errBudgetExceeded)
+	}
+	// The next plugin gets a hop of its own, its time is already counted as ours by the plugin before us.
+	return plugin.NextOrFailure(c.Name(), c.next, context.WithValue(ctx, hopKey{}, &hop{}), w, r)
+}
@@ -1,12 +1,18 @@
 package dnsserver
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/coredns/caddy"
 	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnscrypt"
+	"github.com/coredns/coredns/plugin/pkg/odoh"
+	"github.com/coredns/coredns/plugin/pkg/proxyproto"
+	"github.com/coredns/coredns/request"
 )
 
 // Config configuration for a single server.
@@ -40,6 +46,67 @@ type Config struct {
 	// TLSConfig when listening for encrypted connections (gRPC, DNS-over-TLS).
 	TLSConfig *tls.Config
 
+	// DNSCrypt holds the keys of the resolver when listening for DNSCrypt queries.
+	DNSCrypt *dnscrypt.Resolver
+
+	// ODoH holds the key of the target when answering Oblivious DNS-over-HTTPS queries.
+	ODoH *odoh.Target
+
+	// DoH configures the paths, CORS and h2c of a DNS-over-HTTPS server, nil is the defaults.
+	DoH *DoHConfig
+
+	// ReadTimeout, WriteTimeout and IdleTimeout are the timeouts of the connections to the server.
+	// When zero the defaults of the transport are used.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+
+	// GraceTimeout is the maximum duration of a graceful shutdown, when zero it is 5s.
+	GraceTimeout time.Duration
+
+	// TCPKeepalive signals the idle timeout to clients with the EDNS TCP keepalive option (RFC 7828).
+	TCPKeepalive bool
+
+	// QueryTimeout is the deadline of each query. Plugins that honor the context give up when it
+	// passes. Zero is no deadline.
+	QueryTimeout time.Duration
+
+	// Budgets limit the time a plugin, keyed by its name, may spend on a query.
+	Budgets map[string]Budget
+
+	// MaxTCPConnections limits the concurrent TCP connections to the server, MaxTCPConnectionsPerClient
+	// does the same per client IP address. MaxTCPQueries limits the queries on a single connection.
+	// Zero is no limit, for MaxTCPQueries it is the default of the transport.
+	MaxTCPConnections          int
+	MaxTCPConnectionsPerClient int
+	MaxTCPQueries              int
+
+	// ProxyProtocol, when set, makes the server accept PROXY protocol headers from the trusted load
+	// balancers in it, so the address of the client is used instead of the load balancer's.
+	ProxyProtocol *proxyproto.Config
+
+	// UDPSockets is the number of UDP sockets, with SO_REUSEPORT, that are opened for the listener.
+	// Each socket has its own reader. Zero is a single socket.
+	UDPSockets int
+
+	// UDPBatchSize, when not zero, reads and writes up to this many UDP datagrams with a single
+	// system call, if supported.
+	UDPBatchSize int
+
+	// Padding is the block size that responses over DNS-over-TLS, DNS-over-HTTPS and gRPC are padded
+	// to, when the client padded its query (RFC 7830, RFC 8467). Zero is the recommended 468 bytes,
+	// negative disables padding.
+	Padding int
+
+	// FilterFuncs is used to further filter access to this config, only when all of them return
+	// true the config is used for a query. This allows multiple server blocks to serve the same
+	// zone on the same listener, e.g. as views. Configs with filter funcs are tried in the order
+	// they are defined, before a config for the same zone without them.
+	FilterFuncs []FilterFunc
+
+	// ViewName is the name of the view this config implements, if any.
+	ViewName string
+
 	// Plugin stack.
 	Plugin []plugin.Plugin
 
@@ -54,6 +121,18 @@ type Config struct {
 	// firstConfigInBlock is used to reference the first config in a server block, for the
 	// purpose of sharing single instance of each plugin among all zones in a server block.
 	firstConfigInBlock *Config
+
+	// metaCollector collects the metadata before the query is handled, so the filter funcs can use it.
+	metaCollector MetadataCollector
+}
+
+// FilterFunc is a function that filters requests from the Config.
+type FilterFunc func(context.Context, *request.Request) bool
+
+// MetadataCollector is implemented by a plugin that can collect the metadata of a request before it
+// is passed down the plugin chain.
+type MetadataCollector interface {
+	Collect(context.Context, request.Request) context.Context
 }
 
 // keyForConfig builds a key for identifying the configs during setup time
@@ -0,0 +1,24 @@
+package dnsserver
+
+import (
+	"crypto/tls"
+
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// ConnectionState returns the TLS connection state of the connection the query in w was received
+// on. It returns nil when the query wasn't received over TLS (DNS-over-TLS, DoH or gRPC).
+func ConnectionState(w dns.ResponseWriter) *tls.ConnectionState {
+	for {
+		switch x := w.(type) {
+		case *request.ScrubWriter:
+			w = x.ResponseWriter
+		case dns.ConnectionStater:
+			return x.ConnectionState()
+		default:
+			return nil
+		}
+	}
+}
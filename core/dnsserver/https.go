@@ -1,12 +1,31 @@
 package dnsserver
 
 import (
+	"crypto/tls"
 	"net"
 	"net/http"
 
+	"github.com/coredns/coredns/plugin/pkg/doh"
 	"github.com/coredns/coredns/plugin/pkg/nonwriter"
 )
 
+// DoHConfig configures a DNS-over-HTTPS server.
+type DoHConfig struct {
+	// Path is the path of RFC 8484 queries, the default is /dns-query.
+	Path string
+	// JSONPath is the path of DNS JSON API queries, JSON is then also served on Path to clients that
+	// ask for it. Empty, the default, disables the JSON API.
+	JSONPath string
+	// CORSOrigins are the origins that are allowed to query the server from a browser, with "*"
+	// for all. Empty disables CORS.
+	CORSOrigins []string
+	// H2C serves HTTP/1.1 and HTTP/2 without TLS, for use behind a proxy that terminates TLS.
+	H2C bool
+}
+
+// DefaultDoHConfig is the configuration of a DNS-over-HTTPS server without a DoH config.
+var DefaultDoHConfig = DoHConfig{Path: doh.Path}
+
 // DoHWriter is a nonwriter.Writer that adds more specific LocalAddr and RemoteAddr methods.
 type DoHWriter struct {
 	nonwriter.Writer
@@ -28,3 +47,11 @@ func (d *DoHWriter) LocalAddr() net.Addr { return d.laddr }
 
 // Request returns the HTTP request
 func (d *DoHWriter) Request() *http.Request { return d.request }
+
+// ConnectionState implements the dns.ConnectionStater interface.
+func (d *DoHWriter) ConnectionState() *tls.ConnectionState {
+	if d.request == nil {
+		return nil
+	}
+	return d.request.TLS
+}
@@ -0,0 +1,204 @@
+package dnsserver
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin/metrics/vars"
+
+	"github.com/miekg/dns"
+)
+
+// defaultIdleTimeout is the idle timeout of TCP connections when none is configured, it is the
+// default of the dns package.
+const defaultIdleTimeout = 8 * time.Second
+
+// setTimeouts copies the timeouts and limits that are set in config to s.
+func (s *Server) setTimeouts(config *Config) {
+	if config.ReadTimeout != 0 {
+		s.readTimeout = config.ReadTimeout
+	}
+	if config.WriteTimeout != 0 {
+		s.writeTimeout = config.WriteTimeout
+	}
+	if config.IdleTimeout != 0 {
+		s.idleTimeout = config.IdleTimeout
+	}
+	if config.GraceTimeout != 0 {
+		s.graceTimeout = config.GraceTimeout
+	}
+	if config.TCPKeepalive {
+		s.tcpKeepalive = true
+	}
+	if config.QueryTimeout != 0 {
+		s.queryTimeout = config.QueryTimeout
+	}
+	if config.MaxTCPConnections != 0 {
+		s.maxTCPConns = config.MaxTCPConnections
+	}
+	if config.MaxTCPConnectionsPerClient != 0 {
+		s.maxTCPConnsPerClient = config.MaxTCPConnectionsPerClient
+	}
+	if config.MaxTCPQueries != 0 {
+		s.maxTCPQueries = config.MaxTCPQueries
+	}
+}
+
+// setTCPTimeouts sets the timeouts and the maximum number of queries per connection on the TCP server srv.
+func (s *Server) setTCPTimeouts(srv *dns.Server) {
+	srv.ReadTimeout = s.readTimeout
+	srv.WriteTimeout = s.writeTimeout
+	if s.idleTimeout != 0 {
+		idle := s.idleTimeout
+		srv.IdleTimeout = func() time.Duration { return idle }
+	}
+	srv.MaxTCPQueries = s.maxTCPQueries
+}
+
+// tcpIdleTimeout returns the idle timeout of TCP connections.
+func (s *Server) tcpIdleTimeout() time.Duration {
+	if s.idleTimeout != 0 {
+		return s.idleTimeout
+	}
+	return defaultIdleTimeout
+}
+
+// limitListener wraps l to enforce the maximum number of concurrent connections of s. If no limits
+// are configured l is returned.
+func (s *Server) limitListener(l net.Listener) net.Listener {
+	if s.maxTCPConns == 0 && s.maxTCPConnsPerClient == 0 {
+		return l
+	}
+	return &limitListener{
+		Listener:  l,
+		server:    s.Addr,
+		max:       s.maxTCPConns,
+		perClient: s.maxTCPConnsPerClient,
+		clients:   make(map[string]int),
+	}
+}
+
+// limitListener is a net.Listener that closes new connections right away when there are too many
+// connections, in total or from the same IP address.
+type limitListener struct {
+	net.Listener
+	server    string
+	max       int
+	perClient int
+
+	mu      sync.Mutex
+	total   int
+	clients map[string]int
+}
+
+// Accept implements net.Listener.
+func (l *limitListener) Accept() (net.Conn, error) {
+	for {
+		c, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		ip := clientIP(c.RemoteAddr())
+		if reason := l.acquire(ip); reason != "" {
+			vars.TCPConnectionsRejected.WithLabelValues(l.server, reason).Inc()
+			c.Close()
+			continue
+		}
+		return &limitConn{Conn: c, release: func() { l.release(ip) }}, nil
+	}
+}
+
+// acquire accounts for a new connection from ip. It returns the reason when that would exceed a limit.
+func (l *limitListener) acquire(ip string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.max > 0 && l.total >= l.max {
+		return "total"
+	}
+	if l.perClient > 0 && l.clients[ip] >= l.perClient {
+		return "client"
+	}
+	l.total++
+	l.clients[ip]++
+	return ""
+}
+
+func (l *limitListener) release(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.total--
+	if l.clients[ip]--; l.clients[ip] <= 0 {
+		delete(l.clients, ip)
+	}
+}
+
+// limitConn releases its slot in the limitListener when it is closed.
+type limitConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+// Close implements net.Conn.
+func (c *limitConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+	return err
+}
+
+func clientIP(a net.Addr) string {
+	if ta, ok := a.(*net.TCPAddr); ok {
+		return ta.IP.String()
+	}
+	host, _, err := net.SplitHostPort(a.String())
+	if err != nil {
+		return a.String()
+	}
+	return host
+}
+
+// keepaliveWriter returns a writer that adds the EDNS TCP keepalive option to the response, if
+// keepalive is enabled and the client sent the option in r (RFC 7828, section 3.2). Otherwise w is
+// returned.
+func (s *Server) keepaliveWriter(w dns.ResponseWriter, r *dns.Msg) dns.ResponseWriter {
+	if !s.tcpKeepalive {
+		return w
+	}
+	opt := r.IsEdns0()
+	if opt == nil {
+		return w
+	}
+	for _, o := range opt.Option {
+		if o.Option() == dns.EDNS0TCPKEEPALIVE {
+			// The timeout is in units of 100 milliseconds.
+			timeout := s.tcpIdleTimeout() / (100 * time.Millisecond)
+			if timeout > 0xffff {
+				timeout = 0xffff
+			}
+			return &tcpKeepaliveWriter{ResponseWriter: w, timeout: uint16(timeout)}
+		}
+	}
+	return w
+}
+
+// tcpKeepaliveWriter adds the EDNS TCP keepalive option with the idle timeout to responses.
+type tcpKeepaliveWriter struct {
+	dns.ResponseWriter
+	timeout uint16
+}
+
+// WriteMsg implements dns.ResponseWriter.
+func (w *tcpKeepaliveWriter) WriteMsg(m *dns.Msg) error {
+	if opt := m.IsEdns0(); opt != nil {
+		// Don't filter in place, the options may be shared with the request.
+		options := make([]dns.EDNS0, 0, len(opt.Option)+1)
+		for _, o := range opt.Option {
+			if o.Option() != dns.EDNS0TCPKEEPALIVE {
+				options = append(options, o)
+			}
+		}
+		opt.Option = append(options, &dns.EDNS0_TCP_KEEPALIVE{Code: dns.EDNS0TCPKEEPALIVE, Timeout: w.timeout})
+	}
+	return w.ResponseWriter.WriteMsg(m)
+}
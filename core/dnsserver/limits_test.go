@@ -0,0 +1,178 @@
+package dnsserver
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestLimitListener(t *testing.T) {
+	s := &Server{Addr: "dns://127.0.0.1:53"}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if l := s.limitListener(ln); l != ln {
+		t.Fatalf("Expected the listener to be returned as is without limits")
+	}
+
+	s.maxTCPConns, s.maxTCPConnsPerClient = 3, 2
+	l := s.limitListener(ln).(*limitListener)
+
+	for i, tc := range []struct {
+		ip     string
+		reason string
+	}{
+		{"192.0.2.1", ""},
+		{"192.0.2.1", ""},
+		{"192.0.2.1", "client"},
+		{"192.0.2.2", ""},
+		{"192.0.2.3", "total"},
+	} {
+		if reason := l.acquire(tc.ip); reason != tc.reason {
+			t.Errorf("Test %d: expected reason %q, got %q", i, tc.reason, reason)
+		}
+	}
+
+	l.release("192.0.2.1")
+	if reason := l.acquire("192.0.2.3"); reason != "" {
+		t.Errorf("Expected a connection to be allowed after a release, got %q", reason)
+	}
+	l.release("192.0.2.2")
+	if _, ok := l.clients["192.0.2.2"]; ok {
+		t.Errorf("Expected a client without connections to be removed")
+	}
+}
+
+func TestLimitListenerAccept(t *testing.T) {
+	s := &Server{Addr: "dns://127.0.0.1:53", maxTCPConns: 1}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := s.limitListener(ln)
+	defer l.Close()
+
+	accepted := make(chan net.Conn)
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				close(accepted)
+				return
+			}
+			accepted <- c
+		}
+	}()
+
+	c1, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c1.Close()
+	a1 := <-accepted
+
+	// The second connection is over the limit and is closed by the server.
+	c2, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+	c2.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := c2.Read(make([]byte, 1)); err == nil {
+		t.Errorf("Expected the connection over the limit to be closed")
+	}
+
+	// Closing the first connection, twice, makes room for exactly one new one.
+	a1.Close()
+	a1.Close()
+	c3, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c3.Close()
+	select {
+	case a3 := <-accepted:
+		a3.Close()
+	case <-time.After(time.Second):
+		t.Errorf("Expected a new connection to be accepted after closing the first one")
+	}
+}
+
+func TestKeepaliveWriter(t *testing.T) {
+	s := &Server{tcpKeepalive: true, idleTimeout: 5 * time.Second}
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	if _, ok := s.keepaliveWriter(&test.ResponseWriter{}, m).(*tcpKeepaliveWriter); ok {
+		t.Errorf("Expected no keepalive without the option in the query")
+	}
+
+	m.SetEdns0(4096, false)
+	o := m.IsEdns0()
+	o.Option = append(o.Option, &dns.EDNS0_TCP_KEEPALIVE{Code: dns.EDNS0TCPKEEPALIVE})
+
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	w := s.keepaliveWriter(rec, m)
+	ret := new(dns.Msg)
+	ret.SetReply(m)
+	ret.SetEdns0(4096, false)
+	w.WriteMsg(ret)
+
+	var timeout uint16
+	for _, o := range rec.Msg.IsEdns0().Option {
+		if k, ok := o.(*dns.EDNS0_TCP_KEEPALIVE); ok {
+			timeout = k.Timeout
+		}
+	}
+	if timeout != 50 {
+		t.Errorf("Expected a keepalive timeout of 50 (5s), got %d", timeout)
+	}
+
+	// The options of the response may be shared, e.g. with the request, they must not be changed.
+	keepalive := &dns.EDNS0_TCP_KEEPALIVE{Code: dns.EDNS0TCPKEEPALIVE}
+	shared := []dns.EDNS0{keepalive, &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: "0102030405060708"}}
+	ret.IsEdns0().Option = shared
+	w.WriteMsg(ret)
+	if shared[0] != keepalive || len(shared) != 2 {
+		t.Errorf("Expected the shared options to be left alone, got %v", shared)
+	}
+
+	s.tcpKeepalive = false
+	if _, ok := s.keepaliveWriter(rec, m).(*tcpKeepaliveWriter); ok {
+		t.Errorf("Expected no keepalive when it is disabled")
+	}
+}
+
+func TestNewServerTimeouts(t *testing.T) {
+	c1 := testConfig("dns", testPlugin{})
+	c1.ReadTimeout = 3 * time.Second
+	c1.GraceTimeout = time.Minute
+	c2 := testConfig("dns", testPlugin{})
+	c2.Zone = "example.org."
+	c2.ReadTimeout = 4 * time.Second
+	c2.MaxTCPQueries = 10
+
+	s, err := NewServer("127.0.0.1:53", []*Config{c1, c2})
+	if err != nil {
+		t.Fatalf("Expected no error for NewServer, got %s", err)
+	}
+	if s.readTimeout != 4*time.Second {
+		t.Errorf("Expected the read timeout of the last config, got %s", s.readTimeout)
+	}
+	if s.graceTimeout != time.Minute {
+		t.Errorf("Expected a grace timeout of %s, got %s", time.Minute, s.graceTimeout)
+	}
+
+	srv := &dns.Server{}
+	s.setTCPTimeouts(srv)
+	if srv.ReadTimeout != 4*time.Second || srv.MaxTCPQueries != 10 || srv.IdleTimeout != nil {
+		t.Errorf("Expected the timeouts to be set on the TCP server, got %v %d", srv.ReadTimeout, srv.MaxTCPQueries)
+	}
+}
@@ -0,0 +1,50 @@
+package dnsserver
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/coredns/coredns/plugin/pkg/activation"
+	"github.com/coredns/coredns/plugin/pkg/reuseport"
+)
+
+// listen returns the stream socket for addr that is passed in with socket activation, or else opens
+// it with SO_REUSEPORT.
+func listen(network, addr string) (net.Listener, error) {
+	if l, ok := activation.Listener(network, addr); ok {
+		return l, nil
+	}
+	l, err := reuseport.Listen(network, addr)
+	if err != nil {
+		return nil, listenError(err, addr)
+	}
+	return l, nil
+}
+
+// listenPacket returns the datagram socket for addr that is passed in with socket activation, or
+// else opens it with SO_REUSEPORT.
+func listenPacket(network, addr string) (net.PacketConn, error) {
+	if p, ok := activation.PacketConn(network, addr); ok {
+		return p, nil
+	}
+	p, err := reuseport.ListenPacket(network, addr)
+	if err != nil {
+		return nil, listenError(err, addr)
+	}
+	return p, nil
+}
+
+// Unprivileged is set when the process has switched to an unprivileged user after startup. It
+// can then only use the sockets that are already open: a reload reuses the sockets for the same
+// addresses, but new sockets on privileged ports can't be opened.
+var Unprivileged bool
+
+// listenError explains a permission error for addr, when the server has dropped its privileges.
+func listenError(err error, addr string) error {
+	if Unprivileged && errors.Is(err, os.ErrPermission) {
+		return fmt.Errorf("can't listen on %s after dropping privileges, only the addresses that were open at startup can be reused, restart to listen on it: %s", addr, err)
+	}
+	return err
+}
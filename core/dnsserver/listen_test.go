@@ -0,0 +1,27 @@
+package dnsserver
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestListenError(t *testing.T) {
+	err := &net.OpError{Op: "listen", Net: "udp", Err: os.NewSyscallError("bind", syscall.EACCES)}
+	if e := listenError(err, ":53"); e != err {
+		t.Errorf("Expected the error as is when running privileged, got %s", e)
+	}
+
+	Unprivileged = true
+	defer func() { Unprivileged = false }()
+	if e := listenError(err, ":53"); !strings.Contains(e.Error(), "after dropping privileges") {
+		t.Errorf("Expected an explanation of the permission error, got %s", e)
+	}
+	other := errors.New("address already in use")
+	if e := listenError(other, ":53"); e != other {
+		t.Errorf("Expected other errors as is, got %s", e)
+	}
+}
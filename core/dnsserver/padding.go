@@ -0,0 +1,33 @@
+package dnsserver
+
+import (
+	"github.com/coredns/coredns/plugin/pkg/edns"
+
+	"github.com/miekg/dns"
+)
+
+// paddingWriter returns a writer that pads the response with the EDNS padding option, if the client
+// padded its query r (RFC 8467, section 4). Otherwise w is returned. The writer must be the last one
+// before w, so the padding is computed for the final message. Only encrypted transports use it.
+func (s *Server) paddingWriter(w dns.ResponseWriter, r *dns.Msg) dns.ResponseWriter {
+	if s.padding < 0 || !edns.HasPadding(r) {
+		return w
+	}
+	block := s.padding
+	if block == 0 {
+		block = edns.ResponsePaddingBlock
+	}
+	return &paddingWriter{ResponseWriter: w, block: block}
+}
+
+// paddingWriter pads responses to a multiple of block.
+type paddingWriter struct {
+	dns.ResponseWriter
+	block int
+}
+
+// WriteMsg implements dns.ResponseWriter.
+func (w *paddingWriter) WriteMsg(m *dns.Msg) error {
+	edns.Pad(m, w.block)
+	return w.ResponseWriter.WriteMsg(m)
+}
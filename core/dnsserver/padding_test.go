@@ -0,0 +1,48 @@
+package dnsserver
+
+import (
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/pkg/edns"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestPaddingWriter(t *testing.T) {
+	s := &Server{}
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	m.SetEdns0(4096, false)
+	if _, ok := s.paddingWriter(&test.ResponseWriter{}, m).(*paddingWriter); ok {
+		t.Errorf("Expected no padding without the option in the query")
+	}
+
+	edns.Pad(m, edns.QueryPaddingBlock)
+	for _, block := range []int{0, 128} {
+		s.padding = block
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		w := s.paddingWriter(rec, m)
+		ret := new(dns.Msg)
+		ret.SetReply(m)
+		ret.SetEdns0(4096, false)
+		ret.Answer = []dns.RR{test.A("example.org. 5 IN A 127.0.0.1")}
+		w.WriteMsg(ret)
+
+		expected := block
+		if expected == 0 {
+			expected = edns.ResponsePaddingBlock
+		}
+		buf, _ := rec.Msg.Pack()
+		if len(buf)%expected != 0 {
+			t.Errorf("Expected the response to be padded to a multiple of %d, got %d bytes", expected, len(buf))
+		}
+	}
+
+	s.padding = -1
+	if _, ok := s.paddingWriter(&test.ResponseWriter{}, m).(*paddingWriter); ok {
+		t.Errorf("Expected no padding when it is disabled")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"net"
+	"path/filepath"
 	"time"
 
 	"github.com/coredns/caddy"
@@ -66,6 +67,14 @@ func (h *dnsContext) InspectServerBlocks(sourceFile string, serverBlocks []caddy
 		zoneAddrs := []zoneAddr{}
 		for ik, k := range s.Keys {
 			trans, k1 := parse.Transport(k) // get rid of any dns:// or other scheme.
+			if trans == transport.Unix {
+				// A Unix socket serves the root zone, the rest of the key is the path of the socket.
+				if !filepath.IsAbs(k1) {
+					return nil, fmt.Errorf("path of Unix socket %q is not absolute", k1)
+				}
+				zoneAddrs = append(zoneAddrs, zoneAddr{Zone: ".", Transport: trans, Address: k1})
+				continue
+			}
 			hosts, port, err := plugin.SplitHostPort(k1)
 			// We need to make this a fully qualified domain name to catch all errors here and not later when
 			// plugin.Normalize is called again on these strings, with the prime difference being that the domain
@@ -92,6 +101,8 @@ func (h *dnsContext) InspectServerBlocks(sourceFile string, serverBlocks []caddy
 					port = transport.GRPCPort
 				case transport.HTTPS:
 					port = transport.HTTPSPort
+				case transport.DNSCrypt:
+					port = transport.DNSCryptPort
 				}
 			}
 
@@ -112,11 +123,17 @@ func (h *dnsContext) InspectServerBlocks(sourceFile string, serverBlocks []caddy
 
 		for ik := range s.Keys {
 			za := zoneAddrs[ik]
+			listenHosts := []string{""}
+			if za.Transport == transport.Unix {
+				// The path of the Unix socket is the host to listen on.
+				listenHosts = []string{za.Address}
+				za.Address = ""
+			}
 			s.Keys[ik] = za.String()
 			// Save the config to our master list, and key it for lookups.
 			cfg := &Config{
 				Zone:        za.Zone,
-				ListenHosts: []string{""},
+				ListenHosts: listenHosts,
 				Port:        za.Port,
 				Transport:   za.Transport,
 			}
@@ -139,13 +156,6 @@ func (h *dnsContext) InspectServerBlocks(sourceFile string, serverBlocks []caddy
 // MakeServers uses the newly-created siteConfigs to create and return a list of server instances.
 func (h *dnsContext) MakeServers() ([]caddy.Server, error) {
 
-	// Now that all Keys and Directives are parsed and initialized
-	// lets verify that there is no overlap on the zones and addresses to listen for
-	errValid := h.validateZonesAndListeningAddresses()
-	if errValid != nil {
-		return nil, errValid
-	}
-
 	// Copy the Plugin, ListenHosts and Debug from first config in the block
 	// to all other config in the same block . Doing this results in zones
 	// sharing the same plugin instances and settings as other zones in
@@ -155,6 +165,32 @@ func (h *dnsContext) MakeServers() ([]caddy.Server, error) {
 		c.ListenHosts = c.firstConfigInBlock.ListenHosts
 		c.Debug = c.firstConfigInBlock.Debug
 		c.TLSConfig = c.firstConfigInBlock.TLSConfig
+		c.DNSCrypt = c.firstConfigInBlock.DNSCrypt
+		c.ODoH = c.firstConfigInBlock.ODoH
+		c.DoH = c.firstConfigInBlock.DoH
+		c.ReadTimeout = c.firstConfigInBlock.ReadTimeout
+		c.WriteTimeout = c.firstConfigInBlock.WriteTimeout
+		c.IdleTimeout = c.firstConfigInBlock.IdleTimeout
+		c.GraceTimeout = c.firstConfigInBlock.GraceTimeout
+		c.TCPKeepalive = c.firstConfigInBlock.TCPKeepalive
+		c.QueryTimeout = c.firstConfigInBlock.QueryTimeout
+		c.Budgets = c.firstConfigInBlock.Budgets
+		c.MaxTCPConnections = c.firstConfigInBlock.MaxTCPConnections
+		c.MaxTCPConnectionsPerClient = c.firstConfigInBlock.MaxTCPConnectionsPerClient
+		c.MaxTCPQueries = c.firstConfigInBlock.MaxTCPQueries
+		c.ProxyProtocol = c.firstConfigInBlock.ProxyProtocol
+		c.UDPSockets = c.firstConfigInBlock.UDPSockets
+		c.UDPBatchSize = c.firstConfigInBlock.UDPBatchSize
+		c.Padding = c.firstConfigInBlock.Padding
+		c.FilterFuncs = c.firstConfigInBlock.FilterFuncs
+		c.ViewName = c.firstConfigInBlock.ViewName
+	}
+
+	// Now that all Keys and Directives are parsed and initialized
+	// lets verify that there is no overlap on the zones and addresses to listen for
+	errValid := h.validateZonesAndListeningAddresses()
+	if errValid != nil {
+		return nil, errValid
 	}
 
 	// we must map (group) each config to a bind address
@@ -194,6 +230,20 @@ func (h *dnsContext) MakeServers() ([]caddy.Server, error) {
 				return nil, err
 			}
 			servers = append(servers, s)
+
+		case transport.DNSCrypt:
+			s, err := NewServerDNSCrypt(addr, group)
+			if err != nil {
+				return nil, err
+			}
+			servers = append(servers, s)
+
+		case transport.Unix:
+			s, err := NewServerUnix(addr, group)
+			if err != nil {
+				return nil, err
+			}
+			servers = append(servers, s)
 		}
 
 	}
@@ -253,7 +303,14 @@ func (h *dnsContext) validateZonesAndListeningAddresses() error {
 		for _, h := range conf.ListenHosts {
 			// Validate the overlapping of ZoneAddr
 			akey := zoneAddr{Transport: conf.Transport, Zone: conf.Zone, Address: h, Port: conf.Port}
-			existZone, overlapZone := checker.registerAndCheck(akey)
+			var existZone, overlapZone *zoneAddr
+			if len(conf.FilterFuncs) > 0 {
+				// A filtered config (a view) may share its zone with other configs, as long as
+				// it comes before the unfiltered one, which would otherwise catch all queries.
+				existZone, overlapZone = checker.check(akey)
+			} else {
+				existZone, overlapZone = checker.registerAndCheck(akey)
+			}
 			if existZone != nil {
 				return fmt.Errorf("cannot serve %s - it is already defined", akey.String())
 			}
@@ -276,6 +333,11 @@ func groupConfigsByListenAddr(configs []*Config) (map[string][]*Config, error) {
 	groups := make(map[string][]*Config)
 	for _, conf := range configs {
 		for _, h := range conf.ListenHosts {
+			if conf.Transport == transport.Unix {
+				addrstr := conf.Transport + "://" + h
+				groups[addrstr] = append(groups[addrstr], conf)
+				continue
+			}
 			addr, err := net.ResolveTCPAddr("tcp", net.JoinHostPort(h, conf.Port))
 			if err != nil {
 				return nil, err
@@ -1,7 +1,10 @@
 package dnsserver
 
 import (
+	"context"
 	"testing"
+
+	"github.com/coredns/coredns/request"
 )
 
 func TestHandler(t *testing.T) {
@@ -96,6 +99,14 @@ func TestGroupingServers(t *testing.T) {
 			{Transport: "dns", Zone: "com.", Port: "53", ListenHosts: []string{""}}},
 			expectedGroups: []string{"dns://127.0.0.1:53", "dns://[::1]:53", "dns://:53"},
 			failing:        false},
+
+		// 2 configs on Unix sockets, and one on a port -> 3 groups
+		{configs: []*Config{
+			{Transport: "unix", Zone: ".", ListenHosts: []string{"/run/coredns.sock"}},
+			{Transport: "unix", Zone: ".", ListenHosts: []string{"/run/other.sock"}},
+			{Transport: "dns", Zone: ".", Port: "53", ListenHosts: []string{""}}},
+			expectedGroups: []string{"unix:///run/coredns.sock", "unix:///run/other.sock", "dns://:53"},
+			failing:        false},
 	} {
 		groups, err := groupConfigsByListenAddr(test.configs)
 		if err != nil {
@@ -119,3 +130,29 @@ func TestGroupingServers(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateViews(t *testing.T) {
+	filtered := func() *Config {
+		c := testConfig("dns", testPlugin{})
+		c.FilterFuncs = []FilterFunc{func(context.Context, *request.Request) bool { return true }}
+		return c
+	}
+	for i, test := range []struct {
+		configs   []*Config
+		expectErr bool
+	}{
+		{configs: []*Config{filtered(), filtered(), testConfig("dns", testPlugin{})}},
+		{configs: []*Config{filtered(), filtered()}},
+		{configs: []*Config{testConfig("dns", testPlugin{}), filtered()}, expectErr: true},
+		{configs: []*Config{testConfig("dns", testPlugin{}), testConfig("dns", testPlugin{})}, expectErr: true},
+	} {
+		h := &dnsContext{configs: test.configs}
+		err := h.validateZonesAndListeningAddresses()
+		if test.expectErr && err == nil {
+			t.Errorf("Test %d: expected an error, got none", i)
+		}
+		if !test.expectErr && err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+		}
+	}
+}
@@ -15,8 +15,8 @@ import (
 	"github.com/coredns/coredns/plugin/metrics/vars"
 	"github.com/coredns/coredns/plugin/pkg/edns"
 	"github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/pkg/proxyproto"
 	"github.com/coredns/coredns/plugin/pkg/rcode"
-	"github.com/coredns/coredns/plugin/pkg/reuseport"
 	"github.com/coredns/coredns/plugin/pkg/trace"
 	"github.com/coredns/coredns/plugin/pkg/transport"
 	"github.com/coredns/coredns/request"
@@ -33,15 +33,33 @@ import (
 type Server struct {
 	Addr string // Address we listen on
 
-	server [2]*dns.Server // 0 is a net.Listener, 1 is a net.PacketConn (a *UDPConn) in our case.
-	m      sync.Mutex     // protects the servers
+	server     [2]*dns.Server // 0 is a net.Listener, 1 is a net.PacketConn (a *UDPConn) in our case.
+	udpServers []*dns.Server  // the servers of the additional UDP sockets
+	m          sync.Mutex     // protects the servers
 
-	zones        map[string]*Config // zones keyed by their address
-	dnsWg        sync.WaitGroup     // used to wait on outstanding connections
-	graceTimeout time.Duration      // the maximum duration of a graceful shutdown
-	trace        trace.Trace        // the trace plugin for the server
-	debug        bool               // disable recover()
-	classChaos   bool               // allow non-INET class queries
+	zones        map[string][]*Config // zones keyed by their address
+	dnsWg        sync.WaitGroup       // used to wait on outstanding connections
+	graceTimeout time.Duration        // the maximum duration of a graceful shutdown
+	trace        trace.Trace          // the trace plugin for the server
+	debug        bool                 // disable recover()
+	classChaos   bool                 // allow non-INET class queries
+
+	readTimeout          time.Duration // timeouts of the connections, zero uses the transport's default
+	writeTimeout         time.Duration
+	idleTimeout          time.Duration
+	tcpKeepalive         bool // signal the idle timeout with EDNS TCP keepalive
+	maxTCPConns          int  // maximum concurrent TCP connections, zero is no limit
+	maxTCPConnsPerClient int  // same, per client IP address
+	maxTCPQueries        int  // maximum queries per TCP connection, zero uses the transport's default
+
+	queryTimeout time.Duration // deadline of a query, zero is none
+
+	proxyProtocol *proxyproto.Config // trusted sources of PROXY protocol headers, if enabled
+
+	udpSockets   int // number of UDP sockets, zero is one
+	udpBatchSize int // number of datagrams per system call, zero is no batching
+
+	padding int // block size of padded responses on encrypted transports, negative is no padding
 }
 
 // NewServer returns a new CoreDNS server and compiles all plugins in to it. By default CH class
@@ -50,7 +68,7 @@ func NewServer(addr string, group []*Config) (*Server, error) {
 
 	s := &Server{
 		Addr:         addr,
-		zones:        make(map[string]*Config),
+		zones:        make(map[string][]*Config),
 		graceTimeout: 5 * time.Second,
 	}
 
@@ -67,12 +85,28 @@ func NewServer(addr string, group []*Config) (*Server, error) {
 			s.debug = true
 			log.D.Set()
 		}
-		// set the config per zone
-		s.zones[site.Zone] = site
+		// The timeouts and limits are per listener, the last config that sets them wins.
+		s.setTimeouts(site)
+		if site.ProxyProtocol != nil {
+			s.proxyProtocol = site.ProxyProtocol
+		}
+		if site.UDPSockets != 0 {
+			s.udpSockets = site.UDPSockets
+		}
+		if site.UDPBatchSize != 0 {
+			s.udpBatchSize = site.UDPBatchSize
+		}
+		if site.Padding != 0 {
+			s.padding = site.Padding
+		}
+
+		// append the config to the zone's configs
+		s.zones[site.Zone] = append(s.zones[site.Zone], site)
 
 		// compile custom plugin for everything
 		var stack plugin.Handler
 		for i := len(site.Plugin) - 1; i >= 0; i-- {
+			next := stack
 			stack = site.Plugin[i](stack)
 
 			// register the *handler* also
@@ -89,6 +123,21 @@ func NewServer(addr string, group []*Config) (*Server, error) {
 			if _, ok := EnableChaos[stack.Name()]; ok {
 				s.classChaos = true
 			}
+
+			// A plugin that collects metadata is needed before the filter funcs run.
+			if mc, ok := stack.(MetadataCollector); ok {
+				site.metaCollector = mc
+			}
+
+			// With budgets the chain has the plugins wrapped, for their budget and metrics; the
+			// registry keeps the plugin itself.
+			if len(site.Budgets) > 0 {
+				var budget *Budget
+				if b, ok := site.Budgets[stack.Name()]; ok {
+					budget = &b
+				}
+				stack = newChainHandler(stack, next, addr, budget)
+			}
 		}
 		site.pluginChain = stack
 	}
@@ -108,11 +157,13 @@ var _ caddy.GracefulServer = &Server{}
 // This implements caddy.TCPServer interface.
 func (s *Server) Serve(l net.Listener) error {
 	s.m.Lock()
+	l = s.proxyListener(s.limitListener(l))
 	s.server[tcp] = &dns.Server{Listener: l, Net: "tcp", Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
 		ctx := context.WithValue(context.Background(), Key{}, s)
 		ctx = context.WithValue(ctx, LoopKey{}, 0)
-		s.ServeDNS(ctx, w, r)
+		s.ServeDNS(ctx, s.keepaliveWriter(w, r), r)
 	})}
+	s.setTCPTimeouts(s.server[tcp])
 	s.m.Unlock()
 
 	return s.server[tcp].ActivateAndServe()
@@ -121,34 +172,65 @@ func (s *Server) Serve(l net.Listener) error {
 // ServePacket starts the server with an existing packetconn. It blocks until the server stops.
 // This implements caddy.UDPServer interface.
 func (s *Server) ServePacket(p net.PacketConn) error {
+	extra := s.listenUDPSockets(p)
+
 	s.m.Lock()
-	s.server[udp] = &dns.Server{PacketConn: p, Net: "udp", Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+	s.server[udp] = s.newUDPServer(p)
+	s.udpServers = nil
+	for _, c := range extra {
+		s.udpServers = append(s.udpServers, s.newUDPServer(c))
+	}
+	servers := s.udpServers
+	s.m.Unlock()
+
+	startUDPServers(servers)
+	return s.server[udp].ActivateAndServe()
+}
+
+// newUDPServer returns the DNS server for the UDP socket p.
+func (s *Server) newUDPServer(p net.PacketConn) *dns.Server {
+	p = s.proxyPacketConn(s.batchPacketConn(p))
+	return &dns.Server{PacketConn: p, Net: "udp", Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
 		ctx := context.WithValue(context.Background(), Key{}, s)
 		ctx = context.WithValue(ctx, LoopKey{}, 0)
 		s.ServeDNS(ctx, w, r)
 	})}
-	s.m.Unlock()
-
-	return s.server[udp].ActivateAndServe()
 }
 
 // Listen implements caddy.TCPServer interface.
 func (s *Server) Listen() (net.Listener, error) {
-	l, err := reuseport.Listen("tcp", s.Addr[len(transport.DNS+"://"):])
+	l, err := listen("tcp", s.Addr[len(transport.DNS+"://"):])
 	if err != nil {
 		return nil, err
 	}
 	return l, nil
 }
 
-// WrapListener Listen implements caddy.GracefulServer interface.
+// WrapListener Listen implements caddy.GracefulServer interface. The listeners are wrapped in Serve
+// and ServePacket instead, as listeners are passed on as is to the new server on a reload.
 func (s *Server) WrapListener(ln net.Listener) net.Listener {
 	return ln
 }
 
+// proxyListener wraps l to accept PROXY protocol headers, if enabled.
+func (s *Server) proxyListener(l net.Listener) net.Listener {
+	if s.proxyProtocol == nil {
+		return l
+	}
+	return proxyproto.NewListener(l, s.proxyProtocol)
+}
+
+// proxyPacketConn wraps p to accept PROXY protocol headers, if enabled.
+func (s *Server) proxyPacketConn(p net.PacketConn) net.PacketConn {
+	if s.proxyProtocol == nil {
+		return p
+	}
+	return proxyproto.NewPacketConn(p, s.proxyProtocol)
+}
+
 // ListenPacket implements caddy.UDPServer interface.
 func (s *Server) ListenPacket() (net.PacketConn, error) {
-	p, err := reuseport.ListenPacket("udp", s.Addr[len(transport.DNS+"://"):])
+	p, err := listenPacket("udp", s.Addr[len(transport.DNS+"://"):])
 	if err != nil {
 		return nil, err
 	}
@@ -189,6 +271,11 @@ func (s *Server) Stop() (err error) {
 			err = s1.Shutdown()
 		}
 	}
+	for _, s1 := range s.udpServers {
+		if err1 := s1.Shutdown(); err1 != nil {
+			err = err1
+		}
+	}
 	s.m.Unlock()
 	return
 }
@@ -230,6 +317,12 @@ func (s *Server) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 		return
 	}
 
+	if s.queryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.queryTimeout)
+		defer cancel()
+	}
+
 	// Wrap the response writer in a ScrubWriter so we automatically make the reply fit in the client's buffer.
 	w = request.NewScrubWriter(r, w)
 
@@ -238,27 +331,45 @@ func (s *Server) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 		off       int
 		end       bool
 		dshandler *Config
+		collected bool // metadata is collected once per query, by the first config that can.
 	)
 
 	for {
-		if h, ok := s.zones[q[off:]]; ok {
-			if h.pluginChain == nil { // zone defined, but has not got any plugins
-				errorAndMetricsFunc(s.Addr, w, r, dns.RcodeRefused)
-				return
-			}
-			if r.Question[0].Qtype != dns.TypeDS {
-				rcode, _ := h.pluginChain.ServeDNS(ctx, w, r)
-				if !plugin.ClientWrite(rcode) {
-					errorFunc(s.Addr, w, r, rcode)
+		if z, ok := s.zones[q[off:]]; ok {
+			for _, h := range z {
+				if h.pluginChain == nil { // zone defined, but has not got any plugins
+					errorAndMetricsFunc(s.Addr, w, r, dns.RcodeRefused)
+					return
+				}
+
+				if h.metaCollector != nil && !collected {
+					// Collect metadata now, so it can be used before we send a request down the plugin chain.
+					ctx = h.metaCollector.Collect(ctx, request.Request{Req: r, W: w})
+					collected = true
+				}
+
+				// If all filter funcs pass, use this config.
+				if passAllFilterFuncs(ctx, h.FilterFuncs, &request.Request{Req: r, W: w}) {
+					if h.ViewName != "" {
+						// if there was a view defined for this Config, set the view name in the context
+						ctx = context.WithValue(ctx, ViewKey{}, h.ViewName)
+					}
+					if r.Question[0].Qtype != dns.TypeDS {
+						rcode, _ := h.pluginChain.ServeDNS(ctx, w, r)
+						if !plugin.ClientWrite(rcode) {
+							errorFunc(s.Addr, w, r, rcode)
+						}
+						return
+					}
+					// The type is DS, keep the handler, but keep on searching as maybe we are serving
+					// the parent as well and the DS should be routed to it - this will probably *misroute* DS
+					// queries to a possibly grand parent, but there is no way for us to know at this point
+					// if there is an actual delegation from grandparent -> parent -> zone.
+					// In all fairness: direct DS queries should not be needed.
+					dshandler = h
+					break
 				}
-				return
 			}
-			// The type is DS, keep the handler, but keep on searching as maybe we are serving
-			// the parent as well and the DS should be routed to it - this will probably *misroute* DS
-			// queries to a possibly grand parent, but there is no way for us to know at this point
-			// if there is an actual delegation from grandparent -> parent -> zone.
-			// In all fairness: direct DS queries should not be needed.
-			dshandler = h
 		}
 		off, end = dns.NextLabel(q, off)
 		if end {
@@ -276,18 +387,48 @@ func (s *Server) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 	}
 
 	// Wildcard match, if we have found nothing try the root zone as a last resort.
-	if h, ok := s.zones["."]; ok && h.pluginChain != nil {
-		rcode, _ := h.pluginChain.ServeDNS(ctx, w, r)
-		if !plugin.ClientWrite(rcode) {
-			errorFunc(s.Addr, w, r, rcode)
+	if z, ok := s.zones["."]; ok {
+		for _, h := range z {
+			if h.pluginChain == nil { // zone defined, but has not got any plugins
+				errorAndMetricsFunc(s.Addr, w, r, dns.RcodeRefused)
+				return
+			}
+
+			if h.metaCollector != nil && !collected {
+				// Collect metadata now, so it can be used before we send a request down the plugin chain.
+				ctx = h.metaCollector.Collect(ctx, request.Request{Req: r, W: w})
+				collected = true
+			}
+
+			// If all filter funcs pass, use this config.
+			if passAllFilterFuncs(ctx, h.FilterFuncs, &request.Request{Req: r, W: w}) {
+				if h.ViewName != "" {
+					// if there was a view defined for this Config, set the view name in the context
+					ctx = context.WithValue(ctx, ViewKey{}, h.ViewName)
+				}
+				rcode, _ := h.pluginChain.ServeDNS(ctx, w, r)
+				if !plugin.ClientWrite(rcode) {
+					errorFunc(s.Addr, w, r, rcode)
+				}
+				return
+			}
 		}
-		return
 	}
 
 	// Still here? Error out with REFUSED.
 	errorAndMetricsFunc(s.Addr, w, r, dns.RcodeRefused)
 }
 
+// passAllFilterFuncs returns true if all filter funcs evaluate to true.
+func passAllFilterFuncs(ctx context.Context, filterFuncs []FilterFunc, req *request.Request) bool {
+	for _, ff := range filterFuncs {
+		if !ff(ctx, req) {
+			return false
+		}
+	}
+	return true
+}
+
 // OnStartupComplete lists the sites served by this server
 // and any relevant information, assuming Quiet is false.
 func (s *Server) OnStartupComplete() {
@@ -344,6 +485,9 @@ type (
 
 	// LoopKey is the context key to detect server wide loops.
 	LoopKey struct{}
+
+	// ViewKey is the context key for the current view, if defined
+	ViewKey struct{}
 )
 
 // EnableChaos is a map with plugin names for which we should open CH class queries as we block these by default.
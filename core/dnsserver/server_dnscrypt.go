@@ -0,0 +1,276 @@
+package dnsserver
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/plugin/pkg/dnscrypt"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+
+	"github.com/miekg/dns"
+)
+
+// ServerDNSCrypt represents an instance of a DNSCrypt server.
+type ServerDNSCrypt struct {
+	*Server
+	resolver *dnscrypt.Resolver
+
+	listener   net.Listener
+	packetConn net.PacketConn
+}
+
+// NewServerDNSCrypt returns a new CoreDNS DNSCrypt server and compiles all plugins in to it.
+func NewServerDNSCrypt(addr string, group []*Config) (*ServerDNSCrypt, error) {
+	s, err := NewServer(addr, group)
+	if err != nil {
+		return nil, err
+	}
+	// The *dnscrypt* plugin makes sure it is only specified once.
+	var resolver *dnscrypt.Resolver
+	for _, z := range s.zones {
+		for _, conf := range z {
+			resolver = conf.DNSCrypt
+		}
+	}
+	if resolver == nil {
+		return nil, fmt.Errorf("DNSCrypt requires a provider to be configured, see the dnscrypt plugin")
+	}
+
+	return &ServerDNSCrypt{Server: s, resolver: resolver}, nil
+}
+
+// Compile-time check to ensure Server implements the caddy.GracefulServer interface
+var _ caddy.GracefulServer = &ServerDNSCrypt{}
+
+// Serve implements caddy.TCPServer interface.
+func (s *ServerDNSCrypt) Serve(l net.Listener) error {
+	s.m.Lock()
+	s.listener = l
+	s.m.Unlock()
+
+	l = s.proxyListener(s.limitListener(l))
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// serveConn handles the queries on a TCP connection, each query and response is preceded by its length.
+func (s *ServerDNSCrypt) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	idle := dnscryptTCPIdleTimeout
+	if s.idleTimeout != 0 {
+		idle = s.idleTimeout
+	}
+	write := dnscryptTCPIdleTimeout
+	if s.writeTimeout != 0 {
+		write = s.writeTimeout
+	}
+	for queries := 0; s.maxTCPQueries <= 0 || queries < s.maxTCPQueries; queries++ {
+		conn.SetReadDeadline(time.Now().Add(idle))
+		var l [2]byte
+		if _, err := io.ReadFull(conn, l[:]); err != nil {
+			return
+		}
+		if s.readTimeout != 0 {
+			conn.SetReadDeadline(time.Now().Add(s.readTimeout))
+		}
+		packet := make([]byte, binary.BigEndian.Uint16(l[:]))
+		if _, err := io.ReadFull(conn, packet); err != nil {
+			return
+		}
+
+		resp := s.handle(packet, conn.LocalAddr(), conn.RemoteAddr(), false)
+		if resp == nil {
+			return
+		}
+		out := make([]byte, 2+len(resp))
+		binary.BigEndian.PutUint16(out, uint16(len(resp)))
+		copy(out[2:], resp)
+		conn.SetWriteDeadline(time.Now().Add(write))
+		if _, err := conn.Write(out); err != nil {
+			return
+		}
+	}
+}
+
+// ServePacket implements caddy.UDPServer interface.
+func (s *ServerDNSCrypt) ServePacket(p net.PacketConn) error {
+	s.m.Lock()
+	s.packetConn = p
+	s.m.Unlock()
+
+	p = s.proxyPacketConn(p)
+
+	buf := make([]byte, dns.MaxMsgSize)
+	for {
+		n, addr, err := p.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		packet := make([]byte, n)
+		copy(packet, buf)
+		go func() {
+			if resp := s.handle(packet, p.LocalAddr(), addr, true); resp != nil {
+				p.WriteTo(resp, addr)
+			}
+		}()
+	}
+}
+
+// handle decrypts packet, calls the plugin chain with the query in it, and returns the encrypted
+// response. Plain DNS queries for the certificates are answered in plain DNS. If nothing should be
+// sent back, nil is returned.
+func (s *ServerDNSCrypt) handle(packet []byte, laddr, raddr net.Addr, overUDP bool) []byte {
+	q, err := s.resolver.Decrypt(packet)
+	if err == dnscrypt.ErrNotEncrypted {
+		return s.certificates(packet)
+	}
+	if err != nil {
+		return nil
+	}
+	msg := new(dns.Msg)
+	if err := msg.Unpack(q.Msg); err != nil {
+		return nil
+	}
+
+	w := &dnscryptResponse{localAddr: laddr, remoteAddr: raddr}
+	ctx := context.WithValue(context.Background(), Key{}, s.Server)
+	ctx = context.WithValue(ctx, LoopKey{}, 0)
+	s.ServeDNS(ctx, w, msg)
+	if w.Msg == nil {
+		return nil
+	}
+
+	// Over UDP the response can't be larger than the query, to prevent amplification.
+	size := dns.MaxMsgSize
+	if overUDP {
+		size = dnscrypt.MaxResponseSize(len(packet))
+		if w.Msg.Len() > size {
+			w.Msg.Truncate(size)
+		}
+	}
+	buf, err := w.Msg.Pack()
+	if err != nil || len(buf) > size {
+		return nil
+	}
+	resp, err := q.Encrypt(buf)
+	if err != nil {
+		return nil
+	}
+	return resp
+}
+
+// certificates returns the response with the certificates if packet is a query for them.
+func (s *ServerDNSCrypt) certificates(packet []byte) []byte {
+	r := new(dns.Msg)
+	if err := r.Unpack(packet); err != nil || len(r.Question) != 1 {
+		return nil
+	}
+	q := r.Question[0]
+	if q.Qtype != dns.TypeTXT || q.Qclass != dns.ClassINET || !strings.EqualFold(q.Name, s.resolver.ProviderName()) {
+		return nil
+	}
+
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+	m.Answer = s.resolver.Certificates(dnscryptCertTTL)
+	buf, err := m.Pack()
+	if err != nil {
+		return nil
+	}
+	return buf
+}
+
+// Listen implements caddy.TCPServer interface.
+func (s *ServerDNSCrypt) Listen() (net.Listener, error) {
+	l, err := listen("tcp", s.Addr[len(transport.DNSCrypt+"://"):])
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// ListenPacket implements caddy.UDPServer interface.
+func (s *ServerDNSCrypt) ListenPacket() (net.PacketConn, error) {
+	p, err := listenPacket("udp", s.Addr[len(transport.DNSCrypt+"://"):])
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// OnStartupComplete lists the sites served by this server
+// and any relevant information, assuming Quiet is false.
+func (s *ServerDNSCrypt) OnStartupComplete() {
+	if Quiet {
+		return
+	}
+
+	out := startUpZones(transport.DNSCrypt+"://", s.Addr, s.zones)
+	if out != "" {
+		fmt.Print(out)
+	}
+}
+
+// Stop stops the server. Connections that are being served are closed when they're idle.
+func (s *ServerDNSCrypt) Stop() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	if s.packetConn != nil {
+		s.packetConn.Close()
+	}
+	return nil
+}
+
+// dnscryptResponse is the dns.ResponseWriter for a DNSCrypt query, the response is picked up and encrypted
+// in handle.
+type dnscryptResponse struct {
+	localAddr  net.Addr
+	remoteAddr net.Addr
+	Msg        *dns.Msg
+}
+
+// Write unpacks b, so it can be picked up in handle.
+func (r *dnscryptResponse) Write(b []byte) (int, error) {
+	r.Msg = new(dns.Msg)
+	return len(b), r.Msg.Unpack(b)
+}
+
+// These methods implement the dns.ResponseWriter interface from Go DNS.
+func (r *dnscryptResponse) Close() error              { return nil }
+func (r *dnscryptResponse) TsigStatus() error         { return nil }
+func (r *dnscryptResponse) TsigTimersOnly(b bool)     {}
+func (r *dnscryptResponse) Hijack()                   {}
+func (r *dnscryptResponse) LocalAddr() net.Addr       { return r.localAddr }
+func (r *dnscryptResponse) RemoteAddr() net.Addr      { return r.remoteAddr }
+func (r *dnscryptResponse) WriteMsg(m *dns.Msg) error { r.Msg = m; return nil }
+
+const (
+	// dnscryptTCPIdleTimeout is how long a TCP connection may be idle.
+	dnscryptTCPIdleTimeout = 10 * time.Second
+	// dnscryptCertTTL is the TTL of the certificate records.
+	dnscryptCertTTL = 600
+)
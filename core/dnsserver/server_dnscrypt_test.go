@@ -0,0 +1,58 @@
+package dnsserver
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnscrypt"
+
+	"github.com/miekg/dns"
+)
+
+func TestNewServerDNSCrypt(t *testing.T) {
+	if _, err := NewServerDNSCrypt("dnscrypt://127.0.0.1:443", []*Config{testConfig("dnscrypt", testPlugin{})}); err == nil {
+		t.Errorf("Expected an error for NewServerDNSCrypt without a provider")
+	}
+}
+
+func TestDNSCryptCertificates(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	r, err := dnscrypt.New("2.dnscrypt-cert.example.org.", key, []uint16{dnscrypt.XSalsa20Poly1305, dnscrypt.XChacha20Poly1305}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := testConfig("dnscrypt", testPlugin{})
+	c.DNSCrypt = r
+	s, err := NewServerDNSCrypt("dnscrypt://127.0.0.1:443", []*Config{c})
+	if err != nil {
+		t.Fatalf("Expected no error for NewServerDNSCrypt, got %s", err)
+	}
+
+	laddr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 443}
+	raddr := &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 40212}
+
+	m := new(dns.Msg)
+	m.SetQuestion("2.dnscrypt-cert.example.org.", dns.TypeTXT)
+	buf, _ := m.Pack()
+	resp := s.handle(buf, laddr, raddr, true)
+	if resp == nil {
+		t.Fatalf("Expected a response with the certificates")
+	}
+	reply := new(dns.Msg)
+	if err := reply.Unpack(resp); err != nil {
+		t.Fatalf("Expected a plain DNS response, got %s", err)
+	}
+	if len(reply.Answer) != 2 {
+		t.Errorf("Expected 2 certificates, got %d", len(reply.Answer))
+	}
+
+	// Other plain queries are dropped.
+	m.SetQuestion("www.example.com.", dns.TypeA)
+	buf, _ = m.Pack()
+	if resp := s.handle(buf, laddr, raddr, true); resp != nil {
+		t.Errorf("Expected no response for a plain DNS query")
+	}
+}
@@ -5,18 +5,27 @@ import (
 	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
 	"net"
+	"sync"
+	"time"
 
 	"github.com/coredns/caddy"
 	"github.com/coredns/coredns/pb"
-	"github.com/coredns/coredns/plugin/pkg/reuseport"
 	"github.com/coredns/coredns/plugin/pkg/transport"
 
 	"github.com/grpc-ecosystem/grpc-opentracing/go/otgrpc"
 	"github.com/miekg/dns"
 	"github.com/opentracing/opentracing-go"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 )
 
 // ServergRPC represents an instance of a DNS-over-gRPC server.
@@ -25,6 +34,12 @@ type ServergRPC struct {
 	grpcServer *grpc.Server
 	listenAddr net.Addr
 	tlsConfig  *tls.Config
+
+	health *health.Server
+	done   context.Context // canceled when the server stops
+	stop   context.CancelFunc
+
+	queries chan struct{} // one token per stream query being served
 }
 
 // NewServergRPC returns a new CoreDNS GRPC server and compiles all plugin in to it.
@@ -36,39 +51,88 @@ func NewServergRPC(addr string, group []*Config) (*ServergRPC, error) {
 	// The *tls* plugin must make sure that multiple conflicting
 	// TLS configuration returns an error: it can only be specified once.
 	var tlsConfig *tls.Config
-	for _, conf := range s.zones {
-		// Should we error if some configs *don't* have TLS?
-		tlsConfig = conf.TLSConfig
+	for _, z := range s.zones {
+		for _, conf := range z {
+			// Should we error if some configs *don't* have TLS?
+			tlsConfig = conf.TLSConfig
+		}
 	}
 
-	return &ServergRPC{Server: s, tlsConfig: tlsConfig}, nil
+	return &ServergRPC{Server: s, tlsConfig: tlsConfig, queries: make(chan struct{}, maxGRPCQueries)}, nil
 }
 
 // Compile-time check to ensure Server implements the caddy.GracefulServer interface
 var _ caddy.GracefulServer = &Server{}
 
+const (
+	// grpcService is the name of the DNS service in the health service.
+	grpcService = "coredns.dns.DnsService"
+	// healthInterval is how often the health service checks the readiness.
+	healthInterval = time.Second
+	// maxStreamQueries is the number of queries of one stream that are served at once, the next
+	// query isn't read from the stream before one of them is answered.
+	maxStreamQueries = 256
+	// maxGRPCQueries is the number of stream queries the server serves at once, over all streams.
+	maxGRPCQueries = 4096
+)
+
+var readiness struct {
+	sync.RWMutex
+	ready func() bool
+}
+
+// SetReadiness sets the function the gRPC health service calls to check if CoreDNS is ready
+// to serve; the ready plugin sets it. Without it a gRPC server is serving once it is started.
+func SetReadiness(ready func() bool) {
+	readiness.Lock()
+	defer readiness.Unlock()
+	readiness.ready = ready
+}
+
+func isReady() bool {
+	readiness.RLock()
+	defer readiness.RUnlock()
+	return readiness.ready == nil || readiness.ready()
+}
+
 // Serve implements caddy.TCPServer interface.
 func (s *ServergRPC) Serve(l net.Listener) error {
 	s.m.Lock()
 	s.listenAddr = l.Addr()
 	s.m.Unlock()
 
+	var opts []grpc.ServerOption
 	if s.Tracer() != nil {
 		onlyIfParent := func(parentSpanCtx opentracing.SpanContext, method string, req, resp interface{}) bool {
 			return parentSpanCtx != nil
 		}
 		intercept := otgrpc.OpenTracingServerInterceptor(s.Tracer(), otgrpc.IncludingSpans(onlyIfParent))
-		s.grpcServer = grpc.NewServer(grpc.UnaryInterceptor(intercept))
-	} else {
-		s.grpcServer = grpc.NewServer()
+		streamIntercept := otgrpc.OpenTracingStreamServerInterceptor(s.Tracer(), otgrpc.IncludingSpans(onlyIfParent))
+		opts = append(opts, grpc.UnaryInterceptor(intercept), grpc.StreamInterceptor(streamIntercept))
+	}
+	// Let gRPC handle TLS, so the TLS state of the client is available in Query.
+	if s.tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(s.tlsConfig)))
+	}
+	if s.idleTimeout != 0 {
+		opts = append(opts, grpc.KeepaliveParams(keepalive.ServerParameters{MaxConnectionIdle: s.idleTimeout}))
 	}
+	if s.readTimeout != 0 {
+		opts = append(opts, grpc.ConnectionTimeout(s.readTimeout))
+	}
+	s.m.Lock()
+	s.grpcServer = grpc.NewServer(opts...)
+	s.health = health.NewServer()
+	s.done, s.stop = context.WithCancel(context.Background())
+	s.m.Unlock()
 
 	pb.RegisterDnsServiceServer(s.grpcServer, s)
+	healthpb.RegisterHealthServer(s.grpcServer, s.health)
+	reflection.Register(s.grpcServer)
 
-	if s.tlsConfig != nil {
-		l = tls.NewListener(l, s.tlsConfig)
-	}
-	return s.grpcServer.Serve(l)
+	go s.checkHealth(s.done)
+
+	return s.grpcServer.Serve(s.proxyListener(s.limitListener(l)))
 }
 
 // ServePacket implements caddy.UDPServer interface.
@@ -77,7 +141,7 @@ func (s *ServergRPC) ServePacket(p net.PacketConn) error { return nil }
 // Listen implements caddy.TCPServer interface.
 func (s *ServergRPC) Listen() (net.Listener, error) {
 
-	l, err := reuseport.Listen("tcp", s.Addr[len(transport.GRPC+"://"):])
+	l, err := listen("tcp", s.Addr[len(transport.GRPC+"://"):])
 	if err != nil {
 		return nil, err
 	}
@@ -105,6 +169,7 @@ func (s *ServergRPC) OnStartupComplete() {
 func (s *ServergRPC) Stop() (err error) {
 	s.m.Lock()
 	defer s.m.Unlock()
+	s.stopping()
 	if s.grpcServer != nil {
 		s.grpcServer.GracefulStop()
 	}
@@ -121,6 +186,113 @@ func (s *ServergRPC) Query(ctx context.Context, in *pb.DnsPacket) (*pb.DnsPacket
 		return nil, err
 	}
 
+	packed, err := s.serve(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.DnsPacket{Msg: packed}, nil
+}
+
+// Stream is the entry-point for clients that send many queries over one stream. Each query
+// is served in its own goroutine and its response is sent as soon as it is ready, so responses
+// can be out of order; clients match them to their queries by the message ID. At most
+// maxStreamQueries queries of a stream are served at once, and maxGRPCQueries of all streams.
+func (s *ServergRPC) Stream(stream pb.DnsService_StreamServer) error {
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex // serializes Send
+		closed bool
+	)
+	// The stream must not be used after we return.
+	defer func() {
+		mu.Lock()
+		closed = true
+		mu.Unlock()
+	}()
+
+	ctx := stream.Context()
+	inflight := make(chan struct{}, maxStreamQueries)
+	errc := make(chan error, 1)
+	go func() {
+		for {
+			in, err := stream.Recv()
+			if err != nil {
+				errc <- err
+				return
+			}
+
+			msg := new(dns.Msg)
+			if err := msg.Unpack(in.Msg); err != nil {
+				errc <- err
+				return
+			}
+
+			// Don't read the next query while too many are being served, the client has to wait.
+			if !s.acquire(ctx, inflight) {
+				return
+			}
+			if !s.acquire(ctx, s.queries) {
+				<-inflight
+				return
+			}
+
+			wg.Add(1)
+			go func() {
+				defer func() {
+					<-s.queries
+					<-inflight
+					wg.Done()
+				}()
+				packed, err := s.serve(ctx, msg)
+				if err != nil {
+					// The client waits for a response to every query, tell it this one failed.
+					ret := new(dns.Msg)
+					ret.SetRcode(msg, dns.RcodeServerFailure)
+					if packed, err = ret.Pack(); err != nil {
+						return
+					}
+				}
+				mu.Lock()
+				if !closed {
+					stream.Send(&pb.DnsPacket{Msg: packed})
+				}
+				mu.Unlock()
+			}()
+		}
+	}()
+
+	select {
+	case err := <-errc:
+		if err != io.EOF {
+			return err
+		}
+		// The client is done sending, answer the queries that are still in flight.
+		wg.Wait()
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.done.Done():
+		// Streams can last forever, end them so GracefulStop doesn't block.
+		return status.Error(codes.Unavailable, "server is stopping")
+	}
+}
+
+// acquire takes a token from sem, waiting for one to be free. It returns false if ctx is done, or the
+// server stops, first.
+func (s *ServergRPC) acquire(ctx context.Context, sem chan struct{}) bool {
+	select {
+	case sem <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-s.done.Done():
+		return false
+	}
+}
+
+// serve calls ServeDNS for msg and returns the packed response.
+func (s *ServergRPC) serve(ctx context.Context, msg *dns.Msg) ([]byte, error) {
 	p, ok := peer.FromContext(ctx)
 	if !ok {
 		return nil, errors.New("no peer in gRPC context")
@@ -132,21 +304,53 @@ func (s *ServergRPC) Query(ctx context.Context, in *pb.DnsPacket) (*pb.DnsPacket
 	}
 
 	w := &gRPCresponse{localAddr: s.listenAddr, remoteAddr: a, Msg: msg}
+	if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+		w.tlsState = &info.State
+	}
 
 	dnsCtx := context.WithValue(ctx, Key{}, s.Server)
 	dnsCtx = context.WithValue(dnsCtx, LoopKey{}, 0)
-	s.ServeDNS(dnsCtx, w, msg)
+	s.ServeDNS(dnsCtx, s.paddingWriter(w, msg), msg)
 
-	packed, err := w.Msg.Pack()
-	if err != nil {
-		return nil, err
+	return w.Msg.Pack()
+}
+
+// checkHealth keeps the status of the health service in line with the readiness of CoreDNS,
+// until ctx is canceled.
+func (s *ServergRPC) checkHealth(ctx context.Context) {
+	tick := time.NewTicker(healthInterval)
+	defer tick.Stop()
+	for {
+		st := healthpb.HealthCheckResponse_SERVING
+		if !isReady() {
+			st = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		s.health.SetServingStatus("", st)
+		s.health.SetServingStatus(grpcService, st)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
 	}
+}
 
-	return &pb.DnsPacket{Msg: packed}, nil
+// stopping sets the health service to NOT_SERVING for good, so clients move away, and ends
+// the streams.
+func (s *ServergRPC) stopping() {
+	if s.health == nil {
+		return
+	}
+	s.stop()
+	s.health.Shutdown()
 }
 
 // Shutdown stops the server (non gracefully).
 func (s *ServergRPC) Shutdown() error {
+	s.m.Lock()
+	s.stopping()
+	s.m.Unlock()
 	if s.grpcServer != nil {
 		s.grpcServer.Stop()
 	}
@@ -156,6 +360,7 @@ func (s *ServergRPC) Shutdown() error {
 type gRPCresponse struct {
 	localAddr  net.Addr
 	remoteAddr net.Addr
+	tlsState   *tls.ConnectionState
 	Msg        *dns.Msg
 }
 
@@ -175,3 +380,6 @@ func (r *gRPCresponse) Hijack()                   {}
 func (r *gRPCresponse) LocalAddr() net.Addr       { return r.localAddr }
 func (r *gRPCresponse) RemoteAddr() net.Addr      { return r.remoteAddr }
 func (r *gRPCresponse) WriteMsg(m *dns.Msg) error { r.Msg = m; return nil }
+
+// ConnectionState implements the dns.ConnectionStater interface.
+func (r *gRPCresponse) ConnectionState() *tls.ConnectionState { return r.tlsState }
@@ -0,0 +1,149 @@
+package dnsserver
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/pb"
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/miekg/dns"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+// testStreamServer is a pb.DnsService_StreamServer that receives the queries on in and sends the
+// responses to out. Closing in ends the stream.
+type testStreamServer struct {
+	grpc.ServerStream
+	ctx   context.Context
+	in    chan *pb.DnsPacket
+	out   chan *pb.DnsPacket
+	recvs int32 // number of calls to Recv
+}
+
+func (t *testStreamServer) Context() context.Context { return t.ctx }
+
+func (t *testStreamServer) Send(p *pb.DnsPacket) error {
+	t.out <- p
+	return nil
+}
+
+func (t *testStreamServer) Recv() (*pb.DnsPacket, error) {
+	atomic.AddInt32(&t.recvs, 1)
+	p, ok := <-t.in
+	if !ok {
+		return nil, io.EOF
+	}
+	return p, nil
+}
+
+func newTestStreamServer(ctx context.Context, n int) *testStreamServer {
+	return &testStreamServer{ctx: ctx, in: make(chan *pb.DnsPacket), out: make(chan *pb.DnsPacket, n)}
+}
+
+func newTestServergRPC(t *testing.T, p plugin.Handler) *ServergRPC {
+	s, err := NewServergRPC("127.0.0.1:53", []*Config{testConfig("grpc", p)})
+	if err != nil {
+		t.Fatalf("Expected no error for NewServergRPC, got %s", err)
+	}
+	s.done, s.stop = context.WithCancel(context.Background())
+	return s
+}
+
+func packedQuery(t *testing.T, id uint16) *pb.DnsPacket {
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+	m.Id = id
+	buf, err := m.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &pb.DnsPacket{Msg: buf}
+}
+
+func TestStreamMaxQueries(t *testing.T) {
+	var served int32
+	release := make(chan struct{})
+	s := newTestServergRPC(t, plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		atomic.AddInt32(&served, 1)
+		<-release
+		m := new(dns.Msg)
+		m.SetReply(r)
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	}))
+	defer s.stop()
+
+	const n = 2 * maxStreamQueries
+	ctx := peer.NewContext(context.TODO(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 5353}})
+	st := newTestStreamServer(ctx, n)
+	errc := make(chan error, 1)
+	go func() { errc <- s.Stream(st) }()
+
+	queries := make([]*pb.DnsPacket, n)
+	for i := range queries {
+		queries[i] = packedQuery(t, uint16(i))
+	}
+	go func() {
+		for _, q := range queries {
+			st.in <- q
+		}
+		close(st.in)
+	}()
+
+	// The query after the limit is read, but the stream isn't read any further.
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&served) < maxStreamQueries || atomic.LoadInt32(&st.recvs) < maxStreamQueries+1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d queries to be served, got %d", maxStreamQueries, atomic.LoadInt32(&served))
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt32(&served); n != maxStreamQueries {
+		t.Errorf("Expected %d queries to be served at once, got %d", maxStreamQueries, n)
+	}
+	if n := atomic.LoadInt32(&st.recvs); n != maxStreamQueries+1 {
+		t.Errorf("Expected %d queries to be read, got %d", maxStreamQueries+1, n)
+	}
+
+	close(release)
+	if err := <-errc; err != nil {
+		t.Fatalf("Expected no error for the stream, got %s", err)
+	}
+	if len(st.out) != n {
+		t.Errorf("Expected %d responses, got %d", n, len(st.out))
+	}
+}
+
+func TestStreamServeError(t *testing.T) {
+	s := newTestServergRPC(t, testPlugin{})
+	defer s.stop()
+
+	// Without a peer in the context the query can't be served.
+	st := newTestStreamServer(context.TODO(), 1)
+	errc := make(chan error, 1)
+	go func() { errc <- s.Stream(st) }()
+
+	st.in <- packedQuery(t, 1234)
+	close(st.in)
+	if err := <-errc; err != nil {
+		t.Fatalf("Expected no error for the stream, got %s", err)
+	}
+
+	if len(st.out) != 1 {
+		t.Fatalf("Expected a response for the query that failed, got %d", len(st.out))
+	}
+	m := new(dns.Msg)
+	if err := m.Unpack((<-st.out).Msg); err != nil {
+		t.Fatal(err)
+	}
+	if m.Id != 1234 || m.Rcode != dns.RcodeServerFailure {
+		t.Errorf("Expected SERVFAIL for query 1234, got %s for query %d", dns.RcodeToString[m.Rcode], m.Id)
+	}
+}
@@ -3,7 +3,9 @@ package dnsserver
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"strconv"
@@ -12,9 +14,13 @@ import (
 	"github.com/coredns/caddy"
 	"github.com/coredns/coredns/plugin/pkg/dnsutil"
 	"github.com/coredns/coredns/plugin/pkg/doh"
+	"github.com/coredns/coredns/plugin/pkg/odoh"
 	"github.com/coredns/coredns/plugin/pkg/response"
-	"github.com/coredns/coredns/plugin/pkg/reuseport"
 	"github.com/coredns/coredns/plugin/pkg/transport"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 // ServerHTTPS represents an instance of a DNS-over-HTTPS server.
@@ -24,6 +30,8 @@ type ServerHTTPS struct {
 	listenAddr   net.Addr
 	tlsConfig    *tls.Config
 	validRequest func(*http.Request) bool
+	odohTarget   *odoh.Target
+	dohConfig    DoHConfig
 }
 
 // NewServerHTTPS returns a new CoreDNS HTTPS server and compiles all plugins in to it.
@@ -35,24 +43,47 @@ func NewServerHTTPS(addr string, group []*Config) (*ServerHTTPS, error) {
 	// The *tls* plugin must make sure that multiple conflicting
 	// TLS configuration returns an error: it can only be specified once.
 	var tlsConfig *tls.Config
-	for _, conf := range s.zones {
-		// Should we error if some configs *don't* have TLS?
-		tlsConfig = conf.TLSConfig
+	dohConfig := DefaultDoHConfig
+	for _, z := range s.zones {
+		for _, conf := range z {
+			// Should we error if some configs *don't* have TLS?
+			tlsConfig = conf.TLSConfig
+			if conf.DoH != nil {
+				dohConfig = *conf.DoH
+			}
+		}
 	}
-	if tlsConfig == nil {
+	switch {
+	case dohConfig.H2C && tlsConfig != nil:
+		return nil, fmt.Errorf("DoH with h2c is without TLS, remove the tls plugin")
+	case !dohConfig.H2C && tlsConfig == nil:
 		return nil, fmt.Errorf("DoH requires TLS to be configured, see the tls plugin")
+	case tlsConfig != nil:
+		// http/2 is recommended when using DoH. We need to specify it in next protos
+		// or the upgrade won't happen.
+		tlsConfig.NextProtos = []string{"h2", "http/1.1"}
 	}
-	// http/2 is recommended when using DoH. We need to specify it in next protos
-	// or the upgrade won't happen.
-	tlsConfig.NextProtos = []string{"h2", "http/1.1"}
 
 	// Use a custom request validation func or use the standard DoH path check.
 	var validator func(*http.Request) bool
-	for _, conf := range s.zones {
-		validator = conf.HTTPRequestValidateFunc
+	for _, z := range s.zones {
+		for _, conf := range z {
+			validator = conf.HTTPRequestValidateFunc
+		}
 	}
 	if validator == nil {
-		validator = func(r *http.Request) bool { return r.URL.Path == doh.Path }
+		path := dohConfig.Path
+		validator = func(r *http.Request) bool { return r.URL.Path == path }
+	}
+
+	// When configured we are also an Oblivious DoH target.
+	var target *odoh.Target
+	for _, z := range s.zones {
+		for _, conf := range z {
+			if conf.ODoH != nil {
+				target = conf.ODoH
+			}
+		}
 	}
 
 	srv := &http.Server{
@@ -60,10 +91,24 @@ func NewServerHTTPS(addr string, group []*Config) (*ServerHTTPS, error) {
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  120 * time.Second,
 	}
+	if s.readTimeout != 0 {
+		srv.ReadTimeout = s.readTimeout
+	}
+	if s.writeTimeout != 0 {
+		srv.WriteTimeout = s.writeTimeout
+	}
+	if s.idleTimeout != 0 {
+		srv.IdleTimeout = s.idleTimeout
+	}
 	sh := &ServerHTTPS{
-		Server: s, tlsConfig: tlsConfig, httpsServer: srv, validRequest: validator,
+		Server: s, tlsConfig: tlsConfig, httpsServer: srv, validRequest: validator, odohTarget: target,
+		dohConfig: dohConfig,
 	}
 	sh.httpsServer.Handler = sh
+	if dohConfig.H2C {
+		// Without TLS there is no ALPN, clients use HTTP/2 with prior knowledge or upgrade to it.
+		sh.httpsServer.Handler = h2c.NewHandler(sh, &http2.Server{IdleTimeout: srv.IdleTimeout})
+	}
 
 	return sh, nil
 }
@@ -77,6 +122,7 @@ func (s *ServerHTTPS) Serve(l net.Listener) error {
 	s.listenAddr = l.Addr()
 	s.m.Unlock()
 
+	l = s.proxyListener(s.limitListener(l))
 	if s.tlsConfig != nil {
 		l = tls.NewListener(l, s.tlsConfig)
 	}
@@ -89,7 +135,7 @@ func (s *ServerHTTPS) ServePacket(p net.PacketConn) error { return nil }
 // Listen implements caddy.TCPServer interface.
 func (s *ServerHTTPS) Listen() (net.Listener, error) {
 
-	l, err := reuseport.Listen("tcp", s.Addr[len(transport.HTTPS+"://"):])
+	l, err := listen("tcp", s.Addr[len(transport.HTTPS+"://"):])
 	if err != nil {
 		return nil, err
 	}
@@ -126,17 +172,130 @@ func (s *ServerHTTPS) Stop() error {
 // chain, converts it back and write it to the client.
 func (s *ServerHTTPS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
-	if !s.validRequest(r) {
+	if s.odohTarget != nil && r.URL.Path == odoh.ConfigsPath {
+		s.serveODoHConfigs(w, r)
+		return
+	}
+
+	jsonAPI := s.dohConfig.JSONPath != "" && r.URL.Path == s.dohConfig.JSONPath
+	if !jsonAPI && !s.validRequest(r) {
 		http.Error(w, "", http.StatusNotFound)
 		return
 	}
 
+	if s.cors(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.odohTarget != nil && r.Method == http.MethodPost && r.Header.Get("Content-Type") == odoh.MimeType {
+		s.serveODoH(w, r)
+		return
+	}
+
+	if jsonAPI || (s.dohConfig.JSONPath != "" && doh.IsJSONRequest(r)) {
+		s.serveJSON(w, r)
+		return
+	}
+
 	msg, err := doh.RequestToMsg(r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	// See section 4.2.1 of RFC 8484.
+	// We are using code 500 to indicate an unexpected situation when the chain
+	// handler has not provided any response message.
+	ret := s.serveDNS(r, msg)
+	if ret == nil {
+		http.Error(w, "No response", http.StatusInternalServerError)
+		return
+	}
+
+	buf, _ := ret.Pack()
+
+	w.Header().Set("Content-Type", doh.MimeType)
+	w.Header().Set("Cache-Control", cacheControl(ret))
+	w.Header().Set("Content-Length", strconv.Itoa(len(buf)))
+	w.WriteHeader(http.StatusOK)
+
+	w.Write(buf)
+}
+
+// serveJSON answers a DNS JSON API query.
+func (s *ServerHTTPS) serveJSON(w http.ResponseWriter, r *http.Request) {
+	msg, err := doh.RequestToMsgJSON(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ret := s.serveDNS(r, msg)
+	if ret == nil {
+		http.Error(w, "No response", http.StatusInternalServerError)
+		return
+	}
+	buf, err := doh.MsgToJSON(ret)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", doh.JSONMimeType)
+	w.Header().Set("Cache-Control", cacheControl(ret))
+	w.Header().Set("Content-Length", strconv.Itoa(len(buf)))
+	w.WriteHeader(http.StatusOK)
+
+	w.Write(buf)
+}
+
+// cors adds the CORS headers to the response when the origin of r is allowed. It returns true when r
+// is a preflight request, which is then answered.
+func (s *ServerHTTPS) cors(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || len(s.dohConfig.CORSOrigins) == 0 {
+		return false
+	}
+	allowed := ""
+	for _, o := range s.dohConfig.CORSOrigins {
+		if o == "*" || o == origin {
+			allowed = o
+			break
+		}
+	}
+	if allowed == "" {
+		return false
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", allowed)
+	if allowed != "*" {
+		w.Header().Add("Vary", "Origin")
+	}
+	if r.Method != http.MethodOptions {
+		return false
+	}
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST")
+	w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type")
+	w.Header().Set("Access-Control-Max-Age", strconv.Itoa(corsMaxAge))
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// cacheControl returns the Cache-Control header for the response m: the minimal TTL, see section
+// 5.1 of RFC 8484.
+func cacheControl(m *dns.Msg) string {
+	mt, _ := response.Typify(m, time.Now().UTC())
+	age := dnsutil.MinimalTTL(m, mt)
+	return fmt.Sprintf("max-age=%d", int(age.Seconds()))
+}
+
+// serveDNS calls the plugin chain for msg, received in r, and returns the response.
+func (s *ServerHTTPS) serveDNS(r *http.Request, msg *dns.Msg) *dns.Msg {
 	// Create a DoHWriter with the correct addresses in it.
 	h, p, _ := net.SplitHostPort(r.RemoteAddr)
 	port, _ := strconv.Atoi(p)
@@ -150,23 +309,72 @@ func (s *ServerHTTPS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// We should expect a packet to be returned that we can send to the client.
 	ctx := context.WithValue(context.Background(), Key{}, s.Server)
 	ctx = context.WithValue(ctx, LoopKey{}, 0)
-	s.ServeDNS(ctx, dw, msg)
+	s.ServeDNS(ctx, s.paddingWriter(dw, msg), msg)
 
-	// See section 4.2.1 of RFC 8484.
-	// We are using code 500 to indicate an unexpected situation when the chain
-	// handler has not provided any response message.
-	if dw.Msg == nil {
-		http.Error(w, "No response", http.StatusInternalServerError)
+	return dw.Msg
+}
+
+// serveODoHConfigs serves the configuration of the ODoH target, see section 6.2 of RFC 9230.
+func (s *ServerHTTPS) serveODoHConfigs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "", http.StatusMethodNotAllowed)
 		return
 	}
+	buf := s.odohTarget.Configs()
 
-	buf, _ := dw.Msg.Pack()
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", odohConfigsMaxAge))
+	w.Header().Set("Content-Length", strconv.Itoa(len(buf)))
+	w.WriteHeader(http.StatusOK)
 
-	mt, _ := response.Typify(dw.Msg, time.Now().UTC())
-	age := dnsutil.MinimalTTL(dw.Msg, mt)
+	w.Write(buf)
+}
 
-	w.Header().Set("Content-Type", doh.MimeType)
-	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%f", age.Seconds()))
+// serveODoH decrypts an Oblivious DoH query, calls the plugin chain and writes the encrypted response. The
+// request usually comes from an ODoH proxy, which is what the plugins see as the client.
+func (s *ServerHTTPS) serveODoH(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	buf, err := io.ReadAll(io.LimitReader(r.Body, odohMaxMessageSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// See section 4.3 of RFC 9230, a query for a key we don't have is answered with 401.
+	query, qc, err := s.odohTarget.DecryptQuery(buf)
+	if errors.Is(err, odoh.ErrKeyID) {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	msg := new(dns.Msg)
+	if err := msg.Unpack(query); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ret := s.serveDNS(r, msg)
+	if ret == nil {
+		http.Error(w, "No response", http.StatusInternalServerError)
+		return
+	}
+	packed, err := ret.Pack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	buf, err = qc.EncryptResponse(packed, odohResponsePadding)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Responses are encrypted for a single client, and must not be cached by the proxy.
+	w.Header().Set("Content-Type", odoh.MimeType)
+	w.Header().Set("Cache-Control", "no-cache, no-store")
 	w.Header().Set("Content-Length", strconv.Itoa(len(buf)))
 	w.WriteHeader(http.StatusOK)
 
@@ -180,3 +388,14 @@ func (s *ServerHTTPS) Shutdown() error {
 	}
 	return nil
 }
+
+const (
+	// corsMaxAge is how long, in seconds, browsers may cache the result of a CORS preflight request.
+	corsMaxAge = 86400
+	// odohConfigsMaxAge is how long, in seconds, clients may cache the ODoH configuration.
+	odohConfigsMaxAge = 3600
+	// odohMaxMessageSize is the maximum size of an ODoH query we read, a DNS message and the encryption overhead.
+	odohMaxMessageSize = dns.MaxMsgSize + 1024
+	// odohResponsePadding pads responses to a multiple of this size, as RFC 8467 recommends.
+	odohResponsePadding = 468
+)
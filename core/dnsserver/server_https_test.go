@@ -3,11 +3,15 @@ package dnsserver
 import (
 	"bytes"
 	"crypto/tls"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"regexp"
 	"testing"
 
+	"github.com/coredns/coredns/plugin/pkg/doh"
+	"github.com/coredns/coredns/plugin/pkg/odoh"
+
 	"github.com/miekg/dns"
 )
 
@@ -64,3 +68,185 @@ func TestCustomHTTPRequestValidator(t *testing.T) {
 		})
 	}
 }
+
+func TestServeHTTPSODoH(t *testing.T) {
+	target, err := odoh.NewTarget(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := testConfig("https", viewPlugin("odoh"))
+	c.TLSConfig = &tls.Config{}
+	c.ODoH = target
+	s, err := NewServerHTTPS("127.0.0.1:443", []*Config{c})
+	if err != nil {
+		t.Fatalf("Could not create HTTPS server: %s", err)
+	}
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, odoh.ConfigsPath, nil))
+	res := w.Result()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status %d for the configs, got %d", http.StatusOK, res.StatusCode)
+	}
+	body, _ := io.ReadAll(res.Body)
+	config, err := odoh.ParseConfigs(body)
+	if err != nil {
+		t.Fatalf("Failed to parse the configs: %s", err)
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeTXT)
+	buf, _ := m.Pack()
+	query, qc, err := config.EncryptQuery(buf, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/dns-query", bytes.NewReader(query))
+	r.Header.Set("Content-Type", odoh.MimeType)
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	res = w.Result()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status %d for the query, got %d", http.StatusOK, res.StatusCode)
+	}
+	if ct := res.Header.Get("Content-Type"); ct != odoh.MimeType {
+		t.Errorf("Expected content type %s, got %s", odoh.MimeType, ct)
+	}
+	body, _ = io.ReadAll(res.Body)
+	buf, err = qc.DecryptResponse(body)
+	if err != nil {
+		t.Fatalf("Failed to decrypt the response: %s", err)
+	}
+	ret := new(dns.Msg)
+	if err := ret.Unpack(buf); err != nil {
+		t.Fatal(err)
+	}
+	if len(ret.Answer) != 1 || ret.Answer[0].(*dns.TXT).Txt[0] != "odoh" {
+		t.Errorf("Expected the TXT record from the plugin chain, got %v", ret.Answer)
+	}
+
+	// A query for another target's key.
+	other, _ := odoh.NewTarget(nil)
+	query, _, _ = other.Config().EncryptQuery(buf, 0)
+	r = httptest.NewRequest(http.MethodPost, "/dns-query", bytes.NewReader(query))
+	r.Header.Set("Content-Type", odoh.MimeType)
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if code := w.Result().StatusCode; code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d for an unknown key, got %d", http.StatusUnauthorized, code)
+	}
+}
+
+func TestServeHTTPSJSON(t *testing.T) {
+	c := testConfig("https", viewPlugin("json"))
+	c.TLSConfig = &tls.Config{}
+	c.DoH = &DoHConfig{Path: doh.Path, JSONPath: doh.JSONPath}
+	s, err := NewServerHTTPS("127.0.0.1:443", []*Config{c})
+	if err != nil {
+		t.Fatalf("Could not create HTTPS server: %s", err)
+	}
+
+	for _, path := range []string{"/resolve?name=example.com&type=TXT", "/dns-query?name=example.com&type=TXT"} {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		res := w.Result()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status %d for %s, got %d", http.StatusOK, path, res.StatusCode)
+		}
+		if ct := res.Header.Get("Content-Type"); ct != doh.JSONMimeType {
+			t.Errorf("Expected content type %s for %s, got %s", doh.JSONMimeType, path, ct)
+		}
+		if cc := res.Header.Get("Cache-Control"); cc != "max-age=5" {
+			t.Errorf("Expected the minimal TTL as max age for %s, got %s", path, cc)
+		}
+		body, _ := io.ReadAll(res.Body)
+		if !bytes.Contains(body, []byte(`"data":"\"json\""`)) {
+			t.Errorf("Expected the TXT record in the response for %s, got %s", path, body)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/resolve?type=TXT", nil))
+	if code := w.Result().StatusCode; code != http.StatusBadRequest {
+		t.Errorf("Expected status %d without a name, got %d", http.StatusBadRequest, code)
+	}
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/dns-query", nil))
+	if res := w.Result(); res.StatusCode != http.StatusMethodNotAllowed || res.Header.Get("Allow") != "GET, POST" {
+		t.Errorf("Expected status %d with the allowed methods for PUT, got %d", http.StatusMethodNotAllowed, res.StatusCode)
+	}
+}
+
+func TestServeHTTPSJSONDefault(t *testing.T) {
+	// Without a DoH config the JSON API is off, on its own path and on the RFC 8484 path.
+	c := testConfig("https", viewPlugin("json"))
+	c.TLSConfig = &tls.Config{}
+	s, err := NewServerHTTPS("127.0.0.1:443", []*Config{c})
+	if err != nil {
+		t.Fatalf("Could not create HTTPS server: %s", err)
+	}
+
+	for path, expected := range map[string]int{
+		"/resolve?name=example.com":   http.StatusNotFound,
+		"/dns-query?name=example.com": http.StatusBadRequest,
+	} {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		res := w.Result()
+		if res.StatusCode != expected {
+			t.Errorf("Expected status %d for %s, got %d", expected, path, res.StatusCode)
+		}
+		if ct := res.Header.Get("Content-Type"); ct == doh.JSONMimeType {
+			t.Errorf("Expected no JSON response for %s", path)
+		}
+	}
+}
+
+func TestServeHTTPSConfig(t *testing.T) {
+	c := testConfig("https", viewPlugin("config"))
+	c.DoH = &DoHConfig{Path: "/custom", JSONPath: "/custom-json", CORSOrigins: []string{"https://example.org"}, H2C: true}
+	s, err := NewServerHTTPS("127.0.0.1:443", []*Config{c})
+	if err != nil {
+		t.Fatalf("Could not create HTTPS server with h2c: %s", err)
+	}
+
+	for path, expected := range map[string]int{
+		"/custom?name=example.com":      http.StatusOK,
+		"/custom-json?name=example.com": http.StatusOK,
+		"/dns-query?name=example.com":   http.StatusNotFound,
+		"/resolve?name=example.com":     http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if code := w.Result().StatusCode; code != expected {
+			t.Errorf("Expected status %d for %s, got %d", expected, path, code)
+		}
+	}
+
+	// A CORS preflight request from an allowed origin.
+	r := httptest.NewRequest(http.MethodOptions, "/custom", nil)
+	r.Header.Set("Origin", "https://example.org")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	res := w.Result()
+	if res.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected status %d for a preflight request, got %d", http.StatusNoContent, res.StatusCode)
+	}
+	if o := res.Header.Get("Access-Control-Allow-Origin"); o != "https://example.org" {
+		t.Errorf("Expected the origin to be allowed, got %q", o)
+	}
+
+	// Another origin doesn't get the CORS headers.
+	r = httptest.NewRequest(http.MethodGet, "/custom?name=example.com", nil)
+	r.Header.Set("Origin", "https://example.net")
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if o := w.Result().Header.Get("Access-Control-Allow-Origin"); o != "" {
+		t.Errorf("Expected no CORS headers for another origin, got %q", o)
+	}
+
+	c.TLSConfig = &tls.Config{}
+	if _, err := NewServerHTTPS("127.0.0.1:443", []*Config{c}); err == nil {
+		t.Errorf("Expected an error for h2c with TLS")
+	}
+}
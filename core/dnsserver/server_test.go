@@ -5,8 +5,10 @@ import (
 	"testing"
 
 	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
 	"github.com/coredns/coredns/plugin/pkg/log"
 	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
 
 	"github.com/miekg/dns"
 )
@@ -93,3 +95,107 @@ func BenchmarkCoreServeDNS(b *testing.B) {
 		s.ServeDNS(ctx, w, m)
 	}
 }
+
+// viewPlugin answers every query with a TXT record holding its name.
+type viewPlugin string
+
+func (vp viewPlugin) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Answer = []dns.RR{test.TXT(r.Question[0].Name + " 5 IN TXT " + string(vp))}
+	w.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
+func (vp viewPlugin) Name() string { return "viewplugin" }
+
+func TestServeDNSView(t *testing.T) {
+	internal := testConfig("dns", viewPlugin("internal"))
+	internal.ViewName = "internal"
+	internal.FilterFuncs = []FilterFunc{func(_ context.Context, state *request.Request) bool {
+		return state.IP() == "10.240.0.1"
+	}}
+	never := testConfig("dns", viewPlugin("never"))
+	never.FilterFuncs = []FilterFunc{
+		func(context.Context, *request.Request) bool { return true },
+		func(context.Context, *request.Request) bool { return false },
+	}
+	external := testConfig("dns", viewPlugin("external"))
+
+	s, err := NewServer("127.0.0.1:53", []*Config{never, internal, external})
+	if err != nil {
+		t.Fatalf("Expected no error for NewServer, got %s", err)
+	}
+
+	tests := []struct {
+		remote   string
+		expected string
+	}{
+		{"10.240.0.1", "internal"},
+		{"192.0.2.1", "external"},
+	}
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion("www.example.com.", dns.TypeTXT)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{RemoteIP: tc.remote})
+		s.ServeDNS(context.TODO(), rec, m)
+		if rec.Msg == nil || len(rec.Msg.Answer) != 1 {
+			t.Fatalf("Test %d: expected an answer, got %v", i, rec.Msg)
+		}
+		if txt := rec.Msg.Answer[0].(*dns.TXT).Txt[0]; txt != tc.expected {
+			t.Errorf("Test %d: expected view %q, got %q", i, tc.expected, txt)
+		}
+	}
+}
+
+// collectPlugin counts how often the metadata is collected.
+type collectPlugin struct {
+	viewPlugin
+	n *int
+}
+
+func (cp collectPlugin) Collect(ctx context.Context, _ request.Request) context.Context {
+	*cp.n++
+	return ctx
+}
+
+func TestServeDNSViewCollectOnce(t *testing.T) {
+	n := 0
+	never := testConfig("dns", collectPlugin{viewPlugin("never"), &n})
+	never.FilterFuncs = []FilterFunc{func(context.Context, *request.Request) bool { return false }}
+	external := testConfig("dns", collectPlugin{viewPlugin("external"), &n})
+
+	s, err := NewServer("127.0.0.1:53", []*Config{never, external})
+	if err != nil {
+		t.Fatalf("Expected no error for NewServer, got %s", err)
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("www.example.com.", dns.TypeTXT)
+	s.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), m)
+	if n != 1 {
+		t.Errorf("Expected metadata to be collected once, got %d", n)
+	}
+}
+
+func TestServeDNSNoPlugins(t *testing.T) {
+	for _, zone := range []string{"example.com.", "."} {
+		c := &Config{Zone: zone, Transport: "dns", ListenHosts: []string{"127.0.0.1"}, Port: "53"}
+		// A second config for the zone, after the one without plugins, is not used.
+		other := testConfig("dns", viewPlugin("other"))
+		other.Zone = zone
+
+		s, err := NewServer("127.0.0.1:53", []*Config{c, other})
+		if err != nil {
+			t.Fatalf("Expected no error for NewServer, got %s", err)
+		}
+
+		m := new(dns.Msg)
+		m.SetQuestion("www.example.com.", dns.TypeTXT)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		s.ServeDNS(context.TODO(), rec, m)
+		if rec.Msg == nil || rec.Msg.Rcode != dns.RcodeRefused {
+			t.Errorf("Zone %s: expected REFUSED for a zone without plugins, got %v", zone, rec.Msg)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package dnsserver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/plugin/pkg/activation"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+
+	"github.com/miekg/dns"
+)
+
+// ServerUnix represents an instance of a DNS server that listens on a Unix domain socket, with DNS
+// over a stream as in DNS over TCP.
+type ServerUnix struct {
+	*Server
+	path string
+}
+
+// NewServerUnix returns a new CoreDNS Unix socket server and compiles all plugins in to it.
+func NewServerUnix(addr string, group []*Config) (*ServerUnix, error) {
+	s, err := NewServer(addr, group)
+	if err != nil {
+		return nil, err
+	}
+	return &ServerUnix{Server: s, path: addr[len(transport.Unix+"://"):]}, nil
+}
+
+// Compile-time check to ensure ServerUnix implements the caddy.GracefulServer interface
+var _ caddy.GracefulServer = &ServerUnix{}
+
+// Serve implements caddy.TCPServer interface.
+func (s *ServerUnix) Serve(l net.Listener) error {
+	s.m.Lock()
+	l = s.limitListener(&unixListener{l})
+	s.server[tcp] = &dns.Server{Listener: l, Net: "tcp", Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		ctx := context.WithValue(context.Background(), Key{}, s.Server)
+		ctx = context.WithValue(ctx, LoopKey{}, 0)
+		s.ServeDNS(ctx, s.keepaliveWriter(w, r), r)
+	})}
+	s.setTCPTimeouts(s.server[tcp])
+	s.m.Unlock()
+
+	return s.server[tcp].ActivateAndServe()
+}
+
+// ServePacket implements caddy.UDPServer interface.
+func (s *ServerUnix) ServePacket(p net.PacketConn) error { return nil }
+
+// Listen implements caddy.TCPServer interface. A socket that is left behind by a previous run is
+// removed first.
+func (s *ServerUnix) Listen() (net.Listener, error) {
+	if l, ok := activation.Listener("unix", s.path); ok {
+		return l, nil
+	}
+	if fi, err := os.Stat(s.path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		os.Remove(s.path)
+	}
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: s.path, Net: "unix"})
+	if err != nil {
+		return nil, listenError(err, s.path)
+	}
+	// The listener is passed on to the new server on a reload, don't remove the socket when the old
+	// server closes it.
+	l.SetUnlinkOnClose(false)
+	return l, nil
+}
+
+// ListenPacket implements caddy.UDPServer interface.
+func (s *ServerUnix) ListenPacket() (net.PacketConn, error) { return nil, nil }
+
+// OnStartupComplete lists the sites served by this server
+// and any relevant information, assuming Quiet is false.
+func (s *ServerUnix) OnStartupComplete() {
+	if Quiet {
+		return
+	}
+
+	zones := make([]string, 0, len(s.zones))
+	for zone := range s.zones {
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+	for _, zone := range zones {
+		fmt.Println(transport.Unix + "://" + zone + " on " + s.path)
+	}
+}
+
+// unixClient is the address that clients on a Unix socket have, to plugins they are local TCP clients.
+var unixClient = &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+
+// unixListener is a net.Listener whose connections have the unixClient address as their local and
+// remote address, so plugins that look at the address of the client work as is.
+type unixListener struct {
+	net.Listener
+}
+
+// Accept implements net.Listener.
+func (l *unixListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &unixConn{c}, nil
+}
+
+type unixConn struct {
+	net.Conn
+}
+
+// LocalAddr implements net.Conn.
+func (c *unixConn) LocalAddr() net.Addr { return unixClient }
+
+// RemoteAddr implements net.Conn.
+func (c *unixConn) RemoteAddr() net.Addr { return unixClient }
@@ -0,0 +1,77 @@
+package dnsserver
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+func TestServerUnix(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix sockets are not supported on windows")
+	}
+	c := testConfig("unix", test.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		state := request.Request{W: w, Req: r}
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = append(m.Answer, test.TXT(state.QName()+" 0 IN TXT "+state.IP()+" "+state.Proto()))
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	}))
+	c.Zone = "."
+
+	path := filepath.Join(t.TempDir(), "coredns.sock")
+	s, err := NewServerUnix("unix://"+path, []*Config{c})
+	if err != nil {
+		t.Fatalf("Expected no error for NewServerUnix, got %s", err)
+	}
+	l, err := s.Listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	defer s.Stop()
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeTXT)
+	buf, _ := m.Pack()
+	if _, err := conn.Write(append([]byte{byte(len(buf) >> 8), byte(len(buf))}, buf...)); err != nil {
+		t.Fatal(err)
+	}
+
+	length := make([]byte, 2)
+	if _, err := io.ReadFull(conn, length); err != nil {
+		t.Fatalf("Expected a response, got %s", err)
+	}
+	buf = make([]byte, binary.BigEndian.Uint16(length))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("Expected a response, got %s", err)
+	}
+	resp := new(dns.Msg)
+	if err := resp.Unpack(buf); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Answer) != 1 {
+		t.Fatalf("Expected 1 answer, got %d", len(resp.Answer))
+	}
+	if txt := resp.Answer[0].(*dns.TXT).Txt; len(txt) != 2 || txt[0] != "127.0.0.1" || txt[1] != "tcp" {
+		t.Errorf("Expected the client to be 127.0.0.1 over tcp, got %v", txt)
+	}
+}
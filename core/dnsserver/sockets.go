@@ -0,0 +1,63 @@
+package dnsserver
+
+import (
+	"net"
+
+	"github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/pkg/reuseport"
+	"github.com/coredns/coredns/plugin/pkg/udpbatch"
+
+	"github.com/miekg/dns"
+)
+
+// listenUDPSockets opens the additional UDP sockets for the address of p, so the kernel spreads the
+// datagrams over s.udpSockets sockets. When a socket can't be opened, e.g. because SO_REUSEPORT is
+// not supported, the sockets that are opened so far are returned.
+func (s *Server) listenUDPSockets(p net.PacketConn) []net.PacketConn {
+	if s.udpSockets <= 1 {
+		return nil
+	}
+	// Use the address of p, it has the actual port if the server listens on port 0.
+	addr := p.LocalAddr().String()
+	var conns []net.PacketConn
+	for i := 1; i < s.udpSockets; i++ {
+		c, err := reuseport.ListenPacket("udp", addr)
+		if err != nil {
+			log.Warningf("Failed to open UDP socket %d of %d for %s: %s", i+1, s.udpSockets, addr, err)
+			break
+		}
+		conns = append(conns, c)
+	}
+	return conns
+}
+
+// batchPacketConn wraps p to read and write datagrams in batches, if enabled and supported.
+func (s *Server) batchPacketConn(p net.PacketConn) net.PacketConn {
+	if s.udpBatchSize == 0 || !udpbatch.Supported {
+		return p
+	}
+	uc, ok := p.(*net.UDPConn)
+	if !ok {
+		return p
+	}
+	return udpbatch.New(uc, s.udpBatchSize)
+}
+
+// startUDPServers starts servers in the background, it returns when each of them is started or has failed.
+func startUDPServers(servers []*dns.Server) {
+	for _, srv := range servers {
+		started := make(chan struct{})
+		errc := make(chan error, 1)
+		srv.NotifyStartedFunc = func() { close(started) }
+		go func(srv *dns.Server) {
+			if err := srv.ActivateAndServe(); err != nil {
+				log.Errorf("Failed to serve UDP socket %s: %s", srv.PacketConn.LocalAddr(), err)
+				errc <- err
+			}
+		}(srv)
+		select {
+		case <-started:
+		case <-errc:
+		}
+	}
+}
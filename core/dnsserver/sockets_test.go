@@ -0,0 +1,55 @@
+package dnsserver
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestServePacketSockets(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("SO_REUSEPORT is not supported on windows")
+	}
+	c := testConfig("dns", test.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	}))
+	c.Zone = "."
+	c.UDPSockets = 3
+	c.UDPBatchSize = 8
+
+	s, err := NewServer("dns://127.0.0.1:0", []*Config{c})
+	if err != nil {
+		t.Fatalf("Expected no error for NewServer, got %s", err)
+	}
+	p, err := s.ListenPacket()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.ServePacket(p)
+	defer s.Stop()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	client := &dns.Client{Timeout: time.Second}
+	// Each exchange uses a new source port, so the queries are spread over the sockets.
+	for i := 0; i < 20; i++ {
+		if _, _, err := client.Exchange(m, p.LocalAddr().String()); err != nil {
+			t.Fatalf("Expected a response to query %d, got %s", i, err)
+		}
+	}
+
+	s.m.Lock()
+	sockets := len(s.udpServers) + 1
+	s.m.Unlock()
+	if sockets != 3 {
+		t.Errorf("Expected 3 UDP sockets, got %d", sockets)
+	}
+}
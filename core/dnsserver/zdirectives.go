@@ -14,11 +14,19 @@ var Directives = []string{
 	"geoip",
 	"cancel",
 	"tls",
+	"dnscrypt",
+	"odoh",
+	"doh",
 	"reload",
 	"nsid",
 	"bufsize",
 	"root",
 	"bind",
+	"timeouts",
+	"limits",
+	"proxyproto",
+	"multisocket",
+	"padding",
 	"debug",
 	"ready",
 	"health",
@@ -29,7 +37,13 @@ var Directives = []string{
 	"dnstap",
 	"local",
 	"dns64",
+	"rrl",
+	"ratelimit",
 	"acl",
+	"blocklist",
+	"rpz",
+	"rebind",
+	"detect",
 	"any",
 	"chaos",
 	"traffic",
@@ -63,4 +77,5 @@ master
 	"on",
 	"sign",
 	"bittorrent",
+	"view",
 }
@@ -0,0 +1,92 @@
+//go:build !windows
+// +build !windows
+
+package coremain
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+
+	"github.com/coredns/coredns/core/dnsserver"
+)
+
+// dropPrivileges chroots to dir and switches to userName and groupName, each when not empty. It is
+// called after all listeners are opened, so the server can bind to privileged ports first.
+func dropPrivileges(dir, userName, groupName string) error {
+	if dir == "" && userName == "" && groupName == "" {
+		return nil
+	}
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("dropping privileges requires running as root, running as uid %d", os.Geteuid())
+	}
+
+	// Look up the ids before the chroot, the user database is likely not available afterwards.
+	uid, gid, err := lookupIDs(userName, groupName)
+	if err != nil {
+		return err
+	}
+
+	if dir != "" {
+		if err := syscall.Chroot(dir); err != nil {
+			return fmt.Errorf("failed to chroot to %s: %s", dir, err)
+		}
+		if err := os.Chdir("/"); err != nil {
+			return fmt.Errorf("failed to change directory to the chroot: %s", err)
+		}
+	}
+	if gid >= 0 {
+		if err := syscall.Setgroups([]int{gid}); err != nil {
+			return fmt.Errorf("failed to set the groups to %d: %s", gid, err)
+		}
+		if err := syscall.Setgid(gid); err != nil {
+			return fmt.Errorf("failed to switch to group %d: %s", gid, err)
+		}
+	}
+	if uid >= 0 {
+		if err := syscall.Setuid(uid); err != nil {
+			return fmt.Errorf("failed to switch to user %d: %s", uid, err)
+		}
+	}
+
+	dnsserver.Unprivileged = uid > 0
+	log.Printf("[INFO] Dropped privileges: uid %d, gid %d, root %q", os.Geteuid(), os.Getegid(), dir)
+	return nil
+}
+
+// lookupIDs returns the ids of userName and groupName, which can be names or numeric ids. An empty
+// groupName is the primary group of userName. The id is -1 for an empty name.
+func lookupIDs(userName, groupName string) (uid, gid int, err error) {
+	uid, gid = -1, -1
+	if userName != "" {
+		u, err := user.Lookup(userName)
+		if err != nil {
+			if u, err = user.LookupId(userName); err != nil {
+				return -1, -1, fmt.Errorf("unknown user %q", userName)
+			}
+		}
+		if uid, err = strconv.Atoi(u.Uid); err != nil {
+			return -1, -1, fmt.Errorf("invalid uid %q of user %q", u.Uid, userName)
+		}
+		if groupName == "" {
+			if gid, err = strconv.Atoi(u.Gid); err != nil {
+				return -1, -1, fmt.Errorf("invalid gid %q of user %q", u.Gid, userName)
+			}
+		}
+	}
+	if groupName != "" {
+		g, err := user.LookupGroup(groupName)
+		if err != nil {
+			if g, err = user.LookupGroupId(groupName); err != nil {
+				return -1, -1, fmt.Errorf("unknown group %q", groupName)
+			}
+		}
+		if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return -1, -1, fmt.Errorf("invalid gid %q of group %q", g.Gid, groupName)
+		}
+	}
+	return uid, gid, nil
+}
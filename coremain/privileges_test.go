@@ -0,0 +1,38 @@
+//go:build !windows
+// +build !windows
+
+package coremain
+
+import "testing"
+
+func TestLookupIDs(t *testing.T) {
+	tests := []struct {
+		user, group string
+		uid, gid    int
+		shouldErr   bool
+	}{
+		{"", "", -1, -1, false},
+		{"root", "", 0, 0, false},
+		{"0", "", 0, 0, false},
+		{"", "0", -1, 0, false},
+		{"root", "0", 0, 0, false},
+		{"no-such-user-coredns", "", -1, -1, true},
+		{"", "no-such-group-coredns", -1, -1, true},
+	}
+	for i, tc := range tests {
+		uid, gid, err := lookupIDs(tc.user, tc.group)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected an error for user %q and group %q", i, tc.user, tc.group)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if uid != tc.uid || gid != tc.gid {
+			t.Errorf("Test %d: expected uid %d and gid %d, got %d and %d", i, tc.uid, tc.gid, uid, gid)
+		}
+	}
+}
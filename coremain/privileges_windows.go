@@ -0,0 +1,11 @@
+package coremain
+
+import "errors"
+
+// dropPrivileges is not supported on Windows.
+func dropPrivileges(dir, userName, groupName string) error {
+	if dir == "" && userName == "" && groupName == "" {
+		return nil
+	}
+	return errors.New("dropping privileges is not supported on windows")
+}
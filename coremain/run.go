@@ -24,6 +24,9 @@ func init() {
 	flag.StringVar(&caddy.PidFile, "pidfile", "", "Path to write pid file")
 	flag.BoolVar(&version, "version", false, "Show version")
 	flag.BoolVar(&dnsserver.Quiet, "quiet", false, "Quiet mode (no initialization output)")
+	flag.StringVar(&runUser, "user", "", "User to switch to after the listeners are opened")
+	flag.StringVar(&runGroup, "group", "", "Group to switch to after the listeners are opened (default the group of -user)")
+	flag.StringVar(&chroot, "chroot", "", "Directory to chroot to after the listeners are opened")
 
 	caddy.RegisterCaddyfileLoader("flag", caddy.LoaderFunc(confLoader))
 	caddy.SetDefaultCaddyfileLoader("default", caddy.LoaderFunc(defaultLoader))
@@ -65,6 +68,12 @@ func Run() {
 		mustLogFatal(err)
 	}
 
+	// All listeners are open, drop the privileges that were needed to open them. A reload reuses the
+	// listeners for the same addresses.
+	if err := dropPrivileges(chroot, runUser, runGroup); err != nil {
+		mustLogFatal(err)
+	}
+
 	if !dnsserver.Quiet {
 		showVersion()
 	}
@@ -163,9 +172,12 @@ func setVersion() {
 
 // Flags that control program flow or startup
 var (
-	conf    string
-	version bool
-	plugins bool
+	conf     string
+	version  bool
+	plugins  bool
+	runUser  string
+	runGroup string
+	chroot   string
 )
 
 // Build information obtained with the help of -ldflags
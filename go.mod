@@ -1,6 +1,6 @@
 module github.com/coredns/coredns
 
-go 1.19
+go 1.21.0
 
 require (
  torrent
@@ -105,6 +105,7 @@ master
 	github.com/Microsoft/go-winio v0.5.0 // indirect
 	github.com/apparentlymart/go-cidr v1.1.0
 	github.com/aws/aws-sdk-go v1.55.1
+	github.com/caddyserver/certmagic v0.21.6
 	github.com/coredns/caddy/v2 v2.1.1
 	github.com/dnstap/golang-dnstap v0.4.0
 	github.com/farsightsec/golang-framestream v0.3.0
@@ -129,7 +130,8 @@ master
 	github.com/zeebo/bencode v1.0.0
 	go.etcd.io/etcd/v3 v3.5.15
 	go.uber.org/zap v1.14.1 // indirect
-	golang.org/x/crypto 089bfa567519
+	golang.org/x/crypto v0.31.0
+	golang.org/x/net v0.33.0
 	golang.org/x/sys b8560ed6a9b7
 	google.golang.org/api v0.187.0
  renovate/google.golang.org-grpc-1.x
@@ -3,8 +3,11 @@ package acl
 import (
 	"context"
 	"net"
+	"regexp"
+	"time"
 
 	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metadata"
 	"github.com/coredns/coredns/plugin/metrics"
 	"github.com/coredns/coredns/request"
 
@@ -17,6 +20,11 @@ type ACL struct {
 	Next plugin.Handler
 
 	Rules []rule
+
+	// lists are all the networks loaded from files, these are reloaded every reload.
+	lists  []*netList
+	reload time.Duration
+	stop   chan struct{}
 }
 
 // rule defines a list of Zones and some ACL policies which will be
@@ -31,11 +39,22 @@ type action int
 
 // policy defines the ACL policy for DNS queries.
 // A policy performs the specified action (block/allow) on all DNS queries
-// matched by source IP or QTYPE.
+// matched by source IP, QTYPE, query name and metadata. All of these must match.
 type policy struct {
 	action action
 	qtypes map[uint16]struct{}
-	filter *iptree.Tree
+	filter *iptree.Tree // nil when the networks only come from lists.
+	lists  []*netList
+
+	names    []string         // query names (and names below them) to match, all names when empty.
+	regexps  []*regexp.Regexp // query names patterns to match, all names when empty.
+	metadata []metadataMatch  // metadata to match, any metadata when empty.
+}
+
+// metadataMatch matches when the metadata label has value.
+type metadataMatch struct {
+	label string
+	value string
 }
 
 const (
@@ -47,6 +66,8 @@ const (
 	actionBlock
 	// actionFilter returns empty sets for queries towards protected DNS zones.
 	actionFilter
+	// actionDrop drops queries towards protected DNS zones without replying.
+	actionDrop
 )
 
 // ServeDNS implements the plugin.Handler interface.
@@ -61,7 +82,7 @@ RulesCheckLoop:
 			continue
 		}
 
-		action := matchWithPolicies(rule.policies, w, r)
+		action := matchWithPolicies(ctx, rule.policies, w, r)
 		switch action {
 		case actionBlock:
 			{
@@ -83,6 +104,11 @@ RulesCheckLoop:
 				RequestFilterCount.WithLabelValues(metrics.WithServer(ctx), zone).Inc()
 				return dns.RcodeSuccess, nil
 			}
+		case actionDrop:
+			{
+				RequestDropCount.WithLabelValues(metrics.WithServer(ctx), zone).Inc()
+				return dns.RcodeSuccess, nil
+			}
 		}
 
 	}
@@ -93,7 +119,7 @@ RulesCheckLoop:
 
 // matchWithPolicies matches the DNS query with a list of ACL polices and returns suitable
 // action against the query.
-func matchWithPolicies(policies []policy, w dns.ResponseWriter, r *dns.Msg) action {
+func matchWithPolicies(ctx context.Context, policies []policy, w dns.ResponseWriter, r *dns.Msg) action {
 	state := request.Request{W: w, Req: r}
 
 	ip := net.ParseIP(state.IP())
@@ -106,8 +132,15 @@ func matchWithPolicies(policies []policy, w dns.ResponseWriter, r *dns.Msg) acti
 			continue
 		}
 
-		_, contained := policy.filter.GetByIP(ip)
-		if !contained {
+		if !policy.matchIP(ip) {
+			continue
+		}
+
+		if !policy.matchName(state.Name()) {
+			continue
+		}
+
+		if !policy.matchMetadata(ctx) {
 			continue
 		}
 
@@ -117,6 +150,65 @@ func matchWithPolicies(policies []policy, w dns.ResponseWriter, r *dns.Msg) acti
 	return actionNone
 }
 
+func (p policy) matchIP(ip net.IP) bool {
+	if p.filter != nil {
+		if _, contained := p.filter.GetByIP(ip); contained {
+			return true
+		}
+	}
+	for _, l := range p.lists {
+		if l.contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p policy) matchName(qname string) bool {
+	if len(p.names) == 0 && len(p.regexps) == 0 {
+		return true
+	}
+	if len(p.names) > 0 && plugin.Zones(p.names).Matches(qname) != "" {
+		return true
+	}
+	for _, re := range p.regexps {
+		if re.MatchString(qname) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p policy) matchMetadata(ctx context.Context) bool {
+	if len(p.metadata) == 0 {
+		return true
+	}
+	for _, m := range p.metadata {
+		if f := metadata.ValueFunc(ctx, m.label); f != nil && f() == m.value {
+			return true
+		}
+	}
+	return false
+}
+
+// OnStartup starts reloading the networks loaded from files.
+func (a ACL) OnStartup() error {
+	if len(a.lists) == 0 || a.reload == 0 {
+		return nil
+	}
+	go reloadLists(a.lists, a.reload, a.stop)
+	return nil
+}
+
+// OnShutdown stops reloading the networks loaded from files.
+func (a ACL) OnShutdown() error {
+	if len(a.lists) == 0 || a.reload == 0 {
+		return nil
+	}
+	close(a.stop)
+	return nil
+}
+
 // Name implements the plugin.Handler interface.
 func (a ACL) Name() string {
 	return "acl"
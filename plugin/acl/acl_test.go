@@ -5,6 +5,8 @@ import (
 	"testing"
 
 	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metadata"
 	"github.com/coredns/coredns/plugin/test"
 
 	"github.com/miekg/dns"
@@ -392,6 +394,104 @@ func TestACLServeDNS(t *testing.T) {
 			dns.RcodeSuccess,
 			false,
 		},
+		{
+			"Refuse 1 REFUSED",
+			`acl {
+				refuse net 192.168.0.0/16
+			}`,
+			[]string{"example.org"},
+			args{
+				"www.example.org.",
+				"192.168.0.2",
+				dns.TypeA,
+			},
+			dns.RcodeRefused,
+			false,
+		},
+		{
+			"Name 1 BLOCKED",
+			`acl {
+				block name bad.example.org
+			}`,
+			[]string{"example.org"},
+			args{
+				"www.bad.example.org.",
+				"192.168.0.2",
+				dns.TypeA,
+			},
+			dns.RcodeRefused,
+			false,
+		},
+		{
+			"Name 1 ALLOWED",
+			`acl {
+				block name bad.example.org
+			}`,
+			[]string{"example.org"},
+			args{
+				"www.good.example.org.",
+				"192.168.0.2",
+				dns.TypeA,
+			},
+			dns.RcodeSuccess,
+			false,
+		},
+		{
+			"Name 2 BLOCKED",
+			`acl {
+				block type A name bad.example.org net 192.168.0.0/16
+			}`,
+			[]string{"example.org"},
+			args{
+				"bad.example.org.",
+				"192.168.0.2",
+				dns.TypeA,
+			},
+			dns.RcodeRefused,
+			false,
+		},
+		{
+			"Name 2 ALLOWED",
+			`acl {
+				block type A name bad.example.org net 192.168.0.0/16
+			}`,
+			[]string{"example.org"},
+			args{
+				"bad.example.org.",
+				"10.0.0.2",
+				dns.TypeA,
+			},
+			dns.RcodeSuccess,
+			false,
+		},
+		{
+			"Regex 1 BLOCKED",
+			`acl {
+				block regex ^[a-z0-9]{32}\.example\.org\.$
+			}`,
+			[]string{"example.org"},
+			args{
+				"0123456789abcdef0123456789abcdef.example.org.",
+				"192.168.0.2",
+				dns.TypeA,
+			},
+			dns.RcodeRefused,
+			false,
+		},
+		{
+			"Regex 1 ALLOWED",
+			`acl {
+				block regex ^[a-z0-9]{32}\.example\.org\.$
+			}`,
+			[]string{"example.org"},
+			args{
+				"www.example.org.",
+				"192.168.0.2",
+				dns.TypeA,
+			},
+			dns.RcodeSuccess,
+			false,
+		},
 	}
 
 	ctx := context.Background()
@@ -420,3 +520,99 @@ func TestACLServeDNS(t *testing.T) {
 		})
 	}
 }
+
+func TestACLDrop(t *testing.T) {
+	ctr := NewTestControllerWithZones(`acl {
+		drop net 192.168.0.0/16
+	}`, []string{"example.org"})
+	a, err := parse(ctr)
+	if err != nil {
+		t.Fatalf("Error: Cannot parse acl from config: %v", err)
+	}
+	a.Next = test.NextHandler(dns.RcodeSuccess, nil)
+
+	w := &writeCounter{}
+	w.RemoteIP = "192.168.0.2"
+	m := new(dns.Msg)
+	m.SetQuestion("www.example.org.", dns.TypeA)
+	rcode, _ := a.ServeDNS(context.Background(), w, m)
+	if w.n != 0 {
+		t.Errorf("Error: expected no reply for a dropped query, got %d", w.n)
+	}
+	if !plugin.ClientWrite(rcode) {
+		t.Errorf("Error: expected rcode %d to signal the reply was handled", rcode)
+	}
+}
+
+func TestACLMetadata(t *testing.T) {
+	ctr := NewTestControllerWithZones(`acl {
+		allow metadata test/tenant=good
+		block
+	}`, []string{"example.org"})
+	a, err := parse(ctr)
+	if err != nil {
+		t.Fatalf("Error: Cannot parse acl from config: %v", err)
+	}
+	a.Next = test.NextHandler(dns.RcodeSuccess, nil)
+
+	for _, tc := range []struct {
+		tenant    string
+		wantRcode int
+	}{
+		{"good", dns.RcodeSuccess},
+		{"bad", dns.RcodeRefused},
+	} {
+		ctx := metadata.ContextWithMetadata(context.Background())
+		tenant := tc.tenant
+		metadata.SetValueFunc(ctx, "test/tenant", func() string { return tenant })
+
+		w := &testResponseWriter{}
+		m := new(dns.Msg)
+		m.SetQuestion("www.example.org.", dns.TypeA)
+		a.ServeDNS(ctx, w, m)
+		if w.Rcode != tc.wantRcode {
+			t.Errorf("Error: tenant %s: Rcode = %v, want %v", tc.tenant, w.Rcode, tc.wantRcode)
+		}
+	}
+}
+
+func TestACLRefuseIsBlock(t *testing.T) {
+	var acls []ACL
+	for _, action := range []string{"block", "refuse"} {
+		a, err := parse(NewTestControllerWithZones("acl {\n"+action+" net 192.168.0.0/16\n}", []string{"example.org"}))
+		if err != nil {
+			t.Fatalf("Error: Cannot parse acl from config: %v", err)
+		}
+		a.Next = test.NextHandler(dns.RcodeSuccess, nil)
+		acls = append(acls, a)
+	}
+	if b, r := acls[0].Rules[0].policies[0].action, acls[1].Rules[0].policies[0].action; b != r {
+		t.Errorf("Error: expected refuse to be the same action as block, got %d and %d", r, b)
+	}
+
+	for _, ip := range []string{"192.168.0.2", "10.0.0.2"} {
+		var rcodes []int
+		for _, a := range acls {
+			w := &testResponseWriter{}
+			w.setRemoteIP(ip)
+			m := new(dns.Msg)
+			m.SetQuestion("www.example.org.", dns.TypeA)
+			a.ServeDNS(context.Background(), w, m)
+			rcodes = append(rcodes, w.Rcode)
+		}
+		if rcodes[0] != rcodes[1] {
+			t.Errorf("Error: %s: block Rcode = %v, refuse Rcode = %v", ip, rcodes[0], rcodes[1])
+		}
+	}
+}
+
+type writeCounter struct {
+	test.ResponseWriter
+	n int
+}
+
+// WriteMsg implement dns.ResponseWriter interface.
+func (w *writeCounter) WriteMsg(m *dns.Msg) error {
+	w.n++
+	return nil
+}
@@ -22,6 +22,13 @@ var (
 		Name:      "filtered_requests_total",
 		Help:      "Counter of DNS requests being filtered.",
 	}, []string{"server", "zone"})
+	// RequestDropCount is the number of DNS requests being dropped.
+	RequestDropCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: pluginName,
+		Name:      "dropped_requests_total",
+		Help:      "Counter of DNS requests being dropped.",
+	}, []string{"server", "zone"})
 	// RequestAllowCount is the number of DNS requests being Allowed.
 	RequestAllowCount = promauto.NewCounterVec(prometheus.CounterOpts{
 		Namespace: plugin.Namespace,
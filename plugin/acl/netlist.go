@@ -0,0 +1,100 @@
+package acl
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/infobloxopen/go-trees/iptree"
+)
+
+// netList is a list of networks loaded from a file. The file holds one address or network (in CIDR
+// notation) per line, empty lines and everything after a '#' are ignored. The list is reloaded when the
+// file changes.
+type netList struct {
+	path string
+
+	tree  atomic.Value // *iptree.Tree
+	mtime time.Time
+	size  int64
+}
+
+func newNetList(path string) (*netList, error) {
+	l := &netList{path: path}
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// contains returns true if ip is in one of the networks of l.
+func (l *netList) contains(ip net.IP) bool {
+	_, ok := l.tree.Load().(*iptree.Tree).GetByIP(ip)
+	return ok
+}
+
+// load (re)reads the file of l, if it changed since the last time it was read. On error the
+// previously loaded networks are kept.
+func (l *netList) load() error {
+	fi, err := os.Stat(l.path)
+	if err != nil {
+		return err
+	}
+	if fi.ModTime().Equal(l.mtime) && fi.Size() == l.size {
+		return nil
+	}
+
+	f, err := os.Open(l.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tree := iptree.NewTree()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		_, n, err := net.ParseCIDR(normalize(line))
+		if err != nil {
+			log.Warningf("Ignoring illegal CIDR notation %q in %s", line, l.path)
+			continue
+		}
+		tree.InplaceInsertNet(n, struct{}{})
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	l.tree.Store(tree)
+	l.mtime = fi.ModTime()
+	l.size = fi.Size()
+	return nil
+}
+
+// reloadLists reloads lists every interval until stop is closed.
+func reloadLists(lists []*netList, interval time.Duration, stop <-chan struct{}) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-tick.C:
+			for _, l := range lists {
+				if err := l.load(); err != nil {
+					log.Warningf("Failed to reload %s: %s", l.path, err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package acl
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNetList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nets")
+	if err := os.WriteFile(path, []byte("# threat intel\n192.0.2.0/24\n\n2001:db8::1 # single host\nnot-a-network\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := newNetList(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	for _, tc := range []struct {
+		ip       string
+		contains bool
+	}{
+		{"192.0.2.10", true},
+		{"192.0.3.10", false},
+		{"2001:db8::1", true},
+		{"2001:db8::2", false},
+	} {
+		if got := l.contains(net.ParseIP(tc.ip)); got != tc.contains {
+			t.Errorf("Expected contains(%s) to be %t, got %t", tc.ip, tc.contains, got)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte("198.51.100.0/24\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Make sure the modification time differs, on some file systems the resolution is coarse.
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+
+	if err := l.load(); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if l.contains(net.ParseIP("192.0.2.10")) {
+		t.Errorf("Expected 192.0.2.10 to be removed after reload")
+	}
+	if !l.contains(net.ParseIP("198.51.100.10")) {
+		t.Errorf("Expected 198.51.100.10 to be added after reload")
+	}
+
+	// A file that disappears keeps the previous networks.
+	os.Remove(path)
+	if err := l.load(); err == nil {
+		t.Errorf("Expected error for removed file")
+	}
+	if !l.contains(net.ParseIP("198.51.100.10")) {
+		t.Errorf("Expected networks to be kept when the file can't be read")
+	}
+}
@@ -2,11 +2,16 @@ package acl
 
 import (
 	"net"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/coredns/caddy"
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metadata"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
 
 	"github.com/infobloxopen/go-trees/iptree"
 	"github.com/miekg/dns"
@@ -14,6 +19,11 @@ import (
 
 const pluginName = "acl"
 
+// defaultReload is how often networks loaded from files are checked for changes.
+const defaultReload = time.Minute
+
+var log = clog.NewWithPlugin(pluginName)
+
 func init() { plugin.Register(pluginName, setup) }
 
 func newDefaultFilter() *iptree.Tree {
@@ -36,11 +46,14 @@ func setup(c *caddy.Controller) error {
 		return a
 	})
 
+	c.OnStartup(a.OnStartup)
+	c.OnShutdown(a.OnShutdown)
+
 	return nil
 }
 
 func parse(c *caddy.Controller) (ACL, error) {
-	a := ACL{}
+	a := ACL{reload: defaultReload, stop: make(chan struct{})}
 	for c.Next() {
 		r := rule{}
 		args := c.RemainingArgs()
@@ -52,16 +65,32 @@ func parse(c *caddy.Controller) (ACL, error) {
 			action := strings.ToLower(c.Val())
 			if action == "allow" {
 				p.action = actionAllow
-			} else if action == "block" {
+			} else if action == "block" || action == "refuse" {
+				// refuse is an alias for block, both answer with REFUSED.
 				p.action = actionBlock
 			} else if action == "filter" {
 				p.action = actionFilter
+			} else if action == "drop" {
+				p.action = actionDrop
+			} else if action == "reload" {
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return a, c.ArgErr()
+				}
+				d, err := time.ParseDuration(args[0])
+				if err != nil {
+					return a, c.Errf("illegal reload duration %q", args[0])
+				}
+				if d < 0 {
+					return a, c.Errf("reload duration can not be negative: %q", args[0])
+				}
+				a.reload = d
+				continue
 			} else {
-				return a, c.Errf("unexpected token %q; expect 'allow', 'block', or 'filter'", c.Val())
+				return a, c.Errf("unexpected token %q; expect 'allow', 'block', 'filter', 'drop', 'refuse' or 'reload'", c.Val())
 			}
 
 			p.qtypes = make(map[uint16]struct{})
-			p.filter = iptree.NewTree()
 
 			hasTypeSection := false
 			hasNetSection := false
@@ -69,7 +98,7 @@ func parse(c *caddy.Controller) (ACL, error) {
 			remainingTokens := c.RemainingArgs()
 			for len(remainingTokens) > 0 {
 				if !isPreservedIdentifier(remainingTokens[0]) {
-					return a, c.Errf("unexpected token %q; expect 'type | net'", remainingTokens[0])
+					return a, c.Errf("unexpected token %q; expect 'type | net | file | name | regex | metadata'", remainingTokens[0])
 				}
 				section := strings.ToLower(remainingTokens[0])
 
@@ -100,6 +129,9 @@ func parse(c *caddy.Controller) (ACL, error) {
 					}
 				case "net":
 					hasNetSection = true
+					if p.filter == nil {
+						p.filter = iptree.NewTree()
+					}
 					for _, token := range tokens {
 						if token == "*" {
 							p.filter = newDefaultFilter()
@@ -112,8 +144,41 @@ func parse(c *caddy.Controller) (ACL, error) {
 						}
 						p.filter.InplaceInsertNet(source, struct{}{})
 					}
+				case "file":
+					hasNetSection = true
+					for _, token := range tokens {
+						if root := dnsserver.GetConfig(c).Root; !filepath.IsAbs(token) && root != "" {
+							token = filepath.Join(root, token)
+						}
+						l, err := newNetList(token)
+						if err != nil {
+							return a, c.Errf("failed to load %q: %s", token, err)
+						}
+						p.lists = append(p.lists, l)
+						a.lists = append(a.lists, l)
+					}
+				case "name":
+					for _, token := range tokens {
+						p.names = append(p.names, plugin.Name(token).Normalize())
+					}
+				case "regex":
+					for _, token := range tokens {
+						re, err := regexp.Compile(token)
+						if err != nil {
+							return a, c.Errf("illegal regular expression %q: %s", token, err)
+						}
+						p.regexps = append(p.regexps, re)
+					}
+				case "metadata":
+					for _, token := range tokens {
+						label, value, ok := strings.Cut(token, "=")
+						if !ok || !metadata.IsLabel(label) {
+							return a, c.Errf("unexpected token %q; expect 'LABEL=VALUE'", token)
+						}
+						p.metadata = append(p.metadata, metadataMatch{label: label, value: value})
+					}
 				default:
-					return a, c.Errf("unexpected token %q; expect 'type | net'", section)
+					return a, c.Errf("unexpected token %q; expect 'type | net | file | name | regex | metadata'", section)
 				}
 			}
 
@@ -136,7 +201,11 @@ func parse(c *caddy.Controller) (ACL, error) {
 
 func isPreservedIdentifier(token string) bool {
 	identifier := strings.ToLower(token)
-	return identifier == "type" || identifier == "net"
+	switch identifier {
+	case "type", "net", "file", "name", "regex", "metadata":
+		return true
+	}
+	return false
 }
 
 // normalize appends '/32' for any single IPv4 address and '/128' for IPv6.
@@ -213,6 +213,64 @@ func TestSetup(t *testing.T) {
 			}`,
 			true,
 		},
+		{
+			"Drop and refuse",
+			`acl {
+				drop net 192.168.0.0/16
+				refuse type ANY
+			}`,
+			false,
+		},
+		{
+			"Names and regex",
+			`acl {
+				block name bad.example.org evil.example.net regex ^xn-- .*\.onion\.$
+			}`,
+			false,
+		},
+		{
+			"Metadata",
+			`acl {
+				allow metadata kubernetes/client-namespace=kube-system
+			}`,
+			false,
+		},
+		{
+			"Reload",
+			`acl {
+				reload 30s
+				block net 10.0.0.0/8
+			}`,
+			false,
+		},
+		{
+			"Illegal regex",
+			`acl {
+				block regex [a-z
+			}`,
+			true,
+		},
+		{
+			"Illegal metadata",
+			`acl {
+				block metadata nolabel
+			}`,
+			true,
+		},
+		{
+			"Missing file",
+			`acl {
+				block file /does/not/exist
+			}`,
+			true,
+		},
+		{
+			"Illegal reload",
+			`acl {
+				reload -1s
+			}`,
+			true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
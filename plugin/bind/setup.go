@@ -8,11 +8,15 @@ import (
 	"github.com/coredns/caddy"
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/transport"
 )
 
 func setup(c *caddy.Controller) error {
 
 	config := dnsserver.GetConfig(c)
+	if config.Transport == transport.Unix {
+		return plugin.Error("bind", fmt.Errorf("can't bind a %s:// server to an address", transport.Unix))
+	}
 	// addresses will be consolidated over all BIND directives available in that BlocServer
 	all := []string{}
 	ifaces, err := net.Interfaces()
@@ -5,6 +5,7 @@ import (
 
 	"github.com/coredns/caddy"
 	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin/pkg/transport"
 )
 
 func TestSetup(t *testing.T) {
@@ -45,3 +46,11 @@ func TestSetup(t *testing.T) {
 		}
 	}
 }
+
+func TestSetupUnix(t *testing.T) {
+	c := caddy.NewTestController("dns", `bind 127.0.0.1`)
+	dnsserver.GetConfig(c).Transport = transport.Unix
+	if err := setup(c); err == nil {
+		t.Errorf("Expected an error for bind on a Unix socket")
+	}
+}
@@ -0,0 +1,260 @@
+// Package blocklist implements a plugin that blocks names found in block lists.
+package blocklist
+
+import (
+	"context"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/plugin/metrics"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// Blocklist is a plugin that answers queries for blocked names itself.
+type Blocklist struct {
+	Next  plugin.Handler
+	Zones []string
+
+	lists      []*list
+	allowLists []*list
+	allowNames []string
+
+	response response
+	sinkhole []net.IP
+
+	reload time.Duration
+	index  atomic.Value // *index
+	mu     sync.Mutex   // serializes loads.
+	stop   chan struct{}
+}
+
+// list is a block (or allow) list read from a file.
+type list struct {
+	name string
+	path string
+
+	mtime time.Time
+	size  int64
+}
+
+// index holds the names of all lists.
+type index struct {
+	block *trie
+	allow *trie
+}
+
+type response int
+
+const (
+	responseNXDomain response = iota
+	responseNodata
+	responseNull
+	responseSinkhole
+)
+
+// New returns a new Blocklist.
+func New() *Blocklist {
+	b := &Blocklist{Zones: []string{"."}, reload: defaultReload, stop: make(chan struct{})}
+	b.index.Store(&index{block: newTrie(), allow: newTrie()})
+	return b
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (b *Blocklist) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+
+	zone := plugin.Zones(b.Zones).Matches(state.Name())
+	if zone == "" {
+		return plugin.NextOrFailure(b.Name(), b.Next, ctx, w, r)
+	}
+	l := b.match(state.Name())
+	if l == nil {
+		return plugin.NextOrFailure(b.Name(), b.Next, ctx, w, r)
+	}
+
+	hits.WithLabelValues(metrics.WithServer(ctx), l.name).Inc()
+	w.WriteMsg(b.reply(state))
+	return dns.RcodeSuccess, nil
+}
+
+// Metadata implements the metadata.Provider interface.
+func (b *Blocklist) Metadata(ctx context.Context, state request.Request) context.Context {
+	metadata.SetValueFunc(ctx, "blocklist/list", func() string {
+		if plugin.Zones(b.Zones).Matches(state.Name()) == "" {
+			return ""
+		}
+		if l := b.match(state.Name()); l != nil {
+			return l.name
+		}
+		return ""
+	})
+	return ctx
+}
+
+// Name implements the Handler interface.
+func (b *Blocklist) Name() string { return "blocklist" }
+
+// match returns the list that blocks qname, or nil if qname isn't blocked.
+func (b *Blocklist) match(qname string) *list {
+	if plugin.Zones(b.allowNames).Matches(qname) != "" {
+		return nil
+	}
+	idx := b.index.Load().(*index)
+	if idx.allow.match(qname) >= 0 {
+		return nil
+	}
+	if i := idx.block.match(qname); i >= 0 {
+		return b.lists[i]
+	}
+	return nil
+}
+
+// reply returns the reply for the blocked name in state.
+func (b *Blocklist) reply(state request.Request) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetReply(state.Req)
+	m.Authoritative = true
+
+	switch b.response {
+	case responseNXDomain:
+		m.Rcode = dns.RcodeNameError
+		return m
+	case responseNodata:
+		return m
+	}
+
+	hdr := dns.RR_Header{Name: state.QName(), Class: dns.ClassINET, Ttl: defaultTTL}
+	switch state.QType() {
+	case dns.TypeA:
+		hdr.Rrtype = dns.TypeA
+		if b.response == responseNull {
+			m.Answer = []dns.RR{&dns.A{Hdr: hdr, A: net.IPv4zero}}
+			break
+		}
+		for _, ip := range b.sinkhole {
+			if ip4 := ip.To4(); ip4 != nil {
+				m.Answer = append(m.Answer, &dns.A{Hdr: hdr, A: ip4})
+			}
+		}
+	case dns.TypeAAAA:
+		hdr.Rrtype = dns.TypeAAAA
+		if b.response == responseNull {
+			m.Answer = []dns.RR{&dns.AAAA{Hdr: hdr, AAAA: net.IPv6zero}}
+			break
+		}
+		for _, ip := range b.sinkhole {
+			if ip.To4() == nil {
+				m.Answer = append(m.Answer, &dns.AAAA{Hdr: hdr, AAAA: ip})
+			}
+		}
+	}
+	return m
+}
+
+// load (re)reads all lists and replaces the index when any of them changed. When force is true
+// the lists are read even if they didn't change.
+func (b *Blocklist) load(force bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !force && !changed(b.lists) && !changed(b.allowLists) {
+		return nil
+	}
+
+	idx := &index{block: newTrie(), allow: newTrie()}
+	counts := make([]int, len(b.lists))
+	for i, l := range b.lists {
+		i := i
+		before := idx.block.size
+		if err := l.read(func(name string) { idx.block.insert(name, i) }, func(name string) { idx.allow.insert(name, i) }); err != nil {
+			return err
+		}
+		counts[i] = idx.block.size - before
+	}
+	for i, l := range b.allowLists {
+		i := i
+		allow := func(name string) { idx.allow.insert(name, i) }
+		if err := l.read(allow, allow); err != nil {
+			return err
+		}
+	}
+
+	b.index.Store(idx)
+	for i, l := range b.lists {
+		entries.WithLabelValues(l.name).Set(float64(counts[i]))
+	}
+	return nil
+}
+
+// read reads the list from disk.
+func (l *list) read(block, allow func(string)) error {
+	f, err := os.Open(l.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := parseList(f, block, allow); err != nil {
+		return err
+	}
+	l.mtime = fi.ModTime()
+	l.size = fi.Size()
+	return nil
+}
+
+// changed returns true if any of the lists changed on disk, since it was last read.
+func changed(lists []*list) bool {
+	for _, l := range lists {
+		fi, err := os.Stat(l.path)
+		if err != nil {
+			continue
+		}
+		if !fi.ModTime().Equal(l.mtime) || fi.Size() != l.size {
+			return true
+		}
+	}
+	return false
+}
+
+// OnStartup starts reloading the lists.
+func (b *Blocklist) OnStartup() error {
+	if b.reload == 0 {
+		return nil
+	}
+	go func() {
+		tick := time.NewTicker(b.reload)
+		defer tick.Stop()
+		for {
+			select {
+			case <-b.stop:
+				return
+			case <-tick.C:
+				if err := b.load(false); err != nil {
+					log.Warningf("Failed to reload lists: %s", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// OnShutdown stops reloading the lists.
+func (b *Blocklist) OnShutdown() error {
+	close(b.stop)
+	return nil
+}
+
+const (
+	defaultTTL    = 3600
+	defaultReload = time.Minute
+)
@@ -0,0 +1,178 @@
+package blocklist
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+func newTestBlocklist(t *testing.T, r response, sinkhole ...string) *Blocklist {
+	dir := t.TempDir()
+	ads := filepath.Join(dir, "ads")
+	if err := os.WriteFile(ads, []byte("0.0.0.0 ads.example.org\n||tracker.example.org^\n@@||ok.tracker.example.org^\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	malware := filepath.Join(dir, "malware")
+	if err := os.WriteFile(malware, []byte("evil.example.net\nads.example.org\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	b := New()
+	b.lists = []*list{{name: "ads", path: ads}, {name: "malware", path: malware}}
+	b.allowNames = []string{"safe.evil.example.net."}
+	b.response = r
+	for _, s := range sinkhole {
+		b.sinkhole = append(b.sinkhole, net.ParseIP(s))
+	}
+	if err := b.load(true); err != nil {
+		t.Fatal(err)
+	}
+	b.Next = test.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = []dns.RR{test.A(r.Question[0].Name + " 300 IN A 192.0.2.1")}
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+	return b
+}
+
+func TestBlocklist(t *testing.T) {
+	b := newTestBlocklist(t, responseNXDomain)
+
+	for _, tc := range []struct {
+		qname   string
+		blocked bool
+	}{
+		{"ads.example.org.", true},
+		{"www.ads.example.org.", true},
+		{"tracker.example.org.", true},
+		{"ok.tracker.example.org.", false}, // allowed in the list itself
+		{"evil.example.net.", true},
+		{"safe.evil.example.net.", false}, // allowed in the configuration
+		{"example.org.", false},
+	} {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := b.ServeDNS(context.TODO(), rec, m); err != nil {
+			t.Fatalf("Expected no error for %s, got %s", tc.qname, err)
+		}
+		blocked := rec.Msg.Rcode == dns.RcodeNameError
+		if blocked != tc.blocked {
+			t.Errorf("Expected %s to be blocked: %t, got %t", tc.qname, tc.blocked, blocked)
+		}
+	}
+}
+
+func TestBlocklistResponse(t *testing.T) {
+	for _, tc := range []struct {
+		response response
+		sinkhole []string
+		qtype    uint16
+		rcode    int
+		answer   string
+	}{
+		{responseNXDomain, nil, dns.TypeA, dns.RcodeNameError, ""},
+		{responseNodata, nil, dns.TypeA, dns.RcodeSuccess, ""},
+		{responseNull, nil, dns.TypeA, dns.RcodeSuccess, "0.0.0.0"},
+		{responseNull, nil, dns.TypeAAAA, dns.RcodeSuccess, "::"},
+		{responseNull, nil, dns.TypeMX, dns.RcodeSuccess, ""},
+		{responseSinkhole, []string{"192.0.2.53", "2001:db8::53"}, dns.TypeA, dns.RcodeSuccess, "192.0.2.53"},
+		{responseSinkhole, []string{"192.0.2.53", "2001:db8::53"}, dns.TypeAAAA, dns.RcodeSuccess, "2001:db8::53"},
+		{responseSinkhole, []string{"192.0.2.53"}, dns.TypeAAAA, dns.RcodeSuccess, ""},
+	} {
+		b := newTestBlocklist(t, tc.response, tc.sinkhole...)
+		m := new(dns.Msg)
+		m.SetQuestion("ads.example.org.", tc.qtype)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		b.ServeDNS(context.TODO(), rec, m)
+
+		if rec.Msg.Rcode != tc.rcode {
+			t.Errorf("Expected rcode %d, got %d", tc.rcode, rec.Msg.Rcode)
+		}
+		if tc.answer == "" {
+			if len(rec.Msg.Answer) != 0 {
+				t.Errorf("Expected no answer, got %v", rec.Msg.Answer)
+			}
+			continue
+		}
+		if len(rec.Msg.Answer) != 1 {
+			t.Fatalf("Expected 1 answer, got %d", len(rec.Msg.Answer))
+		}
+		var ip net.IP
+		switch rr := rec.Msg.Answer[0].(type) {
+		case *dns.A:
+			ip = rr.A
+		case *dns.AAAA:
+			ip = rr.AAAA
+		}
+		if !ip.Equal(net.ParseIP(tc.answer)) {
+			t.Errorf("Expected answer %s, got %s", tc.answer, ip)
+		}
+	}
+}
+
+func TestBlocklistMetadata(t *testing.T) {
+	b := newTestBlocklist(t, responseNXDomain)
+
+	for _, tc := range []struct {
+		qname string
+		list  string
+	}{
+		{"ads.example.org.", "ads"}, // in both lists, the first wins
+		{"evil.example.net.", "malware"},
+		{"example.org.", ""},
+	} {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, dns.TypeA)
+		state := request.Request{W: &test.ResponseWriter{}, Req: m}
+
+		ctx := metadata.ContextWithMetadata(context.TODO())
+		ctx = b.Metadata(ctx, state)
+		f := metadata.ValueFunc(ctx, "blocklist/list")
+		if f == nil {
+			t.Fatal("Expected metadata function for blocklist/list")
+		}
+		if got := f(); got != tc.list {
+			t.Errorf("Expected list %q for %s, got %q", tc.list, tc.qname, got)
+		}
+	}
+}
+
+func TestBlocklistReload(t *testing.T) {
+	b := newTestBlocklist(t, responseNXDomain)
+	path := b.lists[1].path
+
+	if b.match("new.example.com.") != nil {
+		t.Fatal("Expected new.example.com. not to be blocked")
+	}
+	if err := os.WriteFile(path, []byte("new.example.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+
+	if err := b.load(false); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if l := b.match("new.example.com."); l == nil || l.name != "malware" {
+		t.Errorf("Expected new.example.com. to be blocked by malware, got %v", l)
+	}
+	if b.match("evil.example.net.") != nil {
+		t.Error("Expected evil.example.net. not to be blocked after reload")
+	}
+}
+
+var _ plugin.Handler = &Blocklist{}
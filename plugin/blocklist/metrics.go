@@ -0,0 +1,25 @@
+package blocklist
+
+import (
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// hits is the number of blocked queries, by the list that blocked them.
+	hits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "blocklist",
+		Name:      "hits_total",
+		Help:      "Counter of queries blocked, per list.",
+	}, []string{"server", "list"})
+	// entries is the number of names in each list.
+	entries = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "blocklist",
+		Name:      "entries",
+		Help:      "The number of names loaded from each list.",
+	}, []string{"list"})
+)
@@ -0,0 +1,96 @@
+package blocklist
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// parseList reads a list from r and calls block for every name that is blocked and allow for every
+// name that is explicitly allowed. Three formats are understood, and they may be mixed:
+//
+//	0.0.0.0 ads.example.org tracker.example.org   # hosts
+//	ads.example.org                               # domain
+//	||ads.example.org^                            # Adblock, "@@||name^" allows a name
+//
+// Lines that can't be parsed, or Adblock rules that are more than a domain match, are skipped.
+func parseList(r io.Reader, block, allow func(name string)) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '!' || line[0] == '[' {
+			continue // Adblock comments and headers.
+		}
+		if strings.HasPrefix(line, "@@||") {
+			if name, ok := adblockName(line[4:]); ok {
+				allow(name)
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "||") {
+			if name, ok := adblockName(line[2:]); ok {
+				block(name)
+			}
+			continue
+		}
+
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+			continue
+		case len(fields) == 1:
+			if name, ok := domainName(fields[0]); ok {
+				block(name)
+			}
+		case net.ParseIP(fields[0]) != nil:
+			for _, f := range fields[1:] {
+				if isLocalhost(f) {
+					continue
+				}
+				if name, ok := domainName(f); ok {
+					block(name)
+				}
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// adblockName returns the name of an Adblock rule, the part after "||". Only rules that match a
+// whole domain, optionally with options that we ignore, are supported.
+func adblockName(rule string) (string, bool) {
+	i := strings.IndexByte(rule, '^')
+	if i < 0 {
+		return "", false
+	}
+	if rest := rule[i+1:]; rest != "" && rest[0] != '$' {
+		return "", false
+	}
+	name := rule[:i]
+	if strings.ContainsAny(name, "*/") {
+		return "", false
+	}
+	return domainName(name)
+}
+
+// domainName returns s as a lower cased, fully qualified, domain name.
+func domainName(s string) (string, bool) {
+	if _, ok := dns.IsDomainName(s); !ok || s == "." || net.ParseIP(s) != nil {
+		return "", false
+	}
+	return dns.Fqdn(strings.ToLower(s)), true
+}
+
+func isLocalhost(s string) bool {
+	switch strings.ToLower(s) {
+	case "localhost", "localhost.localdomain", "local", "broadcasthost", "ip6-localhost", "ip6-loopback", "0.0.0.0":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package blocklist
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseList(t *testing.T) {
+	const input = `# hosts format
+127.0.0.1 localhost
+0.0.0.0 ads.example.org tracker.example.org # trailing comment
+::1 ip6-localhost
+! Adblock comment
+[Adblock Plus 2.0]
+||Banner.example.net^
+||cdn.example.net^$third-party
+||example.com/path^
+||*.example.com^
+@@||good.example.net^
+plain.example.com
+not_a domain with spaces
+`
+	var block, allow []string
+	err := parseList(strings.NewReader(input),
+		func(name string) { block = append(block, name) },
+		func(name string) { allow = append(allow, name) },
+	)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	expectBlock := []string{"ads.example.org.", "tracker.example.org.", "banner.example.net.", "cdn.example.net.", "plain.example.com."}
+	if !reflect.DeepEqual(block, expectBlock) {
+		t.Errorf("Expected blocked names %v, got %v", expectBlock, block)
+	}
+	expectAllow := []string{"good.example.net."}
+	if !reflect.DeepEqual(allow, expectAllow) {
+		t.Errorf("Expected allowed names %v, got %v", expectAllow, allow)
+	}
+}
@@ -0,0 +1,144 @@
+package blocklist
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+)
+
+var log = clog.NewWithPlugin("blocklist")
+
+func init() { plugin.Register("blocklist", setup) }
+
+func setup(c *caddy.Controller) error {
+	b, err := parse(c)
+	if err != nil {
+		return plugin.Error("blocklist", err)
+	}
+	if err := b.load(true); err != nil {
+		return plugin.Error("blocklist", err)
+	}
+
+	c.OnStartup(b.OnStartup)
+	c.OnShutdown(b.OnShutdown)
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		b.Next = next
+		return b
+	})
+
+	return nil
+}
+
+func parse(c *caddy.Controller) (*Blocklist, error) {
+	b := New()
+	config := dnsserver.GetConfig(c)
+	path := func(p string) string {
+		if !filepath.IsAbs(p) && config.Root != "" {
+			return filepath.Join(config.Root, p)
+		}
+		return p
+	}
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		b.Zones = plugin.OriginsFromArgsOrServerBlock(c.RemainingArgs(), c.ServerBlockKeys)
+		names := map[string]bool{}
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "list":
+				args := c.RemainingArgs()
+				if len(args) != 2 {
+					return nil, c.ArgErr()
+				}
+				if names[args[0]] {
+					return nil, c.Errf("duplicate list name %q", args[0])
+				}
+				names[args[0]] = true
+				b.lists = append(b.lists, &list{name: args[0], path: path(args[1])})
+			case "allow":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				for _, a := range args {
+					b.allowNames = append(b.allowNames, plugin.Name(a).Normalize())
+				}
+			case "allowlist":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				for _, a := range args {
+					b.allowLists = append(b.allowLists, &list{name: a, path: path(a)})
+				}
+			case "response":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				switch args[0] {
+				case "nxdomain":
+					b.response = responseNXDomain
+				case "nodata":
+					b.response = responseNodata
+				case "null":
+					b.response = responseNull
+				case "sinkhole":
+					b.response = responseSinkhole
+				default:
+					return nil, c.Errf("unknown response %q", args[0])
+				}
+				if b.response != responseSinkhole {
+					if len(args) != 1 {
+						return nil, c.ArgErr()
+					}
+					continue
+				}
+				if len(args) == 1 {
+					return nil, c.ArgErr()
+				}
+				b.sinkhole = nil
+				for _, a := range args[1:] {
+					ip := net.ParseIP(a)
+					if ip == nil {
+						return nil, c.Errf("invalid sinkhole address %q", a)
+					}
+					b.sinkhole = append(b.sinkhole, ip)
+				}
+			case "reload":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				d, err := time.ParseDuration(args[0])
+				if err != nil {
+					return nil, c.Errf("invalid duration %q", args[0])
+				}
+				if d < 0 {
+					return nil, c.Errf("invalid negative duration %q", args[0])
+				}
+				b.reload = d
+			default:
+				return nil, c.Errf("unknown property %q", c.Val())
+			}
+		}
+	}
+
+	if len(b.lists) == 0 {
+		return nil, fmt.Errorf("at least one list is required")
+	}
+	return b, nil
+}
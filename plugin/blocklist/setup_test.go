@@ -0,0 +1,126 @@
+package blocklist
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coredns/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ads")
+	if err := os.WriteFile(path, []byte("ads.example.org\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input     string
+		shouldErr bool
+		response  response
+		sinkhole  int
+		reload    time.Duration
+	}{
+		{`blocklist {
+			list ads ` + path + `
+		}`, false, responseNXDomain, 0, defaultReload},
+		{`blocklist example.org {
+			list ads ` + path + `
+			allow good.example.org
+			allowlist ` + path + `
+			response sinkhole 192.0.2.1 2001:db8::1
+			reload 0
+		}`, false, responseSinkhole, 2, 0},
+		{`blocklist {
+			list ads ` + path + `
+			response null
+			reload 10s
+		}`, false, responseNull, 0, 10 * time.Second},
+		{`blocklist {
+			list ads ` + path + `
+			response nodata
+		}`, false, responseNodata, 0, defaultReload},
+
+		// fails
+		{`blocklist`, true, 0, 0, 0},
+		{`blocklist {
+			list ads
+		}`, true, 0, 0, 0},
+		{`blocklist {
+			list ads ` + path + `
+			list ads ` + path + `
+		}`, true, 0, 0, 0},
+		{`blocklist {
+			list ads ` + path + `
+			response sinkhole
+		}`, true, 0, 0, 0},
+		{`blocklist {
+			list ads ` + path + `
+			response sinkhole not-an-ip
+		}`, true, 0, 0, 0},
+		{`blocklist {
+			list ads ` + path + `
+			response null 0.0.0.0
+		}`, true, 0, 0, 0},
+		{`blocklist {
+			list ads ` + path + `
+			response refused
+		}`, true, 0, 0, 0},
+		{`blocklist {
+			list ads ` + path + `
+			reload -1s
+		}`, true, 0, 0, 0},
+		{`blocklist {
+			list ads ` + path + `
+			allow
+		}`, true, 0, 0, 0},
+		{`blocklist {
+			list ads ` + path + `
+			unknown
+		}`, true, 0, 0, 0},
+		{`blocklist {
+			list ads ` + path + `
+		}
+		blocklist`, true, 0, 0, 0},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		b, err := parse(c)
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error but found none for input %s", i, test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error but found one for input %s, got: %v", i, test.input, err)
+			continue
+		}
+		if b.response != test.response {
+			t.Errorf("Test %d: expected response %d, got %d", i, test.response, b.response)
+		}
+		if len(b.sinkhole) != test.sinkhole {
+			t.Errorf("Test %d: expected %d sinkhole addresses, got %d", i, test.sinkhole, len(b.sinkhole))
+		}
+		if b.reload != test.reload {
+			t.Errorf("Test %d: expected reload %s, got %s", i, test.reload, b.reload)
+		}
+	}
+}
+
+func TestSetupMissingList(t *testing.T) {
+	c := caddy.NewTestController("dns", `blocklist {
+		list ads /does/not/exist
+	}`)
+	err := setup(c)
+	if err == nil {
+		t.Fatal("Expected error for missing list")
+	}
+	if !strings.Contains(err.Error(), "exist") {
+		t.Errorf("Expected error about missing file, got %s", err)
+	}
+}
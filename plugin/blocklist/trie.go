@@ -0,0 +1,82 @@
+package blocklist
+
+import "strings"
+
+// trie is a suffix trie of domain names: the path from the root is the name's labels from right to
+// left. A name matches when it, or one of its parents, was inserted.
+type trie struct {
+	root node
+	size int
+}
+
+type node struct {
+	children map[string]*node // allocated on the first child
+	list     int              // index of the list the name came from, -1 when this node isn't a name of its own.
+}
+
+func newTrie() *trie { return &trie{root: node{list: -1}} }
+
+// insert adds name, taken from list, to t. If name is already in t the first list is kept.
+func (t *trie) insert(name string, list int) {
+	n := &t.root
+	forEachLabel(name, func(label string) bool {
+		n = n.child(label, true)
+		return true
+	})
+	if n == &t.root {
+		return
+	}
+	if n.list < 0 {
+		n.list = list
+		t.size++
+	}
+}
+
+// match returns the list of the first inserted name that is equal to or a parent of name, it
+// returns -1 if there is none.
+func (t *trie) match(name string) int {
+	list := -1
+	n := &t.root
+	forEachLabel(name, func(label string) bool {
+		n = n.child(label, false)
+		if n == nil {
+			return false
+		}
+		if n.list >= 0 {
+			list = n.list
+			return false
+		}
+		return true
+	})
+	return list
+}
+
+// child returns the child of n with label. If it doesn't exist it is created when create is true,
+// otherwise nil is returned.
+func (n *node) child(label string, create bool) *node {
+	if c, ok := n.children[label]; ok || !create {
+		return c
+	}
+	if n.children == nil {
+		n.children = make(map[string]*node)
+	}
+	c := &node{list: -1}
+	n.children[label] = c
+	return c
+}
+
+// forEachLabel calls f for each label of the lower cased name, from right to left, until f returns false.
+// The root label is skipped.
+func forEachLabel(name string, f func(label string) bool) {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	for name != "" {
+		i := strings.LastIndexByte(name, '.')
+		if !f(name[i+1:]) {
+			return
+		}
+		if i < 0 {
+			return
+		}
+		name = name[:i]
+	}
+}
@@ -0,0 +1,73 @@
+package blocklist
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestTrie(t *testing.T) {
+	tr := newTrie()
+	tr.insert("ads.example.org.", 0)
+	tr.insert("tracker.example.org.", 1)
+	tr.insert("Example.NET.", 1)
+	tr.insert("ads.example.org.", 2) // duplicate, first list is kept.
+
+	if tr.size != 3 {
+		t.Errorf("Expected size 3, got %d", tr.size)
+	}
+
+	for _, tc := range []struct {
+		name string
+		list int
+	}{
+		{"ads.example.org.", 0},
+		{"www.ads.example.org.", 0},
+		{"tracker.example.org.", 1},
+		{"example.net.", 1},
+		{"a.b.EXAMPLE.net.", 1},
+		{"example.org.", -1},
+		{"badads.example.org.", -1},
+		{"org.", -1},
+		{".", -1},
+	} {
+		if got := tr.match(tc.name); got != tc.list {
+			t.Errorf("Expected match(%s) to be %d, got %d", tc.name, tc.list, got)
+		}
+	}
+}
+
+// benchNames returns n names, most of them directly below com., like a real blocklist.
+func benchNames(n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		if i%4 == 0 {
+			names[i] = "ads." + strconv.Itoa(i) + ".example.net."
+			continue
+		}
+		names[i] = "tracker-" + strconv.Itoa(i) + ".com."
+	}
+	return names
+}
+
+func BenchmarkTrieInsert(b *testing.B) {
+	names := benchNames(1000000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tr := newTrie()
+		for j, name := range names {
+			tr.insert(name, j%2)
+		}
+	}
+}
+
+func BenchmarkTrieMatch(b *testing.B) {
+	names := benchNames(1000000)
+	tr := newTrie()
+	for _, name := range names {
+		tr.insert(name, 0)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tr.match("www." + names[i%len(names)])
+	}
+}
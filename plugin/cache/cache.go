@@ -4,6 +4,7 @@ package cache
 import (
 	"hash/fnv"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/coredns/coredns/plugin"
@@ -38,6 +39,29 @@ type Cache struct {
 
 	staleUpTo time.Duration
 
+	// RFC 8767 client response timer and failure recheck timer, and when we last refreshed a stale item.
+	staleTimeout time.Duration
+	staleRecheck time.Duration
+	refreshes    *cache.Cache
+
+	// Keys of the stale items being refreshed right now, a single refresh runs per item.
+	inflightMu sync.Mutex
+	inflight   map[uint64]struct{}
+
+	// Per name TTL overrides and names that are never cached.
+	rules   []rule
+	nocache []string
+
+	// TTLs used for SERVFAIL and REFUSED responses, zero disables caching them.
+	failttl time.Duration
+	refttl  time.Duration
+
+	// remote is the optional second level cache.
+	remote *remote
+
+	// share is the name of the shared cache used, empty when this cache is private to the server block.
+	share string
+
 	// Testing.
 	now func() time.Time
 }
@@ -58,6 +82,9 @@ func New() *Cache {
 		prefetch:   0,
 		duration:   1 * time.Minute,
 		percentage: 10,
+		failttl:    minTTL,
+		refreshes:  cache.New(defaultCap),
+		inflight:   make(map[uint64]struct{}),
 		now:        time.Now,
 	}
 }
@@ -78,6 +105,11 @@ func key(qname string, m *dns.Msg, t response.Type) (bool, uint64) {
 	return true, hash(qname, m.Question[0].Qtype)
 }
 
+// isRefused returns true if m is a REFUSED response that may be cached.
+func isRefused(m *dns.Msg, t response.Type) bool {
+	return t == response.OtherError && m.Rcode == dns.RcodeRefused && !m.Truncated && len(m.Question) > 0
+}
+
 func hash(qname string, qtype uint16) uint64 {
 	h := fnv.New64()
 	h.Write([]byte{byte(qtype >> 8)})
@@ -147,18 +179,15 @@ func (w *ResponseWriter) WriteMsg(res *dns.Msg) error {
 
 	// key returns empty string for anything we don't want to cache.
 	hasKey, key := key(w.state.Name(), res, mt)
-
-	msgTTL := dnsutil.MinimalTTL(res, mt)
-	var duration time.Duration
-	if mt == response.NameError || mt == response.NoData {
-		duration = computeTTL(msgTTL, w.minnttl, w.nttl)
-	} else if mt == response.ServerError {
-		// use default ttl which is 5s
-		duration = minTTL
-	} else {
-		duration = computeTTL(msgTTL, w.minpttl, w.pttl)
+	if !hasKey && w.refttl > 0 && isRefused(res, mt) {
+		hasKey, key = true, hash(w.state.Name(), w.state.QType())
+	}
+	if w.noCache(w.state.Name()) {
+		hasKey = false
 	}
 
+	duration := w.ttlFor(w.state.Name(), w.state.QType(), res, mt)
+
 	if hasKey && duration > 0 {
 		if w.state.Match(res) {
 			w.set(res, key, mt, duration)
@@ -197,6 +226,7 @@ func (w *ResponseWriter) set(m *dns.Msg, key uint64, mt response.Type, duration
 		if w.pcache.Add(key, i) {
 			evictions.WithLabelValues(w.server, Success).Inc()
 		}
+		w.setRemote(w.state.Name(), w.state.QType(), i, w.server)
 		// when pre-fetching, remove the negative cache entry if it exists
 		if w.prefetch {
 			w.ncache.Remove(key)
@@ -207,9 +237,17 @@ func (w *ResponseWriter) set(m *dns.Msg, key uint64, mt response.Type, duration
 		if w.ncache.Add(key, i) {
 			evictions.WithLabelValues(w.server, Denial).Inc()
 		}
+		w.setRemote(w.state.Name(), w.state.QType(), i, w.server)
 
 	case response.OtherError:
-		// don't cache these
+		// don't cache these, unless we're asked to cache REFUSED.
+		if !isRefused(m, mt) {
+			break
+		}
+		i := newItem(m, w.now(), duration)
+		if w.ncache.Add(key, i) {
+			evictions.WithLabelValues(w.server, Denial).Inc()
+		}
 	default:
 		log.Warningf("Caching called with unknown classification: %d", mt)
 	}
@@ -231,6 +269,19 @@ const (
 	maxNTTL = dnsutil.MaximumDefaulTTL / 2
 	minNTTL = dnsutil.MinimalDefaultTTL
 
+	// maxFailTTL is the upper bound for caching SERVFAIL responses, see RFC 2308, Section 7.1.
+	maxFailTTL = 5 * time.Minute
+
+	// Defaults for the remote cache: how long we wait for it, and for how long we stop
+	// using it after it failed.
+	remoteTimeout = 50 * time.Millisecond
+	remoteBackoff = 5 * time.Second
+
+	// staleRecheck is the default time between refreshes of a stale item, see RFC 8767, Section 5.
+	staleRecheck = 30 * time.Second
+	// staleRefreshTimeout bounds a refresh of a stale item that continues after the query is answered.
+	staleRefreshTimeout = 5 * time.Second
+
 	defaultCap = 10000 // default capacity of the cache.
 
 	// Success is the class for caching positive caching.
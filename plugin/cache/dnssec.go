@@ -34,12 +34,12 @@ func filterRRSlice(rrs []dns.RR, ttl uint32, do, dup bool) []dns.RR {
 		if r.Header().Rrtype == dns.TypeOPT {
 			continue
 		}
-		r.Header().Ttl = ttl
+		// Copy before setting the TTL, rrs may be read by other queries at the same time.
 		if dup {
-			rs[j] = dns.Copy(r)
-		} else {
-			rs[j] = r
+			r = dns.Copy(r)
 		}
+		r.Header().Ttl = ttl
+		rs[j] = r
 		j++
 	}
 	return rs[:j]
@@ -35,6 +35,9 @@ func (c *Cache) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 
 	ttl := 0
 	i := c.getIgnoreTTL(now, state, server)
+	if i == nil {
+		i = c.getRemote(ctx, now, state, server)
+	}
 	if i != nil {
 		ttl = i.ttl(now)
 	}
@@ -42,6 +45,9 @@ func (c *Cache) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 		crr := &ResponseWriter{ResponseWriter: w, Cache: c, state: state, server: server, do: do}
 		return c.doRefresh(ctx, state, crr)
 	}
+	if ttl < 0 && c.staleTimeout > 0 {
+		return c.serveStaleAfterTimeout(ctx, w, r, state, i, ttl, server)
+	}
 	if ttl < 0 {
 		servedStale.WithLabelValues(server).Inc()
 		// Adjust the time to get a 0 TTL in the reply built from a stale item.
@@ -53,6 +59,9 @@ func (c *Cache) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 		go c.doPrefetch(ctx, state, cw, i, now)
 	}
 	resp := i.toMsg(r, now, do)
+	if c.share != "" {
+		c.clampTTL(resp, i, state.Name(), state.QType(), ttl < 0)
+	}
 	w.WriteMsg(resp)
 
 	return dns.RcodeSuccess, nil
@@ -110,17 +119,18 @@ func (c *Cache) get(now time.Time, state request.Request, server string) (*item,
 func (c *Cache) getIgnoreTTL(now time.Time, state request.Request, server string) *item {
 	k := hash(state.Name(), state.QType())
 	cacheRequests.WithLabelValues(server).Inc()
+	staleUpTo := c.staleUpToFor(state.Name(), state.QType())
 
 	if i, ok := c.ncache.Get(k); ok {
 		ttl := i.(*item).ttl(now)
-		if ttl > 0 || (c.staleUpTo > 0 && -ttl < int(c.staleUpTo.Seconds())) {
+		if ttl > 0 || (staleUpTo > 0 && -ttl < int(staleUpTo.Seconds())) {
 			cacheHits.WithLabelValues(server, Denial).Inc()
 			return i.(*item)
 		}
 	}
 	if i, ok := c.pcache.Get(k); ok {
 		ttl := i.(*item).ttl(now)
-		if ttl > 0 || (c.staleUpTo > 0 && -ttl < int(c.staleUpTo.Seconds())) {
+		if ttl > 0 || (staleUpTo > 0 && -ttl < int(staleUpTo.Seconds())) {
 			cacheHits.WithLabelValues(server, Success).Inc()
 			return i.(*item)
 		}
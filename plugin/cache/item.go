@@ -83,6 +83,22 @@ func (i *item) toMsg(m *dns.Msg, now time.Time, do bool) *dns.Msg {
 	return m1
 }
 
+// denial returns true if i holds a denial of existence or an error response.
+func (i *item) denial() bool {
+	if i.Rcode != dns.RcodeSuccess {
+		return true
+	}
+	if len(i.Answer) > 0 {
+		return false
+	}
+	for _, r := range i.Ns {
+		if r.Header().Rrtype == dns.TypeSOA {
+			return true
+		}
+	}
+	return false
+}
+
 func (i *item) ttl(now time.Time) int {
 	ttl := int(i.origTTL) - int(now.UTC().Sub(i.stored).Seconds())
 	return ttl
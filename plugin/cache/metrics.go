@@ -64,4 +64,32 @@ var (
 		Name:      "evictions_total",
 		Help:      "The count of cache evictions.",
 	}, []string{"server", "type"})
+	// remoteRequests is the counter of lookups in the remote cache.
+	remoteRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "cache",
+		Name:      "remote_requests_total",
+		Help:      "The count of remote cache requests.",
+	}, []string{"server"})
+	// remoteHits is the counter of remote cache hits.
+	remoteHits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "cache",
+		Name:      "remote_hits_total",
+		Help:      "The count of remote cache hits.",
+	}, []string{"server"})
+	// remoteErrors is the counter of failed remote cache operations.
+	remoteErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "cache",
+		Name:      "remote_errors_total",
+		Help:      "The count of failed remote cache operations.",
+	}, []string{"server"})
+	// remoteDropped is the counter of writes to the remote cache dropped because too many were pending.
+	remoteDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "cache",
+		Name:      "remote_dropped_total",
+		Help:      "The count of remote cache writes dropped because too many were pending.",
+	}, []string{"server"})
 )
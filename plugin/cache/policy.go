@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnsutil"
+	"github.com/coredns/coredns/plugin/pkg/response"
+
+	"github.com/miekg/dns"
+)
+
+// rule overrides the TTL bounds and the stale duration for names under zone. If qtypes is
+// not empty the rule only applies to those query types.
+type rule struct {
+	zone   string
+	qtypes map[uint16]struct{}
+
+	minttl time.Duration
+	maxttl time.Duration
+	stale  time.Duration // -1 when not set, then the server block's serve_stale is used.
+}
+
+func (r rule) matchType(qtype uint16) bool {
+	if len(r.qtypes) == 0 {
+		return true
+	}
+	_, ok := r.qtypes[qtype]
+	return ok
+}
+
+// rule returns the most specific rule for qname and qtype, or nil when no rule applies.
+// A longer zone is more specific, on equal zones a rule listing query types wins.
+func (c *Cache) rule(qname string, qtype uint16) *rule {
+	var best *rule
+	for i := range c.rules {
+		r := &c.rules[i]
+		if !dns.IsSubDomain(r.zone, qname) || !r.matchType(qtype) {
+			continue
+		}
+		if best == nil || len(r.zone) > len(best.zone) || (len(r.zone) == len(best.zone) && len(r.qtypes) > 0 && len(best.qtypes) == 0) {
+			best = r
+		}
+	}
+	return best
+}
+
+// noCache returns true if qname must never be cached.
+func (c *Cache) noCache(qname string) bool {
+	if len(c.nocache) == 0 {
+		return false
+	}
+	return plugin.Zones(c.nocache).Matches(qname) != ""
+}
+
+// staleUpToFor returns how long stale items for qname and qtype may be served.
+func (c *Cache) staleUpToFor(qname string, qtype uint16) time.Duration {
+	if r := c.rule(qname, qtype); r != nil && r.stale >= 0 {
+		return r.stale
+	}
+	return c.staleUpTo
+}
+
+// ttlFor returns for how long the response res, classified as mt, is cached.
+func (c *Cache) ttlFor(qname string, qtype uint16, res *dns.Msg, mt response.Type) time.Duration {
+	switch {
+	case mt == response.ServerError:
+		return c.failttl
+	case mt == response.OtherError && res.Rcode == dns.RcodeRefused:
+		return c.refttl
+	}
+
+	msgTTL := dnsutil.MinimalTTL(res, mt)
+	if r := c.rule(qname, qtype); r != nil {
+		return computeTTL(msgTTL, r.minttl, r.maxttl)
+	}
+	if mt == response.NameError || mt == response.NoData {
+		return computeTTL(msgTTL, c.minnttl, c.nttl)
+	}
+	return computeTTL(msgTTL, c.minpttl, c.pttl)
+}
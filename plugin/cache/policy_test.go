@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestRule(t *testing.T) {
+	c := New()
+	c.rules = []rule{
+		{zone: "example.org.", minttl: 1 * time.Second, maxttl: 10 * time.Second, stale: -1},
+		{zone: "example.org.", qtypes: map[uint16]struct{}{dns.TypeMX: {}}, minttl: 2 * time.Second, maxttl: 20 * time.Second, stale: -1},
+		{zone: "a.example.org.", minttl: 3 * time.Second, maxttl: 30 * time.Second, stale: time.Minute},
+	}
+
+	tests := []struct {
+		qname  string
+		qtype  uint16
+		maxttl time.Duration // 0 when no rule should match
+	}{
+		{"example.org.", dns.TypeA, 10 * time.Second},
+		{"www.example.org.", dns.TypeA, 10 * time.Second},
+		{"example.org.", dns.TypeMX, 20 * time.Second},
+		{"a.example.org.", dns.TypeMX, 30 * time.Second},
+		{"b.a.example.org.", dns.TypeA, 30 * time.Second},
+		{"example.net.", dns.TypeA, 0},
+	}
+	for i, tc := range tests {
+		r := c.rule(tc.qname, tc.qtype)
+		if tc.maxttl == 0 {
+			if r != nil {
+				t.Errorf("Test %d: expected no rule, got %v", i, r.zone)
+			}
+			continue
+		}
+		if r == nil {
+			t.Errorf("Test %d: expected rule, got none", i)
+			continue
+		}
+		if r.maxttl != tc.maxttl {
+			t.Errorf("Test %d: expected max TTL %s, got %s", i, tc.maxttl, r.maxttl)
+		}
+	}
+
+	c.staleUpTo = time.Hour
+	if d := c.staleUpToFor("a.example.org.", dns.TypeA); d != time.Minute {
+		t.Errorf("Expected stale %s, got %s", time.Minute, d)
+	}
+	if d := c.staleUpToFor("example.org.", dns.TypeA); d != time.Hour {
+		t.Errorf("Expected stale %s, got %s", time.Hour, d)
+	}
+}
+
+func TestRuleTTL(t *testing.T) {
+	c := New()
+	c.Next = ttlBackend(3600)
+	c.rules = []rule{{zone: "example.org.", minttl: 0, maxttl: 60 * time.Second, stale: -1}}
+
+	req := new(dns.Msg)
+	req.SetQuestion("example.org.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	c.ServeDNS(context.TODO(), rec, req)
+
+	if rec.Msg.Answer[0].Header().Ttl != 60 {
+		t.Errorf("Expected TTL of %d, got %d", 60, rec.Msg.Answer[0].Header().Ttl)
+	}
+}
+
+func TestNoCache(t *testing.T) {
+	c := New()
+	c.Next = ttlBackend(60)
+	c.nocache = []string{"example.org."}
+
+	req := new(dns.Msg)
+	req.SetQuestion("www.example.org.", dns.TypeA)
+	c.ServeDNS(context.TODO(), &test.ResponseWriter{}, req)
+
+	if c.pcache.Len() != 0 {
+		t.Errorf("Expected no items to be cached, got %d", c.pcache.Len())
+	}
+}
+
+func TestCacheErrors(t *testing.T) {
+	tests := []struct {
+		rcode   int
+		failttl time.Duration
+		refttl  time.Duration
+		cached  bool
+	}{
+		{dns.RcodeServerFailure, minTTL, 0, true},
+		{dns.RcodeServerFailure, 0, 0, false},
+		{dns.RcodeRefused, minTTL, 0, false},
+		{dns.RcodeRefused, minTTL, 10 * time.Second, true},
+	}
+	for i, tc := range tests {
+		c := New()
+		c.Next = rcodeBackend(tc.rcode)
+		c.failttl = tc.failttl
+		c.refttl = tc.refttl
+
+		req := new(dns.Msg)
+		req.SetQuestion("example.org.", dns.TypeA)
+		c.ServeDNS(context.TODO(), &test.ResponseWriter{}, req)
+
+		if cached := c.ncache.Len() == 1; cached != tc.cached {
+			t.Errorf("Test %d: expected cached to be %t, got %t", i, tc.cached, cached)
+		}
+	}
+}
+
+func rcodeBackend(rcode int) plugin.Handler {
+	return plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetRcode(r, rcode)
+		w.WriteMsg(m)
+		return rcode, nil
+	})
+}
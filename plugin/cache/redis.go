@@ -0,0 +1,235 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// redis is a Storage that speaks the Redis protocol (RESP). Only the GET, SET, AUTH and SELECT
+// commands are used, so any server implementing those will do.
+type redis struct {
+	addr     string
+	password string
+	db       int
+
+	sem   chan struct{} // one token per active connection
+	mu    sync.Mutex
+	conns []*redisConn // idle connections
+}
+
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+const (
+	// maxIdleConns is the number of idle connections to the Redis server kept open.
+	maxIdleConns = 16
+	// maxActiveConns is the number of connections to the Redis server that may be in use at once.
+	maxActiveConns = 64
+	// maxReplyLen is the largest bulk reply accepted from the Redis server: an item in wire format.
+	maxReplyLen = itemHeaderLen + dns.MaxMsgSize
+)
+
+// newRedis returns a Redis Storage from the URL u: redis://[:PASSWORD@]HOST[:PORT][/DB].
+func newRedis(u string) (*redis, error) {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+	if pu.Scheme != "redis" {
+		return nil, fmt.Errorf("unsupported remote cache scheme: %q", pu.Scheme)
+	}
+	if pu.Host == "" {
+		return nil, fmt.Errorf("no host in remote cache URL: %q", u)
+	}
+	r := &redis{addr: pu.Host, sem: make(chan struct{}, maxActiveConns)}
+	if _, _, err := net.SplitHostPort(r.addr); err != nil {
+		r.addr = net.JoinHostPort(r.addr, "6379")
+	}
+	if pu.User != nil {
+		r.password, _ = pu.User.Password()
+	}
+	if db := strings.TrimPrefix(pu.Path, "/"); db != "" {
+		r.db, err = strconv.Atoi(db)
+		if err != nil {
+			return nil, fmt.Errorf("invalid database in remote cache URL: %q", db)
+		}
+	}
+	return r, nil
+}
+
+// Get implements the Storage interface.
+func (r *redis) Get(ctx context.Context, key string) ([]byte, error) {
+	return r.do(ctx, "GET", key)
+}
+
+// Set implements the Storage interface.
+func (r *redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := r.do(ctx, "SET", key, string(value), "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	return err
+}
+
+// Close implements the Storage interface.
+func (r *redis) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.conns {
+		c.Close()
+	}
+	r.conns = nil
+	return nil
+}
+
+// do sends the command args to the server and returns the reply. A nil bulk string reply is
+// returned as ErrNotFound. When maxActiveConns connections are in use, do waits for one to become
+// available for as long as ctx allows.
+func (r *redis) do(ctx context.Context, args ...string) ([]byte, error) {
+	select {
+	case r.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-r.sem }()
+
+	c, err := r.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if d, ok := ctx.Deadline(); ok {
+		c.SetDeadline(d)
+	}
+	buf, err := c.do(args...)
+	if err != nil && err != ErrNotFound {
+		// We can't tell in what state the connection is, so don't reuse it.
+		c.Close()
+		return nil, err
+	}
+	c.SetDeadline(time.Time{})
+	r.put(c)
+	return buf, err
+}
+
+func (r *redis) get(ctx context.Context) (*redisConn, error) {
+	r.mu.Lock()
+	if n := len(r.conns); n > 0 {
+		c := r.conns[n-1]
+		r.conns = r.conns[:n-1]
+		r.mu.Unlock()
+		return c, nil
+	}
+	r.mu.Unlock()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", r.addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &redisConn{Conn: conn, r: bufio.NewReader(conn)}
+	if dl, ok := ctx.Deadline(); ok {
+		c.SetDeadline(dl)
+	}
+	if r.password != "" {
+		if _, err := c.do("AUTH", r.password); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	if r.db != 0 {
+		if _, err := c.do("SELECT", strconv.Itoa(r.db)); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (r *redis) put(c *redisConn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.conns) >= maxIdleConns {
+		c.Close()
+		return
+	}
+	r.conns = append(r.conns, c)
+}
+
+func (c *redisConn) do(args ...string) ([]byte, error) {
+	if _, err := c.Write(encodeCommand(args...)); err != nil {
+		return nil, err
+	}
+	return readReply(c.r)
+}
+
+// encodeCommand encodes args as a RESP array of bulk strings.
+func encodeCommand(args ...string) []byte {
+	b := make([]byte, 0, 64)
+	b = append(b, '*')
+	b = strconv.AppendInt(b, int64(len(args)), 10)
+	b = append(b, '\r', '\n')
+	for _, a := range args {
+		b = append(b, '$')
+		b = strconv.AppendInt(b, int64(len(a)), 10)
+		b = append(b, '\r', '\n')
+		b = append(b, a...)
+		b = append(b, '\r', '\n')
+	}
+	return b
+}
+
+// readReply reads a single RESP reply from r. Only simple strings, errors, integers and bulk strings
+// are supported, as these are the only replies the commands we use return.
+func readReply(r *bufio.Reader) ([]byte, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("empty reply")
+	}
+	switch line[0] {
+	case '+', ':':
+		return append([]byte(nil), line[1:]...), nil
+	case '-':
+		return nil, errors.New(string(line[1:]))
+	case '$':
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		if n == -1 {
+			return nil, ErrNotFound
+		}
+		if n < 0 || n > maxReplyLen {
+			return nil, fmt.Errorf("invalid bulk reply length: %d", n)
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+	return nil, fmt.Errorf("unsupported reply type: %q", line[0])
+}
+
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, errors.New("malformed reply")
+	}
+	return line[:len(line)-2], nil
+}
@@ -0,0 +1,341 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+// fakeRedis is an in-process stand-in for a Redis server that implements GET and SET.
+type fakeRedis struct {
+	ln net.Listener
+
+	mu sync.Mutex
+	m  map[string]string
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	f := &fakeRedis{ln: ln, m: make(map[string]string)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeRedis) Close() { f.ln.Close() }
+
+func (f *fakeRedis) URL() string { return "redis://" + f.ln.Addr().String() }
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		switch args[0] {
+		case "GET":
+			if v, ok := f.m[args[1]]; ok {
+				conn.Write([]byte("$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n"))
+			} else {
+				conn.Write([]byte("$-1\r\n"))
+			}
+		case "SET":
+			f.m[args[1]] = args[2]
+			conn.Write([]byte("+OK\r\n"))
+		default:
+			conn.Write([]byte("-ERR unknown command\r\n"))
+		}
+		f.mu.Unlock()
+	}
+}
+
+func (f *fakeRedis) len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.m)
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	n, _ := strconv.Atoi(string(line[1:]))
+	args := make([]string, n)
+	for i := range args {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		l, _ := strconv.Atoi(string(line[1:]))
+		buf := make([]byte, l+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:l])
+	}
+	return args, nil
+}
+
+func TestRedis(t *testing.T) {
+	f := newFakeRedis(t)
+	defer f.Close()
+
+	r, err := newRedis(f.URL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	ctx := context.TODO()
+	if _, err := r.Get(ctx, "key"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if err := r.Set(ctx, "key", []byte("val\r\nue"), time.Minute); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	v, err := r.Get(ctx, "key")
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if string(v) != "val\r\nue" {
+		t.Errorf("Expected %q, got %q", "val\r\nue", v)
+	}
+}
+
+func TestReadReply(t *testing.T) {
+	tests := []struct {
+		reply       string
+		expected    string
+		expectedErr bool
+	}{
+		{"+OK\r\n", "OK", false},
+		{":1\r\n", "1", false},
+		{"$5\r\nvalue\r\n", "value", false},
+		{"$-1\r\n", "", true},
+		{"-ERR failed\r\n", "", true},
+		{"$-2\r\n", "", true},
+		{"$" + strconv.Itoa(maxReplyLen+1) + "\r\n", "", true},
+		{"$2147483647\r\n", "", true},
+		{"*1\r\n", "", true},
+	}
+	for i, tc := range tests {
+		v, err := readReply(bufio.NewReader(strings.NewReader(tc.reply)))
+		if (err != nil) != tc.expectedErr {
+			t.Errorf("Test %d: expected error %t, got %v", i, tc.expectedErr, err)
+		}
+		if string(v) != tc.expected {
+			t.Errorf("Test %d: expected %q, got %q", i, tc.expected, v)
+		}
+	}
+}
+
+func TestRedisMaxActiveConns(t *testing.T) {
+	f := newFakeRedis(t)
+	defer f.Close()
+
+	r, err := newRedis(f.URL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// Take all connections, the next command must wait for one and give up when its context expires.
+	for i := 0; i < maxActiveConns; i++ {
+		r.sem <- struct{}{}
+	}
+	ctx, cancel := context.WithTimeout(context.TODO(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := r.Get(ctx, "key"); err != context.DeadlineExceeded {
+		t.Errorf("Expected %q, got %v", context.DeadlineExceeded, err)
+	}
+
+	<-r.sem
+	if _, err := r.Get(context.TODO(), "key"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound once a connection is available, got %v", err)
+	}
+}
+
+func TestNewRedis(t *testing.T) {
+	tests := []struct {
+		url       string
+		shouldErr bool
+		addr      string
+		password  string
+		db        int
+	}{
+		{"redis://localhost", false, "localhost:6379", "", 0},
+		{"redis://:secret@127.0.0.1:6380/2", false, "127.0.0.1:6380", "secret", 2},
+		{"http://localhost", true, "", "", 0},
+		{"redis://localhost/db", true, "", "", 0},
+		{"redis://", true, "", "", 0},
+	}
+	for i, tc := range tests {
+		r, err := newRedis(tc.url)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got nil", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if r.addr != tc.addr || r.password != tc.password || r.db != tc.db {
+			t.Errorf("Test %d: expected %s %q %d, got %s %q %d", i, tc.addr, tc.password, tc.db, r.addr, r.password, r.db)
+		}
+	}
+}
+
+func TestRemoteCache(t *testing.T) {
+	f := newFakeRedis(t)
+	defer f.Close()
+
+	newRemoteCache := func() *Cache {
+		r, err := newRedis(f.URL())
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := New()
+		c.remote = newRemote(r, time.Second, remoteBackoff)
+		return c
+	}
+
+	c1 := newRemoteCache()
+	c1.Next = ttlBackend(60)
+
+	req := new(dns.Msg)
+	req.SetQuestion("example.org.", dns.TypeA)
+	c1.ServeDNS(context.TODO(), &test.ResponseWriter{}, req)
+
+	// The remote is written to in the background.
+	for i := 0; i < 100 && f.len() == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if f.len() != 1 {
+		t.Fatalf("Expected 1 item in the remote cache, got %d", f.len())
+	}
+
+	// A second, cold, cache must answer from the remote without asking the backend.
+	c2 := newRemoteCache()
+	c2.Next = plugin.HandlerFunc(func(context.Context, dns.ResponseWriter, *dns.Msg) (int, error) {
+		return 255, nil // 255 means we tried querying upstream.
+	})
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	if ret, _ := c2.ServeDNS(context.TODO(), rec, req); ret != dns.RcodeSuccess {
+		t.Fatalf("Expected answer from the remote cache, got %d", ret)
+	}
+	if len(rec.Msg.Answer) != 1 {
+		t.Fatalf("Expected 1 answer, got %d", len(rec.Msg.Answer))
+	}
+	if ttl := rec.Msg.Answer[0].Header().Ttl; ttl > 60 || ttl < 58 {
+		t.Errorf("Expected TTL of about 60, got %d", ttl)
+	}
+	if c2.pcache.Len() != 1 {
+		t.Errorf("Expected remote item to be added to the local cache")
+	}
+}
+
+func TestRemoteCacheDown(t *testing.T) {
+	// Grab a free port and close it, so there is nothing listening.
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := ln.Addr().String()
+	ln.Close()
+
+	r, _ := newRedis("redis://" + addr)
+	c := New()
+	c.remote = newRemote(r, 100*time.Millisecond, time.Minute)
+	defer c.remote.Close()
+	c.Next = ttlBackend(60)
+
+	req := new(dns.Msg)
+	req.SetQuestion("example.org.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	if ret, _ := c.ServeDNS(context.TODO(), rec, req); ret != dns.RcodeSuccess {
+		t.Fatalf("Expected answer from the backend, got %d", ret)
+	}
+	if c.remote.available(time.Now()) {
+		t.Errorf("Expected remote cache to be marked as down")
+	}
+}
+
+// blockingStorage is a Storage whose Set blocks until release is closed.
+type blockingStorage struct{ release chan struct{} }
+
+func (b blockingStorage) Get(context.Context, string) ([]byte, error) { return nil, ErrNotFound }
+func (b blockingStorage) Set(ctx context.Context, _ string, _ []byte, _ time.Duration) error {
+	<-b.release
+	return nil
+}
+func (b blockingStorage) Close() error { return nil }
+
+func TestRemoteQueueFull(t *testing.T) {
+	st := blockingStorage{make(chan struct{})}
+	r := newRemote(st, time.Second, remoteBackoff)
+
+	// The writers each take one write and block on it, the rest fills up the queue.
+	dropped := 0
+	for i := 0; i < remoteWriters+remoteQueueSize+10; i++ {
+		if !r.enqueue(remoteWrite{key: strconv.Itoa(i)}) {
+			dropped++
+		}
+	}
+	if dropped < 10 {
+		t.Errorf("Expected at least 10 writes to be dropped, got %d", dropped)
+	}
+
+	close(st.release)
+	if err := r.Close(); err != nil {
+		t.Errorf("Expected no error on close, got %s", err)
+	}
+}
+
+func TestEncodeItem(t *testing.T) {
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	m.Answer = []dns.RR{test.A("example.org. 300 IN A 127.0.0.1")}
+	m.AuthenticatedData = true
+
+	now := time.Now().Truncate(time.Second)
+	i := newItem(m, now, 300*time.Second)
+	buf, err := encodeItem(i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i1, err := decodeItem(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !i1.stored.Equal(i.stored) || i1.origTTL != i.origTTL || !i1.AuthenticatedData || len(i1.Answer) != 1 {
+		t.Errorf("Expected decoded item to be equal to the encoded one")
+	}
+	if _, err := decodeItem(buf[:5]); err == nil {
+		t.Errorf("Expected error on short buffer")
+	}
+}
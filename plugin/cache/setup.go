@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/coredns/caddy"
@@ -11,6 +12,8 @@ import (
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/pkg/cache"
 	clog "github.com/coredns/coredns/plugin/pkg/log"
+
+	"github.com/miekg/dns"
 )
 
 var log = clog.NewWithPlugin("cache")
@@ -27,6 +30,13 @@ func setup(c *caddy.Controller) error {
 		return ca
 	})
 
+	if ca.remote != nil {
+		c.OnShutdown(ca.remote.Close)
+	}
+
+	// Named caches live for as long as this configuration, a reload starts with fresh ones.
+	c.OnRestart(sharedCaches.reset)
+
 	return nil
 }
 
@@ -179,15 +189,166 @@ func cacheParse(c *caddy.Controller) (*Cache, error) {
 					}
 					ca.staleUpTo = d
 				}
+			case "stale_answer_client_timeout":
+				args := c.RemainingArgs()
+				if len(args) == 0 || len(args) > 2 {
+					return nil, c.ArgErr()
+				}
+				d, err := time.ParseDuration(args[0])
+				if err != nil {
+					return nil, err
+				}
+				if d <= 0 {
+					return nil, fmt.Errorf("stale answer client timeout must be positive: %s", d)
+				}
+				ca.staleTimeout = d
+				ca.staleRecheck = staleRecheck
+				if len(args) == 2 {
+					d, err := time.ParseDuration(args[1])
+					if err != nil {
+						return nil, err
+					}
+					if d < 0 {
+						return nil, errors.New("invalid negative duration for stale recheck")
+					}
+					ca.staleRecheck = d
+				}
+			case "ttl":
+				r, err := parseRule(c)
+				if err != nil {
+					return nil, err
+				}
+				ca.rules = append(ca.rules, r)
+			case "nocache":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				ca.nocache = append(ca.nocache, plugin.OriginsFromArgsOrServerBlock(args, nil)...)
+			case "servfail", "refused":
+				what := c.Val()
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				d, err := time.ParseDuration(args[0])
+				if err != nil {
+					return nil, err
+				}
+				if d < 0 {
+					return nil, fmt.Errorf("invalid negative duration for %s", what)
+				}
+				if d > maxFailTTL {
+					return nil, fmt.Errorf("%s duration can not be larger than %s: %s", what, maxFailTTL, d)
+				}
+				if what == "servfail" {
+					ca.failttl = d
+				} else {
+					ca.refttl = d
+				}
+			case "remote":
+				args := c.RemainingArgs()
+				if len(args) == 0 || len(args) > 2 {
+					return nil, c.ArgErr()
+				}
+				st, err := newRedis(args[0])
+				if err != nil {
+					return nil, err
+				}
+				timeout := remoteTimeout
+				if len(args) == 2 {
+					d, err := time.ParseDuration(args[1])
+					if err != nil {
+						return nil, err
+					}
+					if d <= 0 {
+						return nil, fmt.Errorf("remote timeout must be positive: %s", d)
+					}
+					timeout = d
+				}
+				ca.remote = newRemote(st, timeout, remoteBackoff)
+			case "share":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				ca.share = args[0]
 			default:
 				return nil, c.ArgErr()
 			}
 		}
 
 		ca.Zones = origins
-		ca.pcache = cache.New(ca.pcap)
-		ca.ncache = cache.New(ca.ncap)
+		if ca.share == "" {
+			ca.pcache = cache.New(ca.pcap)
+			ca.ncache = cache.New(ca.ncap)
+			continue
+		}
+		s, err := sharedCaches.getOrCreate(ca.share, ca.pcap, ca.ncap)
+		if err != nil {
+			return nil, err
+		}
+		ca.pcache = s.pcache
+		ca.ncache = s.ncache
 	}
 
 	return ca, nil
 }
+
+// parseRule parses: ttl ZONE [QTYPE...] MINTTL MAXTTL [STALE]
+func parseRule(c *caddy.Controller) (rule, error) {
+	args := c.RemainingArgs()
+	if len(args) < 3 {
+		return rule{}, c.ArgErr()
+	}
+	r := rule{zone: plugin.Name(args[0]).Normalize(), stale: -1}
+	args = args[1:]
+
+	for len(args) > 0 {
+		t, ok := dns.StringToType[strings.ToUpper(args[0])]
+		if !ok {
+			break
+		}
+		if r.qtypes == nil {
+			r.qtypes = make(map[uint16]struct{})
+		}
+		r.qtypes[t] = struct{}{}
+		args = args[1:]
+	}
+	if len(args) < 2 || len(args) > 3 {
+		return rule{}, c.ArgErr()
+	}
+
+	minttl, err := strconv.Atoi(args[0])
+	if err != nil {
+		return rule{}, err
+	}
+	maxttl, err := strconv.Atoi(args[1])
+	if err != nil {
+		return rule{}, err
+	}
+	if minttl < 0 {
+		return rule{}, fmt.Errorf("cache min TTL can not be negative: %d", minttl)
+	}
+	// Reserve 0 (and smaller for future things)
+	if maxttl <= 0 {
+		return rule{}, fmt.Errorf("cache TTL can not be zero or negative: %d", maxttl)
+	}
+	if minttl > maxttl {
+		return rule{}, fmt.Errorf("cache min TTL %d is larger than max TTL %d", minttl, maxttl)
+	}
+	r.minttl = time.Duration(minttl) * time.Second
+	r.maxttl = time.Duration(maxttl) * time.Second
+
+	if len(args) == 3 {
+		d, err := time.ParseDuration(args[2])
+		if err != nil {
+			return rule{}, err
+		}
+		if d < 0 {
+			return rule{}, errors.New("invalid negative duration for stale")
+		}
+		r.stale = d
+	}
+	return r, nil
+}
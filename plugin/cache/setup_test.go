@@ -150,3 +150,170 @@ func TestServeStale(t *testing.T) {
 		}
 	}
 }
+
+func TestSetupShare(t *testing.T) {
+	defer sharedCaches.reset()
+
+	c1 := caddy.NewTestController("dns", "cache {\nshare common\n}")
+	ca1, err := cacheParse(c1)
+	if err != nil {
+		t.Fatalf("Expected no error but found error: %v", err)
+	}
+	c2 := caddy.NewTestController("dns", "cache 30 {\nshare common\n}")
+	ca2, err := cacheParse(c2)
+	if err != nil {
+		t.Fatalf("Expected no error but found error: %v", err)
+	}
+	if ca1.pcache != ca2.pcache || ca1.ncache != ca2.ncache {
+		t.Errorf("Expected caches to be shared")
+	}
+	if ca2.pttl != 30*time.Second {
+		t.Errorf("Expected pttl %v but found: %v", 30*time.Second, ca2.pttl)
+	}
+
+	c3 := caddy.NewTestController("dns", "cache {\nshare other\n}")
+	ca3, err := cacheParse(c3)
+	if err != nil {
+		t.Fatalf("Expected no error but found error: %v", err)
+	}
+	if ca1.pcache == ca3.pcache {
+		t.Errorf("Expected caches with different names not to be shared")
+	}
+
+	c4 := caddy.NewTestController("dns", "cache {\nshare common\nsuccess 2048\n}")
+	if _, err := cacheParse(c4); err == nil {
+		t.Errorf("Expected error for mismatched capacity, but found nil")
+	}
+	c5 := caddy.NewTestController("dns", "cache {\nshare\n}")
+	if _, err := cacheParse(c5); err == nil {
+		t.Errorf("Expected error for missing name, but found nil")
+	}
+}
+
+func TestSetupPolicy(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		rules     int
+		nocache   int
+		failttl   time.Duration
+		refttl    time.Duration
+	}{
+		{"ttl example.org 10 60", false, 1, 0, minTTL, 0},
+		{"ttl example.org A AAAA 10 60 5m", false, 1, 0, minTTL, 0},
+		{"ttl example.org 10 60\nttl example.net MX 0 30", false, 2, 0, minTTL, 0},
+		{"nocache example.org example.net", false, 0, 2, minTTL, 0},
+		{"servfail 0", false, 0, 0, 0, 0},
+		{"servfail 30s\nrefused 10s", false, 0, 0, 30 * time.Second, 10 * time.Second},
+		// fails
+		{"ttl example.org 10", true, 0, 0, 0, 0},
+		{"ttl example.org A 10", true, 0, 0, 0, 0},
+		{"ttl example.org 60 10", true, 0, 0, 0, 0},
+		{"ttl example.org 10 0", true, 0, 0, 0, 0},
+		{"ttl example.org 10 60 -1m", true, 0, 0, 0, 0},
+		{"ttl example.org 10 60 5m extra", true, 0, 0, 0, 0},
+		{"nocache", true, 0, 0, 0, 0},
+		{"servfail 10m", true, 0, 0, 0, 0},
+		{"refused -1s", true, 0, 0, 0, 0},
+	}
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", fmt.Sprintf("cache {\n%s\n}", test.input))
+		ca, err := cacheParse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %v: Expected error but found nil", i)
+			continue
+		} else if !test.shouldErr && err != nil {
+			t.Errorf("Test %v: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr && err != nil {
+			continue
+		}
+		if len(ca.rules) != test.rules {
+			t.Errorf("Test %v: Expected %d rules but found: %d", i, test.rules, len(ca.rules))
+		}
+		if len(ca.nocache) != test.nocache {
+			t.Errorf("Test %v: Expected %d nocache names but found: %d", i, test.nocache, len(ca.nocache))
+		}
+		if ca.failttl != test.failttl {
+			t.Errorf("Test %v: Expected servfail %v but found: %v", i, test.failttl, ca.failttl)
+		}
+		if ca.refttl != test.refttl {
+			t.Errorf("Test %v: Expected refused %v but found: %v", i, test.refttl, ca.refttl)
+		}
+	}
+}
+
+func TestSetupRemote(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		timeout   time.Duration
+	}{
+		{"remote redis://localhost", false, remoteTimeout},
+		{"remote redis://localhost:6380/1 200ms", false, 200 * time.Millisecond},
+		// fails
+		{"remote", true, 0},
+		{"remote memcache://localhost", true, 0},
+		{"remote redis://localhost 0s", true, 0},
+		{"remote redis://localhost 1s extra", true, 0},
+	}
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", fmt.Sprintf("cache {\n%s\n}", test.input))
+		ca, err := cacheParse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %v: Expected error but found nil", i)
+			continue
+		} else if !test.shouldErr && err != nil {
+			t.Errorf("Test %v: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr && err != nil {
+			continue
+		}
+		if ca.remote == nil {
+			t.Errorf("Test %v: Expected remote cache to be set", i)
+			continue
+		}
+		if ca.remote.timeout != test.timeout {
+			t.Errorf("Test %v: Expected timeout %v but found: %v", i, test.timeout, ca.remote.timeout)
+		}
+	}
+}
+
+func TestSetupStaleAnswerClientTimeout(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		timeout   time.Duration
+		recheck   time.Duration
+	}{
+		{"stale_answer_client_timeout 1800ms", false, 1800 * time.Millisecond, staleRecheck},
+		{"stale_answer_client_timeout 1s 10s", false, time.Second, 10 * time.Second},
+		// fails
+		{"stale_answer_client_timeout", true, 0, 0},
+		{"stale_answer_client_timeout 0s", true, 0, 0},
+		{"stale_answer_client_timeout 1s -1s", true, 0, 0},
+		{"stale_answer_client_timeout 1s 1s 1s", true, 0, 0},
+	}
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", fmt.Sprintf("cache {\nserve_stale\n%s\n}", test.input))
+		ca, err := cacheParse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %v: Expected error but found nil", i)
+			continue
+		} else if !test.shouldErr && err != nil {
+			t.Errorf("Test %v: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr && err != nil {
+			continue
+		}
+		if ca.staleTimeout != test.timeout {
+			t.Errorf("Test %v: Expected timeout %v but found: %v", i, test.timeout, ca.staleTimeout)
+		}
+		if ca.staleRecheck != test.recheck {
+			t.Errorf("Test %v: Expected recheck %v but found: %v", i, test.recheck, ca.staleRecheck)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/coredns/coredns/plugin/pkg/cache"
+
+	"github.com/miekg/dns"
+)
+
+// shared holds the positive and negative caches of a named cache instance. Every
+// server block that references the same name stores into and reads from these.
+type shared struct {
+	pcap   int
+	pcache *cache.Cache
+	ncap   int
+	ncache *cache.Cache
+}
+
+type sharedReg struct {
+	sync.Mutex
+	s map[string]*shared
+}
+
+func newSharedReg() *sharedReg { return &sharedReg{s: make(map[string]*shared)} }
+
+// getOrCreate returns the shared caches stored under name. If none exist yet they are created
+// with the given capacities. An error is returned when name was previously declared with
+// different capacities.
+func (r *sharedReg) getOrCreate(name string, pcap, ncap int) (*shared, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if s, ok := r.s[name]; ok {
+		if s.pcap != pcap || s.ncap != ncap {
+			return nil, fmt.Errorf("shared cache %q already declared with success %d and denial %d capacity", name, s.pcap, s.ncap)
+		}
+		return s, nil
+	}
+
+	s := &shared{pcap: pcap, pcache: cache.New(pcap), ncap: ncap, ncache: cache.New(ncap)}
+	r.s[name] = s
+	return s, nil
+}
+
+// reset forgets all named caches, the next configuration load will create new ones.
+func (r *sharedReg) reset() error {
+	r.Lock()
+	defer r.Unlock()
+	r.s = make(map[string]*shared)
+	return nil
+}
+
+var sharedCaches = newSharedReg()
+
+// clampTTL brings the TTLs in m within the minimum and maximum TTL configured for this server block.
+// Items in a shared cache may have been stored by a server block with other bounds. A stale reply
+// keeps its TTLs, those must stay at 0.
+func (c *Cache) clampTTL(m *dns.Msg, i *item, qname string, qtype uint16, stale bool) {
+	min, max := uint32(c.minpttl.Seconds()), uint32(c.pttl.Seconds())
+	if i.denial() {
+		min, max = uint32(c.minnttl.Seconds()), uint32(c.nttl.Seconds())
+	}
+	if r := c.rule(qname, qtype); r != nil {
+		min, max = uint32(r.minttl.Seconds()), uint32(r.maxttl.Seconds())
+	}
+	// Errors are cached for a fixed time, there is no minimum to raise them to.
+	if stale || (i.Rcode != dns.RcodeSuccess && i.Rcode != dns.RcodeNameError) {
+		min = 0
+	}
+	for _, s := range [][]dns.RR{m.Answer, m.Ns, m.Extra} {
+		for _, r := range s {
+			switch {
+			case r.Header().Ttl > max:
+				r.Header().Ttl = max
+			case r.Header().Ttl < min:
+				r.Header().Ttl = min
+			}
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestSharedCacheTTL(t *testing.T) {
+	defer sharedCaches.reset()
+
+	// Both blocks share the cache, but have a different minimum and maximum TTL.
+	low, err := cacheParse(caddy.NewTestController("dns", "cache {\nshare common\nsuccess 10000 3600 0\n}"))
+	if err != nil {
+		t.Fatalf("Expected no error but found error: %v", err)
+	}
+	high, err := cacheParse(caddy.NewTestController("dns", "cache {\nshare common\nsuccess 10000 120 60\n}"))
+	if err != nil {
+		t.Fatalf("Expected no error but found error: %v", err)
+	}
+	low.Zones, high.Zones = []string{"."}, []string{"."}
+	low.Next = ttlBackend(10)
+
+	req := new(dns.Msg)
+	req.SetQuestion("example.org.", dns.TypeA)
+
+	tests := []struct {
+		c           *Cache
+		expectedTTL uint32
+	}{
+		{low, 10},  // stored by low, with its own minimum
+		{high, 60}, // raised to the minimum of high
+		{low, 10},
+	}
+	for i, tc := range tests {
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		tc.c.ServeDNS(context.TODO(), rec, req.Copy())
+		if len(rec.Msg.Answer) != 1 {
+			t.Fatalf("Test %d: expected 1 answer, got %d", i, len(rec.Msg.Answer))
+		}
+		if ttl := rec.Msg.Answer[0].Header().Ttl; ttl != tc.expectedTTL {
+			t.Errorf("Test %d: expected TTL %d, got %d", i, tc.expectedTTL, ttl)
+		}
+	}
+
+	// An item stored by low is capped to the maximum of high when read by high.
+	sharedCaches.reset()
+	low, _ = cacheParse(caddy.NewTestController("dns", "cache {\nshare other\nsuccess 10000 3600 0\n}"))
+	high, _ = cacheParse(caddy.NewTestController("dns", "cache {\nshare other\nsuccess 10000 120 60\n}"))
+	low.Zones, high.Zones = []string{"."}, []string{"."}
+	low.Next = ttlBackend(3000)
+
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	low.ServeDNS(context.TODO(), rec, req.Copy())
+	rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	high.ServeDNS(context.TODO(), rec, req.Copy())
+	if ttl := rec.Msg.Answer[0].Header().Ttl; ttl != 120 {
+		t.Errorf("Expected TTL %d, got %d", 120, ttl)
+	}
+}
+
+func TestClampTTL(t *testing.T) {
+	c := New()
+	c.minpttl, c.pttl = 60*time.Second, 120*time.Second
+	c.minnttl, c.nttl = 60*time.Second, 120*time.Second
+
+	tests := []struct {
+		ttl         uint32
+		rcode       int
+		stale       bool
+		expectedTTL uint32
+	}{
+		{3000, dns.RcodeSuccess, false, 120},       // capped to the maximum
+		{10, dns.RcodeSuccess, false, 60},          // raised to the minimum
+		{90, dns.RcodeSuccess, false, 90},          // within bounds
+		{0, dns.RcodeSuccess, true, 0},             // stale, not raised
+		{10, dns.RcodeServerFailure, false, 10},    // error, not raised
+		{3000, dns.RcodeServerFailure, false, 120}, // error, capped
+	}
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		m.Rcode = tc.rcode
+		m.Answer = []dns.RR{test.A(fmt.Sprintf("example.org. %d IN A 127.0.0.53", tc.ttl))}
+		it := newItem(m, time.Now(), time.Duration(tc.ttl)*time.Second)
+
+		c.clampTTL(m, it, "example.org.", dns.TypeA, tc.stale)
+		if ttl := m.Answer[0].Header().Ttl; ttl != tc.expectedTTL {
+			t.Errorf("Test %d: expected TTL %d, got %d", i, tc.expectedTTL, ttl)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// serveStaleAfterTimeout implements the client response timer of RFC 8767. The stale item i is only
+// returned when the refresh of it doesn't produce a usable answer within c.staleTimeout: a refresh
+// that fails, or is answered with SERVFAIL or REFUSED, gets the stale answer too. A refresh that is
+// still running then completes in the background and updates the cache. After a failed refresh, the
+// name is not refreshed again for c.staleRecheck; in between i is returned right away. So is it while
+// another query is refreshing it.
+func (c *Cache) serveStaleAfterTimeout(ctx context.Context, w dns.ResponseWriter, r *dns.Msg, state request.Request, i *item, ttl int, server string) (int, error) {
+	do := state.Do()
+	// Adjust the time to get a 0 TTL in the reply built from a stale item.
+	now := c.now().UTC().Add(time.Duration(ttl) * time.Second)
+	key := hash(state.Name(), state.QType())
+
+	if !c.refreshAllowed(key, c.now()) || !c.startRefresh(key) {
+		servedStale.WithLabelValues(server).Inc()
+		w.WriteMsg(i.toMsg(r, now, do))
+		return dns.RcodeSuccess, nil
+	}
+
+	// The refresh goes through the prefetch writer, which caches the reply, and ends up in rw
+	// instead of with the client: only we write to the client.
+	rw := &refreshWriter{ResponseWriter: w}
+	cw := newPrefetchResponseWriter(server, state, c)
+	cw.ResponseWriter = rw
+	cw.prefetch = false
+	cw.do = do
+
+	type result struct {
+		rcode int
+		err   error
+		msg   *dns.Msg
+	}
+	done := make(chan result, 1)
+	go func() {
+		// The refresh outlives the query when it takes longer than c.staleTimeout.
+		rctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), staleRefreshTimeout)
+		defer cancel()
+
+		rcode, err := c.doRefresh(rctx, state, cw)
+		res := result{rcode, err, rw.msg}
+		if refreshFailed(res.msg) {
+			c.refreshes.Add(key, c.now())
+		} else {
+			c.refreshes.Remove(key)
+		}
+		c.endRefresh(key)
+		done <- res
+	}()
+
+	timer := time.NewTimer(c.staleTimeout)
+	defer timer.Stop()
+
+	select {
+	case res := <-done:
+		if !refreshFailed(res.msg) {
+			w.WriteMsg(res.msg)
+			return res.rcode, res.err
+		}
+		// The stale answer is better than an error.
+	case <-timer.C:
+	}
+
+	servedStale.WithLabelValues(server).Inc()
+	w.WriteMsg(i.toMsg(r, now, do))
+	return dns.RcodeSuccess, nil
+}
+
+// refreshAllowed returns true if a refresh for the item under key may be attempted at time now, that
+// is if the last refresh didn't fail less than c.staleRecheck ago.
+func (c *Cache) refreshAllowed(key uint64, now time.Time) bool {
+	v, ok := c.refreshes.Get(key)
+	return !ok || now.Sub(v.(time.Time)) >= c.staleRecheck
+}
+
+// startRefresh marks the item under key as being refreshed. It returns false if a refresh of it is
+// already running.
+func (c *Cache) startRefresh(key uint64) bool {
+	c.inflightMu.Lock()
+	defer c.inflightMu.Unlock()
+	if _, ok := c.inflight[key]; ok {
+		return false
+	}
+	c.inflight[key] = struct{}{}
+	return true
+}
+
+// endRefresh is called when the refresh started with startRefresh is done.
+func (c *Cache) endRefresh(key uint64) {
+	c.inflightMu.Lock()
+	defer c.inflightMu.Unlock()
+	delete(c.inflight, key)
+}
+
+// refreshFailed returns true if m, the reply to a refresh, can't be used instead of the stale item.
+func refreshFailed(m *dns.Msg) bool {
+	return m == nil || m.Rcode == dns.RcodeServerFailure || m.Rcode == dns.RcodeRefused
+}
+
+// refreshWriter keeps the reply to a refresh, instead of writing it to the client.
+type refreshWriter struct {
+	dns.ResponseWriter
+	msg *dns.Msg
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w *refreshWriter) WriteMsg(m *dns.Msg) error {
+	w.msg = m
+	return nil
+}
+
+// Write implements the dns.ResponseWriter interface.
+func (w *refreshWriter) Write(buf []byte) (int, error) {
+	m := new(dns.Msg)
+	if err := m.Unpack(buf); err != nil {
+		return 0, err
+	}
+	w.msg = m
+	return len(buf), nil
+}
@@ -0,0 +1,190 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// newStaleCache returns a cache holding a stale answer for example.org.
+func newStaleCache(t *testing.T) (*Cache, *dns.Msg) {
+	c := New()
+	c.Next = ttlBackend(60)
+	c.staleUpTo = time.Hour
+	c.staleTimeout = 20 * time.Millisecond
+	c.staleRecheck = staleRecheck
+
+	req := new(dns.Msg)
+	req.SetQuestion("example.org.", dns.TypeA)
+	c.ServeDNS(context.TODO(), &test.ResponseWriter{}, req)
+	if c.pcache.Len() != 1 {
+		t.Fatalf("Msg with > 0 TTL should have been cached")
+	}
+	c.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	return c, req
+}
+
+func TestStaleAnswerClientTimeoutFast(t *testing.T) {
+	c, req := newStaleCache(t)
+
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	c.ServeDNS(context.TODO(), rec, req)
+	if ttl := rec.Msg.Answer[0].Header().Ttl; ttl != 60 {
+		t.Errorf("Expected fresh answer with TTL 60, got %d", ttl)
+	}
+}
+
+func TestStaleAnswerClientTimeoutSlow(t *testing.T) {
+	c, req := newStaleCache(t)
+
+	done := make(chan struct{})
+	next := ttlBackend(60)
+	c.Next = plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		defer close(done)
+		time.Sleep(200 * time.Millisecond)
+		return next.ServeDNS(ctx, w, r)
+	})
+
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	c.ServeDNS(context.TODO(), rec, req)
+	if ttl := rec.Msg.Answer[0].Header().Ttl; ttl != 0 {
+		t.Errorf("Expected stale answer with TTL 0, got %d", ttl)
+	}
+
+	<-done
+	i := c.exists(request.Request{W: &test.ResponseWriter{}, Req: req})
+	if i == nil || i.ttl(c.now()) <= 0 {
+		t.Errorf("Expected the background refresh to update the cache")
+	}
+}
+
+func TestStaleAnswerClientTimeoutFailure(t *testing.T) {
+	c, req := newStaleCache(t)
+	c.Next = plugin.HandlerFunc(func(context.Context, dns.ResponseWriter, *dns.Msg) (int, error) {
+		return dns.RcodeServerFailure, nil
+	})
+
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	if ret, _ := c.ServeDNS(context.TODO(), rec, req); ret != dns.RcodeSuccess {
+		t.Errorf("Expected stale answer, got rcode %d", ret)
+	}
+	if rec.Msg == nil || len(rec.Msg.Answer) != 1 {
+		t.Errorf("Expected stale answer to be written")
+	}
+}
+
+func TestStaleAnswerRecheck(t *testing.T) {
+	c, req := newStaleCache(t)
+
+	var calls int32
+	c.Next = plugin.HandlerFunc(func(context.Context, dns.ResponseWriter, *dns.Msg) (int, error) {
+		atomic.AddInt32(&calls, 1)
+		return dns.RcodeServerFailure, nil
+	})
+
+	for i := 0; i < 3; i++ {
+		c.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), req)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("Expected 1 refresh within the recheck interval, got %d", n)
+	}
+}
+
+func TestStaleAnswerClientTimeoutServfail(t *testing.T) {
+	for _, rcode := range []int{dns.RcodeServerFailure, dns.RcodeRefused} {
+		c, req := newStaleCache(t)
+		c.Next = plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+			m := new(dns.Msg)
+			m.SetRcode(r, rcode)
+			w.WriteMsg(m)
+			return rcode, nil
+		})
+
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		if ret, _ := c.ServeDNS(context.TODO(), rec, req); ret != dns.RcodeSuccess {
+			t.Errorf("Expected stale answer instead of %s, got rcode %d", dns.RcodeToString[rcode], ret)
+		}
+		if rec.Msg == nil || rec.Msg.Rcode != dns.RcodeSuccess || len(rec.Msg.Answer) != 1 {
+			t.Errorf("Expected stale answer to be written instead of %s", dns.RcodeToString[rcode])
+		}
+	}
+}
+
+func TestStaleAnswerRecheckAfterSuccess(t *testing.T) {
+	c, req := newStaleCache(t)
+	key := hash("example.org.", dns.TypeA)
+
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	c.ServeDNS(context.TODO(), rec, req)
+	if !c.refreshAllowed(key, c.now()) {
+		t.Errorf("Expected no back off after a successful refresh")
+	}
+}
+
+func TestStaleAnswerRefreshOutlivesQuery(t *testing.T) {
+	c, req := newStaleCache(t)
+
+	errc := make(chan error, 1)
+	next := ttlBackend(60)
+	c.Next = plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		time.Sleep(100 * time.Millisecond)
+		errc <- ctx.Err()
+		return next.ServeDNS(ctx, w, r)
+	})
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	c.ServeDNS(ctx, dnstest.NewRecorder(&test.ResponseWriter{}), req)
+	cancel()
+
+	if err := <-errc; err != nil {
+		t.Errorf("Expected the refresh to continue after the query is done, got %s", err)
+	}
+}
+
+func TestStaleAnswerSingleRefresh(t *testing.T) {
+	c, req := newStaleCache(t)
+
+	var calls int32
+	unblock := make(chan struct{})
+	done := make(chan struct{})
+	next := ttlBackend(60)
+	c.Next = plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		defer close(done)
+		atomic.AddInt32(&calls, 1)
+		<-unblock
+		return next.ServeDNS(ctx, w, r)
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := dnstest.NewRecorder(&test.ResponseWriter{})
+			c.ServeDNS(context.TODO(), rec, req.Copy())
+			if rec.Msg == nil || len(rec.Msg.Answer) != 1 || rec.Msg.Answer[0].Header().Ttl != 0 {
+				t.Errorf("Expected stale answer while the upstream is blocked")
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("Expected 1 refresh while one is running, got %d", n)
+	}
+
+	close(unblock)
+	<-done
+	key := hash("example.org.", dns.TypeA)
+	if !c.startRefresh(key) {
+		t.Errorf("Expected no refresh running after it completed")
+	}
+}
@@ -0,0 +1,229 @@
+package cache
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// Storage is a remote store shared between CoreDNS instances. It is used as a second level (L2)
+// cache: it is consulted when the in-memory cache misses and is written to whenever the
+// in-memory cache is.
+type Storage interface {
+	// Get returns the value stored under key, or ErrNotFound if there is none.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set stores value under key, the store should expire it after ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Close releases all resources held.
+	Close() error
+}
+
+// ErrNotFound is returned by a Storage when a key does not exist.
+var ErrNotFound = errors.New("not found")
+
+// remote wraps a Storage and stops using it for a while after it fails, so a slow or
+// unavailable backend does not slow down every query. Writes are queued and done by a fixed
+// number of workers; when the queue is full they are dropped.
+type remote struct {
+	Storage
+
+	timeout time.Duration
+	backoff time.Duration
+	down    int64 // unix nano until which the backend is considered down, accessed atomically.
+
+	queue chan remoteWrite
+	stop  chan struct{}
+	wg    sync.WaitGroup
+	once  sync.Once
+}
+
+// remoteWrite is a write queued for the remote store.
+type remoteWrite struct {
+	key    string
+	qname  string
+	value  []byte
+	ttl    time.Duration
+	server string
+}
+
+const (
+	// remoteQueueSize is the number of writes that may be waiting for the remote store.
+	remoteQueueSize = 1024
+	// remoteWriters is the number of writes to the remote store that may be in flight.
+	remoteWriters = 4
+)
+
+// newRemote returns a remote for st and starts its writers.
+func newRemote(st Storage, timeout, backoff time.Duration) *remote {
+	r := &remote{
+		Storage: st,
+		timeout: timeout,
+		backoff: backoff,
+		queue:   make(chan remoteWrite, remoteQueueSize),
+		stop:    make(chan struct{}),
+	}
+	r.wg.Add(remoteWriters)
+	for i := 0; i < remoteWriters; i++ {
+		go r.write()
+	}
+	return r
+}
+
+// write performs the queued writes until the remote is closed.
+func (r *remote) write() {
+	defer r.wg.Done()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case w := <-r.queue:
+			if !r.available(time.Now()) {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+			err := r.Set(ctx, w.key, w.value, w.ttl)
+			cancel()
+			if err != nil {
+				remoteErrors.WithLabelValues(w.server).Inc()
+				log.Warningf("Failed to store %s in remote cache: %s", w.qname, err)
+				r.fail(time.Now())
+			}
+		}
+	}
+}
+
+// enqueue queues w, it returns false if the queue is full and w is dropped.
+func (r *remote) enqueue(w remoteWrite) bool {
+	select {
+	case r.queue <- w:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close stops the writers, pending writes are dropped, and closes the Storage.
+func (r *remote) Close() error {
+	r.once.Do(func() { close(r.stop) })
+	r.wg.Wait()
+	return r.Storage.Close()
+}
+
+func (r *remote) available(now time.Time) bool {
+	return now.UnixNano() >= atomic.LoadInt64(&r.down)
+}
+
+func (r *remote) fail(now time.Time) {
+	atomic.StoreInt64(&r.down, now.Add(r.backoff).UnixNano())
+}
+
+// remoteKey returns the key under which the message for qname and qtype is stored remotely.
+func remoteKey(qname string, qtype uint16) string {
+	return "coredns:cache:" + strconv.Itoa(int(qtype)) + ":" + qname
+}
+
+// getRemote looks up the message for state in the remote store. A found item is also added to the
+// in-memory cache.
+func (c *Cache) getRemote(ctx context.Context, now time.Time, state request.Request, server string) *item {
+	if c.remote == nil || !c.remote.available(now) {
+		return nil
+	}
+	remoteRequests.WithLabelValues(server).Inc()
+
+	ctx, cancel := context.WithTimeout(ctx, c.remote.timeout)
+	defer cancel()
+
+	buf, err := c.remote.Get(ctx, remoteKey(state.Name(), state.QType()))
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		remoteErrors.WithLabelValues(server).Inc()
+		log.Warningf("Failed to get %s from remote cache: %s", state.Name(), err)
+		c.remote.fail(now)
+		return nil
+	}
+	i, err := decodeItem(buf)
+	if err != nil {
+		remoteErrors.WithLabelValues(server).Inc()
+		return nil
+	}
+	ttl := i.ttl(now)
+	staleUpTo := c.staleUpToFor(state.Name(), state.QType())
+	if ttl <= 0 && (staleUpTo == 0 || -ttl >= int(staleUpTo.Seconds())) {
+		return nil
+	}
+	remoteHits.WithLabelValues(server).Inc()
+
+	k := hash(state.Name(), state.QType())
+	if i.denial() {
+		c.ncache.Add(k, i)
+	} else {
+		c.pcache.Add(k, i)
+	}
+	return i
+}
+
+// setRemote stores i in the remote store. This is done in the background, the client is
+// not kept waiting on the remote store. If too many writes are pending, i is not stored.
+func (c *Cache) setRemote(qname string, qtype uint16, i *item, server string) {
+	if c.remote == nil || !c.remote.available(c.now()) {
+		return
+	}
+	buf, err := encodeItem(i)
+	if err != nil {
+		return
+	}
+	// Keep the item around for as long as it may be served stale.
+	ttl := time.Duration(i.origTTL)*time.Second + c.staleUpToFor(qname, qtype)
+
+	if !c.remote.enqueue(remoteWrite{key: remoteKey(qname, qtype), qname: qname, value: buf, ttl: ttl, server: server}) {
+		remoteDropped.WithLabelValues(server).Inc()
+	}
+}
+
+// itemHeaderLen is the length of the header encodeItem puts before the packed message.
+const itemHeaderLen = 12
+
+// encodeItem returns i in wire format: the time i was stored (8 bytes, unix seconds), the original
+// TTL (4 bytes) and the packed message.
+func encodeItem(i *item) ([]byte, error) {
+	m := new(dns.Msg)
+	m.Rcode = i.Rcode
+	m.AuthenticatedData = i.AuthenticatedData
+	m.RecursionAvailable = i.RecursionAvailable
+	m.Answer = i.Answer
+	m.Ns = i.Ns
+	m.Extra = i.Extra
+
+	buf, err := m.Pack()
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, itemHeaderLen, itemHeaderLen+len(buf))
+	binary.BigEndian.PutUint64(b, uint64(i.stored.Unix()))
+	binary.BigEndian.PutUint32(b[8:], i.origTTL)
+	return append(b, buf...), nil
+}
+
+// decodeItem is the inverse of encodeItem.
+func decodeItem(b []byte) (*item, error) {
+	if len(b) < itemHeaderLen {
+		return nil, errors.New("short buffer")
+	}
+	m := new(dns.Msg)
+	if err := m.Unpack(b[itemHeaderLen:]); err != nil {
+		return nil, err
+	}
+	stored := time.Unix(int64(binary.BigEndian.Uint64(b)), 0)
+	ttl := time.Duration(binary.BigEndian.Uint32(b[8:])) * time.Second
+	return newItem(m, stored, ttl), nil
+}
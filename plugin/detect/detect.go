@@ -0,0 +1,159 @@
+// Package detect implements a plugin that detects DNS tunnelling and domain generation algorithms.
+package detect
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/plugin/metrics"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// Detect is a plugin that scores queries for signs of DNS tunnelling and DGA.
+type Detect struct {
+	Next  plugin.Handler
+	Zones []string
+
+	allow []string
+
+	entropy        float64
+	labelLength    int
+	nameLength     int
+	qtypes         map[uint16]bool
+	qtypeCount     int
+	subdomainCount int
+	window         time.Duration
+	baseLabels     int // zero uses the public suffix list
+
+	threshold int
+	action    action
+
+	windows *windows
+	now     func() time.Time
+}
+
+type action int
+
+const (
+	actionTag action = iota
+	actionLog
+	actionBlock
+)
+
+func (a action) String() string {
+	switch a {
+	case actionTag:
+		return "tag"
+	case actionLog:
+		return "log"
+	}
+	return "block"
+}
+
+const (
+	defaultEntropy        = 4.0
+	defaultLabelLength    = 52
+	defaultNameLength     = 150
+	defaultQtypeCount     = 50
+	defaultSubdomainCount = 100
+	defaultWindow         = time.Minute
+	defaultTableSize      = 10000
+)
+
+// New returns a new Detect with the default settings.
+func New() *Detect {
+	return &Detect{
+		Zones:          []string{"."},
+		entropy:        defaultEntropy,
+		labelLength:    defaultLabelLength,
+		nameLength:     defaultNameLength,
+		qtypes:         map[uint16]bool{dns.TypeTXT: true, dns.TypeNULL: true},
+		qtypeCount:     defaultQtypeCount,
+		subdomainCount: defaultSubdomainCount,
+		window:         defaultWindow,
+		threshold:      1,
+		action:         actionLog,
+		windows:        newWindows(defaultTableSize),
+		now:            time.Now,
+	}
+}
+
+// finding holds the signals that fired for a query. The query is scored at most once, either when the
+// metadata is first read or when the query reaches the plugin.
+type finding struct {
+	once    sync.Once
+	signals []string
+}
+
+type findingKey struct{}
+
+// finding returns the finding for the query in state, scoring the query if that didn't happen yet.
+func (d *Detect) finding(ctx context.Context, state request.Request) *finding {
+	f, ok := ctx.Value(findingKey{}).(*finding)
+	if !ok {
+		f = &finding{}
+	}
+	f.once.Do(func() {
+		if d.skip(state.Name()) {
+			return
+		}
+		f.signals = d.score(state, d.now())
+	})
+	return f
+}
+
+// skip returns true if qname isn't scored.
+func (d *Detect) skip(qname string) bool {
+	return plugin.Zones(d.Zones).Matches(qname) == "" || plugin.Zones(d.allow).Matches(qname) != ""
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (d *Detect) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+
+	f := d.finding(ctx, state)
+	if len(f.signals) < d.threshold {
+		return plugin.NextOrFailure(d.Name(), d.Next, ctx, w, r)
+	}
+
+	server := metrics.WithServer(ctx)
+	for _, s := range f.signals {
+		findings.WithLabelValues(server, s).Inc()
+	}
+	detected.WithLabelValues(server, d.action.String()).Inc()
+
+	if d.action >= actionLog {
+		log.Infof("%s %s from %s: %s", state.Name(), state.Type(), state.IP(), strings.Join(f.signals, ","))
+	}
+	if d.action == actionBlock {
+		m := new(dns.Msg)
+		m.SetRcode(r, dns.RcodeRefused)
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	}
+	return plugin.NextOrFailure(d.Name(), d.Next, ctx, w, r)
+}
+
+// Metadata implements the metadata.Provider interface.
+func (d *Detect) Metadata(ctx context.Context, state request.Request) context.Context {
+	f := &finding{}
+	ctx = context.WithValue(ctx, findingKey{}, f)
+
+	metadata.SetValueFunc(ctx, "detect/score", func() string {
+		return strconv.Itoa(len(d.finding(ctx, state).signals))
+	})
+	metadata.SetValueFunc(ctx, "detect/reasons", func() string {
+		return strings.Join(d.finding(ctx, state).signals, ",")
+	})
+	return ctx
+}
+
+// Name implements the Handler interface.
+func (d *Detect) Name() string { return "detect" }
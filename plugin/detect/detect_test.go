@@ -0,0 +1,85 @@
+package detect
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+func answer() test.HandlerFunc {
+	return test.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+}
+
+func TestDetect(t *testing.T) {
+	for _, tc := range []struct {
+		action    action
+		threshold int
+		allow     []string
+		qname     string
+		rcode     int
+	}{
+		{actionLog, 1, nil, "x7kq2p9zm4vb8n3c.example.org.", dns.RcodeSuccess},
+		{actionBlock, 1, nil, "www.example.org.", dns.RcodeSuccess},
+		{actionBlock, 1, nil, "x7kq2p9zm4vb8n3c.example.org.", dns.RcodeRefused},
+		{actionBlock, 2, nil, "x7kq2p9zm4vb8n3c.example.org.", dns.RcodeSuccess},
+		{actionBlock, 1, []string{"example.org."}, "x7kq2p9zm4vb8n3c.example.org.", dns.RcodeSuccess},
+	} {
+		d := New()
+		d.action = tc.action
+		d.threshold = tc.threshold
+		d.allow = tc.allow
+		d.Next = answer()
+
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		d.ServeDNS(context.TODO(), rec, m)
+
+		if rec.Msg.Rcode != tc.rcode {
+			t.Errorf("Expected rcode %d for %s with action %s, got %d", tc.rcode, tc.qname, tc.action, rec.Msg.Rcode)
+		}
+	}
+}
+
+func TestDetectMetadata(t *testing.T) {
+	d := New()
+	d.subdomainCount = 1
+	d.Next = answer()
+
+	m := new(dns.Msg)
+	m.SetQuestion("x7kq2p9zm4vb8n3c.example.org.", dns.TypeA)
+	state := request.Request{W: &test.ResponseWriter{}, Req: m}
+
+	ctx := metadata.ContextWithMetadata(context.TODO())
+	ctx = d.Metadata(ctx, state)
+
+	score := metadata.ValueFunc(ctx, "detect/score")
+	reasons := metadata.ValueFunc(ctx, "detect/reasons")
+	if score == nil || reasons == nil {
+		t.Fatal("Expected metadata functions for detect/score and detect/reasons")
+	}
+	if got := score(); got != "1" {
+		t.Errorf("Expected score 1, got %s", got)
+	}
+	if got := reasons(); got != signalEntropy {
+		t.Errorf("Expected reasons %s, got %s", signalEntropy, got)
+	}
+
+	// The query was already scored, serving it doesn't count the subdomain a second time.
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	d.ServeDNS(ctx, rec, m)
+	if got := reasons(); got != signalEntropy {
+		t.Errorf("Expected reasons %s after serving, got %s", signalEntropy, got)
+	}
+}
@@ -0,0 +1,25 @@
+package detect
+
+import (
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// findings is the number of signals that fired, for queries that reached the threshold.
+	findings = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "detect",
+		Name:      "findings_total",
+		Help:      "Counter of signals that fired for queries that reached the threshold.",
+	}, []string{"server", "signal"})
+	// detected is the number of queries that reached the threshold, by the action taken.
+	detected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "detect",
+		Name:      "detected_queries_total",
+		Help:      "Counter of queries that reached the threshold.",
+	}, []string{"server", "action"})
+)
@@ -0,0 +1,170 @@
+package detect
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/cache"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/publicsuffix"
+)
+
+// Signals, as used in the detect/reasons metadata and in metrics.
+const (
+	signalEntropy     = "entropy"
+	signalLabelLength = "label-length"
+	signalNameLength  = "name-length"
+	signalQtypes      = "qtypes"
+	signalSubdomains  = "subdomains"
+)
+
+// minEntropyLength is the minimum length of a subdomain for its entropy to be checked, the entropy
+// of short strings is low whatever their contents.
+const minEntropyLength = 16
+
+// score returns the signals that fire for the query in state. The per client counters are updated,
+// so it must be called once per query.
+func (d *Detect) score(state request.Request, now time.Time) []string {
+	var signals []string
+	qname := state.Name()
+	base, sub := d.split(qname)
+
+	if d.entropy > 0 && len(sub) >= minEntropyLength && entropy(sub) >= d.entropy {
+		signals = append(signals, signalEntropy)
+	}
+	if d.labelLength > 0 && longestLabel(qname) > d.labelLength {
+		signals = append(signals, signalLabelLength)
+	}
+	if d.nameLength > 0 && len(qname) > d.nameLength {
+		signals = append(signals, signalNameLength)
+	}
+
+	client := state.IP()
+	if d.qtypeCount > 0 && d.qtypes[state.QType()] {
+		w := d.windows.get(signalQtypes+"|"+client, now, d.window)
+		if w.count() > d.qtypeCount {
+			signals = append(signals, signalQtypes)
+		}
+	}
+	if d.subdomainCount > 0 && sub != "" {
+		w := d.windows.get(signalSubdomains+"|"+client+"|"+base, now, d.window)
+		if w.unique(sub, d.subdomainCount) > d.subdomainCount {
+			signals = append(signals, signalSubdomains)
+		}
+	}
+	return signals
+}
+
+// split splits qname in its base domain and the subdomain below it, without dots. The base domain is
+// the registered domain according to the public suffix list, or the last d.baseLabels labels.
+func (d *Detect) split(qname string) (base, sub string) {
+	labels := dns.SplitDomainName(qname)
+	n := d.baseLabels
+	if n == 0 {
+		n = registeredLabels(qname)
+	}
+	if len(labels) <= n {
+		return qname, ""
+	}
+	i := len(labels) - n
+	return strings.Join(labels[i:], ".") + ".", strings.Join(labels[:i], "")
+}
+
+// registeredLabels returns the number of labels of the registered domain of qname: its public suffix
+// and one label more. When qname is a public suffix itself, all of its labels are returned.
+func registeredLabels(qname string) int {
+	etld1, err := publicsuffix.EffectiveTLDPlusOne(strings.TrimSuffix(qname, "."))
+	if err != nil {
+		return dns.CountLabel(qname)
+	}
+	return dns.CountLabel(etld1 + ".")
+}
+
+// entropy returns the Shannon entropy of s, in bits per character.
+func entropy(s string) float64 {
+	var freq [256]int
+	for i := 0; i < len(s); i++ {
+		freq[s[i]]++
+	}
+	e := 0.0
+	n := float64(len(s))
+	for _, f := range freq {
+		if f == 0 {
+			continue
+		}
+		p := float64(f) / n
+		e -= p * math.Log2(p)
+	}
+	return e
+}
+
+// longestLabel returns the length of the longest label in name.
+func longestLabel(name string) int {
+	max := 0
+	for _, l := range dns.SplitDomainName(name) {
+		if len(l) > max {
+			max = len(l)
+		}
+	}
+	return max
+}
+
+// window counts queries, and the unique subdomains queried, in a fixed time window.
+type window struct {
+	sync.Mutex
+	start time.Time
+	n     int
+	seen  map[uint64]struct{}
+}
+
+// count counts a query and returns the number of queries in the current window.
+func (w *window) count() int {
+	w.Lock()
+	defer w.Unlock()
+	w.n++
+	return w.n
+}
+
+// unique records sub and returns the number of unique subdomains in the current window. At most
+// max+1 subdomains are remembered, that is enough to know the limit was exceeded.
+func (w *window) unique(sub string, max int) int {
+	w.Lock()
+	defer w.Unlock()
+	if len(w.seen) > max {
+		return len(w.seen)
+	}
+	if w.seen == nil {
+		w.seen = make(map[uint64]struct{})
+	}
+	w.seen[cache.Hash([]byte(sub))] = struct{}{}
+	return len(w.seen)
+}
+
+// windows holds the windows for all clients. It is bounded in size, when full random windows are
+// evicted.
+type windows struct {
+	c *cache.Cache
+}
+
+func newWindows(size int) *windows { return &windows{c: cache.New(size)} }
+
+// get returns the window for key, when the window expired a new one is started.
+func (t *windows) get(key string, now time.Time, length time.Duration) *window {
+	k := cache.Hash([]byte(key))
+	if v, ok := t.c.Get(k); ok {
+		w := v.(*window)
+		w.Lock()
+		expired := now.Sub(w.start) >= length
+		w.Unlock()
+		if !expired {
+			return w
+		}
+	}
+	w := &window{start: now}
+	t.c.Add(k, w)
+	return w
+}
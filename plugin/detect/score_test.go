@@ -0,0 +1,131 @@
+package detect
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+func TestEntropy(t *testing.T) {
+	for _, tc := range []struct {
+		in  string
+		out float64
+	}{
+		{"aaaa", 0},
+		{"abab", 1},
+		{"abcd", 2},
+		{"0123456789abcdef", 4},
+	} {
+		if got := entropy(tc.in); math.Abs(got-tc.out) > 1e-9 {
+			t.Errorf("Expected entropy(%s) to be %f, got %f", tc.in, tc.out, got)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	d := New()
+	for _, tc := range []struct {
+		qname string
+		base  string
+		sub   string
+	}{
+		{"example.org.", "example.org.", ""},
+		{"www.example.org.", "example.org.", "www"},
+		{"a.b.example.org.", "example.org.", "ab"},
+		{"org.", "org.", ""},
+		{"www.example.co.uk.", "example.co.uk.", "www"},
+		{"a1b2.c3.example.co.uk.", "example.co.uk.", "a1b2c3"},
+		{"example.co.uk.", "example.co.uk.", ""},
+		{"co.uk.", "co.uk.", ""},
+		{"host.svc.internal.", "svc.internal.", "host"},
+	} {
+		base, sub := d.split(tc.qname)
+		if base != tc.base || sub != tc.sub {
+			t.Errorf("Expected split(%s) to be %s, %s, got %s, %s", tc.qname, tc.base, tc.sub, base, sub)
+		}
+	}
+
+	// A fixed number of labels ignores the public suffix list.
+	d.baseLabels = 2
+	if base, sub := d.split("www.example.co.uk."); base != "co.uk." || sub != "wwwexample" {
+		t.Errorf("Expected split with 2 base labels to be co.uk., wwwexample, got %s, %s", base, sub)
+	}
+}
+
+func scoreQuery(d *Detect, qname string, qtype uint16, now time.Time) []string {
+	m := new(dns.Msg)
+	m.SetQuestion(qname, qtype)
+	return d.score(request.Request{W: &test.ResponseWriter{}, Req: m}, now)
+}
+
+func TestScore(t *testing.T) {
+	d := New()
+	now := time.Now()
+
+	for _, tc := range []struct {
+		qname   string
+		signals []string
+	}{
+		{"www.example.org.", nil},
+		{"mail-server-01.example.org.", nil},
+		{"x7kq2p9zm4vb8n3c.example.org.", []string{signalEntropy}},
+		{"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa.example.org.", []string{signalLabelLength}},
+	} {
+		if got := scoreQuery(d, tc.qname, dns.TypeA, now); !reflect.DeepEqual(got, tc.signals) {
+			t.Errorf("Expected signals %v for %s, got %v", tc.signals, tc.qname, got)
+		}
+	}
+}
+
+func TestScoreNameLength(t *testing.T) {
+	d := New()
+	d.nameLength = 20
+	if got := scoreQuery(d, "aaaa.bbbb.cccc.example.org.", dns.TypeA, time.Now()); !reflect.DeepEqual(got, []string{signalNameLength}) {
+		t.Errorf("Expected name-length signal, got %v", got)
+	}
+}
+
+func TestScoreQtypes(t *testing.T) {
+	d := New()
+	d.qtypeCount = 3
+	now := time.Now()
+
+	for i := 1; i <= 4; i++ {
+		got := scoreQuery(d, "t.example.org.", dns.TypeTXT, now)
+		if fired := len(got) > 0; fired != (i > 3) {
+			t.Errorf("Query %d: expected qtypes signal %t, got %v", i, i > 3, got)
+		}
+	}
+	// Other types aren't counted.
+	if got := scoreQuery(d, "t.example.org.", dns.TypeA, now); len(got) != 0 {
+		t.Errorf("Expected no signals for A query, got %v", got)
+	}
+	// A new window starts over.
+	if got := scoreQuery(d, "t.example.org.", dns.TypeTXT, now.Add(d.window)); len(got) != 0 {
+		t.Errorf("Expected no signals in a new window, got %v", got)
+	}
+}
+
+func TestScoreSubdomains(t *testing.T) {
+	d := New()
+	d.subdomainCount = 3
+	now := time.Now()
+
+	for i := 1; i <= 4; i++ {
+		got := scoreQuery(d, "q"+strconv.Itoa(i)+".tunnel.example.", dns.TypeA, now)
+		if fired := len(got) > 0; fired != (i > 3) {
+			t.Errorf("Query %d: expected subdomains signal %t, got %v", i, i > 3, got)
+		}
+	}
+	// Repeating a name doesn't count, and other base domains have their own count.
+	if got := scoreQuery(d, "q1.other.example.", dns.TypeA, now); len(got) != 0 {
+		t.Errorf("Expected no signals for other base domain, got %v", got)
+	}
+}
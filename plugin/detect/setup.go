@@ -0,0 +1,163 @@
+package detect
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+
+	"github.com/miekg/dns"
+)
+
+var log = clog.NewWithPlugin("detect")
+
+func init() { plugin.Register("detect", setup) }
+
+func setup(c *caddy.Controller) error {
+	d, err := parse(c)
+	if err != nil {
+		return plugin.Error("detect", err)
+	}
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		d.Next = next
+		return d
+	})
+
+	return nil
+}
+
+func parse(c *caddy.Controller) (*Detect, error) {
+	d := New()
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		d.Zones = plugin.OriginsFromArgsOrServerBlock(c.RemainingArgs(), c.ServerBlockKeys)
+		size := defaultTableSize
+
+		for c.NextBlock() {
+			var err error
+			switch c.Val() {
+			case "entropy":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				d.entropy, err = strconv.ParseFloat(args[0], 64)
+				if err != nil || d.entropy < 0 {
+					return nil, c.Errf("invalid entropy %q", args[0])
+				}
+			case "label-length":
+				if d.labelLength, err = intArg(c); err != nil {
+					return nil, err
+				}
+			case "name-length":
+				if d.nameLength, err = intArg(c); err != nil {
+					return nil, err
+				}
+			case "qtypes":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				d.qtypeCount, err = strconv.Atoi(args[0])
+				if err != nil || d.qtypeCount < 0 {
+					return nil, c.Errf("invalid count %q", args[0])
+				}
+				if len(args) > 1 {
+					d.qtypes = map[uint16]bool{}
+				}
+				for _, a := range args[1:] {
+					t, ok := dns.StringToType[strings.ToUpper(a)]
+					if !ok {
+						return nil, c.Errf("invalid query type %q", a)
+					}
+					d.qtypes[t] = true
+				}
+			case "subdomains":
+				if d.subdomainCount, err = intArg(c); err != nil {
+					return nil, err
+				}
+			case "window":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				d.window, err = time.ParseDuration(args[0])
+				if err != nil || d.window <= 0 {
+					return nil, c.Errf("invalid window %q", args[0])
+				}
+			case "base-labels":
+				if d.baseLabels, err = intArg(c); err != nil {
+					return nil, err
+				}
+				if d.baseLabels == 0 {
+					return nil, c.Errf("base-labels must be at least 1")
+				}
+			case "threshold":
+				if d.threshold, err = intArg(c); err != nil {
+					return nil, err
+				}
+				if d.threshold == 0 {
+					return nil, c.Errf("threshold must be at least 1")
+				}
+			case "allow":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				for _, a := range args {
+					d.allow = append(d.allow, plugin.Name(a).Normalize())
+				}
+			case "action":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				switch args[0] {
+				case "tag":
+					d.action = actionTag
+				case "log":
+					d.action = actionLog
+				case "block":
+					d.action = actionBlock
+				default:
+					return nil, c.Errf("unknown action %q", args[0])
+				}
+			case "max-clients":
+				if size, err = intArg(c); err != nil {
+					return nil, err
+				}
+				if size == 0 {
+					return nil, c.Errf("max-clients must be at least 1")
+				}
+			default:
+				return nil, c.Errf("unknown property %q", c.Val())
+			}
+		}
+		d.windows = newWindows(size)
+	}
+	return d, nil
+}
+
+// intArg parses the single, non-negative, integer argument of the current property.
+func intArg(c *caddy.Controller) (int, error) {
+	args := c.RemainingArgs()
+	if len(args) != 1 {
+		return 0, c.ArgErr()
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 0 {
+		return 0, c.Errf("invalid number %q", args[0])
+	}
+	return n, nil
+}
@@ -0,0 +1,100 @@
+package detect
+
+import (
+	"testing"
+	"time"
+
+	"github.com/coredns/caddy"
+
+	"github.com/miekg/dns"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		action    action
+		threshold int
+		window    time.Duration
+		qtype     uint16
+	}{
+		{`detect`, false, actionLog, 1, defaultWindow, dns.TypeTXT},
+		{`detect example.org {
+			entropy 3.5
+			label-length 40
+			name-length 0
+			qtypes 20 TXT NULL MX
+			subdomains 50
+			window 30s
+			base-labels 3
+			threshold 2
+			allow cdn.example.net
+			action block
+			max-clients 100
+		}`, false, actionBlock, 2, 30 * time.Second, dns.TypeMX},
+		{`detect {
+			action tag
+		}`, false, actionTag, 1, defaultWindow, dns.TypeNULL},
+
+		// fails
+		{`detect {
+			entropy high
+		}`, true, 0, 0, 0, 0},
+		{`detect {
+			label-length -1
+		}`, true, 0, 0, 0, 0},
+		{`detect {
+			qtypes 10 BOGUS
+		}`, true, 0, 0, 0, 0},
+		{`detect {
+			qtypes
+		}`, true, 0, 0, 0, 0},
+		{`detect {
+			window 0s
+		}`, true, 0, 0, 0, 0},
+		{`detect {
+			base-labels 0
+		}`, true, 0, 0, 0, 0},
+		{`detect {
+			threshold 0
+		}`, true, 0, 0, 0, 0},
+		{`detect {
+			action drop
+		}`, true, 0, 0, 0, 0},
+		{`detect {
+			max-clients 0
+		}`, true, 0, 0, 0, 0},
+		{`detect {
+			unknown
+		}`, true, 0, 0, 0, 0},
+		{`detect
+		detect`, true, 0, 0, 0, 0},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		d, err := parse(c)
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error but found none for input %s", i, test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error but found one for input %s, got: %v", i, test.input, err)
+			continue
+		}
+		if d.action != test.action {
+			t.Errorf("Test %d: expected action %s, got %s", i, test.action, d.action)
+		}
+		if d.threshold != test.threshold {
+			t.Errorf("Test %d: expected threshold %d, got %d", i, test.threshold, d.threshold)
+		}
+		if d.window != test.window {
+			t.Errorf("Test %d: expected window %s, got %s", i, test.window, d.window)
+		}
+		if !d.qtypes[test.qtype] {
+			t.Errorf("Test %d: expected query type %d to be counted", i, test.qtype)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Package dnscrypt implements a plugin that configures the provider of a DNSCrypt server.
+package dnscrypt
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnscrypt"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+)
+
+var log = clog.NewWithPlugin("dnscrypt")
+
+func init() { plugin.Register("dnscrypt", setup) }
+
+func setup(c *caddy.Controller) error {
+	r, err := parse(c)
+	if err != nil {
+		return plugin.Error("dnscrypt", err)
+	}
+	dnsserver.GetConfig(c).DNSCrypt = r
+	log.Infof("Provider %s with public key %s", r.ProviderName(), hex.EncodeToString(r.PublicKey()))
+	return nil
+}
+
+func parse(c *caddy.Controller) (*dnscrypt.Resolver, error) {
+	config := dnsserver.GetConfig(c)
+	if config.DNSCrypt != nil {
+		return nil, c.Errf("DNSCrypt already configured for this server instance")
+	}
+
+	var (
+		name, path string
+		esVersions []uint16
+		rotate     = defaultRotate
+	)
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		args := c.RemainingArgs()
+		if len(args) != 2 {
+			return nil, c.ArgErr()
+		}
+		name, path = args[0], args[1]
+		if !filepath.IsAbs(path) && config.Root != "" {
+			path = filepath.Join(config.Root, path)
+		}
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "es_version":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				for _, a := range args {
+					switch a {
+					case "xsalsa20poly1305":
+						esVersions = append(esVersions, dnscrypt.XSalsa20Poly1305)
+					case "xchacha20poly1305":
+						esVersions = append(esVersions, dnscrypt.XChacha20Poly1305)
+					default:
+						return nil, c.Errf("unknown encryption system %q", a)
+					}
+				}
+			case "rotate":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				d, err := time.ParseDuration(args[0])
+				if err != nil {
+					return nil, c.Errf("invalid duration %q", args[0])
+				}
+				if d < time.Minute {
+					return nil, c.Errf("rotate must be at least a minute, got %s", d)
+				}
+				rotate = d
+			default:
+				return nil, c.Errf("unknown property %q", c.Val())
+			}
+		}
+	}
+	if esVersions == nil {
+		esVersions = []uint16{dnscrypt.XSalsa20Poly1305, dnscrypt.XChacha20Poly1305}
+	}
+
+	key, err := readKey(path)
+	if err != nil {
+		return nil, err
+	}
+	return dnscrypt.New(name, key, esVersions, rotate)
+}
+
+// readKey reads the Ed25519 secret key of the provider from path. The file holds the 64 byte key, either
+// as is or hex encoded.
+func readKey(path string) (ed25519.PrivateKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != ed25519.PrivateKeySize {
+		b, err = hex.DecodeString(string(bytes.TrimSpace(b)))
+		if err != nil || len(b) != ed25519.PrivateKeySize {
+			return nil, fmt.Errorf("%s: not an Ed25519 secret key", path)
+		}
+	}
+	return ed25519.NewKeyFromSeed(b[:ed25519.SeedSize]), nil
+}
+
+const defaultRotate = 12 * time.Hour
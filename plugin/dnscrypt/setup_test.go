@@ -0,0 +1,71 @@
+package dnscrypt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+)
+
+func TestSetup(t *testing.T) {
+	dir := t.TempDir()
+	pub, key, _ := ed25519.GenerateKey(rand.Reader)
+	raw := filepath.Join(dir, "secret.key")
+	if err := os.WriteFile(raw, key, 0600); err != nil {
+		t.Fatal(err)
+	}
+	hexed := filepath.Join(dir, "secret.hex")
+	if err := os.WriteFile(hexed, []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	invalid := filepath.Join(dir, "invalid.key")
+	if err := os.WriteFile(invalid, []byte("not a key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input     string
+		expectErr bool
+	}{
+		{input: "dnscrypt 2.dnscrypt-cert.example.org " + raw},
+		{input: "dnscrypt 2.dnscrypt-cert.example.org " + hexed},
+		{input: "dnscrypt 2.dnscrypt-cert.example.org " + raw + " {\nes_version xchacha20poly1305\nrotate 1h\n}"},
+		{input: "dnscrypt 2.dnscrypt-cert.example.org", expectErr: true},
+		{input: "dnscrypt example.org " + raw, expectErr: true},
+		{input: "dnscrypt 2.dnscrypt-cert.example.org " + invalid, expectErr: true},
+		{input: "dnscrypt 2.dnscrypt-cert.example.org " + filepath.Join(dir, "missing.key"), expectErr: true},
+		{input: "dnscrypt 2.dnscrypt-cert.example.org " + raw + " {\nes_version aes\n}", expectErr: true},
+		{input: "dnscrypt 2.dnscrypt-cert.example.org " + raw + " {\nes_version\n}", expectErr: true},
+		{input: "dnscrypt 2.dnscrypt-cert.example.org " + raw + " {\nrotate 1s\n}", expectErr: true},
+		{input: "dnscrypt 2.dnscrypt-cert.example.org " + raw + " {\nrotate\n}", expectErr: true},
+		{input: "dnscrypt 2.dnscrypt-cert.example.org " + raw + " {\nunknown\n}", expectErr: true},
+		{input: "dnscrypt 2.dnscrypt-cert.example.org " + raw + "\ndnscrypt 2.dnscrypt-cert.example.org " + raw, expectErr: true},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		err := setup(c)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("Test %d: expected an error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		r := dnsserver.GetConfig(c).DNSCrypt
+		if r == nil {
+			t.Fatalf("Test %d: expected the DNSCrypt resolver to be configured", i)
+		}
+		if !pub.Equal(r.PublicKey()) {
+			t.Errorf("Test %d: expected public key %x, got %x", i, pub, r.PublicKey())
+		}
+	}
+}
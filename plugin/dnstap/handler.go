@@ -6,6 +6,8 @@ import (
 
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/dnstap/msg"
+	"github.com/coredns/coredns/plugin/pkg/replacer"
+	"github.com/coredns/coredns/request"
 
 	tap "github.com/dnstap/golang-dnstap"
 	"github.com/miekg/dns"
@@ -18,6 +20,9 @@ type Dnstap struct {
 
 	// IncludeRawMessage will include the raw DNS message into the dnstap messages if true.
 	IncludeRawMessage bool
+	// ExtraFormat is the format of the extra field of the dnstap messages, it may hold metadata labels.
+	ExtraFormat string
+	repl        replacer.Replacer
 }
 
 // TapMessage sends the message m to the dnstap interface.
@@ -26,7 +31,19 @@ func (h Dnstap) TapMessage(m *tap.Message) {
 	h.io.Dnstap(tap.Dnstap{Type: &t, Message: m})
 }
 
-func (h Dnstap) tapQuery(w dns.ResponseWriter, query *dns.Msg, queryTime time.Time) {
+// TapMessageWithMetadata sends the message m to the dnstap interface, with the extra field set from
+// ExtraFormat.
+func (h Dnstap) TapMessageWithMetadata(ctx context.Context, m *tap.Message, state request.Request) {
+	if h.ExtraFormat == "" {
+		h.TapMessage(m)
+		return
+	}
+	t := tap.Dnstap_MESSAGE
+	extra := h.repl.Replace(ctx, state, nil, h.ExtraFormat)
+	h.io.Dnstap(tap.Dnstap{Type: &t, Message: m, Extra: []byte(extra)})
+}
+
+func (h Dnstap) tapQuery(ctx context.Context, w dns.ResponseWriter, query *dns.Msg, queryTime time.Time) {
 	q := new(tap.Message)
 	msg.SetQueryTime(q, queryTime)
 	msg.SetQueryAddress(q, w.RemoteAddr())
@@ -36,7 +53,7 @@ func (h Dnstap) tapQuery(w dns.ResponseWriter, query *dns.Msg, queryTime time.Ti
 		q.QueryMessage = buf
 	}
 	msg.SetType(q, tap.Message_CLIENT_QUERY)
-	h.TapMessage(q)
+	h.TapMessageWithMetadata(ctx, q, request.Request{W: w, Req: query})
 }
 
 // ServeDNS logs the client query and response to dnstap and passes the dnstap Context.
@@ -44,13 +61,14 @@ func (h Dnstap) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 	rw := &ResponseWriter{
 		ResponseWriter: w,
 		Dnstap:         h,
+		ctx:            ctx,
 		query:          r,
 		queryTime:      time.Now(),
 	}
 
 	// The query tap message should be sent before sending the query to the
 	// forwarder. Otherwise, the tap messages will come out out of order.
-	h.tapQuery(w, r, rw.queryTime)
+	h.tapQuery(ctx, w, r, rw.queryTime)
 
 	return plugin.NextOrFailure(h.Name(), h.Next, ctx, rw, r)
 }
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/coredns/coredns/plugin/dnstap/msg"
+	"github.com/coredns/coredns/plugin/metadata"
 	test "github.com/coredns/coredns/plugin/test"
 
 	tap "github.com/dnstap/golang-dnstap"
@@ -83,3 +84,37 @@ func testMessage() *tap.Message {
 		QueryPort:      &port,
 	}
 }
+
+type extraWriter struct {
+	extra []string
+}
+
+func (w *extraWriter) Dnstap(e tap.Dnstap) { w.extra = append(w.extra, string(e.Extra)) }
+
+func TestDnstapExtra(t *testing.T) {
+	w := &extraWriter{}
+	h := Dnstap{
+		Next: test.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+			metadata.SetValueFunc(ctx, "test/label", func() string { return "value" })
+			return 0, w.WriteMsg(r)
+		}),
+		io:          w,
+		ExtraFormat: "{name} {/test/label}",
+	}
+	q := test.Case{Qname: "example.org.", Qtype: dns.TypeA}.Msg()
+	ctx := metadata.ContextWithMetadata(context.TODO())
+	if _, err := h.ServeDNS(ctx, &test.ResponseWriter{}, q); err != nil {
+		t.Fatal(err)
+	}
+
+	// The label is set after the query was tapped.
+	expect := []string{"example.org. -", "example.org. value"}
+	if len(w.extra) != len(expect) {
+		t.Fatalf("Expected %d messages, got %d", len(expect), len(w.extra))
+	}
+	for i := range expect {
+		if w.extra[i] != expect[i] {
+			t.Errorf("Expected extra %q, got %q", expect[i], w.extra[i])
+		}
+	}
+}
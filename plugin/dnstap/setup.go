@@ -17,11 +17,12 @@ func init() { plugin.Register("dnstap", setup) }
 func parseConfig(c *caddy.Controller) (Dnstap, error) {
 	c.Next() // directive name
 	d := Dnstap{}
-	endpoint := ""
 
-	if !c.Args(&endpoint) {
+	args := c.RemainingArgs()
+	if len(args) == 0 || len(args) > 2 {
 		return d, c.ArgErr()
 	}
+	endpoint := args[0]
 
 	if strings.HasPrefix(endpoint, "tcp://") {
 		// remote IP endpoint
@@ -37,7 +38,24 @@ func parseConfig(c *caddy.Controller) (Dnstap, error) {
 		d = Dnstap{io: dio}
 	}
 
-	d.IncludeRawMessage = c.NextArg() && c.Val() == "full"
+	if len(args) == 2 {
+		if args[1] != "full" {
+			return d, c.Errf("unknown argument '%s'", args[1])
+		}
+		d.IncludeRawMessage = true
+	}
+
+	for c.NextBlock() {
+		switch c.Val() {
+		case "extra":
+			if !c.NextArg() {
+				return d, c.ArgErr()
+			}
+			d.ExtraFormat = c.Val()
+		default:
+			return d, c.Errf("unknown property '%s'", c.Val())
+		}
+	}
 
 	return d, nil
 }
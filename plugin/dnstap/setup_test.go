@@ -12,12 +12,19 @@ func TestConfig(t *testing.T) {
 		endpoint string
 		full     bool
 		proto    string
+		extra    string
 		fail     bool
 	}{
-		{"dnstap dnstap.sock full", "dnstap.sock", true, "unix", false},
-		{"dnstap unix://dnstap.sock", "dnstap.sock", false, "unix", false},
-		{"dnstap tcp://127.0.0.1:6000", "127.0.0.1:6000", false, "tcp", false},
-		{"dnstap", "fail", false, "tcp", true},
+		{"dnstap dnstap.sock full", "dnstap.sock", true, "unix", "", false},
+		{"dnstap unix://dnstap.sock", "dnstap.sock", false, "unix", "", false},
+		{"dnstap tcp://127.0.0.1:6000", "127.0.0.1:6000", false, "tcp", "", false},
+		{"dnstap dnstap.sock full {\n extra \"{/detect/reasons}\"\n}", "dnstap.sock", true, "unix", "{/detect/reasons}", false},
+		{"dnstap", "fail", false, "tcp", "", true},
+		{"dnstap dnstap.sock {\n extra\n}", "fail", false, "unix", "", true},
+		{"dnstap dnstap.sock {\n unknown\n}", "fail", false, "unix", "", true},
+		{"dnstap dnstap.sock {\n extra \"{/detect/reasons}\"\n}", "dnstap.sock", false, "unix", "{/detect/reasons}", false},
+		{"dnstap dnstap.sock partial", "fail", false, "unix", "", true},
+		{"dnstap dnstap.sock full more", "fail", false, "unix", "", true},
 	}
 	for i, tc := range tests {
 		c := caddy.NewTestController("dns", tc.in)
@@ -41,5 +48,8 @@ func TestConfig(t *testing.T) {
 		if x := tap.IncludeRawMessage; x != tc.full {
 			t.Errorf("Test %d: expected IncludeRawMessage %t, got %t", i, tc.full, x)
 		}
+		if x := tap.ExtraFormat; x != tc.extra {
+			t.Errorf("Test %d: expected ExtraFormat %q, got %q", i, tc.extra, x)
+		}
 	}
 }
@@ -1,9 +1,11 @@
 package dnstap
 
 import (
+	"context"
 	"time"
 
 	"github.com/coredns/coredns/plugin/dnstap/msg"
+	"github.com/coredns/coredns/request"
 
 	tap "github.com/dnstap/golang-dnstap"
 	"github.com/miekg/dns"
@@ -11,6 +13,7 @@ import (
 
 // ResponseWriter captures the client response and logs the query to dnstap.
 type ResponseWriter struct {
+	ctx       context.Context
 	queryTime time.Time
 	query     *dns.Msg
 	dns.ResponseWriter
@@ -35,6 +38,6 @@ func (w *ResponseWriter) WriteMsg(resp *dns.Msg) error {
 	}
 
 	msg.SetType(r, tap.Message_CLIENT_RESPONSE)
-	w.TapMessage(r)
+	w.TapMessageWithMetadata(w.ctx, r, request.Request{W: w.ResponseWriter, Req: w.query})
 	return nil
 }
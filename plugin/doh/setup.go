@@ -0,0 +1,85 @@
+// Package doh implements a plugin that configures a DNS-over-HTTPS server.
+package doh
+
+import (
+	"strings"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/doh"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+)
+
+func init() { plugin.Register("doh", setup) }
+
+func setup(c *caddy.Controller) error {
+	dc, err := parse(c)
+	if err != nil {
+		return plugin.Error("doh", err)
+	}
+	dnsserver.GetConfig(c).DoH = dc
+	return nil
+}
+
+func parse(c *caddy.Controller) (*dnsserver.DoHConfig, error) {
+	config := dnsserver.GetConfig(c)
+	if config.Transport != transport.HTTPS {
+		return nil, c.Errf("doh requires a %s:// server, not %s://", transport.HTTPS, config.Transport)
+	}
+
+	dc := dnsserver.DefaultDoHConfig
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		if len(c.RemainingArgs()) != 0 {
+			return nil, c.ArgErr()
+		}
+		for c.NextBlock() {
+			switch c.Val() {
+			case "path":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				if !strings.HasPrefix(args[0], "/") {
+					return nil, c.Errf("path must start with a slash, got %q", args[0])
+				}
+				dc.Path = args[0]
+			case "json":
+				args := c.RemainingArgs()
+				switch len(args) {
+				case 0:
+					dc.JSONPath = doh.JSONPath
+				case 1:
+					if !strings.HasPrefix(args[0], "/") {
+						return nil, c.Errf("json path must start with a slash, got %q", args[0])
+					}
+					dc.JSONPath = args[0]
+				default:
+					return nil, c.ArgErr()
+				}
+			case "cors":
+				dc.CORSOrigins = c.RemainingArgs()
+				if len(dc.CORSOrigins) == 0 {
+					dc.CORSOrigins = []string{"*"}
+				}
+			case "h2c":
+				if len(c.RemainingArgs()) != 0 {
+					return nil, c.ArgErr()
+				}
+				dc.H2C = true
+			default:
+				return nil, c.Errf("unknown property %q", c.Val())
+			}
+		}
+	}
+	if dc.Path == dc.JSONPath {
+		return nil, c.Errf("path and json path are both %q", dc.Path)
+	}
+	return &dc, nil
+}
@@ -0,0 +1,62 @@
+package doh
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input       string
+		transport   string
+		shouldErr   bool
+		expected    dnsserver.DoHConfig
+		expectedErr string
+	}{
+		// positive
+		{"doh", transport.HTTPS, false, dnsserver.DoHConfig{Path: "/dns-query"}, ""},
+		{"doh {\npath /q\njson /json\n}", transport.HTTPS, false, dnsserver.DoHConfig{Path: "/q", JSONPath: "/json"}, ""},
+		{"doh {\njson\ncors\nh2c\n}", transport.HTTPS, false, dnsserver.DoHConfig{Path: "/dns-query", JSONPath: "/resolve", CORSOrigins: []string{"*"}, H2C: true}, ""},
+		{"doh {\ncors https://example.org https://example.net\n}", transport.HTTPS, false, dnsserver.DoHConfig{Path: "/dns-query", CORSOrigins: []string{"https://example.org", "https://example.net"}}, ""},
+		// negative
+		{"doh", transport.DNS, true, dnsserver.DoHConfig{}, "requires a https:// server"},
+		{"doh /dns-query", transport.HTTPS, true, dnsserver.DoHConfig{}, "Wrong argument count"},
+		{"doh {\npath\n}", transport.HTTPS, true, dnsserver.DoHConfig{}, "Wrong argument count"},
+		{"doh {\npath dns-query\n}", transport.HTTPS, true, dnsserver.DoHConfig{}, "path must start with a slash"},
+		{"doh {\njson resolve\n}", transport.HTTPS, true, dnsserver.DoHConfig{}, "json path must start with a slash"},
+		{"doh {\njson /dns-query\n}", transport.HTTPS, true, dnsserver.DoHConfig{}, "are both"},
+		{"doh {\njson /a /b\n}", transport.HTTPS, true, dnsserver.DoHConfig{}, "Wrong argument count"},
+		{"doh {\nh2c yes\n}", transport.HTTPS, true, dnsserver.DoHConfig{}, "Wrong argument count"},
+		{"doh {\nhttp3\n}", transport.HTTPS, true, dnsserver.DoHConfig{}, "unknown property"},
+		{"doh\ndoh", transport.HTTPS, true, dnsserver.DoHConfig{}, "plugin"},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		dnsserver.GetConfig(c).Transport = test.transport
+		err := setup(c)
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error but found none for input %s", i, test.input)
+			} else if !strings.Contains(err.Error(), test.expectedErr) {
+				t.Errorf("Test %d: expected error to contain: %v, found error: %v", i, test.expectedErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Test %d: expected no error but found one for input %s, got: %v", i, test.input, err)
+		}
+
+		dc := dnsserver.GetConfig(c).DoH
+		if dc.Path != test.expected.Path || dc.JSONPath != test.expected.JSONPath || dc.H2C != test.expected.H2C {
+			t.Errorf("Test %d: expected %+v, got %+v", i, test.expected, *dc)
+		}
+		if strings.Join(dc.CORSOrigins, " ") != strings.Join(test.expected.CORSOrigins, " ") {
+			t.Errorf("Test %d: expected CORS origins %v, got %v", i, test.expected.CORSOrigins, dc.CORSOrigins)
+		}
+	}
+}
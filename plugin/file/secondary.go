@@ -107,10 +107,17 @@ func less(a, b uint32) bool {
 // and uses the SOA parameters. Every refresh it will check for a new SOA number. If that fails (for all
 // server) it will retry every retry interval. If the zone failed to transfer before the expire, the zone
 // will be marked expired.
-func (z *Zone) Update() error {
+func (z *Zone) Update() error { return z.UpdateUntil(nil) }
+
+// UpdateUntil is like Update, but it returns when stop is closed.
+func (z *Zone) UpdateUntil(stop <-chan struct{}) error {
 	// If we don't have a SOA, we don't have a zone, wait for it to appear.
 	for z.Apex.SOA == nil {
-		time.Sleep(1 * time.Second)
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(1 * time.Second):
+		}
 	}
 	retryActive := false
 
@@ -125,6 +132,12 @@ Restart:
 
 	for {
 		select {
+		case <-stop:
+			refreshTicker.Stop()
+			retryTicker.Stop()
+			expireTicker.Stop()
+			return nil
+
 		case <-expireTicker.C:
 			if !retryActive {
 				break
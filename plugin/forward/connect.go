@@ -11,6 +11,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/coredns/coredns/plugin/pkg/edns"
 	"github.com/coredns/coredns/request"
 
 	"github.com/miekg/dns"
@@ -73,6 +74,10 @@ func (t *Transport) Dial(proto string) (*persistConn, bool, error) {
 
 // Connect selects an upstream, sends the request and waits for a response.
 func (p *Proxy) Connect(ctx context.Context, state request.Request, opts options) (*dns.Msg, error) {
+	if p.odoh != nil {
+		return p.connectODoH(ctx, state)
+	}
+
 	start := time.Now()
 
 	proto := ""
@@ -96,8 +101,15 @@ func (p *Proxy) Connect(ctx context.Context, state request.Request, opts options
 		pc.c.UDPSize = 512
 	}
 
+	// Pad queries over TLS, so their length doesn't give away the name (RFC 8467).
+	req := state.Req
+	padded := p.transport.tlsConfig != nil && opts.padding >= 0
+	if padded {
+		req = padQuery(state.Req, opts.padding)
+	}
+
 	pc.c.SetWriteDeadline(time.Now().Add(maxTimeout))
-	if err := pc.c.WriteMsg(state.Req); err != nil {
+	if err := pc.c.WriteMsg(req); err != nil {
 		pc.c.Close() // not giving it back
 		if err == io.EOF && cached {
 			return nil, ErrCachedClosed
@@ -124,6 +136,10 @@ func (p *Proxy) Connect(ctx context.Context, state request.Request, opts options
 
 	p.transport.Yield(pc)
 
+	if padded {
+		unpadResponse(ret, state.Req)
+	}
+
 	rc, ok := dns.RcodeToString[ret.Rcode]
 	if !ok {
 		rc = strconv.Itoa(ret.Rcode)
@@ -136,4 +152,36 @@ func (p *Proxy) Connect(ctx context.Context, state request.Request, opts options
 	return ret, nil
 }
 
+// padQuery returns a copy of r that is padded to a multiple of block, zero is the recommended 128
+// bytes. If r has no OPT record, one is added.
+func padQuery(r *dns.Msg, block int) *dns.Msg {
+	if block == 0 {
+		block = edns.QueryPaddingBlock
+	}
+	q := r.Copy()
+	if q.IsEdns0() == nil {
+		// Padding needs an OPT record, don't advertise a larger buffer than the client can take.
+		q.SetEdns0(dns.MinMsgSize, false)
+	}
+	edns.Pad(q, block)
+	return q
+}
+
+// unpadResponse removes the padding from the response m to the padded query for r, and the OPT
+// record if r didn't have one. The server pads the response again if the client wants that.
+func unpadResponse(m, r *dns.Msg) {
+	if r.IsEdns0() != nil {
+		edns.RemovePadding(m)
+		return
+	}
+	// Don't filter in place, the records may be shared with a cached response.
+	extra := make([]dns.RR, 0, len(m.Extra))
+	for _, rr := range m.Extra {
+		if rr.Header().Rrtype != dns.TypeOPT {
+			extra = append(extra, rr)
+		}
+	}
+	m.Extra = extra
+}
+
 const cumulativeAvgWeight = 4
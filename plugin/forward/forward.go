@@ -8,6 +8,7 @@ import (
 	"context"
 	"crypto/tls"
 	"errors"
+	"net/url"
 	"sync/atomic"
 	"time"
 
@@ -39,6 +40,7 @@ type Forward struct {
 
 	tlsConfig     *tls.Config
 	tlsServerName string
+	odohProxy     *url.URL
 	maxfails      uint32
 	expire        time.Duration
 	maxConcurrent int64
@@ -234,6 +236,7 @@ type options struct {
 	forceTCP           bool
 	preferUDP          bool
 	hcRecursionDesired bool
+	padding            int // block size of padded queries over TLS, zero is 128, negative is no padding
 }
 
 var defaultTimeout = 5 * time.Second
@@ -39,6 +39,9 @@ func NewHealthChecker(trans string, recursionDesired bool) HealthChecker {
 		c.WriteTimeout = hcWriteTimeout
 
 		return &dnsHc{c: c, recursionDesired: recursionDesired}
+
+	case odohTransport:
+		return &odohHc{recursionDesired: recursionDesired}
 	}
 
 	log.Warningf("No healthchecker for transport %q", trans)
@@ -14,6 +14,7 @@ import (
 )
 
 func TestHealth(t *testing.T) {
+	defer func(d time.Duration) { readTimeout = d }(readTimeout)
 	hcReadTimeout = 10 * time.Millisecond
 	hcWriteTimeout = 10 * time.Millisecond
 	readTimeout = 10 * time.Millisecond
@@ -53,6 +54,7 @@ func TestHealth(t *testing.T) {
 }
 
 func TestHealthNoRecursion(t *testing.T) {
+	defer func(d time.Duration) { readTimeout = d }(readTimeout)
 	hcReadTimeout = 10 * time.Millisecond
 	readTimeout = 10 * time.Millisecond
 	defaultTimeout = 10 * time.Millisecond
@@ -93,6 +95,7 @@ func TestHealthNoRecursion(t *testing.T) {
 }
 
 func TestHealthTimeout(t *testing.T) {
+	defer func(d time.Duration) { readTimeout = d }(readTimeout)
 	hcReadTimeout = 10 * time.Millisecond
 	hcWriteTimeout = 10 * time.Millisecond
 	readTimeout = 10 * time.Millisecond
@@ -137,6 +140,7 @@ func TestHealthTimeout(t *testing.T) {
 }
 
 func TestHealthMaxFails(t *testing.T) {
+	defer func(d time.Duration) { readTimeout = d }(readTimeout)
 	hcReadTimeout = 10 * time.Millisecond
 	hcWriteTimeout = 10 * time.Millisecond
 	readTimeout = 10 * time.Millisecond
@@ -167,6 +171,7 @@ func TestHealthMaxFails(t *testing.T) {
 }
 
 func TestHealthNoMaxFails(t *testing.T) {
+	defer func(d time.Duration) { readTimeout = d }(readTimeout)
 	hcReadTimeout = 10 * time.Millisecond
 	hcWriteTimeout = 10 * time.Millisecond
 	readTimeout = 10 * time.Millisecond
@@ -0,0 +1,267 @@
+package forward
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/doh"
+	"github.com/coredns/coredns/plugin/pkg/odoh"
+	"github.com/coredns/coredns/plugin/pkg/up"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// odohTransport is the scheme of Oblivious DoH targets in the TO list.
+const odohTransport = "odoh"
+
+// odohQueryPadding pads queries to a multiple of this size, as RFC 8467 recommends.
+const odohQueryPadding = 128
+
+// odohTimeout is how long an exchange with the target may take, including the hop through the proxy.
+const odohTimeout = 5 * time.Second
+
+// odohClient sends queries to an Oblivious DoH target, through an ODoH proxy if one is configured. The
+// proxy sees our address but not the queries, the target sees the queries but not our address.
+type odohClient struct {
+	target *url.URL
+	proxy  *url.URL // if nil, queries are sent directly to the target
+	client *http.Client
+
+	mu     sync.Mutex
+	config *odoh.Config // the target's configuration, fetched on first use
+}
+
+func newODoHClient(target *url.URL) *odohClient {
+	o := &odohClient{target: target}
+	o.SetTLSConfig(nil)
+	return o
+}
+
+// SetTLSConfig sets the TLS config used for the connections to the proxy and the target.
+func (o *odohClient) SetTLSConfig(cfg *tls.Config) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg != nil {
+		t.TLSClientConfig = cfg.Clone()
+		// The proxy and the target each have their own name, taken from the URL.
+		t.TLSClientConfig.ServerName = ""
+	}
+	o.client = &http.Client{Transport: t, Timeout: odohTimeout}
+}
+
+// Exchange encrypts m for the target, sends it and decrypts the response.
+func (o *odohClient) Exchange(ctx context.Context, m *dns.Msg) (*dns.Msg, error) {
+	config, err := o.targetConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := m.Pack()
+	if err != nil {
+		return nil, err
+	}
+	query, qc, err := config.EncryptQuery(buf, odohQueryPadding)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.queryURL(), bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", odoh.MimeType)
+	req.Header.Set("Accept", odoh.MimeType)
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized {
+		// The target has a new key, fetch its configuration again for the next query.
+		o.mu.Lock()
+		o.config = nil
+		o.mu.Unlock()
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("odoh: unexpected status %d from %s", resp.StatusCode, req.URL.Host)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, dns.MaxMsgSize+1024))
+	if err != nil {
+		return nil, err
+	}
+	buf, err = qc.DecryptResponse(body)
+	if err != nil {
+		return nil, err
+	}
+	ret := new(dns.Msg)
+	if err := ret.Unpack(buf); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// queryURL returns the URL queries are posted to, see section 4.1 of RFC 9230.
+func (o *odohClient) queryURL() string {
+	if o.proxy == nil {
+		return o.target.String()
+	}
+	u := *o.proxy
+	q := u.Query()
+	host := o.target.Host
+	if o.target.Port() == "443" {
+		host = o.target.Hostname()
+	}
+	q.Set("targethost", host)
+	q.Set("targetpath", o.target.Path)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// targetConfig returns the configuration of the target, fetching it when we don't have it. The
+// configuration is fetched from the target itself, this doesn't reveal any query to it.
+func (o *odohClient) targetConfig(ctx context.Context) (*odoh.Config, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.config != nil {
+		return o.config, nil
+	}
+
+	u := url.URL{Scheme: "https", Host: o.target.Host, Path: odoh.ConfigsPath}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("odoh: unexpected status %d fetching the configs from %s", resp.StatusCode, u.Host)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, dns.MaxMsgSize))
+	if err != nil {
+		return nil, err
+	}
+	config, err := odoh.ParseConfigs(body)
+	if err != nil {
+		return nil, err
+	}
+	o.config = config
+	return config, nil
+}
+
+// NewODoHProxy returns a new proxy for the Oblivious DoH target.
+func NewODoHProxy(target *url.URL) *Proxy {
+	p := &Proxy{
+		addr:      target.Host,
+		fails:     0,
+		probe:     up.New(),
+		transport: newTransport(target.Host),
+		odoh:      newODoHClient(target),
+	}
+	p.health = NewHealthChecker(odohTransport, true)
+	runtime.SetFinalizer(p, (*Proxy).finalizer)
+	return p
+}
+
+// parseODoHTarget parses an odoh://host[:port][/path] target into the https URL of the target. The port
+// defaults to 443 and the path to the DoH path.
+func parseODoHTarget(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != odohTransport || u.Host == "" || u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		return nil, fmt.Errorf("not a valid ODoH target: %s", s)
+	}
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), "443")
+	}
+	if u.Path == "" {
+		u.Path = doh.Path
+	}
+	u.Scheme = "https"
+	return u, nil
+}
+
+// parseODoHProxy parses the https URL of an Oblivious DoH proxy.
+func parseODoHProxy(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("not a valid ODoH proxy, it must be an https URL: %s", s)
+	}
+	return u, nil
+}
+
+// isODoH returns true if s is an Oblivious DoH target.
+func isODoH(s string) bool { return strings.HasPrefix(s, odohTransport+"://") }
+
+// connectODoH sends the request to the Oblivious DoH target of p.
+func (p *Proxy) connectODoH(ctx context.Context, state request.Request) (*dns.Msg, error) {
+	start := time.Now()
+
+	ret, err := p.odoh.Exchange(ctx, state.Req)
+	if err != nil {
+		return nil, err
+	}
+
+	rc, ok := dns.RcodeToString[ret.Rcode]
+	if !ok {
+		rc = strconv.Itoa(ret.Rcode)
+	}
+
+	RequestCount.WithLabelValues(p.addr).Add(1)
+	RcodeCount.WithLabelValues(rc, p.addr).Add(1)
+	RequestDuration.WithLabelValues(p.addr, rc).Observe(time.Since(start).Seconds())
+
+	return ret, nil
+}
+
+// odohHc is a health checker for an Oblivious DoH target, it sends its queries through the proxy too.
+type odohHc struct {
+	recursionDesired bool
+}
+
+func (h *odohHc) SetTLSConfig(cfg *tls.Config) {}
+
+func (h *odohHc) SetRecursionDesired(recursionDesired bool) {
+	h.recursionDesired = recursionDesired
+}
+func (h *odohHc) GetRecursionDesired() bool {
+	return h.recursionDesired
+}
+
+// Check is used as the up.Func in the up.Probe.
+func (h *odohHc) Check(p *Proxy) error {
+	ping := new(dns.Msg)
+	ping.SetQuestion(".", dns.TypeNS)
+	ping.MsgHdr.RecursionDesired = h.recursionDesired
+
+	ctx, cancel := context.WithTimeout(context.Background(), hcReadTimeout+hcWriteTimeout)
+	defer cancel()
+	if _, err := p.odoh.Exchange(ctx, ping); err != nil {
+		HealthcheckFailureCount.WithLabelValues(p.addr).Add(1)
+		atomic.AddUint32(&p.fails, 1)
+		return err
+	}
+
+	atomic.StoreUint32(&p.fails, 0)
+	return nil
+}
@@ -0,0 +1,186 @@
+package forward
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/pkg/odoh"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+func TestSetupODoH(t *testing.T) {
+	tests := []struct {
+		input          string
+		shouldErr      bool
+		expectedTarget string
+		expectedProxy  string
+		expectedErr    string
+	}{
+		{"forward . odoh://odoh.example.org", false, "https://odoh.example.org:443/dns-query", "", ""},
+		{"forward . odoh://odoh.example.org:8443/odoh", false, "https://odoh.example.org:8443/odoh", "", ""},
+		{"forward . odoh://odoh.example.org {\nodoh_proxy https://proxy.example.net/proxy\n}\n", false, "https://odoh.example.org:443/dns-query", "https://proxy.example.net/proxy", ""},
+		// negative
+		{"forward . odoh://", true, "", "", "not a valid ODoH target"},
+		{"forward . odoh://odoh.example.org/?dns=1", true, "", "", "not a valid ODoH target"},
+		{"forward . odoh://odoh.example.org {\nodoh_proxy http://proxy.example.net/proxy\n}\n", true, "", "", "must be an https URL"},
+		{"forward . odoh://odoh.example.org {\nodoh_proxy\n}\n", true, "", "", "Wrong argument count"},
+		{"forward . 127.0.0.1 {\nodoh_proxy https://proxy.example.net/proxy\n}\n", true, "", "", "odoh_proxy requires"},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		f, err := parseForward(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error but found none for input %s", i, tc.input)
+			} else if !strings.Contains(err.Error(), tc.expectedErr) {
+				t.Errorf("Test %d: expected error to contain: %v, found error: %v", i, tc.expectedErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Test %d: expected no error but found one for input %s, got: %v", i, tc.input, err)
+		}
+
+		o := f.proxies[0].odoh
+		if o == nil {
+			t.Fatalf("Test %d: expected an ODoH proxy", i)
+		}
+		if o.target.String() != tc.expectedTarget {
+			t.Errorf("Test %d: expected target %s, got %s", i, tc.expectedTarget, o.target)
+		}
+		proxy := ""
+		if o.proxy != nil {
+			proxy = o.proxy.String()
+		}
+		if proxy != tc.expectedProxy {
+			t.Errorf("Test %d: expected proxy %q, got %q", i, tc.expectedProxy, proxy)
+		}
+	}
+}
+
+// odohTarget returns a handler that is an ODoH target, answering every query with an A record.
+func odohTarget(t *testing.T) http.Handler {
+	target, err := odoh.NewTarget(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == odoh.ConfigsPath {
+			w.Write(target.Configs())
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		buf, qc, err := target.DecryptQuery(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		m := new(dns.Msg)
+		m.Unpack(buf)
+		ret := new(dns.Msg)
+		ret.SetReply(m)
+		ret.Answer = append(ret.Answer, test.A(m.Question[0].Name+" 3600 IN A 127.0.0.1"))
+		buf, _ = ret.Pack()
+		buf, _ = qc.EncryptResponse(buf, 0)
+		w.Header().Set("Content-Type", odoh.MimeType)
+		w.Write(buf)
+	})
+}
+
+func TestODoHExchange(t *testing.T) {
+	target := httptest.NewTLSServer(odohTarget(t))
+	defer target.Close()
+
+	// The proxy relays the query to the target in its parameters.
+	var relayed int32
+	proxy := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != odoh.MimeType {
+			http.Error(w, "", http.StatusUnsupportedMediaType)
+			return
+		}
+		q := r.URL.Query()
+		resp, err := target.Client().Post("https://"+q.Get("targethost")+q.Get("targetpath"), odoh.MimeType, r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		atomic.AddInt32(&relayed, 1)
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+	defer proxy.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(target.Certificate())
+	tlsConfig := &tls.Config{RootCAs: roots, ServerName: "ignored.example.org"}
+
+	for _, viaProxy := range []bool{false, true} {
+		u, err := parseODoHTarget("odoh://" + target.Listener.Addr().String() + "/dns-query")
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := NewODoHProxy(u)
+		p.SetTLSConfig(tlsConfig)
+		if viaProxy {
+			p.odoh.proxy, _ = parseODoHProxy(proxy.URL + "/proxy")
+		}
+
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		state := request.Request{W: &test.ResponseWriter{}, Req: m}
+		ret, err := p.Connect(context.Background(), state, options{})
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err)
+		}
+		if len(ret.Answer) != 1 || ret.Id != m.Id {
+			t.Errorf("Expected a response with one answer, got %s", ret)
+		}
+		if err := p.health.Check(p); err != nil {
+			t.Errorf("Expected the target to be healthy, got %s", err)
+		}
+	}
+	if relayed := atomic.LoadInt32(&relayed); relayed != 2 {
+		t.Errorf("Expected 2 queries relayed through the proxy, got %d", relayed)
+	}
+}
+
+func TestODoHForward(t *testing.T) {
+	target := httptest.NewTLSServer(odohTarget(t))
+	defer target.Close()
+
+	c := caddy.NewTestController("dns", "forward . odoh://"+target.Listener.Addr().String())
+	f, err := parseForward(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(target.Certificate())
+	f.proxies[0].SetTLSConfig(&tls.Config{RootCAs: roots})
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	if _, err := f.ServeDNS(context.Background(), rec, m); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if rec.Msg == nil || len(rec.Msg.Answer) != 1 {
+		t.Errorf("Expected a response with one answer, got %v", rec.Msg)
+	}
+	if f.proxies[0].odoh.config == nil {
+		t.Errorf("Expected the target configuration to be cached")
+	}
+}
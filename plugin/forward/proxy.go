@@ -15,6 +15,7 @@ type Proxy struct {
 	addr  string
 
 	transport *Transport
+	odoh      *odohClient // set for Oblivious DoH targets, these don't use transport
 
 	// health checking
 	probe  *up.Probe
@@ -36,6 +37,10 @@ func NewProxy(addr, trans string) *Proxy {
 
 // SetTLSConfig sets the TLS config in the lower p.transport and in the healthchecking client.
 func (p *Proxy) SetTLSConfig(cfg *tls.Config) {
+	if p.odoh != nil {
+		p.odoh.SetTLSConfig(cfg)
+		return
+	}
 	p.transport.SetTLSConfig(cfg)
 	p.health.SetTLSConfig(cfg)
 }
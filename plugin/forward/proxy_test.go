@@ -6,6 +6,7 @@ import (
 
 	"github.com/coredns/caddy"
 	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/pkg/edns"
 	"github.com/coredns/coredns/plugin/pkg/transport"
 	"github.com/coredns/coredns/plugin/test"
 	"github.com/coredns/coredns/request"
@@ -95,3 +96,48 @@ func TestProtocolSelection(t *testing.T) {
 		}
 	}
 }
+
+func TestPadQuery(t *testing.T) {
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+
+	q := padQuery(m, 0)
+	if m.IsEdns0() != nil {
+		t.Errorf("Expected the original query to be left alone")
+	}
+	if !edns.HasPadding(q) {
+		t.Fatalf("Expected the query to be padded")
+	}
+	if size := q.IsEdns0().UDPSize(); size != dns.MinMsgSize {
+		t.Errorf("Expected a UDP buffer size of %d for a query without OPT, got %d", dns.MinMsgSize, size)
+	}
+	buf, _ := q.Pack()
+	if len(buf)%edns.QueryPaddingBlock != 0 {
+		t.Errorf("Expected a multiple of %d bytes, got %d", edns.QueryPaddingBlock, len(buf))
+	}
+
+	ret := new(dns.Msg)
+	ret.SetReply(q)
+	ret.SetEdns0(4096, false)
+	edns.Pad(ret, edns.ResponsePaddingBlock)
+	// The records of the response may be shared, e.g. with a cached item, they must not be changed.
+	extra := []dns.RR{ret.IsEdns0(), test.A("example.org. 300 IN A 192.0.2.1")}
+	ret.Extra = extra
+	unpadResponse(ret, m)
+	if ret.IsEdns0() != nil || len(ret.Extra) != 1 {
+		t.Errorf("Expected the OPT record to be removed from the response")
+	}
+	if extra[0].Header().Rrtype != dns.TypeOPT {
+		t.Errorf("Expected the shared records to be left alone, got %v", extra)
+	}
+
+	m.SetEdns0(1232, true)
+	ret = new(dns.Msg)
+	ret.SetReply(m)
+	ret.SetEdns0(1232, true)
+	edns.Pad(ret, edns.ResponsePaddingBlock)
+	unpadResponse(ret, m)
+	if ret.IsEdns0() == nil || edns.HasPadding(ret) {
+		t.Errorf("Expected the OPT record to be kept without padding")
+	}
+}
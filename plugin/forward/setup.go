@@ -105,22 +105,38 @@ func parseStanza(c *caddy.Controller) (*Forward, error) {
 		return f, c.ArgErr()
 	}
 
-	toHosts, err := parse.HostPortOrFile(to...)
-	if err != nil {
-		return f, err
-	}
-
-	transports := make([]string, len(toHosts))
+	var (
+		transports  []string
+		odohTargets int
+	)
 	allowedTrans := map[string]bool{"dns": true, "tls": true}
-	for i, host := range toHosts {
-		trans, h := parse.Transport(host)
+	for _, t := range to {
+		// Oblivious DoH targets are URLs, and are parsed separately from the other hosts.
+		if isODoH(t) {
+			u, err := parseODoHTarget(t)
+			if err != nil {
+				return f, err
+			}
+			f.proxies = append(f.proxies, NewODoHProxy(u))
+			transports = append(transports, odohTransport)
+			odohTargets++
+			continue
+		}
+
+		toHosts, err := parse.HostPortOrFile(t)
+		if err != nil {
+			return f, err
+		}
+		for _, host := range toHosts {
+			trans, h := parse.Transport(host)
 
-		if !allowedTrans[trans] {
-			return f, fmt.Errorf("'%s' is not supported as a destination protocol in forward: %s", trans, host)
+			if !allowedTrans[trans] {
+				return f, fmt.Errorf("'%s' is not supported as a destination protocol in forward: %s", trans, host)
+			}
+			p := NewProxy(h, trans)
+			f.proxies = append(f.proxies, p)
+			transports = append(transports, trans)
 		}
-		p := NewProxy(h, trans)
-		f.proxies = append(f.proxies, p)
-		transports[i] = trans
 	}
 
 	for c.NextBlock() {
@@ -129,6 +145,10 @@ func parseStanza(c *caddy.Controller) (*Forward, error) {
 		}
 	}
 
+	if f.odohProxy != nil && odohTargets == 0 {
+		return f, fmt.Errorf("odoh_proxy requires an %s:// destination", odohTransport)
+	}
+
 	if f.tlsServerName != "" {
 		f.tlsConfig.ServerName = f.tlsServerName
 	}
@@ -139,8 +159,12 @@ func parseStanza(c *caddy.Controller) (*Forward, error) {
 
 	for i := range f.proxies {
 		// Only set this for proxies that need it.
-		if transports[i] == transport.TLS {
+		switch transports[i] {
+		case transport.TLS:
 			f.proxies[i].SetTLSConfig(f.tlsConfig)
+		case odohTransport:
+			f.proxies[i].SetTLSConfig(f.tlsConfig)
+			f.proxies[i].odoh.proxy = f.odohProxy
 		}
 		f.proxies[i].SetExpire(f.expire)
 		f.proxies[i].health.SetRecursionDesired(f.opts.hcRecursionDesired)
@@ -219,6 +243,31 @@ func parseBlock(c *caddy.Controller, f *Forward) error {
 			return c.ArgErr()
 		}
 		f.tlsServerName = c.Val()
+	case "padding":
+		if !c.NextArg() {
+			return c.ArgErr()
+		}
+		if c.Val() == "off" {
+			f.opts.padding = -1
+			break
+		}
+		n, err := strconv.Atoi(c.Val())
+		if err != nil {
+			return err
+		}
+		if n < 1 || n > maxPadding {
+			return fmt.Errorf("padding must be between 1 and %d: %d", maxPadding, n)
+		}
+		f.opts.padding = n
+	case "odoh_proxy":
+		if !c.NextArg() {
+			return c.ArgErr()
+		}
+		u, err := parseODoHProxy(c.Val())
+		if err != nil {
+			return err
+		}
+		f.odohProxy = u
 	case "expire":
 		if !c.NextArg() {
 			return c.ArgErr()
@@ -267,3 +316,5 @@ func parseBlock(c *caddy.Controller, f *Forward) error {
 }
 
 const max = 15 // Maximum number of upstreams.
+
+const maxPadding = 4096 // Maximum block size of padded queries.
@@ -25,6 +25,7 @@ type GRPC struct {
 
 	tlsConfig     *tls.Config
 	tlsServerName string
+	stream        bool
 
 	Next plugin.Handler
 }
@@ -108,6 +109,14 @@ func newGRPC() *GRPC {
 // Name implements the Handler interface.
 func (g *GRPC) Name() string { return "grpc" }
 
+// OnShutdown closes the connections to all proxies.
+func (g *GRPC) OnShutdown() error {
+	for _, p := range g.proxies {
+		p.close()
+	}
+	return nil
+}
+
 // Len returns the number of configured proxies.
 func (g *GRPC) len() int { return len(g.proxies) }
 
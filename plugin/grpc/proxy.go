@@ -20,8 +20,12 @@ type Proxy struct {
 	addr string
 
 	// connection
+	conn     *grpc.ClientConn
 	client   pb.DnsServiceClient
 	dialOpts []grpc.DialOption
+
+	// stream is used to send the queries when streaming is enabled.
+	stream *stream
 }
 
 // newProxy returns a new proxy.
@@ -40,11 +44,23 @@ func newProxy(addr string, tlsConfig *tls.Config) (*Proxy, error) {
 	if err != nil {
 		return nil, err
 	}
+	p.conn = conn
 	p.client = pb.NewDnsServiceClient(conn)
 
 	return p, nil
 }
 
+// close closes the stream and the connection to the upstream.
+func (p *Proxy) close() error {
+	if p.stream != nil {
+		p.stream.close()
+	}
+	if p.conn == nil {
+		return nil
+	}
+	return p.conn.Close()
+}
+
 // query sends the request and waits for a response.
 func (p *Proxy) query(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
 	start := time.Now()
@@ -54,17 +70,26 @@ func (p *Proxy) query(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
 		return nil, err
 	}
 
-	reply, err := p.client.Query(ctx, &pb.DnsPacket{Msg: msg})
-	if err != nil {
-		// if not found message, return empty message with NXDomain code
-		if status.Code(err) == codes.NotFound {
-			m := new(dns.Msg).SetRcode(req, dns.RcodeNameError)
-			return m, nil
+	var reply []byte
+	if p.stream != nil {
+		reply, err = p.stream.query(ctx, msg)
+		if err != nil {
+			return nil, err
 		}
-		return nil, err
+	} else {
+		r, err := p.client.Query(ctx, &pb.DnsPacket{Msg: msg})
+		if err != nil {
+			// if not found message, return empty message with NXDomain code
+			if status.Code(err) == codes.NotFound {
+				m := new(dns.Msg).SetRcode(req, dns.RcodeNameError)
+				return m, nil
+			}
+			return nil, err
+		}
+		reply = r.Msg
 	}
 	ret := new(dns.Msg)
-	if err := ret.Unpack(reply.Msg); err != nil {
+	if err := ret.Unpack(reply); err != nil {
 		return nil, err
 	}
 
@@ -64,3 +64,7 @@ type testServiceClient struct {
 func (m testServiceClient) Query(ctx context.Context, in *pb.DnsPacket, opts ...grpc.CallOption) (*pb.DnsPacket, error) {
 	return m.dnsPacket, m.err
 }
+
+func (m testServiceClient) Stream(ctx context.Context, opts ...grpc.CallOption) (pb.DnsService_StreamClient, error) {
+	return nil, errors.New("streaming not supported")
+}
@@ -28,6 +28,8 @@ func setup(c *caddy.Controller) error {
 		return g
 	})
 
+	c.OnShutdown(g.OnShutdown)
+
 	return nil
 }
 
@@ -85,6 +87,9 @@ func parseStanza(c *caddy.Controller) (*GRPC, error) {
 		if err != nil {
 			return nil, err
 		}
+		if g.stream {
+			pr.stream = newStream(pr.client)
+		}
 		g.proxies = append(g.proxies, pr)
 	}
 
@@ -118,6 +123,11 @@ func parseBlock(c *caddy.Controller, g *GRPC) error {
 			return c.ArgErr()
 		}
 		g.tlsServerName = c.Val()
+	case "stream":
+		if c.NextArg() {
+			return c.ArgErr()
+		}
+		g.stream = true
 	case "policy":
 		if !c.NextArg() {
 			return c.ArgErr()
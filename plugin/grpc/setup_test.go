@@ -26,9 +26,11 @@ func TestSetup(t *testing.T) {
 		{"grpc . 127.0.0.1:8080", false, ".", nil, ""},
 		{"grpc . [::1]:53", false, ".", nil, ""},
 		{"grpc . [2003::1]:53", false, ".", nil, ""},
+		{"grpc . 127.0.0.1 {\nstream\n}\n", false, ".", nil, ""},
 		// negative
 		{"grpc . a27.0.0.1", true, "", nil, "not an IP"},
 		{"grpc . 127.0.0.1 {\nblaatl\n}\n", true, "", nil, "unknown property"},
+		{"grpc . 127.0.0.1 {\nstream yes\n}\n", true, "", nil, "Wrong argument count"},
 		{`grpc . ::1
 		grpc com ::2`, true, "", nil, "plugin"},
 	}
@@ -0,0 +1,166 @@
+package grpc
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"sync"
+
+	"github.com/coredns/coredns/pb"
+)
+
+// stream sends queries to an upstream over a single bidirectional gRPC stream. Each query gets
+// an ID that is unique on the stream, so the responses can be matched to the queries in any order.
+type stream struct {
+	client pb.DnsServiceClient
+
+	sendMu sync.Mutex // serializes Send
+
+	mu      sync.Mutex
+	s       pb.DnsService_StreamClient
+	cancel  context.CancelFunc
+	pending map[uint16]chan []byte
+	id      uint16
+}
+
+func newStream(client pb.DnsServiceClient) *stream {
+	return &stream{client: client, pending: make(map[uint16]chan []byte)}
+}
+
+// query sends the packed query msg and waits for the response. The stream is opened on first
+// use, and opened again when it fails.
+func (st *stream) query(ctx context.Context, msg []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	st.mu.Lock()
+	s, err := st.open()
+	if err != nil {
+		st.mu.Unlock()
+		return nil, err
+	}
+	id, ok := st.nextID()
+	if !ok {
+		st.mu.Unlock()
+		return nil, errTooManyQueries
+	}
+	c := make(chan []byte, 1)
+	st.pending[id] = c
+	st.mu.Unlock()
+
+	// Send the query with the ID of the stream, and restore the original ID in the response.
+	orig := binary.BigEndian.Uint16(msg)
+	binary.BigEndian.PutUint16(msg, id)
+
+	st.sendMu.Lock()
+	err = s.Send(&pb.DnsPacket{Msg: msg})
+	st.sendMu.Unlock()
+	if err != nil {
+		st.remove(id, c)
+		return nil, err
+	}
+
+	select {
+	case ret, ok := <-c:
+		if !ok {
+			return nil, errStreamClosed
+		}
+		binary.BigEndian.PutUint16(ret, orig)
+		return ret, nil
+	case <-ctx.Done():
+		st.remove(id, c)
+		return nil, ctx.Err()
+	}
+}
+
+// open returns the stream, and opens it if needed. st.mu must be held.
+func (st *stream) open() (pb.DnsService_StreamClient, error) {
+	if st.s != nil {
+		return st.s, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s, err := st.client.Stream(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	st.s = s
+	st.cancel = cancel
+	go st.receive(s)
+	return s, nil
+}
+
+// nextID returns an ID that isn't used by a pending query. st.mu must be held.
+func (st *stream) nextID() (uint16, bool) {
+	if len(st.pending) > 0xffff {
+		return 0, false
+	}
+	for {
+		st.id++
+		if _, ok := st.pending[st.id]; !ok {
+			return st.id, true
+		}
+	}
+}
+
+// receive hands the responses on s to the queries waiting for them, until s fails.
+func (st *stream) receive(s pb.DnsService_StreamClient) {
+	for {
+		in, err := s.Recv()
+		if err != nil {
+			st.reset(s)
+			return
+		}
+		if len(in.Msg) < headerSize {
+			continue
+		}
+
+		id := binary.BigEndian.Uint16(in.Msg)
+		st.mu.Lock()
+		c, ok := st.pending[id]
+		delete(st.pending, id)
+		st.mu.Unlock()
+		if ok {
+			c <- in.Msg
+		}
+	}
+}
+
+// remove removes the pending query id, if it is still waiting on c.
+func (st *stream) remove(id uint16, c chan []byte) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.pending[id] == c {
+		delete(st.pending, id)
+	}
+}
+
+// reset closes s, if it is still the current stream, and fails the pending queries.
+func (st *stream) reset(s pb.DnsService_StreamClient) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.s == nil || st.s != s {
+		return
+	}
+	st.cancel()
+	st.s = nil
+	for id, c := range st.pending {
+		close(c)
+		delete(st.pending, id)
+	}
+}
+
+// close closes the stream.
+func (st *stream) close() {
+	st.mu.Lock()
+	s := st.s
+	st.mu.Unlock()
+	st.reset(s)
+}
+
+const headerSize = 12 // size of the DNS message header
+
+var (
+	errTooManyQueries = errors.New("too many queries in flight on gRPC stream")
+	errStreamClosed   = errors.New("gRPC stream closed")
+)
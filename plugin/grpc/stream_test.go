@@ -0,0 +1,148 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/coredns/coredns/pb"
+
+	"github.com/miekg/dns"
+	"google.golang.org/grpc"
+)
+
+func TestStream(t *testing.T) {
+	const n = 10
+	client := &testStreamClient{batch: n}
+	p := &Proxy{client: client, stream: newStream(client)}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// All queries have the same ID, the stream must tell them apart.
+			m := new(dns.Msg)
+			m.SetQuestion(fmt.Sprintf("%d.example.org.", i), dns.TypeA)
+			m.Id = 42
+
+			ret, err := p.query(context.TODO(), m)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if ret.Id != 42 {
+				errs <- fmt.Errorf("expected ID 42, got %d", ret.Id)
+				return
+			}
+			if ret.Question[0].Name != m.Question[0].Name {
+				errs <- fmt.Errorf("expected response for %s, got %s", m.Question[0].Name, ret.Question[0].Name)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if client.opened != 1 {
+		t.Errorf("Expected 1 stream to be opened, got %d", client.opened)
+	}
+}
+
+func TestStreamReset(t *testing.T) {
+	client := &testStreamClient{batch: 2, streams: make(chan *testStream, 2)}
+	p := &Proxy{client: client, stream: newStream(client)}
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+
+	// The stream fails before the response is sent.
+	errc := make(chan error)
+	go func() {
+		_, err := p.query(context.TODO(), m)
+		errc <- err
+	}()
+	(<-client.streams).fail()
+	if err := <-errc; err != errStreamClosed {
+		t.Errorf("Expected error %q, got %v", errStreamClosed, err)
+	}
+
+	// The next queries open a new stream.
+	client.batch = 1
+	if _, err := p.query(context.TODO(), m); err != nil {
+		t.Errorf("Expected no error, got %s", err)
+	}
+	if client.opened != 2 {
+		t.Errorf("Expected 2 streams to be opened, got %d", client.opened)
+	}
+	p.close()
+}
+
+// testStreamClient opens streams that answer the queries in batches, in the reverse order.
+type testStreamClient struct {
+	batch int
+
+	mu      sync.Mutex
+	opened  int
+	streams chan *testStream // if not nil, receives the opened streams
+}
+
+func (c *testStreamClient) Query(ctx context.Context, in *pb.DnsPacket, opts ...grpc.CallOption) (*pb.DnsPacket, error) {
+	return nil, errors.New("not used")
+}
+
+func (c *testStreamClient) Stream(ctx context.Context, opts ...grpc.CallOption) (pb.DnsService_StreamClient, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.opened++
+	s := &testStream{ctx: ctx, batch: c.batch, out: make(chan *pb.DnsPacket, c.batch), failed: make(chan struct{})}
+	if c.streams != nil {
+		c.streams <- s
+	}
+	return s, nil
+}
+
+type testStream struct {
+	grpc.ClientStream
+
+	ctx    context.Context
+	batch  int
+	out    chan *pb.DnsPacket
+	failed chan struct{}
+
+	mu sync.Mutex
+	in [][]byte
+}
+
+func (s *testStream) Send(p *pb.DnsPacket) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := make([]byte, len(p.Msg))
+	copy(m, p.Msg)
+	m[2] |= 0x80 // QR bit, make it a response
+	s.in = append(s.in, m)
+	if len(s.in) == s.batch {
+		for i := len(s.in) - 1; i >= 0; i-- {
+			s.out <- &pb.DnsPacket{Msg: s.in[i]}
+		}
+		s.in = nil
+	}
+	return nil
+}
+
+func (s *testStream) Recv() (*pb.DnsPacket, error) {
+	select {
+	case p := <-s.out:
+		return p, nil
+	case <-s.failed:
+		return nil, errors.New("stream failed")
+	case <-s.ctx.Done():
+		return nil, s.ctx.Err()
+	}
+}
+
+func (s *testStream) fail() { close(s.failed) }
@@ -0,0 +1,82 @@
+// Package limits implements a plugin that configures the TCP connection limits of a server.
+package limits
+
+import (
+	"strconv"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+)
+
+func init() { plugin.Register("limits", setup) }
+
+func setup(c *caddy.Controller) error {
+	if err := parse(c); err != nil {
+		return plugin.Error("limits", err)
+	}
+	return nil
+}
+
+func parse(c *caddy.Controller) error {
+	config := dnsserver.GetConfig(c)
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return plugin.ErrOnce
+		}
+		i++
+
+		if len(c.RemainingArgs()) != 0 {
+			return c.ArgErr()
+		}
+		set := false
+		for c.NextBlock() {
+			set = true
+			switch c.Val() {
+			case "tcp_connections":
+				n, err := positive(c)
+				if err != nil {
+					return err
+				}
+				config.MaxTCPConnections = n
+			case "tcp_connections_per_client":
+				n, err := positive(c)
+				if err != nil {
+					return err
+				}
+				config.MaxTCPConnectionsPerClient = n
+			case "tcp_queries":
+				n, err := positive(c)
+				if err != nil {
+					return err
+				}
+				config.MaxTCPQueries = n
+			default:
+				return c.Errf("unknown property %q", c.Val())
+			}
+		}
+		if !set {
+			return c.Err("limits requires at least one property")
+		}
+	}
+	if config.MaxTCPConnections > 0 && config.MaxTCPConnectionsPerClient > config.MaxTCPConnections {
+		return c.Errf("tcp_connections_per_client %d is larger than tcp_connections %d", config.MaxTCPConnectionsPerClient, config.MaxTCPConnections)
+	}
+	return nil
+}
+
+// positive parses the single integer argument of a property, it must be larger than zero.
+func positive(c *caddy.Controller) (int, error) {
+	name := c.Val()
+	args := c.RemainingArgs()
+	if len(args) != 1 {
+		return 0, c.ArgErr()
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n <= 0 {
+		return 0, c.Errf("%s must be a positive integer, got %q", name, args[0])
+	}
+	return n, nil
+}
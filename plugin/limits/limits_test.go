@@ -0,0 +1,61 @@
+package limits
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input             string
+		shouldErr         bool
+		expectedConns     int
+		expectedPerClient int
+		expectedQueries   int
+		expectedErr       string
+	}{
+		// positive
+		{"limits {\ntcp_connections 1000\n}", false, 1000, 0, 0, ""},
+		{"limits {\ntcp_connections 1000\ntcp_connections_per_client 10\ntcp_queries 100\n}", false, 1000, 10, 100, ""},
+		{"limits {\ntcp_connections_per_client 10\n}", false, 0, 10, 0, ""},
+		// negative
+		{"limits", true, 0, 0, 0, "at least one property"},
+		{"limits 10", true, 0, 0, 0, "Wrong argument count"},
+		{"limits {\ntcp_connections\n}", true, 0, 0, 0, "Wrong argument count"},
+		{"limits {\ntcp_connections 0\n}", true, 0, 0, 0, "tcp_connections must be a positive integer"},
+		{"limits {\ntcp_queries many\n}", true, 0, 0, 0, "tcp_queries must be a positive integer"},
+		{"limits {\ntcp_connections 10\ntcp_connections_per_client 20\n}", true, 0, 0, 0, "is larger than"},
+		{"limits {\nudp_queries 10\n}", true, 0, 0, 0, "unknown property"},
+		{"limits {\ntcp_queries 10\n}\nlimits {\ntcp_queries 10\n}", true, 0, 0, 0, "plugin"},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		err := setup(c)
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error but found none for input %s", i, test.input)
+			} else if !strings.Contains(err.Error(), test.expectedErr) {
+				t.Errorf("Test %d: expected error to contain: %v, found error: %v", i, test.expectedErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Test %d: expected no error but found one for input %s, got: %v", i, test.input, err)
+		}
+
+		config := dnsserver.GetConfig(c)
+		if config.MaxTCPConnections != test.expectedConns {
+			t.Errorf("Test %d: expected tcp_connections %d, got %d", i, test.expectedConns, config.MaxTCPConnections)
+		}
+		if config.MaxTCPConnectionsPerClient != test.expectedPerClient {
+			t.Errorf("Test %d: expected tcp_connections_per_client %d, got %d", i, test.expectedPerClient, config.MaxTCPConnectionsPerClient)
+		}
+		if config.MaxTCPQueries != test.expectedQueries {
+			t.Errorf("Test %d: expected tcp_queries %d, got %d", i, test.expectedQueries, config.MaxTCPQueries)
+		}
+	}
+}
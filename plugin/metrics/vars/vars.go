@@ -54,6 +54,28 @@ var (
 		Help:      "Counter of response status codes.",
 	}, []string{"server", "zone", "rcode"})
 
+	TCPConnectionsRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: subsystem,
+		Name:      "tcp_connections_rejected_total",
+		Help:      "Counter of TCP connections closed because of a connection limit, per limit.",
+	}, []string{"server", "limit"})
+
+	PluginBudgetExceeded = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: subsystem,
+		Name:      "plugin_budget_exceeded_total",
+		Help:      "Counter of queries on which a plugin exceeded its time budget, per plugin.",
+	}, []string{"server", "name"})
+
+	PluginDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "plugin",
+		Name:      "request_duration_seconds",
+		Buckets:   plugin.SlimTimeBuckets,
+		Help:      "Histogram of the time each plugin took to process a query, without the plugins after it.",
+	}, []string{"server", "name"})
+
 	Panic = promauto.NewCounter(prometheus.CounterOpts{
 		Namespace: plugin.Namespace,
 		Name:      "panics_total",
@@ -0,0 +1,85 @@
+// Package multisocket implements a plugin that opens several UDP sockets for a server.
+package multisocket
+
+import (
+	"runtime"
+	"strconv"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+	"github.com/coredns/coredns/plugin/pkg/udpbatch"
+)
+
+// maxValue is the maximum number of sockets, and of the batch size.
+const maxValue = 1024
+
+func init() { plugin.Register("multisocket", setup) }
+
+func setup(c *caddy.Controller) error {
+	if err := parse(c); err != nil {
+		return plugin.Error("multisocket", err)
+	}
+	return nil
+}
+
+func parse(c *caddy.Controller) error {
+	config := dnsserver.GetConfig(c)
+	if config.Transport != transport.DNS {
+		return c.Errf("multisocket requires a %s:// server, not %s://", transport.DNS, config.Transport)
+	}
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return plugin.ErrOnce
+		}
+		i++
+
+		args := c.RemainingArgs()
+		switch len(args) {
+		case 0:
+			config.UDPSockets = runtime.GOMAXPROCS(0)
+		case 1:
+			n, err := number(c, "number of sockets", args[0])
+			if err != nil {
+				return err
+			}
+			config.UDPSockets = n
+		default:
+			return c.ArgErr()
+		}
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "batch":
+				args := c.RemainingArgs()
+				switch len(args) {
+				case 0:
+					config.UDPBatchSize = udpbatch.DefaultSize
+				case 1:
+					n, err := number(c, "batch size", args[0])
+					if err != nil {
+						return err
+					}
+					config.UDPBatchSize = n
+				default:
+					return c.ArgErr()
+				}
+			default:
+				return c.Errf("unknown property %q", c.Val())
+			}
+		}
+	}
+	return nil
+}
+
+// number parses s as an integer between 1 and maxValue.
+func number(c *caddy.Controller, name, s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > maxValue {
+		return 0, c.Errf("%s must be between 1 and %d, got %q", name, maxValue, s)
+	}
+	return n, nil
+}
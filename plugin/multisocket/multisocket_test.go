@@ -0,0 +1,63 @@
+package multisocket
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input           string
+		transport       string
+		shouldErr       bool
+		expectedSockets int
+		expectedBatch   int
+		expectedErr     string
+	}{
+		// positive
+		{"multisocket", transport.DNS, false, runtime.GOMAXPROCS(0), 0, ""},
+		{"multisocket 4", transport.DNS, false, 4, 0, ""},
+		{"multisocket 4 {\nbatch\n}", transport.DNS, false, 4, 64, ""},
+		{"multisocket {\nbatch 16\n}", transport.DNS, false, runtime.GOMAXPROCS(0), 16, ""},
+		// negative
+		{"multisocket 4", transport.TLS, true, 0, 0, "requires a dns:// server"},
+		{"multisocket 0", transport.DNS, true, 0, 0, "number of sockets must be between 1 and 1024"},
+		{"multisocket 2000", transport.DNS, true, 0, 0, "number of sockets must be between 1 and 1024"},
+		{"multisocket many", transport.DNS, true, 0, 0, "number of sockets must be between 1 and 1024"},
+		{"multisocket 4 8", transport.DNS, true, 0, 0, "Wrong argument count"},
+		{"multisocket {\nbatch 0\n}", transport.DNS, true, 0, 0, "batch size must be between 1 and 1024"},
+		{"multisocket {\nbatch 16 32\n}", transport.DNS, true, 0, 0, "Wrong argument count"},
+		{"multisocket {\ntcp\n}", transport.DNS, true, 0, 0, "unknown property"},
+		{"multisocket\nmultisocket", transport.DNS, true, 0, 0, "plugin"},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		dnsserver.GetConfig(c).Transport = test.transport
+		err := setup(c)
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error but found none for input %s", i, test.input)
+			} else if !strings.Contains(err.Error(), test.expectedErr) {
+				t.Errorf("Test %d: expected error to contain: %v, found error: %v", i, test.expectedErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Test %d: expected no error but found one for input %s, got: %v", i, test.input, err)
+		}
+
+		config := dnsserver.GetConfig(c)
+		if config.UDPSockets != test.expectedSockets {
+			t.Errorf("Test %d: expected %d sockets, got %d", i, test.expectedSockets, config.UDPSockets)
+		}
+		if config.UDPBatchSize != test.expectedBatch {
+			t.Errorf("Test %d: expected a batch size of %d, got %d", i, test.expectedBatch, config.UDPBatchSize)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// Package odoh implements a plugin that configures the key of an Oblivious DNS-over-HTTPS target.
+package odoh
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/pkg/odoh"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+)
+
+var log = clog.NewWithPlugin("odoh")
+
+func init() { plugin.Register("odoh", setup) }
+
+func setup(c *caddy.Controller) error {
+	t, err := parse(c)
+	if err != nil {
+		return plugin.Error("odoh", err)
+	}
+	dnsserver.GetConfig(c).ODoH = t
+	log.Infof("Target with key id %s", hex.EncodeToString(t.Config().KeyID()))
+	return nil
+}
+
+func parse(c *caddy.Controller) (*odoh.Target, error) {
+	config := dnsserver.GetConfig(c)
+	if config.ODoH != nil {
+		return nil, c.Errf("ODoH already configured for this server instance")
+	}
+	if config.Transport != transport.HTTPS {
+		return nil, c.Errf("ODoH requires a %s:// server, not %s://", transport.HTTPS, config.Transport)
+	}
+
+	var key []byte
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		args := c.RemainingArgs()
+		switch len(args) {
+		case 0:
+		case 1:
+			path := args[0]
+			if !filepath.IsAbs(path) && config.Root != "" {
+				path = filepath.Join(config.Root, path)
+			}
+			var err error
+			if key, err = readKey(path); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, c.ArgErr()
+		}
+		if c.NextBlock() {
+			return nil, c.Errf("unknown property %q", c.Val())
+		}
+	}
+	return odoh.NewTarget(key)
+}
+
+// readKey reads the X25519 private key of the target from path. The file holds the 32 byte key, either
+// as is or hex encoded.
+func readKey(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != keySize {
+		b, err = hex.DecodeString(string(bytes.TrimSpace(b)))
+		if err != nil || len(b) != keySize {
+			return nil, fmt.Errorf("%s: not an X25519 private key", path)
+		}
+	}
+	return b, nil
+}
+
+const keySize = 32
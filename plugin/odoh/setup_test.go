@@ -0,0 +1,86 @@
+package odoh
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+)
+
+func TestSetup(t *testing.T) {
+	dir := t.TempDir()
+	key := strings.Repeat("ab", keySize)
+	hexed := filepath.Join(dir, "odoh.hex")
+	if err := os.WriteFile(hexed, []byte(key+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := hex.DecodeString(key)
+	raw := filepath.Join(dir, "odoh.key")
+	if err := os.WriteFile(raw, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	invalid := filepath.Join(dir, "invalid.key")
+	if err := os.WriteFile(invalid, []byte("not a key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input     string
+		transport string
+		expectErr bool
+	}{
+		{input: "odoh", transport: transport.HTTPS},
+		{input: "odoh " + raw, transport: transport.HTTPS},
+		{input: "odoh " + hexed, transport: transport.HTTPS},
+		{input: "odoh", transport: transport.DNS, expectErr: true},
+		{input: "odoh " + invalid, transport: transport.HTTPS, expectErr: true},
+		{input: "odoh " + filepath.Join(dir, "missing.key"), transport: transport.HTTPS, expectErr: true},
+		{input: "odoh " + raw + " " + hexed, transport: transport.HTTPS, expectErr: true},
+		{input: "odoh {\nunknown\n}", transport: transport.HTTPS, expectErr: true},
+		{input: "odoh\nodoh", transport: transport.HTTPS, expectErr: true},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		dnsserver.GetConfig(c).Transport = tc.transport
+		err := setup(c)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("Test %d: expected an error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if dnsserver.GetConfig(c).ODoH == nil {
+			t.Errorf("Test %d: expected the ODoH target to be configured", i)
+		}
+	}
+}
+
+func TestSetupSameKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "odoh.hex")
+	if err := os.WriteFile(path, []byte(strings.Repeat("01", keySize)), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	for i := 0; i < 2; i++ {
+		c := caddy.NewTestController("dns", "odoh "+path)
+		dnsserver.GetConfig(c).Transport = transport.HTTPS
+		if err := setup(c); err != nil {
+			t.Fatalf("Expected no error, got %s", err)
+		}
+		ids = append(ids, hex.EncodeToString(dnsserver.GetConfig(c).ODoH.Config().KeyID()))
+	}
+	if ids[0] != ids[1] {
+		t.Errorf("Expected the same key id for the same key, got %s and %s", ids[0], ids[1])
+	}
+}
@@ -0,0 +1,62 @@
+// Package padding implements a plugin that configures the EDNS padding of responses on encrypted
+// transports.
+package padding
+
+import (
+	"strconv"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+)
+
+func init() { plugin.Register("padding", setup) }
+
+func setup(c *caddy.Controller) error {
+	if err := parse(c); err != nil {
+		return plugin.Error("padding", err)
+	}
+	return nil
+}
+
+func parse(c *caddy.Controller) error {
+	config := dnsserver.GetConfig(c)
+	switch config.Transport {
+	case transport.TLS, transport.HTTPS, transport.GRPC:
+	default:
+		return c.Errf("padding requires a %s://, %s:// or %s:// server, not %s://", transport.TLS, transport.HTTPS, transport.GRPC, config.Transport)
+	}
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return plugin.ErrOnce
+		}
+		i++
+
+		args := c.RemainingArgs()
+		switch len(args) {
+		case 0:
+			// The default block size.
+		case 1:
+			if args[0] == "off" {
+				config.Padding = -1
+				break
+			}
+			n, err := strconv.Atoi(args[0])
+			if err != nil {
+				return c.Errf("invalid block size %q", args[0])
+			}
+			if n < 1 || n > maxBlock {
+				return c.Errf("block size must be between 1 and %d, got %d", maxBlock, n)
+			}
+			config.Padding = n
+		default:
+			return c.ArgErr()
+		}
+	}
+	return nil
+}
+
+const maxBlock = 4096
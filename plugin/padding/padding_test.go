@@ -0,0 +1,52 @@
+package padding
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input       string
+		transport   string
+		shouldErr   bool
+		expected    int
+		expectedErr string
+	}{
+		// positive
+		{"padding", transport.TLS, false, 0, ""},
+		{"padding 128", transport.HTTPS, false, 128, ""},
+		{"padding off", transport.GRPC, false, -1, ""},
+		// negative
+		{"padding", transport.DNS, true, 0, "requires a tls://, https:// or grpc:// server"},
+		{"padding abc", transport.TLS, true, 0, "invalid block size"},
+		{"padding 0", transport.TLS, true, 0, "block size must be between 1 and 4096"},
+		{"padding 8192", transport.TLS, true, 0, "block size must be between 1 and 4096"},
+		{"padding 128 468", transport.TLS, true, 0, "Wrong argument count"},
+		{"padding\npadding", transport.TLS, true, 0, "plugin"},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		dnsserver.GetConfig(c).Transport = test.transport
+		err := setup(c)
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error but found none for input %s", i, test.input)
+			} else if !strings.Contains(err.Error(), test.expectedErr) {
+				t.Errorf("Test %d: expected error to contain: %v, found error: %v", i, test.expectedErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Test %d: expected no error but found one for input %s, got: %v", i, test.input, err)
+		}
+		if p := dnsserver.GetConfig(c).Padding; p != test.expected {
+			t.Errorf("Test %d: expected padding %d, got %d", i, test.expected, p)
+		}
+	}
+}
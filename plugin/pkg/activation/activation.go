@@ -0,0 +1,138 @@
+// Package activation implements systemd socket activation: using the sockets that are passed in by
+// the service manager, instead of opening them, so the server doesn't need the privileges to bind to
+// them. See sd_listen_fds(3).
+package activation
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/coredns/coredns/plugin/pkg/log"
+)
+
+// firstFD is the first file descriptor that is passed in, SD_LISTEN_FDS_START.
+const firstFD = 3
+
+var (
+	once        sync.Once
+	mu          sync.Mutex // protects listeners and packetConns
+	listeners   []net.Listener
+	packetConns []net.PacketConn
+)
+
+// Listener returns the stream socket that is passed in for addr, and removes it, so it is returned
+// once. Network is "tcp" or "unix", addr is host:port or the path of a Unix socket. An empty host
+// matches a socket on the unspecified address.
+func Listener(network, addr string) (net.Listener, bool) {
+	once.Do(load)
+	mu.Lock()
+	defer mu.Unlock()
+	for i, l := range listeners {
+		if match(l.Addr(), network, addr) {
+			listeners = append(listeners[:i], listeners[i+1:]...)
+			return l, true
+		}
+	}
+	return nil, false
+}
+
+// PacketConn returns the datagram socket that is passed in for addr, and removes it, so it is
+// returned once. Network is "udp", addr is host:port.
+func PacketConn(network, addr string) (net.PacketConn, bool) {
+	once.Do(load)
+	mu.Lock()
+	defer mu.Unlock()
+	for i, p := range packetConns {
+		if match(p.LocalAddr(), network, addr) {
+			packetConns = append(packetConns[:i], packetConns[i+1:]...)
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// load loads the sockets that are passed in, and unsets the environment variables so child processes
+// don't use them.
+func load() {
+	n := count(os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS"), os.Getpid())
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	if n == 0 {
+		return
+	}
+
+	files := make([]*os.File, n)
+	for i := range files {
+		files[i] = os.NewFile(uintptr(firstFD+i), "LISTEN_FD_"+strconv.Itoa(firstFD+i))
+	}
+	mu.Lock()
+	listeners, packetConns = sockets(files)
+	mu.Unlock()
+	log.Infof("Using %d sockets from socket activation", len(listeners)+len(packetConns))
+}
+
+// count returns the number of sockets that are passed in, if they are meant for process pid.
+func count(listenPID, listenFDs string, pid int) int {
+	if listenPID == "" || listenFDs == "" {
+		return 0
+	}
+	if p, err := strconv.Atoi(listenPID); err != nil || p != pid {
+		return 0
+	}
+	n, err := strconv.Atoi(listenFDs)
+	if err != nil || n < 0 {
+		log.Warningf("Invalid LISTEN_FDS %q", listenFDs)
+		return 0
+	}
+	return n
+}
+
+// sockets converts files to listeners and packet conns, and closes them. Files that are neither are
+// skipped.
+func sockets(files []*os.File) (ls []net.Listener, ps []net.PacketConn) {
+	for _, f := range files {
+		if l, err := net.FileListener(f); err == nil {
+			ls = append(ls, l)
+		} else if p, err := net.FilePacketConn(f); err == nil {
+			ps = append(ps, p)
+		} else {
+			log.Warningf("Skipping file descriptor %d from socket activation: %s", f.Fd(), err)
+		}
+		f.Close()
+	}
+	return ls, ps
+}
+
+// match returns true if a is the address addr in network.
+func match(a net.Addr, network, addr string) bool {
+	var ip net.IP
+	var port int
+	switch a := a.(type) {
+	case *net.TCPAddr:
+		if network != "tcp" {
+			return false
+		}
+		ip, port = a.IP, a.Port
+	case *net.UDPAddr:
+		if network != "udp" {
+			return false
+		}
+		ip, port = a.IP, a.Port
+	case *net.UnixAddr:
+		return network == "unix" && a.Name == addr
+	default:
+		return false
+	}
+
+	host, p, err := net.SplitHostPort(addr)
+	if err != nil || p != strconv.Itoa(port) {
+		return false
+	}
+	if host == "" {
+		return ip == nil || ip.IsUnspecified()
+	}
+	return ip.Equal(net.ParseIP(host))
+}
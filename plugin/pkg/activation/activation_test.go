@@ -0,0 +1,110 @@
+package activation
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCount(t *testing.T) {
+	tests := []struct {
+		pid, fds string
+		expected int
+	}{
+		{"100", "2", 2},
+		{"100", "0", 0},
+		{"", "2", 0},
+		{"101", "2", 0},
+		{"100", "", 0},
+		{"100", "two", 0},
+		{"100", "-1", 0},
+	}
+	for i, tc := range tests {
+		if n := count(tc.pid, tc.fds, 100); n != tc.expected {
+			t.Errorf("Test %d: expected %d sockets, got %d", i, tc.expected, n)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		addr     net.Addr
+		network  string
+		address  string
+		expected bool
+	}{
+		{&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 53}, "tcp", "127.0.0.1:53", true},
+		{&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 53}, "tcp", "127.0.0.1:853", false},
+		{&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 53}, "tcp", ":53", false},
+		{&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 53}, "udp", "127.0.0.1:53", false},
+		{&net.TCPAddr{IP: net.IPv6unspecified, Port: 53}, "tcp", ":53", true},
+		{&net.UDPAddr{IP: net.IPv4zero, Port: 53}, "udp", ":53", true},
+		{&net.UDPAddr{IP: net.ParseIP("::1"), Port: 53}, "udp", "[::1]:53", true},
+		{&net.UnixAddr{Name: "/run/coredns.sock", Net: "unix"}, "unix", "/run/coredns.sock", true},
+		{&net.UnixAddr{Name: "/run/coredns.sock", Net: "unix"}, "unix", "/run/other.sock", false},
+	}
+	for i, tc := range tests {
+		if m := match(tc.addr, tc.network, tc.address); m != tc.expected {
+			t.Errorf("Test %d: expected match of %s with %s %s to be %t", i, tc.addr, tc.network, tc.address, tc.expected)
+		}
+	}
+}
+
+func TestSockets(t *testing.T) {
+	tl, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tl.Close()
+	uc, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer uc.Close()
+	path := filepath.Join(t.TempDir(), "coredns.sock")
+	ul, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ul.Close()
+
+	var files []*os.File
+	for _, f := range []interface{ File() (*os.File, error) }{tl, uc, ul} {
+		file, err := f.File()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+
+	once.Do(func() {}) // don't load the sockets from the environment
+	listeners, packetConns = sockets(files)
+	if len(listeners) != 2 || len(packetConns) != 1 {
+		t.Fatalf("Expected 2 listeners and 1 packet conn, got %d and %d", len(listeners), len(packetConns))
+	}
+
+	if _, ok := Listener("tcp", "127.0.0.1:1"); ok {
+		t.Errorf("Expected no listener for another port")
+	}
+	l, ok := Listener("tcp", tl.Addr().String())
+	if !ok {
+		t.Fatalf("Expected the TCP listener for %s", tl.Addr())
+	}
+	l.Close()
+	if _, ok := Listener("tcp", tl.Addr().String()); ok {
+		t.Errorf("Expected the TCP listener to be returned once")
+	}
+
+	p, ok := PacketConn("udp", uc.LocalAddr().String())
+	if !ok {
+		t.Fatalf("Expected the UDP socket for %s", uc.LocalAddr())
+	}
+	p.Close()
+
+	l, ok = Listener("unix", path)
+	if !ok {
+		t.Fatalf("Expected the Unix socket for %s", path)
+	}
+	l.Close()
+}
@@ -0,0 +1,127 @@
+package dnscrypt
+
+import (
+	"crypto/subtle"
+	"errors"
+
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/nacl/box"
+	"golang.org/x/crypto/poly1305"
+	"golang.org/x/crypto/salsa20/salsa"
+)
+
+const (
+	keySize   = 32
+	nonceSize = 24
+	tagSize   = 16
+)
+
+var errDecrypt = errors.New("dnscrypt: message authentication failed")
+
+// sharedKey returns the key shared between the resolver's secret key and the client's public key, for
+// the construction of esVersion.
+func sharedKey(esVersion uint16, secretKey, publicKey *[keySize]byte) (*[keySize]byte, error) {
+	shared, err := curve25519.X25519(secretKey[:], publicKey[:])
+	if err != nil {
+		return nil, err
+	}
+	key := new([keySize]byte)
+	copy(key[:], shared)
+
+	var zero [16]byte
+	switch esVersion {
+	case XSalsa20Poly1305:
+		// This is what box.Precompute does.
+		salsa.HSalsa20(key, &zero, key, &salsa.Sigma)
+	case XChacha20Poly1305:
+		k, err := chacha20.HChaCha20(key[:], zero[:])
+		if err != nil {
+			return nil, err
+		}
+		copy(key[:], k)
+	default:
+		return nil, errors.New("dnscrypt: unsupported construction")
+	}
+	return key, nil
+}
+
+// seal encrypts and authenticates msg. The result is the tag followed by the ciphertext.
+func seal(esVersion uint16, key *[keySize]byte, nonce *[nonceSize]byte, msg []byte) []byte {
+	if esVersion == XSalsa20Poly1305 {
+		return box.SealAfterPrecomputation(nil, msg, nonce, key)
+	}
+	return xsecretboxSeal(key, nonce, msg)
+}
+
+// open authenticates and decrypts box, as made by seal.
+func open(esVersion uint16, key *[keySize]byte, nonce *[nonceSize]byte, boxed []byte) ([]byte, error) {
+	if esVersion == XSalsa20Poly1305 {
+		msg, ok := box.OpenAfterPrecomputation(nil, boxed, nonce, key)
+		if !ok {
+			return nil, errDecrypt
+		}
+		return msg, nil
+	}
+	return xsecretboxOpen(key, nonce, boxed)
+}
+
+// xsecretboxSeal is secretbox.Seal with XChaCha20 instead of XSalsa20, as used by DNSCrypt: the first 32 bytes
+// of the key stream are the Poly1305 key, the rest encrypts msg.
+func xsecretboxSeal(key *[keySize]byte, nonce *[nonceSize]byte, msg []byte) []byte {
+	c, _ := chacha20.NewUnauthenticatedCipher(key[:], nonce[:])
+	buf := make([]byte, 32+len(msg))
+	copy(buf[32:], msg)
+	c.XORKeyStream(buf, buf)
+
+	var polyKey [32]byte
+	copy(polyKey[:], buf[:32])
+	var tag [tagSize]byte
+	poly1305.Sum(&tag, buf[32:], &polyKey)
+
+	out := make([]byte, tagSize+len(msg))
+	copy(out, tag[:])
+	copy(out[tagSize:], buf[32:])
+	return out
+}
+
+// xsecretboxOpen opens a box made by xsecretboxSeal.
+func xsecretboxOpen(key *[keySize]byte, nonce *[nonceSize]byte, boxed []byte) ([]byte, error) {
+	if len(boxed) < tagSize {
+		return nil, errDecrypt
+	}
+	c, _ := chacha20.NewUnauthenticatedCipher(key[:], nonce[:])
+	var polyKey [32]byte
+	c.XORKeyStream(polyKey[:], polyKey[:])
+	var tag [tagSize]byte
+	copy(tag[:], boxed)
+	if !poly1305.Verify(&tag, boxed[tagSize:], &polyKey) {
+		return nil, errDecrypt
+	}
+
+	// The cipher continues at byte 32 of the key stream, where xsecretboxSeal started encrypting.
+	msg := make([]byte, len(boxed)-tagSize)
+	c.XORKeyStream(msg, boxed[tagSize:])
+	return msg, nil
+}
+
+// pad pads msg to a multiple of 64 bytes, with 0x80 followed by zeros, as ISO/IEC 7816-4.
+func pad(msg []byte) []byte {
+	n := (len(msg) + 1 + 63) / 64 * 64
+	out := make([]byte, n)
+	copy(out, msg)
+	out[len(msg)] = 0x80
+	return out
+}
+
+// unpad removes the padding added by pad.
+func unpad(msg []byte) ([]byte, error) {
+	i := len(msg) - 1
+	for i >= 0 && msg[i] == 0 {
+		i--
+	}
+	if i < 0 || subtle.ConstantTimeByteEq(msg[i], 0x80) != 1 {
+		return nil, errors.New("dnscrypt: invalid padding")
+	}
+	return msg[:i], nil
+}
@@ -0,0 +1,75 @@
+package dnscrypt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+// certSize is the size of a certificate, without extensions.
+const certSize = 124
+
+var certMagic = [4]byte{'D', 'N', 'S', 'C'}
+
+// cert is a certificate: a short-term key pair of the resolver, signed by the provider key.
+type cert struct {
+	esVersion uint16
+	publicKey [keySize]byte
+	secretKey [keySize]byte
+	magic     [clientMagicSize]byte
+	serial    uint32
+	start     time.Time
+	end       time.Time
+
+	signed []byte // the certificate as published.
+}
+
+// newCert returns a new certificate, with a new key pair, for esVersion. It is valid from start to end
+// and signed with key.
+func newCert(esVersion uint16, key ed25519.PrivateKey, serial uint32, start, end time.Time) (*cert, error) {
+	c := &cert{esVersion: esVersion, serial: serial, start: start, end: end}
+	if _, err := rand.Read(c.secretKey[:]); err != nil {
+		return nil, err
+	}
+	pk, err := curve25519.X25519(c.secretKey[:], curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	copy(c.publicKey[:], pk)
+	// By convention the client magic is the start of the public key.
+	copy(c.magic[:], c.publicKey[:])
+
+	b := make([]byte, certSize)
+	copy(b[0:4], certMagic[:])
+	binary.BigEndian.PutUint16(b[4:6], esVersion)
+	binary.BigEndian.PutUint16(b[6:8], 0) // protocol minor version.
+	copy(b[72:104], c.publicKey[:])
+	copy(b[104:112], c.magic[:])
+	binary.BigEndian.PutUint32(b[112:116], serial)
+	binary.BigEndian.PutUint32(b[116:120], uint32(start.Unix()))
+	binary.BigEndian.PutUint32(b[120:124], uint32(end.Unix()))
+	copy(b[8:72], ed25519.Sign(key, b[72:]))
+	c.signed = b
+	return c, nil
+}
+
+// valid returns true if c is valid at now.
+func (c *cert) valid(now time.Time) bool { return !now.Before(c.start) && now.Before(c.end) }
+
+// txtString returns the string, in presentation format, of a TXT record holding the binary b.
+func txtString(b []byte) string {
+	var sb strings.Builder
+	for _, c := range b {
+		if c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' {
+			sb.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&sb, "\\%03d", c)
+	}
+	return sb.String()
+}
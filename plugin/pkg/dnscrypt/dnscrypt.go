@@ -0,0 +1,202 @@
+// Package dnscrypt implements the server side of the DNSCrypt (version 2) protocol, see
+// https://dnscrypt.info/protocol.
+package dnscrypt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// The encryption systems, "es-version" in the protocol.
+const (
+	XSalsa20Poly1305  uint16 = 1
+	XChacha20Poly1305 uint16 = 2
+)
+
+const (
+	clientMagicSize = 8
+	// queryHeaderSize is the size of the client magic, the client public key and the half nonce.
+	queryHeaderSize = clientMagicSize + keySize + nonceSize/2
+	// responseOverhead is the size of the resolver magic, the nonce and the tag of a response.
+	responseOverhead = clientMagicSize + nonceSize + tagSize
+)
+
+var resolverMagic = [clientMagicSize]byte{0x72, 0x36, 0x66, 0x6e, 0x76, 0x57, 0x6a, 0x38}
+
+// ErrNotEncrypted is returned by Decrypt when a packet isn't a DNSCrypt query for one of the current
+// certificates. It may be a plain DNS query, e.g. for the certificates.
+var ErrNotEncrypted = errors.New("dnscrypt: not an encrypted query")
+
+// Resolver holds the keys of a DNSCrypt resolver. The short-term keys, and the certificates for them,
+// are rotated when they get older than the rotation interval. A certificate stays valid for twice that
+// interval, so that clients that haven't fetched the new certificates yet can still use the old one.
+type Resolver struct {
+	providerName string
+	key          ed25519.PrivateKey
+	esVersions   []uint16
+	rotate       time.Duration
+
+	mu    sync.RWMutex
+	certs []*cert // newest first.
+	next  time.Time
+}
+
+// New returns a new Resolver for the provider name, with the provider's long-term key. It creates
+// certificates for each of esVersions.
+func New(providerName string, key ed25519.PrivateKey, esVersions []uint16, rotate time.Duration) (*Resolver, error) {
+	providerName = dns.Fqdn(strings.ToLower(providerName))
+	if !strings.HasPrefix(providerName, "2.dnscrypt-cert.") {
+		return nil, errors.New("dnscrypt: the provider name must start with 2.dnscrypt-cert.")
+	}
+	if len(esVersions) == 0 {
+		return nil, errors.New("dnscrypt: no encryption systems")
+	}
+	for _, es := range esVersions {
+		if es != XSalsa20Poly1305 && es != XChacha20Poly1305 {
+			return nil, errors.New("dnscrypt: unsupported encryption system")
+		}
+	}
+	r := &Resolver{providerName: providerName, key: key, esVersions: esVersions, rotate: rotate}
+	if _, err := r.current(time.Now()); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ProviderName returns the provider name, the name the certificates are published at.
+func (r *Resolver) ProviderName() string { return r.providerName }
+
+// PublicKey returns the public key of the provider, clients use it to verify the certificates.
+func (r *Resolver) PublicKey() ed25519.PublicKey { return r.key.Public().(ed25519.PublicKey) }
+
+// Certificates returns the TXT records with the currently valid certificates.
+func (r *Resolver) Certificates(ttl uint32) []dns.RR {
+	certs, err := r.current(time.Now())
+	if err != nil {
+		return nil
+	}
+	rrs := make([]dns.RR, len(certs))
+	for i, c := range certs {
+		rrs[i] = &dns.TXT{
+			Hdr: dns.RR_Header{Name: r.providerName, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: ttl},
+			Txt: []string{txtString(c.signed)},
+		}
+	}
+	return rrs
+}
+
+// current returns the valid certificates, it creates new ones when it's time to rotate the keys.
+func (r *Resolver) current(now time.Time) ([]*cert, error) {
+	r.mu.RLock()
+	certs, next := r.certs, r.next
+	r.mu.RUnlock()
+	if now.Before(next) {
+		return certs, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if now.Before(r.next) {
+		return r.certs, nil
+	}
+	fresh := make([]*cert, 0, len(r.esVersions)+len(r.certs))
+	for _, es := range r.esVersions {
+		c, err := newCert(es, r.key, uint32(now.Unix()), now, now.Add(2*r.rotate))
+		if err != nil {
+			return nil, err
+		}
+		fresh = append(fresh, c)
+	}
+	for _, c := range r.certs {
+		if c.valid(now) {
+			fresh = append(fresh, c)
+		}
+	}
+	r.certs = fresh
+	r.next = now.Add(r.rotate)
+	return r.certs, nil
+}
+
+// Query is a decrypted query. It holds what is needed to encrypt the response.
+type Query struct {
+	// Msg is the DNS message in the query.
+	Msg []byte
+
+	esVersion   uint16
+	key         *[keySize]byte
+	clientNonce [nonceSize / 2]byte
+}
+
+// Decrypt decrypts the DNSCrypt query in packet. If packet isn't a query for one of the current
+// certificates, ErrNotEncrypted is returned.
+func (r *Resolver) Decrypt(packet []byte) (*Query, error) {
+	if len(packet) < queryHeaderSize+tagSize {
+		return nil, ErrNotEncrypted
+	}
+	now := time.Now()
+	certs, err := r.current(now)
+	if err != nil {
+		return nil, err
+	}
+	var c *cert
+	for _, cc := range certs {
+		if string(cc.magic[:]) == string(packet[:clientMagicSize]) && cc.valid(now) {
+			c = cc
+			break
+		}
+	}
+	if c == nil {
+		return nil, ErrNotEncrypted
+	}
+
+	var clientPK [keySize]byte
+	copy(clientPK[:], packet[clientMagicSize:clientMagicSize+keySize])
+	key, err := sharedKey(c.esVersion, &c.secretKey, &clientPK)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &Query{esVersion: c.esVersion, key: key}
+	copy(q.clientNonce[:], packet[clientMagicSize+keySize:queryHeaderSize])
+	var nonce [nonceSize]byte
+	copy(nonce[:], q.clientNonce[:])
+
+	padded, err := open(c.esVersion, key, &nonce, packet[queryHeaderSize:])
+	if err != nil {
+		return nil, err
+	}
+	q.Msg, err = unpad(padded)
+	return q, err
+}
+
+// Encrypt encrypts msg, the response to q.
+func (q *Query) Encrypt(msg []byte) ([]byte, error) {
+	var nonce [nonceSize]byte
+	copy(nonce[:], q.clientNonce[:])
+	if _, err := rand.Read(nonce[nonceSize/2:]); err != nil {
+		return nil, err
+	}
+
+	boxed := seal(q.esVersion, q.key, &nonce, pad(msg))
+	out := make([]byte, 0, clientMagicSize+nonceSize+len(boxed))
+	out = append(out, resolverMagic[:]...)
+	out = append(out, nonce[:]...)
+	return append(out, boxed...), nil
+}
+
+// MaxResponseSize returns the maximum size of the DNS message in a response to a query over UDP that is
+// size bytes long. The encrypted response may not be larger than the query. It returns 0 if no response
+// fits.
+func MaxResponseSize(size int) int {
+	n := (size-responseOverhead)/64*64 - 1
+	if n < 0 {
+		return 0
+	}
+	return n
+}
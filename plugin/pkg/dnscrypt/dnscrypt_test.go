@@ -0,0 +1,224 @@
+package dnscrypt
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"golang.org/x/crypto/curve25519"
+)
+
+// client is the client side of the protocol, for a certificate it fetched.
+type client struct {
+	esVersion  uint16
+	resolverPK [keySize]byte
+	magic      [clientMagicSize]byte
+	secretKey  [keySize]byte
+	publicKey  [keySize]byte
+}
+
+// certificate returns the certificate in the TXT record rr.
+func certificate(t *testing.T, rr dns.RR) []byte {
+	buf := make([]byte, 512)
+	off, err := dns.PackRR(rr, buf, 0, nil, false)
+	if err != nil {
+		t.Fatalf("Failed to pack the certificate: %s", err)
+	}
+	// The owner name is not compressed, the header is the name and 10 bytes, the last 2 being the rdata length.
+	hdr := len(rr.Header().Name) + 1 + 10
+	rdlen := int(binary.BigEndian.Uint16(buf[hdr-2:]))
+	if hdr+rdlen != off {
+		t.Fatalf("Unexpected length of the certificate TXT record")
+	}
+	rdata := buf[hdr:off]
+	if int(rdata[0]) != len(rdata)-1 {
+		t.Fatalf("Expected a single string in the certificate TXT record")
+	}
+	return rdata[1:]
+}
+
+func newClient(t *testing.T, pub ed25519.PublicKey, b []byte) *client {
+	if len(b) != certSize || !bytes.Equal(b[:4], certMagic[:]) {
+		t.Fatalf("Invalid certificate %x", b)
+	}
+	if !ed25519.Verify(pub, b[72:], b[8:72]) {
+		t.Fatalf("Invalid signature on the certificate")
+	}
+	start, end := binary.BigEndian.Uint32(b[116:120]), binary.BigEndian.Uint32(b[120:124])
+	if now := uint32(time.Now().Unix()); now < start || now >= end {
+		t.Fatalf("Certificate isn't valid now")
+	}
+
+	c := &client{esVersion: binary.BigEndian.Uint16(b[4:6])}
+	copy(c.resolverPK[:], b[72:104])
+	copy(c.magic[:], b[104:112])
+	rand.Read(c.secretKey[:])
+	pk, _ := curve25519.X25519(c.secretKey[:], curve25519.Basepoint)
+	copy(c.publicKey[:], pk)
+	return c
+}
+
+func (c *client) query(t *testing.T, msg []byte) ([]byte, [nonceSize / 2]byte) {
+	key, err := sharedKey(c.esVersion, &c.secretKey, &c.resolverPK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nonce [nonceSize]byte
+	rand.Read(nonce[:nonceSize/2])
+	var half [nonceSize / 2]byte
+	copy(half[:], nonce[:])
+
+	padded := pad(msg)
+	if len(padded) < 256 {
+		padded = append(padded, make([]byte, 256-len(padded))...)
+	}
+	q := append([]byte{}, c.magic[:]...)
+	q = append(q, c.publicKey[:]...)
+	q = append(q, half[:]...)
+	return append(q, seal(c.esVersion, key, &nonce, padded)...), half
+}
+
+func (c *client) response(t *testing.T, resp []byte, half [nonceSize / 2]byte) []byte {
+	if !bytes.Equal(resp[:clientMagicSize], resolverMagic[:]) {
+		t.Fatalf("Expected resolver magic in the response")
+	}
+	if !bytes.Equal(resp[clientMagicSize:clientMagicSize+nonceSize/2], half[:]) {
+		t.Fatalf("Expected the client nonce in the response")
+	}
+	key, _ := sharedKey(c.esVersion, &c.secretKey, &c.resolverPK)
+	var nonce [nonceSize]byte
+	copy(nonce[:], resp[clientMagicSize:])
+	padded, err := open(c.esVersion, key, &nonce, resp[clientMagicSize+nonceSize:])
+	if err != nil {
+		t.Fatalf("Failed to open the response: %s", err)
+	}
+	if len(padded)%64 != 0 {
+		t.Errorf("Expected the response to be padded to a multiple of 64, got %d", len(padded))
+	}
+	msg, err := unpad(padded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestRoundTrip(t *testing.T) {
+	pub, key, _ := ed25519.GenerateKey(rand.Reader)
+	r, err := New("2.dnscrypt-cert.example.org", key, []uint16{XSalsa20Poly1305, XChacha20Poly1305}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certs := r.Certificates(60)
+	if len(certs) != 2 {
+		t.Fatalf("Expected 2 certificates, got %d", len(certs))
+	}
+	for _, rr := range certs {
+		if rr.Header().Name != "2.dnscrypt-cert.example.org." {
+			t.Errorf("Expected certificate at the provider name, got %s", rr.Header().Name)
+		}
+		c := newClient(t, pub, certificate(t, rr))
+
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		msg, _ := m.Pack()
+
+		packet, half := c.query(t, msg)
+		q, err := r.Decrypt(packet)
+		if err != nil {
+			t.Fatalf("Construction %d: failed to decrypt the query: %s", c.esVersion, err)
+		}
+		if !bytes.Equal(q.Msg, msg) {
+			t.Fatalf("Construction %d: expected the query to be %x, got %x", c.esVersion, msg, q.Msg)
+		}
+
+		m.SetReply(m)
+		m.Answer = []dns.RR{&dns.A{Hdr: dns.RR_Header{Name: "example.org.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 5}, A: []byte{192, 0, 2, 1}}}
+		reply, _ := m.Pack()
+		resp, err := q.Encrypt(reply)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resp) > len(packet) {
+			t.Errorf("Construction %d: expected the response to be at most %d bytes, got %d", c.esVersion, len(packet), len(resp))
+		}
+		if got := c.response(t, resp, half); !bytes.Equal(got, reply) {
+			t.Errorf("Construction %d: expected the response to be %x, got %x", c.esVersion, reply, got)
+		}
+
+		// Tampering is detected.
+		packet[len(packet)-1] ^= 1
+		if _, err := r.Decrypt(packet); err == nil {
+			t.Errorf("Construction %d: expected an error for a tampered query", c.esVersion)
+		}
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion(r.ProviderName(), dns.TypeTXT)
+	plain, _ := m.Pack()
+	if _, err := r.Decrypt(plain); err != ErrNotEncrypted {
+		t.Errorf("Expected ErrNotEncrypted for a plain query, got %v", err)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	r, err := New("2.dnscrypt-cert.example.org", key, []uint16{XChacha20Poly1305}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	first, _ := r.current(now)
+
+	certs, _ := r.current(now.Add(90 * time.Minute))
+	if len(certs) != 2 || certs[1] != first[0] {
+		t.Fatalf("Expected a new and the previous certificate after rotating, got %d", len(certs))
+	}
+	certs, _ = r.current(now.Add(150 * time.Minute))
+	if len(certs) != 2 || certs[1] == first[0] {
+		t.Fatalf("Expected the first certificate to be expired")
+	}
+}
+
+func TestNew(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	if _, err := New("example.org", key, []uint16{XSalsa20Poly1305}, time.Hour); err == nil {
+		t.Errorf("Expected an error for a provider name without 2.dnscrypt-cert")
+	}
+	if _, err := New("2.dnscrypt-cert.example.org", key, []uint16{3}, time.Hour); err == nil {
+		t.Errorf("Expected an error for an unknown encryption system")
+	}
+	if _, err := New("2.dnscrypt-cert.example.org", key, nil, time.Hour); err == nil {
+		t.Errorf("Expected an error for no encryption systems")
+	}
+}
+
+func TestPad(t *testing.T) {
+	for _, n := range []int{0, 1, 62, 63, 64, 200} {
+		msg := bytes.Repeat([]byte{0}, n)
+		padded := pad(msg)
+		if len(padded)%64 != 0 || len(padded) <= n {
+			t.Errorf("Expected %d bytes to be padded to a multiple of 64, got %d", n, len(padded))
+		}
+		got, err := unpad(padded)
+		if err != nil || !bytes.Equal(got, msg) {
+			t.Errorf("Expected unpad to return %d bytes, got %d: %v", n, len(got), err)
+		}
+	}
+	if _, err := unpad(make([]byte, 64)); err == nil {
+		t.Errorf("Expected an error for invalid padding")
+	}
+}
+
+func TestMaxResponseSize(t *testing.T) {
+	for _, size := range []int{256, 300, 512, 1252} {
+		n := MaxResponseSize(size)
+		if got := responseOverhead + len(pad(make([]byte, n))); got > size {
+			t.Errorf("Expected a response to a %d byte query to be at most %d bytes, got %d", size, size, got)
+		}
+	}
+}
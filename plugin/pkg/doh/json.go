@@ -0,0 +1,133 @@
+package doh
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// JSONMimeType is the mimetype of the DNS JSON API.
+const JSONMimeType = "application/dns-json"
+
+// JSONPath is the URL path of the DNS JSON API.
+const JSONPath = "/resolve"
+
+// IsJSONRequest returns true if req asks for the DNS JSON API instead of the wire format: it accepts
+// JSON, sets the ct parameter to the JSON mimetype, or is a GET request with a name but no dns
+// parameter.
+func IsJSONRequest(req *http.Request) bool {
+	if strings.Contains(req.Header.Get("Accept"), JSONMimeType) {
+		return true
+	}
+	values := req.URL.Query()
+	if values.Get("ct") == JSONMimeType {
+		return true
+	}
+	return req.Method == http.MethodGet && values.Get("dns") == "" && values.Get("name") != ""
+}
+
+// RequestToMsgJSON converts a DNS JSON API request to a dns message. The parameters are name, type
+// (a mnemonic or number, A by default), do and cd (DNSSEC OK and Checking Disabled, 1 or true). They
+// are read from the URL, or from a form in the body of a POST request.
+func RequestToMsgJSON(req *http.Request) (*dns.Msg, error) {
+	if err := req.ParseForm(); err != nil {
+		return nil, err
+	}
+
+	name := req.Form.Get("name")
+	if name == "" {
+		return nil, fmt.Errorf("no 'name' query parameter found")
+	}
+	if _, ok := dns.IsDomainName(name); !ok {
+		return nil, fmt.Errorf("invalid name %q", name)
+	}
+
+	qtype := dns.TypeA
+	if t := req.Form.Get("type"); t != "" {
+		if n, err := strconv.ParseUint(t, 10, 16); err == nil {
+			qtype = uint16(n)
+		} else if n, ok := dns.StringToType[strings.ToUpper(t)]; ok {
+			qtype = n
+		} else {
+			return nil, fmt.Errorf("invalid type %q", t)
+		}
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	// The ID is not used, and zero makes the responses cacheable, see section 4.1 of RFC 8484.
+	m.Id = 0
+	m.CheckingDisabled = isTrue(req.Form.Get("cd"))
+	if isTrue(req.Form.Get("do")) {
+		m.SetEdns0(dns.DefaultMsgSize, true)
+	}
+	return m, nil
+}
+
+func isTrue(s string) bool { return s == "1" || strings.EqualFold(s, "true") }
+
+// MsgToJSON converts a dns message to a DNS JSON API response.
+func MsgToJSON(m *dns.Msg) ([]byte, error) {
+	j := jsonMsg{
+		Status: m.Rcode,
+		TC:     m.Truncated,
+		RD:     m.RecursionDesired,
+		RA:     m.RecursionAvailable,
+		AD:     m.AuthenticatedData,
+		CD:     m.CheckingDisabled,
+	}
+	for _, q := range m.Question {
+		j.Question = append(j.Question, jsonQuestion{Name: q.Name, Type: q.Qtype})
+	}
+	j.Answer = toJSONRRs(m.Answer)
+	j.Authority = toJSONRRs(m.Ns)
+	j.Additional = toJSONRRs(m.Extra)
+	return json.Marshal(j)
+}
+
+func toJSONRRs(rrs []dns.RR) []jsonRR {
+	var j []jsonRR
+	for _, rr := range rrs {
+		if rr.Header().Rrtype == dns.TypeOPT {
+			continue
+		}
+		hdr := rr.Header()
+		j = append(j, jsonRR{
+			Name: hdr.Name,
+			Type: hdr.Rrtype,
+			TTL:  hdr.Ttl,
+			Data: strings.TrimPrefix(rr.String(), hdr.String()),
+		})
+	}
+	return j
+}
+
+// jsonMsg is a DNS JSON API response, as served by the well known public resolvers.
+type jsonMsg struct {
+	Status     int
+	TC         bool
+	RD         bool
+	RA         bool
+	AD         bool
+	CD         bool
+	Question   []jsonQuestion
+	Answer     []jsonRR `json:",omitempty"`
+	Authority  []jsonRR `json:",omitempty"`
+	Additional []jsonRR `json:",omitempty"`
+}
+
+type jsonQuestion struct {
+	Name string `json:"name"`
+	Type uint16 `json:"type"`
+}
+
+type jsonRR struct {
+	Name string `json:"name"`
+	Type uint16 `json:"type"`
+	TTL  uint32
+	Data string `json:"data"`
+}
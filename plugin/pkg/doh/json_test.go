@@ -0,0 +1,105 @@
+package doh
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestRequestToMsgJSON(t *testing.T) {
+	tests := []struct {
+		query      string
+		shouldErr  bool
+		expectedQ  string
+		expectedT  uint16
+		expectedDO bool
+		expectedCD bool
+	}{
+		{"name=example.org", false, "example.org.", dns.TypeA, false, false},
+		{"name=example.org.&type=aaaa", false, "example.org.", dns.TypeAAAA, false, false},
+		{"name=example.org&type=28&do=1&cd=true", false, "example.org.", dns.TypeAAAA, true, true},
+		{"name=example.org&do=0&cd=false", false, "example.org.", dns.TypeA, false, false},
+		{"type=A", true, "", 0, false, false},
+		{"name=example.org&type=NOPE", true, "", 0, false, false},
+		{"name=example..org", true, "", 0, false, false},
+	}
+	for i, tc := range tests {
+		m, err := RequestToMsgJSON(httptest.NewRequest(http.MethodGet, JSONPath+"?"+tc.query, nil))
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected an error for %q", i, tc.query)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error for %q, got %s", i, tc.query, err)
+			continue
+		}
+		if q := m.Question[0]; q.Name != tc.expectedQ || q.Qtype != tc.expectedT {
+			t.Errorf("Test %d: expected question %s %d, got %s %d", i, tc.expectedQ, tc.expectedT, q.Name, q.Qtype)
+		}
+		do := m.IsEdns0() != nil && m.IsEdns0().Do()
+		if do != tc.expectedDO || m.CheckingDisabled != tc.expectedCD {
+			t.Errorf("Test %d: expected DO %t and CD %t, got %t and %t", i, tc.expectedDO, tc.expectedCD, do, m.CheckingDisabled)
+		}
+	}
+}
+
+func TestRequestToMsgJSONPost(t *testing.T) {
+	form := url.Values{"name": {"example.org"}, "type": {"MX"}}
+	req := httptest.NewRequest(http.MethodPost, JSONPath, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	m, err := RequestToMsgJSON(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q := m.Question[0]; q.Name != "example.org." || q.Qtype != dns.TypeMX {
+		t.Errorf("Expected question example.org. MX, got %s %d", q.Name, q.Qtype)
+	}
+}
+
+func TestIsJSONRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, Path+"?name=example.org", nil)
+	if !IsJSONRequest(req) {
+		t.Errorf("Expected a GET request with a name to be a JSON request")
+	}
+	req = httptest.NewRequest(http.MethodGet, Path+"?dns=AAABAAABAAAAAAAAB2V4YW1wbGUDb3JnAAABAAE", nil)
+	if IsJSONRequest(req) {
+		t.Errorf("Expected a GET request with dns to be a wire format request")
+	}
+	req.Header.Set("Accept", JSONMimeType)
+	if !IsJSONRequest(req) {
+		t.Errorf("Expected a request that accepts JSON to be a JSON request")
+	}
+	req = httptest.NewRequest(http.MethodPost, Path+"?ct="+url.QueryEscape(JSONMimeType), nil)
+	if !IsJSONRequest(req) {
+		t.Errorf("Expected a request with the ct parameter to be a JSON request")
+	}
+}
+
+func TestMsgToJSON(t *testing.T) {
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	m.Response, m.RecursionAvailable = true, true
+	m.Answer = []dns.RR{test.A("example.org. 300 IN A 192.0.2.1")}
+	m.Ns = []dns.RR{test.NS("example.org. 3600 IN NS ns.example.org.")}
+	m.SetEdns0(4096, false)
+
+	buf, err := MsgToJSON(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"Status":0,"TC":false,"RD":true,"RA":true,"AD":false,"CD":false,` +
+		`"Question":[{"name":"example.org.","type":1}],` +
+		`"Answer":[{"name":"example.org.","type":1,"TTL":300,"data":"192.0.2.1"}],` +
+		`"Authority":[{"name":"example.org.","type":2,"TTL":3600,"data":"ns.example.org."}]}`
+	if string(buf) != expected {
+		t.Errorf("Expected %s, got %s", expected, buf)
+	}
+}
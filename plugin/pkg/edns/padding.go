@@ -0,0 +1,58 @@
+package edns
+
+import "github.com/miekg/dns"
+
+// The block sizes RFC 8467 (section 4.1) recommends for padding queries and responses.
+const (
+	QueryPaddingBlock    = 128
+	ResponsePaddingBlock = 468
+)
+
+// HasPadding returns true if m has the EDNS padding option (RFC 7830).
+func HasPadding(m *dns.Msg) bool {
+	opt := m.IsEdns0()
+	if opt == nil {
+		return false
+	}
+	for _, o := range opt.Option {
+		if o.Option() == dns.EDNS0PADDING {
+			return true
+		}
+	}
+	return false
+}
+
+// Pad sets the EDNS padding option in m, so that the length of the packed message is a multiple
+// of block. Padding that is already in m is replaced. It returns false when m has no OPT record or
+// the padded message would be larger than the maximum message size.
+func Pad(m *dns.Msg, block int) bool {
+	opt := m.IsEdns0()
+	if opt == nil {
+		return false
+	}
+	RemovePadding(m)
+
+	l := m.Len() + 4 // the code and length of the option
+	n := (block - l%block) % block
+	if l+n > dns.MaxMsgSize {
+		return false
+	}
+	opt.Option = append(opt.Option, &dns.EDNS0_PADDING{Padding: make([]byte, n)})
+	return true
+}
+
+// RemovePadding removes the EDNS padding option from m.
+func RemovePadding(m *dns.Msg) {
+	opt := m.IsEdns0()
+	if opt == nil {
+		return
+	}
+	// Don't filter in place, the options may be shared with the request.
+	options := make([]dns.EDNS0, 0, len(opt.Option))
+	for _, o := range opt.Option {
+		if o.Option() != dns.EDNS0PADDING {
+			options = append(options, o)
+		}
+	}
+	opt.Option = options
+}
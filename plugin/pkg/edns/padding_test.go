@@ -0,0 +1,53 @@
+package edns
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestPad(t *testing.T) {
+	for _, block := range []int{QueryPaddingBlock, ResponsePaddingBlock} {
+		m := ednsMsg()
+		if HasPadding(m) {
+			t.Errorf("Expected no padding before Pad")
+		}
+		if !Pad(m, block) {
+			t.Fatalf("Expected Pad to succeed for block %d", block)
+		}
+		if !HasPadding(m) {
+			t.Errorf("Expected padding after Pad")
+		}
+		buf, err := m.Pack()
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err)
+		}
+		if len(buf)%block != 0 {
+			t.Errorf("Expected a multiple of %d bytes, got %d", block, len(buf))
+		}
+
+		// Padding again replaces the option.
+		m.Answer = append(m.Answer, &dns.A{Hdr: dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 5}})
+		Pad(m, block)
+		if n := len(m.IsEdns0().Option); n != 1 {
+			t.Errorf("Expected 1 option, got %d", n)
+		}
+		buf, _ = m.Pack()
+		if len(buf)%block != 0 {
+			t.Errorf("Expected a multiple of %d bytes, got %d", block, len(buf))
+		}
+
+		RemovePadding(m)
+		if HasPadding(m) {
+			t.Errorf("Expected no padding after RemovePadding")
+		}
+	}
+}
+
+func TestPadNoEdns(t *testing.T) {
+	m := ednsMsg()
+	m.Extra = nil
+	if Pad(m, ResponsePaddingBlock) {
+		t.Errorf("Expected Pad to fail without OPT record")
+	}
+}
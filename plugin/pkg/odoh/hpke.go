@@ -0,0 +1,132 @@
+package odoh
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// This implements the base mode of HPKE (RFC 9180) for the one suite ODoH requires: DHKEM(X25519,
+// HKDF-SHA256), HKDF-SHA256 and AES-128-GCM.
+
+const (
+	kemX25519HKDFSHA256 uint16 = 0x0020
+	kdfHKDFSHA256       uint16 = 0x0001
+	aeadAES128GCM       uint16 = 0x0001
+
+	keySize   = 32 // Npk, Nsk and Nsecret of the KEM.
+	aeadKey   = 16 // Nk
+	aeadNonce = 12 // Nn
+	hashSize  = 32 // Nh
+)
+
+var (
+	kemSuiteID  = []byte{'K', 'E', 'M', 0x00, 0x20}
+	hpkeSuiteID = []byte{'H', 'P', 'K', 'E', 0x00, 0x20, 0x00, 0x01, 0x00, 0x01}
+)
+
+func labeledExtract(suiteID, salt []byte, label string, ikm []byte) []byte {
+	labeled := append([]byte("HPKE-v1"), suiteID...)
+	labeled = append(labeled, label...)
+	labeled = append(labeled, ikm...)
+	return hkdf.Extract(sha256.New, labeled, salt)
+}
+
+func labeledExpand(suiteID, prk []byte, label string, info []byte, l int) []byte {
+	labeled := make([]byte, 2, 2+7+len(suiteID)+len(label)+len(info))
+	binary.BigEndian.PutUint16(labeled, uint16(l))
+	labeled = append(labeled, "HPKE-v1"...)
+	labeled = append(labeled, suiteID...)
+	labeled = append(labeled, label...)
+	labeled = append(labeled, info...)
+
+	out := make([]byte, l)
+	io.ReadFull(hkdf.Expand(sha256.New, prk, labeled), out)
+	return out
+}
+
+// extractAndExpand derives the shared secret of the KEM from the Diffie-Hellman result.
+func extractAndExpand(dh, kemContext []byte) []byte {
+	prk := labeledExtract(kemSuiteID, nil, "eae_prk", dh)
+	return labeledExpand(kemSuiteID, prk, "shared_secret", kemContext, keySize)
+}
+
+// encap returns a shared secret and its encapsulation for the public key pkR.
+func encap(pkR []byte) (sharedSecret, enc []byte, err error) {
+	skE := make([]byte, keySize)
+	if _, err := rand.Read(skE); err != nil {
+		return nil, nil, err
+	}
+	pkE, err := curve25519.X25519(skE, curve25519.Basepoint)
+	if err != nil {
+		return nil, nil, err
+	}
+	dh, err := curve25519.X25519(skE, pkR)
+	if err != nil {
+		return nil, nil, err
+	}
+	return extractAndExpand(dh, append(append([]byte{}, pkE...), pkR...)), pkE, nil
+}
+
+// decap returns the shared secret encapsulated in enc, for the key pair skR, pkR.
+func decap(enc, skR, pkR []byte) ([]byte, error) {
+	dh, err := curve25519.X25519(skR, enc)
+	if err != nil {
+		return nil, err
+	}
+	return extractAndExpand(dh, append(append([]byte{}, enc...), pkR...)), nil
+}
+
+// hpkeContext is the encryption context of HPKE. ODoH only encrypts a single message with it, so the
+// sequence number is always zero and the nonce is the base nonce.
+type hpkeContext struct {
+	aead           cipher.AEAD
+	baseNonce      []byte
+	exporterSecret []byte
+}
+
+// keySchedule returns the context for the shared secret and info, in the base mode.
+func keySchedule(sharedSecret, info []byte) (*hpkeContext, error) {
+	pskIDHash := labeledExtract(hpkeSuiteID, nil, "psk_id_hash", nil)
+	infoHash := labeledExtract(hpkeSuiteID, nil, "info_hash", info)
+	ksContext := append([]byte{0x00}, pskIDHash...) // mode_base
+	ksContext = append(ksContext, infoHash...)
+
+	secret := labeledExtract(hpkeSuiteID, sharedSecret, "secret", nil)
+	key := labeledExpand(hpkeSuiteID, secret, "key", ksContext, aeadKey)
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &hpkeContext{
+		aead:           aead,
+		baseNonce:      labeledExpand(hpkeSuiteID, secret, "base_nonce", ksContext, aeadNonce),
+		exporterSecret: labeledExpand(hpkeSuiteID, secret, "exp", ksContext, hashSize),
+	}, nil
+}
+
+func (c *hpkeContext) seal(aad, plaintext []byte) []byte {
+	return c.aead.Seal(nil, c.baseNonce, plaintext, aad)
+}
+
+func (c *hpkeContext) open(aad, ciphertext []byte) ([]byte, error) {
+	return c.aead.Open(nil, c.baseNonce, ciphertext, aad)
+}
+
+func (c *hpkeContext) export(exporterContext []byte, l int) []byte {
+	return labeledExpand(hpkeSuiteID, c.exporterSecret, "sec", exporterContext, l)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
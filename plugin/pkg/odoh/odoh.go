@@ -0,0 +1,318 @@
+// Package odoh implements the message encryption of Oblivious DNS over HTTPS, as specified in RFC 9230.
+//
+// A client encrypts its query to the public key of a target and sends it through a proxy. The proxy sees
+// the address of the client but not the query, the target sees the query but not the address of the
+// client.
+package odoh
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// MimeType is the media type of ODoH messages.
+const MimeType = "application/oblivious-dns-message"
+
+// ConfigsPath is the well-known URL path where a target publishes its configurations.
+const ConfigsPath = "/.well-known/odohconfigs"
+
+const (
+	version = 0x0001
+
+	messageQuery    = 0x01
+	messageResponse = 0x02
+
+	responseNonceSize = 16 // max(Nn, Nk)
+)
+
+var (
+	// ErrKeyID is returned when a query is encrypted for a key that is not ours.
+	ErrKeyID = errors.New("odoh: unknown key id")
+	// ErrMessage is returned when a message can not be parsed.
+	ErrMessage = errors.New("odoh: malformed message")
+	// ErrNoConfig is returned when a list of configurations does not contain a supported one.
+	ErrNoConfig = errors.New("odoh: no supported configuration")
+)
+
+// Config is the public configuration of a target: its key and the algorithms to use.
+type Config struct {
+	publicKey []byte
+	keyID     []byte
+}
+
+// KeyID returns the key id of c, that identifies the key of the target in queries.
+func (c *Config) KeyID() []byte { return c.keyID }
+
+// contents returns the encoded ObliviousDoHConfigContents.
+func (c *Config) contents() []byte {
+	b := make([]byte, 8, 8+len(c.publicKey))
+	binary.BigEndian.PutUint16(b[0:], kemX25519HKDFSHA256)
+	binary.BigEndian.PutUint16(b[2:], kdfHKDFSHA256)
+	binary.BigEndian.PutUint16(b[4:], aeadAES128GCM)
+	binary.BigEndian.PutUint16(b[6:], uint16(len(c.publicKey)))
+	return append(b, c.publicKey...)
+}
+
+// newConfig returns the configuration for the public key, with its key id.
+func newConfig(publicKey []byte) *Config {
+	c := &Config{publicKey: publicKey}
+	prk := hkdf.Extract(sha256.New, c.contents(), nil)
+	c.keyID = make([]byte, hashSize)
+	io.ReadFull(hkdf.Expand(sha256.New, prk, []byte("odoh key id")), c.keyID)
+	return c
+}
+
+// ParseConfigs parses ObliviousDoHConfigs, as served by a target, and returns the first configuration
+// that is supported.
+func ParseConfigs(b []byte) (*Config, error) {
+	configs, ok := readVector(&b)
+	if !ok || len(b) != 0 {
+		return nil, ErrMessage
+	}
+	for len(configs) > 0 {
+		if len(configs) < 4 {
+			return nil, ErrMessage
+		}
+		v := binary.BigEndian.Uint16(configs)
+		configs = configs[2:]
+		contents, ok := readVector(&configs)
+		if !ok {
+			return nil, ErrMessage
+		}
+		if v != version || len(contents) < 8 {
+			continue
+		}
+		if binary.BigEndian.Uint16(contents[0:]) != kemX25519HKDFSHA256 ||
+			binary.BigEndian.Uint16(contents[2:]) != kdfHKDFSHA256 ||
+			binary.BigEndian.Uint16(contents[4:]) != aeadAES128GCM {
+			continue
+		}
+		contents = contents[6:]
+		pk, ok := readVector(&contents)
+		if !ok || len(contents) != 0 || len(pk) != keySize {
+			return nil, ErrMessage
+		}
+		return newConfig(pk), nil
+	}
+	return nil, ErrNoConfig
+}
+
+// EncryptQuery encrypts the DNS message for the target of c. The message is padded to a multiple of
+// padding bytes, when padding is larger than zero. The returned context decrypts the response.
+func (c *Config) EncryptQuery(msg []byte, padding int) ([]byte, *QueryContext, error) {
+	plain := encodePlaintext(msg, padding)
+
+	sharedSecret, enc, err := encap(c.publicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	hc, err := keySchedule(sharedSecret, []byte("odoh query"))
+	if err != nil {
+		return nil, nil, err
+	}
+	ct := hc.seal(messageAAD(messageQuery, c.keyID), plain)
+	return encodeMessage(messageQuery, c.keyID, append(enc, ct...)), &QueryContext{hpke: hc, query: plain}, nil
+}
+
+// Target holds the private key of a target, it decrypts queries and encrypts their responses.
+type Target struct {
+	privateKey []byte
+	config     *Config
+}
+
+// NewTarget returns a target for the X25519 private key. If key is nil a new key is generated.
+func NewTarget(key []byte) (*Target, error) {
+	if key == nil {
+		key = make([]byte, keySize)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+	}
+	if len(key) != keySize {
+		return nil, errors.New("odoh: private key must be 32 bytes")
+	}
+	pk, err := curve25519.X25519(key, curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	return &Target{privateKey: key, config: newConfig(pk)}, nil
+}
+
+// Config returns the public configuration of t.
+func (t *Target) Config() *Config { return t.config }
+
+// Configs returns the ObliviousDoHConfigs of t, to be served on ConfigsPath.
+func (t *Target) Configs() []byte {
+	contents := t.config.contents()
+	config := make([]byte, 4, 4+len(contents))
+	binary.BigEndian.PutUint16(config[0:], version)
+	binary.BigEndian.PutUint16(config[2:], uint16(len(contents)))
+	config = append(config, contents...)
+	return appendVector(nil, config)
+}
+
+// DecryptQuery decrypts an ODoH query and returns the DNS message in it. The returned context
+// encrypts the response. ErrKeyID is returned if the query is not encrypted for t.
+func (t *Target) DecryptQuery(b []byte) ([]byte, *QueryContext, error) {
+	keyID, ct, err := decodeMessage(messageQuery, b)
+	if err != nil {
+		return nil, nil, err
+	}
+	if string(keyID) != string(t.config.keyID) {
+		return nil, nil, ErrKeyID
+	}
+	if len(ct) < keySize {
+		return nil, nil, ErrMessage
+	}
+	sharedSecret, err := decap(ct[:keySize], t.privateKey, t.config.publicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	hc, err := keySchedule(sharedSecret, []byte("odoh query"))
+	if err != nil {
+		return nil, nil, err
+	}
+	plain, err := hc.open(messageAAD(messageQuery, keyID), ct[keySize:])
+	if err != nil {
+		return nil, nil, err
+	}
+	msg, err := decodePlaintext(plain)
+	if err != nil {
+		return nil, nil, err
+	}
+	return msg, &QueryContext{hpke: hc, query: plain}, nil
+}
+
+// QueryContext is the state shared by the client and the target for a single query.
+type QueryContext struct {
+	hpke  *hpkeContext
+	query []byte // the encoded plaintext of the query
+}
+
+// EncryptResponse encrypts the DNS message of the response to the query of q. The message is padded
+// to a multiple of padding bytes, when padding is larger than zero.
+func (q *QueryContext) EncryptResponse(msg []byte, padding int) ([]byte, error) {
+	nonce := make([]byte, responseNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	aead, err := q.responseAEAD(nonce)
+	if err != nil {
+		return nil, err
+	}
+	ct := aead.seal(messageAAD(messageResponse, nonce), encodePlaintext(msg, padding))
+	return encodeMessage(messageResponse, nonce, ct), nil
+}
+
+// DecryptResponse decrypts the response to the query of q and returns the DNS message in it.
+func (q *QueryContext) DecryptResponse(b []byte) ([]byte, error) {
+	nonce, ct, err := decodeMessage(messageResponse, b)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := q.responseAEAD(nonce)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := aead.open(messageAAD(messageResponse, nonce), ct)
+	if err != nil {
+		return nil, err
+	}
+	return decodePlaintext(plain)
+}
+
+// responseAEAD returns the AEAD, wrapped in an hpkeContext for its fixed nonce, of the response.
+func (q *QueryContext) responseAEAD(nonce []byte) (*hpkeContext, error) {
+	secret := q.hpke.export([]byte("odoh response"), aeadKey)
+	salt := appendVector(append([]byte{}, q.query...), nonce)
+	prk := hkdf.Extract(sha256.New, secret, salt)
+
+	key := make([]byte, aeadKey)
+	io.ReadFull(hkdf.Expand(sha256.New, prk, []byte("odoh key")), key)
+	n := make([]byte, aeadNonce)
+	io.ReadFull(hkdf.Expand(sha256.New, prk, []byte("odoh nonce")), n)
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &hpkeContext{aead: aead, baseNonce: n}, nil
+}
+
+// encodePlaintext returns the ObliviousDoHMessagePlaintext for msg, zero padded to a multiple of padding.
+func encodePlaintext(msg []byte, padding int) []byte {
+	pad := 0
+	if padding > 0 {
+		if r := (len(msg) + 4) % padding; r > 0 {
+			pad = padding - r
+		}
+	}
+	b := appendVector(nil, msg)
+	return appendVector(b, make([]byte, pad))
+}
+
+func decodePlaintext(b []byte) ([]byte, error) {
+	msg, ok := readVector(&b)
+	if !ok {
+		return nil, ErrMessage
+	}
+	pad, ok := readVector(&b)
+	if !ok || len(b) != 0 {
+		return nil, ErrMessage
+	}
+	for _, p := range pad {
+		if p != 0 {
+			return nil, ErrMessage
+		}
+	}
+	return msg, nil
+}
+
+func encodeMessage(typ byte, keyID, ct []byte) []byte {
+	b := appendVector([]byte{typ}, keyID)
+	return appendVector(b, ct)
+}
+
+func decodeMessage(typ byte, b []byte) (keyID, ct []byte, err error) {
+	if len(b) < 1 || b[0] != typ {
+		return nil, nil, ErrMessage
+	}
+	b = b[1:]
+	keyID, ok := readVector(&b)
+	if !ok {
+		return nil, nil, ErrMessage
+	}
+	ct, ok = readVector(&b)
+	if !ok || len(b) != 0 {
+		return nil, nil, ErrMessage
+	}
+	return keyID, ct, nil
+}
+
+func messageAAD(typ byte, keyID []byte) []byte { return appendVector([]byte{typ}, keyID) }
+
+// appendVector appends v, prefixed with its 2 byte length, to b.
+func appendVector(b, v []byte) []byte {
+	b = append(b, byte(len(v)>>8), byte(len(v)))
+	return append(b, v...)
+}
+
+// readVector reads a vector with a 2 byte length from b and advances b past it.
+func readVector(b *[]byte) ([]byte, bool) {
+	if len(*b) < 2 {
+		return nil, false
+	}
+	l := int(binary.BigEndian.Uint16(*b))
+	if len(*b) < 2+l {
+		return nil, false
+	}
+	v := (*b)[2 : 2+l]
+	*b = (*b)[2+l:]
+	return v, true
+}
@@ -0,0 +1,138 @@
+package odoh
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func unhex(s string) []byte {
+	b, _ := hex.DecodeString(s)
+	return b
+}
+
+// TestHPKE checks the base mode against the test vector in RFC 9180, appendix A.1.1.
+func TestHPKE(t *testing.T) {
+	enc := unhex("37fda3567bdbd628e88668c3c8d7e97d1d1253b6d4ea6d44c150f741f1bf4431")
+	skR := unhex("4612c550263fc8ad58375df3f557aac531d26850903e55a9f23f21d8534e8ac8")
+	pkR := unhex("3948cfe0ad1ddb695d780e59077195da6c56506b027329794ab02bca80815c4d")
+
+	sharedSecret, err := decap(enc, skR, pkR)
+	if err != nil {
+		t.Fatalf("Failed to decapsulate: %s", err)
+	}
+	if x := unhex("fe0e18c9f024ce43799ae393c7e8fe8fce9d218875e8227b0187c04e7d2ea1fc"); !bytes.Equal(sharedSecret, x) {
+		t.Fatalf("Expected shared secret %x, got %x", x, sharedSecret)
+	}
+
+	hc, err := keySchedule(sharedSecret, unhex("4f6465206f6e2061204772656369616e2055726e"))
+	if err != nil {
+		t.Fatalf("Failed to setup the context: %s", err)
+	}
+	if x := unhex("56d890e5accaaf011cff4b7d"); !bytes.Equal(hc.baseNonce, x) {
+		t.Errorf("Expected base nonce %x, got %x", x, hc.baseNonce)
+	}
+	if x := unhex("45ff1c2e220db587171952c0592d5f5ebe103f1561a2614e38f2ffd47e99e3f8"); !bytes.Equal(hc.exporterSecret, x) {
+		t.Errorf("Expected exporter secret %x, got %x", x, hc.exporterSecret)
+	}
+	ct := hc.seal([]byte("Count-0"), []byte("Beauty is truth, truth beauty"))
+	if x := unhex("f938558b5d72f1a23810b4be2ab4f84331acc02fc97babc53a52ae8218a355a96d8770ac83d07bea87e13c512a"); !bytes.Equal(ct, x) {
+		t.Errorf("Expected ciphertext %x, got %x", x, ct)
+	}
+}
+
+func TestQueryResponse(t *testing.T) {
+	target, err := NewTarget(nil)
+	if err != nil {
+		t.Fatalf("Failed to create the target: %s", err)
+	}
+	config, err := ParseConfigs(target.Configs())
+	if err != nil {
+		t.Fatalf("Failed to parse the configs: %s", err)
+	}
+	if !bytes.Equal(config.keyID, target.Config().keyID) {
+		t.Fatalf("Expected key id %x, got %x", target.Config().keyID, config.keyID)
+	}
+
+	query := []byte("a DNS query")
+	ct, qc, err := config.EncryptQuery(query, 128)
+	if err != nil {
+		t.Fatalf("Failed to encrypt the query: %s", err)
+	}
+	msg, tc, err := target.DecryptQuery(ct)
+	if err != nil {
+		t.Fatalf("Failed to decrypt the query: %s", err)
+	}
+	if !bytes.Equal(msg, query) {
+		t.Fatalf("Expected query %q, got %q", query, msg)
+	}
+	if len(tc.query)%128 != 0 {
+		t.Errorf("Expected the query to be padded to 128 bytes, got %d", len(tc.query))
+	}
+
+	response := []byte("a DNS response")
+	ct, err = tc.EncryptResponse(response, 0)
+	if err != nil {
+		t.Fatalf("Failed to encrypt the response: %s", err)
+	}
+	msg, err = qc.DecryptResponse(ct)
+	if err != nil {
+		t.Fatalf("Failed to decrypt the response: %s", err)
+	}
+	if !bytes.Equal(msg, response) {
+		t.Fatalf("Expected response %q, got %q", response, msg)
+	}
+
+	ct[len(ct)-1] ^= 1
+	if _, err := qc.DecryptResponse(ct); err == nil {
+		t.Errorf("Expected an error for a modified response")
+	}
+}
+
+func TestDecryptQueryKeyID(t *testing.T) {
+	target, _ := NewTarget(nil)
+	other, _ := NewTarget(nil)
+
+	ct, _, err := other.Config().EncryptQuery([]byte("a DNS query"), 0)
+	if err != nil {
+		t.Fatalf("Failed to encrypt the query: %s", err)
+	}
+	if _, _, err := target.DecryptQuery(ct); err != ErrKeyID {
+		t.Errorf("Expected %s, got %v", ErrKeyID, err)
+	}
+	if _, _, err := target.DecryptQuery(ct[:10]); err != ErrMessage {
+		t.Errorf("Expected %s, got %v", ErrMessage, err)
+	}
+}
+
+func TestParseConfigs(t *testing.T) {
+	target, _ := NewTarget(nil)
+	configs := target.Configs()
+
+	// A configuration with an unknown version comes first and must be skipped.
+	unknown := []byte{0xff, 0xff, 0x00, 0x02, 0xab, 0xcd}
+	b := appendVector(nil, append(unknown, configs[2:]...))
+	if _, err := ParseConfigs(b); err != nil {
+		t.Errorf("Expected the supported configuration to be found, got %s", err)
+	}
+	if _, err := ParseConfigs(appendVector(nil, unknown)); err != ErrNoConfig {
+		t.Errorf("Expected %s, got %v", ErrNoConfig, err)
+	}
+	if _, err := ParseConfigs(configs[:len(configs)-1]); err != ErrMessage {
+		t.Errorf("Expected %s, got %v", ErrMessage, err)
+	}
+}
+
+func TestNewTargetKey(t *testing.T) {
+	if _, err := NewTarget(make([]byte, 31)); err == nil {
+		t.Errorf("Expected an error for a short key")
+	}
+	key := unhex("4612c550263fc8ad58375df3f557aac531d26850903e55a9f23f21d8534e8ac8")
+	target, err := NewTarget(key)
+	if err != nil {
+		t.Fatalf("Failed to create the target: %s", err)
+	}
+	if x := unhex("3948cfe0ad1ddb695d780e59077195da6c56506b027329794ab02bca80815c4d"); !bytes.Equal(target.Config().publicKey, x) {
+		t.Errorf("Expected public key %x, got %x", x, target.Config().publicKey)
+	}
+}
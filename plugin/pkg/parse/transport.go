@@ -27,6 +27,14 @@ func Transport(s string) (trans string, addr string) {
 		s = s[len(transport.HTTPS+"://"):]
 
 		return transport.HTTPS, s
+
+	case strings.HasPrefix(s, transport.DNSCrypt+"://"):
+		s = s[len(transport.DNSCrypt+"://"):]
+		return transport.DNSCrypt, s
+
+	case strings.HasPrefix(s, transport.Unix+"://"):
+		s = s[len(transport.Unix+"://"):]
+		return transport.Unix, s
 	}
 
 	return transport.DNS, s
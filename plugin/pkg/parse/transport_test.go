@@ -16,6 +16,8 @@ func TestTransport(t *testing.T) {
 		{"grpc://example.org:1443 ", transport.GRPC},
 		{"tls://example.org ", transport.TLS},
 		{"https://example.org ", transport.HTTPS},
+		{"dnscrypt://example.org ", transport.DNSCrypt},
+		{"unix:///run/coredns.sock", transport.Unix},
 	} {
 		actual, _ := Transport(test.input)
 		if actual != test.expected {
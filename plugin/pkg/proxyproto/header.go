@@ -0,0 +1,171 @@
+// Package proxyproto implements the receiving side of the PROXY protocol, versions 1 and 2, see
+// https://www.haproxy.org/download/2.9/doc/proxy-protocol.txt.
+//
+// A load balancer that passes on TCP connections or UDP datagrams starts them with a header that holds
+// the address of the client. Headers are only accepted from trusted sources.
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrNoHeader is returned when a connection or datagram doesn't start with a PROXY protocol header.
+	ErrNoHeader = errors.New("proxyproto: no PROXY protocol header")
+	// ErrInvalidHeader is returned when the header can not be parsed.
+	ErrInvalidHeader = errors.New("proxyproto: invalid PROXY protocol header")
+)
+
+var (
+	v1Prefix    = []byte("PROXY ")
+	v2Signature = []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+)
+
+const (
+	v1MaxLength = 107 // including the CRLF
+	v2HeaderLen = 16  // signature, version and command, family and length
+
+	v2CmdLocal = 0x20
+	v2CmdProxy = 0x21
+
+	v2FamUnspec = 0x00
+	v2FamTCP4   = 0x11
+	v2FamUDP4   = 0x12
+	v2FamTCP6   = 0x21
+	v2FamUDP6   = 0x22
+)
+
+// ReadHeader reads a version 1 or version 2 header from r and returns the source address in it. The
+// source address is nil for headers without addresses, e.g. the health checks of the load balancer.
+func ReadHeader(r *bufio.Reader) (net.Addr, error) {
+	b, err := r.Peek(len(v1Prefix))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(b, v1Prefix) {
+		return readV1(r)
+	}
+
+	b, err = r.Peek(v2HeaderLen)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(b[:len(v2Signature)], v2Signature) {
+		return nil, ErrNoHeader
+	}
+	l := v2HeaderLen + int(binary.BigEndian.Uint16(b[14:]))
+	b, err = r.Peek(l)
+	if err != nil {
+		return nil, err
+	}
+	src, _, err := ParseV2(b)
+	if err != nil {
+		return nil, err
+	}
+	r.Discard(l)
+	return src, nil
+}
+
+// readV1 reads the human-readable header, e.g. "PROXY TCP4 192.0.2.1 192.0.2.2 53000 53\r\n".
+func readV1(r *bufio.Reader) (net.Addr, error) {
+	var line []byte
+	for len(line) < v1MaxLength {
+		c, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, c)
+		if c == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, ErrInvalidHeader
+	}
+
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 {
+		return nil, ErrInvalidHeader
+	}
+	ip := net.ParseIP(fields[2])
+	if ip == nil || net.ParseIP(fields[3]) == nil {
+		return nil, ErrInvalidHeader
+	}
+	switch fields[1] {
+	case "TCP4":
+		if ip.To4() == nil {
+			return nil, ErrInvalidHeader
+		}
+	case "TCP6":
+		if ip.To4() != nil {
+			return nil, ErrInvalidHeader
+		}
+	default:
+		return nil, ErrInvalidHeader
+	}
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, ErrInvalidHeader
+	}
+	if _, err := strconv.ParseUint(fields[5], 10, 16); err != nil {
+		return nil, ErrInvalidHeader
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// ParseV2 parses the binary header at the start of b, it returns the source address in it and the data
+// after the header. The source address is nil for a LOCAL command or an unspecified address family.
+func ParseV2(b []byte) (net.Addr, []byte, error) {
+	if len(b) < v2HeaderLen || !bytes.Equal(b[:len(v2Signature)], v2Signature) {
+		return nil, nil, ErrNoHeader
+	}
+	cmd, fam := b[12], b[13]
+	l := int(binary.BigEndian.Uint16(b[14:]))
+	if len(b) < v2HeaderLen+l {
+		return nil, nil, ErrInvalidHeader
+	}
+	addrs, rest := b[v2HeaderLen:v2HeaderLen+l], b[v2HeaderLen+l:]
+
+	switch cmd {
+	case v2CmdLocal:
+		return nil, rest, nil
+	case v2CmdProxy:
+	default:
+		return nil, nil, fmt.Errorf("%w: version and command 0x%02x", ErrInvalidHeader, cmd)
+	}
+
+	switch fam {
+	case v2FamUnspec:
+		return nil, rest, nil
+	case v2FamTCP4, v2FamUDP4:
+		if len(addrs) < 12 {
+			return nil, nil, ErrInvalidHeader
+		}
+		ip, port := net.IP(append([]byte{}, addrs[0:4]...)), int(binary.BigEndian.Uint16(addrs[8:]))
+		if fam == v2FamUDP4 {
+			return &net.UDPAddr{IP: ip, Port: port}, rest, nil
+		}
+		return &net.TCPAddr{IP: ip, Port: port}, rest, nil
+	case v2FamTCP6, v2FamUDP6:
+		if len(addrs) < 36 {
+			return nil, nil, ErrInvalidHeader
+		}
+		ip, port := net.IP(append([]byte{}, addrs[0:16]...)), int(binary.BigEndian.Uint16(addrs[32:]))
+		if fam == v2FamUDP6 {
+			return &net.UDPAddr{IP: ip, Port: port}, rest, nil
+		}
+		return &net.TCPAddr{IP: ip, Port: port}, rest, nil
+	}
+	// Unix sockets and unknown families carry no address we can use.
+	return nil, rest, nil
+}
@@ -0,0 +1,181 @@
+package proxyproto
+
+import (
+	"bufio"
+	"net"
+	"sync"
+	"time"
+)
+
+// Config holds the sources that are trusted to send PROXY protocol headers.
+type Config struct {
+	// Trusted are the networks of the load balancers. Connections and datagrams from other addresses
+	// are used as is, connections from these addresses must start with a header.
+	Trusted []*net.IPNet
+	// Timeout is the time allowed to read the header of a connection.
+	Timeout time.Duration
+}
+
+// DefaultTimeout is the default time allowed to read the header of a connection.
+const DefaultTimeout = 5 * time.Second
+
+// Trusts returns true if addr is in one of the trusted networks.
+func (c *Config) Trusts(addr net.Addr) bool {
+	var ip net.IP
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		ip = a.IP
+	case *net.UDPAddr:
+		ip = a.IP
+	default:
+		host, _, err := net.SplitHostPort(addr.String())
+		if err != nil {
+			return false
+		}
+		ip = net.ParseIP(host)
+	}
+	if ip == nil {
+		return false
+	}
+	for _, n := range c.Trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewListener returns a listener whose connections from trusted sources report the client address in
+// their PROXY protocol header as their remote address. The header is read on the first Read or
+// RemoteAddr call, so slow senders don't block Accept.
+func NewListener(l net.Listener, c *Config) net.Listener { return &listener{Listener: l, config: c} }
+
+type listener struct {
+	net.Listener
+	config *Config
+}
+
+// Accept implements net.Listener.
+func (l *listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if !l.config.Trusts(c.RemoteAddr()) {
+		return c, nil
+	}
+	return &Conn{Conn: c, timeout: l.config.Timeout, r: bufio.NewReader(c)}, nil
+}
+
+// Conn is a connection from a trusted source, that starts with a PROXY protocol header.
+type Conn struct {
+	net.Conn
+	timeout time.Duration
+	r       *bufio.Reader
+
+	once   sync.Once
+	remote net.Addr
+	err    error
+}
+
+func (c *Conn) readHeader() {
+	timeout := c.timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	c.Conn.SetReadDeadline(time.Now().Add(timeout))
+	c.remote, c.err = ReadHeader(c.r)
+	c.Conn.SetReadDeadline(time.Time{})
+	if c.remote == nil {
+		// A header without an address, the connection is from the load balancer itself.
+		c.remote = c.Conn.RemoteAddr()
+	}
+}
+
+// Read implements net.Conn. It returns an error if the connection doesn't start with a valid header.
+func (c *Conn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.r.Read(b)
+}
+
+// RemoteAddr implements net.Conn. It returns the address of the client from the header.
+func (c *Conn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	return c.remote
+}
+
+// NewPacketConn returns a packet conn that strips the version 2 PROXY protocol header from datagrams from
+// trusted sources, and reports the client address in it as the source. Datagrams from trusted sources
+// without a valid header are dropped. Responses to a client are sent back through the load balancer
+// the last datagram of that client came from.
+func NewPacketConn(p net.PacketConn, c *Config) net.PacketConn {
+	return &packetConn{PacketConn: p, config: c, proxies: make(map[string]proxyEntry)}
+}
+
+type packetConn struct {
+	net.PacketConn
+	config *Config
+
+	mu      sync.Mutex
+	proxies map[string]proxyEntry // keyed by client address
+}
+
+type proxyEntry struct {
+	addr net.Addr
+	seen time.Time
+}
+
+// maxProxyEntries is the number of client addresses we keep before expiring old ones.
+const maxProxyEntries = 10000
+
+// proxyEntryTTL is how long we remember the load balancer of a client after its last datagram, the
+// entries are only expired when there are too many.
+const proxyEntryTTL = 10 * time.Second
+
+// ReadFrom implements net.PacketConn.
+func (p *packetConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	for {
+		n, addr, err := p.PacketConn.ReadFrom(b)
+		if err != nil || !p.config.Trusts(addr) {
+			return n, addr, err
+		}
+		src, rest, err := ParseV2(b[:n])
+		if err != nil {
+			continue
+		}
+		n = copy(b, rest)
+		if src == nil {
+			return n, addr, nil
+		}
+		p.remember(src, addr)
+		return n, src, nil
+	}
+}
+
+// WriteTo implements net.PacketConn.
+func (p *packetConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	p.mu.Lock()
+	e, ok := p.proxies[addr.String()]
+	p.mu.Unlock()
+	if ok {
+		addr = e.addr
+	}
+	return p.PacketConn.WriteTo(b, addr)
+}
+
+func (p *packetConn) remember(client, proxy net.Addr) {
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.proxies) >= maxProxyEntries {
+		for k, e := range p.proxies {
+			if now.Sub(e.seen) > proxyEntryTTL {
+				delete(p.proxies, k)
+			}
+		}
+	}
+	p.proxies[client.String()] = proxyEntry{addr: proxy, seen: now}
+}
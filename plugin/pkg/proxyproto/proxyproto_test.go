@@ -0,0 +1,217 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// v2Header returns a version 2 PROXY header for src and dst, with the family fam.
+func v2Header(fam byte, src, dst *net.UDPAddr) []byte {
+	var addrs []byte
+	switch fam {
+	case v2FamTCP4, v2FamUDP4:
+		addrs = append(append(addrs, src.IP.To4()...), dst.IP.To4()...)
+	case v2FamTCP6, v2FamUDP6:
+		addrs = append(append(addrs, src.IP.To16()...), dst.IP.To16()...)
+	}
+	if addrs != nil {
+		addrs = binary.BigEndian.AppendUint16(addrs, uint16(src.Port))
+		addrs = binary.BigEndian.AppendUint16(addrs, uint16(dst.Port))
+	}
+	b := append(append([]byte{}, v2Signature...), v2CmdProxy, fam)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(addrs)))
+	return append(b, addrs...)
+}
+
+func TestReadHeader(t *testing.T) {
+	src4 := &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 53000}
+	dst4 := &net.UDPAddr{IP: net.ParseIP("198.51.100.1"), Port: 53}
+	src6 := &net.UDPAddr{IP: net.ParseIP("2001:db8::1"), Port: 53001}
+	dst6 := &net.UDPAddr{IP: net.ParseIP("2001:db8::53"), Port: 53}
+	local := append(append([]byte{}, v2Signature...), v2CmdLocal, v2FamUnspec, 0, 0)
+
+	tests := []struct {
+		header   string
+		expected string
+		err      bool
+	}{
+		{"PROXY TCP4 192.0.2.1 198.51.100.1 53000 53\r\n", "192.0.2.1:53000", false},
+		{"PROXY TCP6 2001:db8::1 2001:db8::53 53001 53\r\n", "[2001:db8::1]:53001", false},
+		{"PROXY UNKNOWN\r\n", "", false},
+		{"PROXY UNKNOWN ffff::1 ffff::2 1 2\r\n", "", false},
+		{string(v2Header(v2FamTCP4, src4, dst4)), "192.0.2.1:53000", false},
+		{string(v2Header(v2FamTCP6, src6, dst6)), "[2001:db8::1]:53001", false},
+		{string(local), "", false},
+		// invalid
+		{"PROXY TCP4 192.0.2.1 198.51.100.1 53000 53\n", "", true},
+		{"PROXY TCP4 2001:db8::1 198.51.100.1 53000 53\r\n", "", true},
+		{"PROXY TCP4 192.0.2.1 198.51.100.1 530000 53\r\n", "", true},
+		{"PROXY UDP4 192.0.2.1 198.51.100.1 53000 53\r\n", "", true},
+		{"PROXY TCP4 192.0.2.1\r\n", "", true},
+		{"PROXY " + strings.Repeat("A", 200), "", true},
+		{"GET / HTTP/1.1\r\nHost: example.org\r\n\r\n", "", true},
+		{string(v2Header(v2FamTCP4, src4, dst4)[:20]), "", true},
+	}
+
+	for i, tc := range tests {
+		r := bufio.NewReader(strings.NewReader(tc.header + "payload"))
+		addr, err := ReadHeader(r)
+		if tc.err {
+			if err == nil {
+				t.Errorf("Test %d: expected an error, got %v", i, addr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		got := ""
+		if addr != nil {
+			got = addr.String()
+		}
+		if got != tc.expected {
+			t.Errorf("Test %d: expected address %q, got %q", i, tc.expected, got)
+		}
+		if rest, _ := io.ReadAll(r); string(rest) != "payload" {
+			t.Errorf("Test %d: expected the payload after the header, got %q", i, rest)
+		}
+	}
+}
+
+func TestParseV2UDP(t *testing.T) {
+	src := &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 53000}
+	dst := &net.UDPAddr{IP: net.ParseIP("198.51.100.1"), Port: 53}
+	b := append(v2Header(v2FamUDP4, src, dst), "query"...)
+
+	addr, rest, err := ParseV2(b)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if a, ok := addr.(*net.UDPAddr); !ok || a.String() != src.String() {
+		t.Errorf("Expected UDP address %s, got %v", src, addr)
+	}
+	if string(rest) != "query" {
+		t.Errorf("Expected the query after the header, got %q", rest)
+	}
+	if _, _, err := ParseV2([]byte("query")); err != ErrNoHeader {
+		t.Errorf("Expected %s, got %v", ErrNoHeader, err)
+	}
+}
+
+func trusted(t *testing.T, cidr string) *Config {
+	_, n, err := net.ParseCIDR(cidr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Config{Trusted: []*net.IPNet{n}, Timeout: time.Second}
+}
+
+func TestListener(t *testing.T) {
+	for _, tc := range []struct {
+		cidr     string
+		header   string
+		expected string
+	}{
+		{"127.0.0.0/8", "PROXY TCP4 192.0.2.1 198.51.100.1 53000 53\r\n", "192.0.2.1:53000"},
+		{"192.0.2.0/24", "", "127.0.0.1"}, // not trusted, the connection is used as is
+	} {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		l := NewListener(ln, trusted(t, tc.cidr))
+
+		c, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Write([]byte(tc.header + "query"))
+
+		conn, err := l.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if addr := conn.RemoteAddr().String(); !strings.HasPrefix(addr, tc.expected) {
+			t.Errorf("Expected remote address %s, got %s", tc.expected, addr)
+		}
+		buf := make([]byte, 5)
+		if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "query" {
+			t.Errorf("Expected to read the query, got %q: %v", buf, err)
+		}
+		conn.Close()
+		c.Close()
+		l.Close()
+	}
+}
+
+func TestListenerInvalidHeader(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewListener(ln, trusted(t, "127.0.0.0/8"))
+	defer l.Close()
+
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.Write([]byte("\x00\x1cnot a PROXY header"))
+
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Read(make([]byte, 10)); err != ErrNoHeader {
+		t.Errorf("Expected %s, got %v", ErrNoHeader, err)
+	}
+}
+
+func TestPacketConn(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewPacketConn(pc, trusted(t, "127.0.0.0/8"))
+	defer p.Close()
+
+	lb, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lb.Close()
+
+	client := &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 53000}
+	dst := &net.UDPAddr{IP: net.ParseIP("198.51.100.1"), Port: 53}
+	lb.WriteTo([]byte("no header"), pc.LocalAddr()) // dropped
+	lb.WriteTo(append(v2Header(v2FamUDP4, client, dst), "query"...), pc.LocalAddr())
+
+	p.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 512)
+	n, addr, err := p.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "query" || addr.String() != client.String() {
+		t.Errorf("Expected query from %s, got %q from %s", client, buf[:n], addr)
+	}
+
+	// The response goes back to the load balancer.
+	if _, err := p.WriteTo([]byte("response"), addr); err != nil {
+		t.Fatal(err)
+	}
+	lb.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err = lb.ReadFrom(buf)
+	if err != nil || !bytes.Equal(buf[:n], []byte("response")) {
+		t.Errorf("Expected the response at the load balancer, got %q: %v", buf[:n], err)
+	}
+}
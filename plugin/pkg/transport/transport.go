@@ -2,10 +2,12 @@ package transport
 
 // These transports are supported by CoreDNS.
 const (
-	DNS   = "dns"
-	TLS   = "tls"
-	GRPC  = "grpc"
-	HTTPS = "https"
+	DNS      = "dns"
+	TLS      = "tls"
+	GRPC     = "grpc"
+	HTTPS    = "https"
+	DNSCrypt = "dnscrypt"
+	Unix     = "unix"
 )
 
 // Port numbers for the various transports.
@@ -18,4 +20,6 @@ const (
 	GRPCPort = "443"
 	// HTTPSPort is the default port for DNS-over-HTTPS.
 	HTTPSPort = "443"
+	// DNSCryptPort is the default port for DNSCrypt.
+	DNSCryptPort = "443"
 )
@@ -0,0 +1,8 @@
+// Package udpbatch reads and writes UDP datagrams in batches, with recvmmsg and sendmmsg on Linux.
+package udpbatch
+
+// DefaultSize is the default number of datagrams read or written with a single system call.
+const DefaultSize = 64
+
+// bufSize is the size of the buffer of each datagram that is read, larger datagrams are truncated.
+const bufSize = 4096
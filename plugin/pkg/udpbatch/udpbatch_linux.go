@@ -0,0 +1,132 @@
+package udpbatch
+
+import (
+	"net"
+	"sync"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// Supported is true when batched reads and writes are supported on this platform.
+const Supported = true
+
+// batchConn is implemented by ipv4.PacketConn and ipv6.PacketConn.
+type batchConn interface {
+	ReadBatch([]ipv4.Message, int) (int, error)
+	WriteBatch([]ipv4.Message, int) (int, error)
+}
+
+// New returns a net.PacketConn that reads up to size datagrams from c with a single recvmmsg call,
+// and writes the datagrams that are queued by concurrent writers with sendmmsg. Datagrams larger than
+// 4096 bytes are truncated when read.
+//
+// WriteTo returns as soon as the datagram is queued, and when it returns the datagram has been
+// handed to the kernel by this or another writer. Errors of datagrams that are written by another
+// writer are not returned.
+func New(c *net.UDPConn, size int) net.PacketConn {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	var bc batchConn = ipv4.NewPacketConn(c)
+	if a, ok := c.LocalAddr().(*net.UDPAddr); ok && a.IP.To4() == nil {
+		bc = ipv6.NewPacketConn(c)
+	}
+
+	b := &conn{UDPConn: c, bc: bc, rmsgs: make([]ipv4.Message, size), wmsgs: make([]ipv4.Message, 0, size)}
+	for i := range b.rmsgs {
+		b.rmsgs[i].Buffers = [][]byte{make([]byte, bufSize)}
+	}
+	return b
+}
+
+type conn struct {
+	*net.UDPConn
+	bc batchConn
+
+	rmu   sync.Mutex
+	rmsgs []ipv4.Message // datagrams read by the last ReadBatch
+	rn    int            // number of datagrams read
+	ri    int            // next datagram to return
+
+	wmu      sync.Mutex
+	wmsgs    []ipv4.Message // datagrams queued for the next WriteBatch
+	flushing bool           // a writer is writing the queued datagrams
+}
+
+// ReadFrom implements net.PacketConn.
+func (c *conn) ReadFrom(b []byte) (int, net.Addr, error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+
+	if c.ri == c.rn {
+		n, err := c.bc.ReadBatch(c.rmsgs, 0)
+		if err != nil {
+			return 0, nil, err
+		}
+		c.rn, c.ri = n, 0
+	}
+
+	m := c.rmsgs[c.ri]
+	c.ri++
+	n := copy(b, m.Buffers[0][:m.N])
+	return n, m.Addr, nil
+}
+
+// WriteTo implements net.PacketConn.
+func (c *conn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	buf := make([]byte, len(b))
+	copy(buf, b)
+
+	c.wmu.Lock()
+	c.wmsgs = append(c.wmsgs, ipv4.Message{Buffers: [][]byte{buf}, Addr: addr})
+	if c.flushing {
+		// The writer that is flushing picks up this datagram as well.
+		c.wmu.Unlock()
+		return len(b), nil
+	}
+	c.flushing = true
+
+	var err error
+	for len(c.wmsgs) > 0 {
+		msgs := c.wmsgs
+		c.wmsgs = make([]ipv4.Message, 0, cap(msgs))
+		c.wmu.Unlock()
+
+		if werr := c.write(msgs); werr != nil && err == nil {
+			err = werr
+		}
+
+		c.wmu.Lock()
+	}
+	c.flushing = false
+	c.wmu.Unlock()
+
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// write writes msgs, a datagram that fails is dropped and the first error is returned.
+func (c *conn) write(msgs []ipv4.Message) error {
+	var err error
+	for len(msgs) > 0 {
+		n, werr := c.bc.WriteBatch(msgs, 0)
+		if n < 0 {
+			n = 0
+		}
+		if werr != nil {
+			if err == nil {
+				err = werr
+			}
+			// Skip the datagram that failed.
+			n++
+		}
+		if n > len(msgs) {
+			n = len(msgs)
+		}
+		msgs = msgs[n:]
+	}
+	return err
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package udpbatch
+
+import "net"
+
+// Supported is true when batched reads and writes are supported on this platform.
+const Supported = false
+
+// New returns c, batching is only supported on Linux.
+func New(c *net.UDPConn, size int) net.PacketConn { return c }
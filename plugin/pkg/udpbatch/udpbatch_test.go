@@ -0,0 +1,98 @@
+package udpbatch
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReadWrite(t *testing.T) {
+	uc, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := New(uc, 4)
+	defer c.Close()
+
+	client, err := net.DialUDP("udp", nil, uc.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	const count = 10
+	for i := 0; i < count; i++ {
+		if _, err := client.Write([]byte(fmt.Sprintf("query %d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Echo the datagrams from concurrent writers, so writes are queued while another is flushing.
+	var wg sync.WaitGroup
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for i := 0; i < count; i++ {
+		buf := make([]byte, 512)
+		n, addr, err := c.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("Failed to read datagram %d: %s", i, err)
+		}
+		if got, want := string(buf[:n]), fmt.Sprintf("query %d", i); got != want {
+			t.Errorf("Expected %q, got %q", want, got)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.WriteTo(buf[:n], addr); err != nil {
+				t.Errorf("Failed to write: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	seen := map[string]bool{}
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for i := 0; i < count; i++ {
+		buf := make([]byte, 512)
+		n, err := client.Read(buf)
+		if err != nil {
+			t.Fatalf("Failed to read response %d: %s", i, err)
+		}
+		seen[string(buf[:n])] = true
+	}
+	for i := 0; i < count; i++ {
+		if q := fmt.Sprintf("query %d", i); !seen[q] {
+			t.Errorf("Expected a response to %q", q)
+		}
+	}
+}
+
+func TestReadTruncated(t *testing.T) {
+	uc, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := New(uc, 0)
+	defer c.Close()
+
+	client, err := net.DialUDP("udp", nil, uc.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.Write(make([]byte, 100))
+
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 10)
+	n, addr, err := c.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 10 {
+		t.Errorf("Expected the datagram to be truncated to 10 bytes, got %d", n)
+	}
+	if addr.String() != client.LocalAddr().String() {
+		t.Errorf("Expected the datagram to be from %s, got %s", client.LocalAddr(), addr)
+	}
+}
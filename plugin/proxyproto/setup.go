@@ -0,0 +1,85 @@
+// Package proxyproto implements a plugin that accepts PROXY protocol headers from trusted load balancers.
+package proxyproto
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/proxyproto"
+)
+
+func init() { plugin.Register("proxyproto", setup) }
+
+func setup(c *caddy.Controller) error {
+	pc, err := parse(c)
+	if err != nil {
+		return plugin.Error("proxyproto", err)
+	}
+	dnsserver.GetConfig(c).ProxyProtocol = pc
+	return nil
+}
+
+func parse(c *caddy.Controller) (*proxyproto.Config, error) {
+	pc := &proxyproto.Config{Timeout: proxyproto.DefaultTimeout}
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		args := c.RemainingArgs()
+		if len(args) == 0 {
+			return nil, c.ArgErr()
+		}
+		for _, a := range args {
+			n, err := network(a)
+			if err != nil {
+				return nil, c.Errf("invalid network %q", a)
+			}
+			pc.Trusted = append(pc.Trusted, n)
+		}
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "timeout":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				d, err := time.ParseDuration(args[0])
+				if err != nil {
+					return nil, c.Errf("invalid duration %q", args[0])
+				}
+				if d <= 0 {
+					return nil, c.Errf("timeout must be positive, got %s", d)
+				}
+				pc.Timeout = d
+			default:
+				return nil, c.Errf("unknown property %q", c.Val())
+			}
+		}
+	}
+	return pc, nil
+}
+
+// network parses a network in CIDR notation, or a single IP address.
+func network(s string) (*net.IPNet, error) {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, &net.ParseError{Type: "IP address", Text: s}
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+	}
+	_, n, err := net.ParseCIDR(s)
+	return n, err
+}
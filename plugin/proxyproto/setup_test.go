@@ -0,0 +1,67 @@
+package proxyproto
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input           string
+		shouldErr       bool
+		expectedTrusted []string
+		expectedTimeout time.Duration
+		expectedErr     string
+	}{
+		// positive
+		{"proxyproto 10.0.0.0/8", false, []string{"10.0.0.0/8"}, 5 * time.Second, ""},
+		{"proxyproto 10.0.0.0/8 192.0.2.1 2001:db8::/32 {\ntimeout 2s\n}", false, []string{"10.0.0.0/8", "192.0.2.1/32", "2001:db8::/32"}, 2 * time.Second, ""},
+		// negative
+		{"proxyproto", true, nil, 0, "Wrong argument count"},
+		{"proxyproto example.org", true, nil, 0, "invalid network"},
+		{"proxyproto 10.0.0.0/33", true, nil, 0, "invalid network"},
+		{"proxyproto 10.0.0.0/8 {\ntimeout\n}", true, nil, 0, "Wrong argument count"},
+		{"proxyproto 10.0.0.0/8 {\ntimeout 0s\n}", true, nil, 0, "must be positive"},
+		{"proxyproto 10.0.0.0/8 {\nversion 2\n}", true, nil, 0, "unknown property"},
+		{"proxyproto 10.0.0.0/8\nproxyproto 10.0.0.0/8", true, nil, 0, "plugin"},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		err := setup(c)
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error but found none for input %s", i, test.input)
+			} else if !strings.Contains(err.Error(), test.expectedErr) {
+				t.Errorf("Test %d: expected error to contain: %v, found error: %v", i, test.expectedErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Test %d: expected no error but found one for input %s, got: %v", i, test.input, err)
+		}
+
+		pc := dnsserver.GetConfig(c).ProxyProtocol
+		if pc == nil {
+			t.Fatalf("Test %d: expected the PROXY protocol to be configured", i)
+		}
+		var trusted []string
+		for _, n := range pc.Trusted {
+			trusted = append(trusted, n.String())
+		}
+		if strings.Join(trusted, " ") != strings.Join(test.expectedTrusted, " ") {
+			t.Errorf("Test %d: expected trusted networks %v, got %v", i, test.expectedTrusted, trusted)
+		}
+		if pc.Timeout != test.expectedTimeout {
+			t.Errorf("Test %d: expected timeout %s, got %s", i, test.expectedTimeout, pc.Timeout)
+		}
+		if !pc.Trusts(&net.TCPAddr{IP: net.ParseIP("10.1.2.3")}) {
+			t.Errorf("Test %d: expected 10.1.2.3 to be trusted", i)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/cache"
+)
+
+// bucket is a token bucket: it holds up to burst tokens and is refilled with rate tokens per second.
+// Each query takes one token, when there are none left the query is over the limit.
+type bucket struct {
+	sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// take takes a token from b and returns true, or returns false if there are no tokens.
+func (b *bucket) take(rate, burst float64, now time.Time) bool {
+	b.Lock()
+	defer b.Unlock()
+
+	b.tokens += now.Sub(b.last).Seconds() * rate
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// table holds the buckets for all clients. It is bounded in size: when full, random buckets are
+// evicted, which gives the evicted client a full bucket on its next query.
+type table struct {
+	c *cache.Cache
+}
+
+func newTable(size int) *table { return &table{c: cache.New(size)} }
+
+// get returns the bucket for key, a new bucket holds burst tokens.
+func (t *table) get(key string, burst float64, now time.Time) *bucket {
+	k := cache.Hash([]byte(key))
+	if v, ok := t.c.Get(k); ok {
+		return v.(*bucket)
+	}
+	b := &bucket{tokens: burst, last: now}
+	t.c.Add(k, b)
+	return b
+}
+
+// len returns the number of buckets in the table.
+func (t *table) len() int { return t.c.Len() }
@@ -0,0 +1,32 @@
+package ratelimit
+
+import (
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// limited is the number of queries over the limit, by the action taken.
+	limited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "ratelimit",
+		Name:      "limited_queries_total",
+		Help:      "Counter of queries that exceeded the rate limit.",
+	}, []string{"server", "zone", "action"})
+	// allowed is the number of queries that bypassed the limit because of the allow list.
+	allowed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "ratelimit",
+		Name:      "allowlisted_queries_total",
+		Help:      "Counter of queries that bypassed the rate limit because the client is allow listed.",
+	}, []string{"server"})
+	// clients is the number of clients that are tracked.
+	clients = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "ratelimit",
+		Name:      "tracked_clients",
+		Help:      "The number of clients tracked.",
+	}, []string{"server"})
+)
@@ -0,0 +1,170 @@
+// Package ratelimit implements a plugin that limits the rate of queries per client.
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/plugin/metrics"
+	"github.com/coredns/coredns/request"
+
+	"github.com/infobloxopen/go-trees/iptree"
+	"github.com/miekg/dns"
+)
+
+// Ratelimit is a plugin that limits the number of queries per second a client may send.
+type Ratelimit struct {
+	Next  plugin.Handler
+	Zones []string
+
+	limit     limit
+	overrides []override // per zone limits, longest zone first.
+
+	key      keyType
+	ipv4Mask net.IPMask
+	ipv6Mask net.IPMask
+	label    string // metadata label used as key, when key is keyMetadata.
+
+	allow  *iptree.Tree // clients that are never limited, nil if there are none.
+	action action
+
+	table *table
+
+	// Testing.
+	now func() time.Time
+}
+
+// limit is the rate (in queries per second) and burst of a bucket.
+type limit struct {
+	rate  float64
+	burst float64
+}
+
+// override is a limit for the names in zone.
+type override struct {
+	zone string
+	limit
+}
+
+type keyType int
+
+const (
+	keyIP keyType = iota
+	keyPrefix
+	keyMetadata
+)
+
+type action int
+
+const (
+	actionRefuse action = iota
+	actionDrop
+	actionTruncate
+)
+
+var actionNames = map[action]string{actionRefuse: "refuse", actionDrop: "drop", actionTruncate: "truncate"}
+
+// New returns a Ratelimit with default settings.
+func New() *Ratelimit {
+	return &Ratelimit{
+		Zones:    []string{"."},
+		limit:    limit{rate: defaultRate, burst: defaultRate},
+		key:      keyIP,
+		ipv4Mask: net.CIDRMask(defaultIPv4Prefix, 32),
+		ipv6Mask: net.CIDRMask(defaultIPv6Prefix, 128),
+		table:    newTable(defaultTableSize),
+		now:      time.Now,
+	}
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (rl *Ratelimit) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+
+	zone := plugin.Zones(rl.Zones).Matches(state.Name())
+	if zone == "" {
+		return plugin.NextOrFailure(rl.Name(), rl.Next, ctx, w, r)
+	}
+
+	server := metrics.WithServer(ctx)
+	ip := net.ParseIP(state.IP())
+	if rl.allow != nil && ip != nil {
+		if _, ok := rl.allow.GetByIP(ip); ok {
+			allowed.WithLabelValues(server).Inc()
+			return plugin.NextOrFailure(rl.Name(), rl.Next, ctx, w, r)
+		}
+	}
+
+	key := rl.clientKey(ctx, state, ip)
+	if key == "" {
+		return plugin.NextOrFailure(rl.Name(), rl.Next, ctx, w, r)
+	}
+	l := rl.limit
+	for _, o := range rl.overrides {
+		if dns.IsSubDomain(o.zone, state.Name()) {
+			key += "/" + o.zone
+			l = o.limit
+			zone = o.zone
+			break
+		}
+	}
+
+	now := rl.now()
+	b := rl.table.get(key, l.burst, now)
+	clients.WithLabelValues(server).Set(float64(rl.table.len()))
+	if b.take(l.rate, l.burst, now) {
+		return plugin.NextOrFailure(rl.Name(), rl.Next, ctx, w, r)
+	}
+
+	limited.WithLabelValues(server, zone, actionNames[rl.action]).Inc()
+	switch rl.action {
+	case actionDrop:
+		return dns.RcodeSuccess, nil
+	case actionTruncate:
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Truncated = true
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	}
+	m := new(dns.Msg)
+	m.SetRcode(r, dns.RcodeRefused)
+	w.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
+// clientKey returns the key that identifies the client, or the empty string if no key can be
+// determined. Such queries are not limited. A query without a value for the metadata label is
+// limited by the IP address of the client.
+func (rl *Ratelimit) clientKey(ctx context.Context, state request.Request, ip net.IP) string {
+	switch rl.key {
+	case keyMetadata:
+		if f := metadata.ValueFunc(ctx, rl.label); f != nil {
+			if v := f(); v != "" {
+				return v
+			}
+		}
+	case keyPrefix:
+		if ip == nil {
+			return ""
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			return ip4.Mask(rl.ipv4Mask).String()
+		}
+		return ip.Mask(rl.ipv6Mask).String()
+	}
+	return state.IP()
+}
+
+// Name implements the Handler interface.
+func (rl *Ratelimit) Name() string { return "ratelimit" }
+
+const (
+	defaultRate       = 100
+	defaultIPv4Prefix = 24
+	defaultIPv6Prefix = 56
+	defaultTableSize  = 100000
+)
@@ -0,0 +1,133 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func newRatelimit(t *testing.T, config string) *Ratelimit {
+	c := caddy.NewTestController("dns", config)
+	c.ServerBlockKeys = []string{"."}
+	rl, err := parse(c)
+	if err != nil {
+		t.Fatalf("Failed to parse %q: %s", config, err)
+	}
+	rl.Next = plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+	return rl
+}
+
+// query sends a query for qname and returns the rcode of the reply, 256 for a truncated reply or -1
+// when nothing was written.
+func query(rl *Ratelimit, ctx context.Context, qname string) int {
+	req := new(dns.Msg)
+	req.SetQuestion(qname, dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	rl.ServeDNS(ctx, rec, req)
+	if rec.Msg == nil {
+		return -1
+	}
+	if rec.Msg.Truncated {
+		return 256
+	}
+	return rec.Msg.Rcode
+}
+
+func TestRatelimit(t *testing.T) {
+	tests := []struct {
+		config   string
+		qname    string
+		expected []int
+	}{
+		{"ratelimit {\nrate 1 2\n}", "example.org.", []int{0, 0, dns.RcodeRefused, dns.RcodeRefused}},
+		{"ratelimit {\nrate 1 2\naction drop\n}", "example.org.", []int{0, 0, -1}},
+		{"ratelimit {\nrate 1\naction truncate\n}", "example.org.", []int{0, 256}},
+		{"ratelimit {\nrate 1\nallow 10.240.0.1\n}", "example.org.", []int{0, 0, 0}},
+		{"ratelimit example.org {\nrate 1\n}", "example.net.", []int{0, 0, 0}},
+		{"ratelimit {\nrate 1\nzone example.net 3\n}", "www.example.net.", []int{0, 0, 0, dns.RcodeRefused}},
+	}
+
+	for i, tc := range tests {
+		rl := newRatelimit(t, tc.config)
+		now := time.Now()
+		rl.now = func() time.Time { return now }
+
+		for j, e := range tc.expected {
+			if got := query(rl, context.TODO(), tc.qname); got != e {
+				t.Errorf("Test %d, query %d: expected %d, got %d", i, j, e, got)
+			}
+		}
+	}
+}
+
+func TestRatelimitRefill(t *testing.T) {
+	rl := newRatelimit(t, "ratelimit {\nrate 2\n}")
+	now := time.Now()
+	rl.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		query(rl, context.TODO(), "example.org.")
+	}
+	if got := query(rl, context.TODO(), "example.org."); got != dns.RcodeRefused {
+		t.Fatalf("Expected query to be refused, got %d", got)
+	}
+	now = now.Add(500 * time.Millisecond)
+	if got := query(rl, context.TODO(), "example.org."); got != dns.RcodeSuccess {
+		t.Errorf("Expected query to be allowed after refill, got %d", got)
+	}
+}
+
+func TestRatelimitMetadata(t *testing.T) {
+	rl := newRatelimit(t, "ratelimit {\nrate 1\nkey metadata test/tenant\n}")
+	now := time.Now()
+	rl.now = func() time.Time { return now }
+
+	tests := []struct {
+		tenant   string
+		set      bool // set a value for the label
+		expected int
+	}{
+		{"a", true, dns.RcodeSuccess},
+		{"b", true, dns.RcodeSuccess},
+		{"a", true, dns.RcodeRefused},
+		// Without a value, the IP address of the client is used.
+		{"", false, dns.RcodeSuccess},
+		{"", true, dns.RcodeRefused},
+		{"", false, dns.RcodeRefused},
+	}
+	for i, tc := range tests {
+		ctx := metadata.ContextWithMetadata(context.Background())
+		if tc.set {
+			tenant := tc.tenant
+			metadata.SetValueFunc(ctx, "test/tenant", func() string { return tenant })
+		}
+		if got := query(rl, ctx, "example.org."); got != tc.expected {
+			t.Errorf("Test %d: expected %d for tenant %q, got %d", i, tc.expected, tc.tenant, got)
+		}
+	}
+}
+
+func TestTableBounded(t *testing.T) {
+	tb := newTable(1024)
+	now := time.Now()
+	for i := 0; i < 100000; i++ {
+		tb.get(fmt.Sprintf("client-%d", i), 1, now)
+	}
+	if tb.len() > 1024 {
+		t.Errorf("Expected table to hold at most %d buckets, got %d", 1024, tb.len())
+	}
+}
@@ -0,0 +1,209 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metadata"
+
+	"github.com/infobloxopen/go-trees/iptree"
+)
+
+func init() { plugin.Register("ratelimit", setup) }
+
+func setup(c *caddy.Controller) error {
+	rl, err := parse(c)
+	if err != nil {
+		return plugin.Error("ratelimit", err)
+	}
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		rl.Next = next
+		return rl
+	})
+
+	return nil
+}
+
+func parse(c *caddy.Controller) (*Ratelimit, error) {
+	rl := New()
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		rl.Zones = plugin.OriginsFromArgsOrServerBlock(c.RemainingArgs(), c.ServerBlockKeys)
+		size := defaultTableSize
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "rate":
+				l, err := parseLimit(c, c.RemainingArgs())
+				if err != nil {
+					return nil, err
+				}
+				rl.limit = l
+			case "zone":
+				args := c.RemainingArgs()
+				if len(args) < 2 {
+					return nil, c.ArgErr()
+				}
+				l, err := parseLimit(c, args[1:])
+				if err != nil {
+					return nil, err
+				}
+				rl.overrides = append(rl.overrides, override{zone: plugin.Name(args[0]).Normalize(), limit: l})
+			case "key":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				switch args[0] {
+				case "ip":
+					if len(args) != 1 {
+						return nil, c.ArgErr()
+					}
+					rl.key = keyIP
+				case "prefix":
+					if len(args) > 3 {
+						return nil, c.ArgErr()
+					}
+					rl.key = keyPrefix
+					if len(args) > 1 {
+						m, err := parseMask(args[1], 32)
+						if err != nil {
+							return nil, err
+						}
+						rl.ipv4Mask = m
+					}
+					if len(args) > 2 {
+						m, err := parseMask(args[2], 128)
+						if err != nil {
+							return nil, err
+						}
+						rl.ipv6Mask = m
+					}
+				case "metadata":
+					if len(args) != 2 {
+						return nil, c.ArgErr()
+					}
+					if !metadata.IsLabel(args[1]) {
+						return nil, fmt.Errorf("invalid metadata label: %q", args[1])
+					}
+					rl.key = keyMetadata
+					rl.label = args[1]
+				default:
+					return nil, c.Errf("unknown key '%s'", args[0])
+				}
+			case "allow":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				if rl.allow == nil {
+					rl.allow = iptree.NewTree()
+				}
+				for _, a := range args {
+					_, n, err := net.ParseCIDR(a)
+					if err != nil {
+						ip := net.ParseIP(a)
+						if ip == nil {
+							return nil, fmt.Errorf("invalid address or network: %q", a)
+						}
+						bits := 32
+						if ip.To4() == nil {
+							bits = 128
+						}
+						n = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+					}
+					rl.allow.InplaceInsertNet(n, struct{}{})
+				}
+			case "action":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				switch args[0] {
+				case "refuse":
+					rl.action = actionRefuse
+				case "drop":
+					rl.action = actionDrop
+				case "truncate":
+					rl.action = actionTruncate
+				default:
+					return nil, c.Errf("unknown action '%s'; expect 'refuse', 'drop' or 'truncate'", args[0])
+				}
+			case "max-clients":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				n, err := strconv.Atoi(args[0])
+				if err != nil {
+					return nil, err
+				}
+				if n < 1 {
+					return nil, fmt.Errorf("max-clients must be positive: %d", n)
+				}
+				size = n
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+
+		// Most specific zone first.
+		sort.SliceStable(rl.overrides, func(i, j int) bool {
+			return len(rl.overrides[i].zone) > len(rl.overrides[j].zone)
+		})
+		rl.table = newTable(size)
+	}
+	return rl, nil
+}
+
+// parseLimit parses: QPS [BURST]. The burst defaults to QPS, with a minimum of 1.
+func parseLimit(c *caddy.Controller, args []string) (limit, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return limit{}, c.ArgErr()
+	}
+	rate, err := strconv.ParseFloat(args[0], 64)
+	if err != nil {
+		return limit{}, err
+	}
+	if rate <= 0 {
+		return limit{}, fmt.Errorf("rate must be positive: %s", args[0])
+	}
+	l := limit{rate: rate, burst: rate}
+	if l.burst < 1 {
+		l.burst = 1
+	}
+	if len(args) == 2 {
+		burst, err := strconv.ParseFloat(args[1], 64)
+		if err != nil {
+			return limit{}, err
+		}
+		if burst < 1 {
+			return limit{}, fmt.Errorf("burst must be at least 1: %s", args[1])
+		}
+		l.burst = burst
+	}
+	return l, nil
+}
+
+func parseMask(s string, bits int) (net.IPMask, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, err
+	}
+	if n < 1 || n > bits {
+		return nil, fmt.Errorf("prefix length should fall in range [1, %d]: %d", bits, n)
+	}
+	return net.CIDRMask(n, bits), nil
+}
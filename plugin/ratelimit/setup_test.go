@@ -0,0 +1,94 @@
+package ratelimit
+
+import (
+	"testing"
+
+	"github.com/coredns/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		limit     limit
+		overrides int
+		key       keyType
+		action    action
+	}{
+		{`ratelimit`, false, limit{defaultRate, defaultRate}, 0, keyIP, actionRefuse},
+		{`ratelimit {
+			rate 10 20
+			zone example.org 5
+			zone svc.example.org 50 100
+			key prefix 24 64
+			allow 10.0.0.0/8 ::1
+			action drop
+			max-clients 1000
+		}`, false, limit{10, 20}, 2, keyPrefix, actionDrop},
+		{`ratelimit {
+			rate 0.5
+			key metadata kubernetes/client-namespace
+			action truncate
+		}`, false, limit{0.5, 1}, 0, keyMetadata, actionTruncate},
+		// fails
+		{`ratelimit {
+			rate 0
+		}`, true, limit{}, 0, keyIP, actionRefuse},
+		{`ratelimit {
+			rate 10 0
+		}`, true, limit{}, 0, keyIP, actionRefuse},
+		{`ratelimit {
+			zone example.org
+		}`, true, limit{}, 0, keyIP, actionRefuse},
+		{`ratelimit {
+			key prefix 33
+		}`, true, limit{}, 0, keyIP, actionRefuse},
+		{`ratelimit {
+			key metadata nolabel
+		}`, true, limit{}, 0, keyIP, actionRefuse},
+		{`ratelimit {
+			key port
+		}`, true, limit{}, 0, keyIP, actionRefuse},
+		{`ratelimit {
+			allow 10.0.0.0/33
+		}`, true, limit{}, 0, keyIP, actionRefuse},
+		{`ratelimit {
+			action servfail
+		}`, true, limit{}, 0, keyIP, actionRefuse},
+		{`ratelimit {
+			max-clients 0
+		}`, true, limit{}, 0, keyIP, actionRefuse},
+		{`ratelimit
+		ratelimit`, true, limit{}, 0, keyIP, actionRefuse},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		rl, err := parse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found nil", i)
+			continue
+		} else if !test.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		if rl.limit != test.limit {
+			t.Errorf("Test %d: Expected limit %v, got %v", i, test.limit, rl.limit)
+		}
+		if len(rl.overrides) != test.overrides {
+			t.Errorf("Test %d: Expected %d overrides, got %d", i, test.overrides, len(rl.overrides))
+		}
+		if len(rl.overrides) > 1 && rl.overrides[0].zone != "svc.example.org." {
+			t.Errorf("Test %d: Expected most specific override first, got %s", i, rl.overrides[0].zone)
+		}
+		if rl.key != test.key {
+			t.Errorf("Test %d: Expected key %d, got %d", i, test.key, rl.key)
+		}
+		if rl.action != test.action {
+			t.Errorf("Test %d: Expected action %d, got %d", i, test.action, rl.action)
+		}
+	}
+}
@@ -17,6 +17,9 @@ func setup(c *caddy.Controller) error {
 	}
 	rd := &ready{Addr: addr}
 
+	// The gRPC health service reports the same readiness as /ready.
+	dnsserver.SetReadiness(func() bool { ok, _ := plugins.Ready(); return ok })
+
 	uniqAddr.Set(addr, rd.onStartup)
 	c.OnStartup(func() error { uniqAddr.Set(addr, rd.onStartup); return nil })
 	c.OnRestartFailed(func() error { uniqAddr.Set(addr, rd.onStartup); return nil })
@@ -0,0 +1,16 @@
+package rebind
+
+import (
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// filtered is the number of responses that held private addresses.
+var filtered = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: plugin.Namespace,
+	Subsystem: "rebind",
+	Name:      "filtered_responses_total",
+	Help:      "Counter of responses with private addresses for names outside the allowed zones.",
+}, []string{"server", "action"})
@@ -0,0 +1,97 @@
+// Package rebind implements a plugin that protects clients against DNS rebinding.
+package rebind
+
+import (
+	"context"
+	"net"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+	"github.com/coredns/coredns/request"
+
+	"github.com/infobloxopen/go-trees/iptree"
+	"github.com/miekg/dns"
+)
+
+// Rebind is a plugin that removes private addresses from responses for public names.
+type Rebind struct {
+	Next  plugin.Handler
+	Zones []string
+
+	allow    []string     // internal zones, these may resolve to private addresses.
+	networks *iptree.Tree // private networks.
+	action   action
+}
+
+type action int
+
+const (
+	actionStrip action = iota
+	actionRefuse
+)
+
+func (a action) String() string {
+	if a == actionRefuse {
+		return "refuse"
+	}
+	return "strip"
+}
+
+// defaultNetworks are the networks that are considered private.
+var defaultNetworks = []string{
+	"0.0.0.0/8",      // "this" network
+	"10.0.0.0/8",     // private
+	"100.64.0.0/10",  // shared address space
+	"127.0.0.0/8",    // loopback
+	"169.254.0.0/16", // link-local
+	"172.16.0.0/12",  // private
+	"192.168.0.0/16", // private
+	"::/128",         // unspecified
+	"::1/128",        // loopback
+	"fc00::/7",       // unique local
+	"fe80::/10",      // link-local
+}
+
+// New returns a new Rebind that considers the default networks private.
+func New() *Rebind {
+	rb := &Rebind{Zones: []string{"."}, networks: iptree.NewTree()}
+	for _, s := range defaultNetworks {
+		_, n, _ := net.ParseCIDR(s)
+		rb.networks.InplaceInsertNet(n, struct{}{})
+	}
+	return rb
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (rb *Rebind) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+	qname := state.Name()
+	if plugin.Zones(rb.Zones).Matches(qname) == "" || plugin.Zones(rb.allow).Matches(qname) != "" {
+		return plugin.NextOrFailure(rb.Name(), rb.Next, ctx, w, r)
+	}
+
+	rw := &ResponseWriter{ResponseWriter: w, rb: rb, server: metrics.WithServer(ctx)}
+	return plugin.NextOrFailure(rb.Name(), rb.Next, ctx, rw, r)
+}
+
+// Name implements the Handler interface.
+func (rb *Rebind) Name() string { return "rebind" }
+
+// private returns true if rr is an address record for a private address.
+func (rb *Rebind) private(rr dns.RR) bool {
+	var ip net.IP
+	switch rr := rr.(type) {
+	case *dns.A:
+		ip = rr.A
+	case *dns.AAAA:
+		ip = rr.AAAA
+	default:
+		return false
+	}
+	// An IPv4-mapped address in an AAAA record reaches the IPv4 host, match it against the IPv4 networks.
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	_, ok := rb.networks.GetByIP(ip)
+	return ok
+}
@@ -0,0 +1,114 @@
+package rebind
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+// backend answers with the addresses in answers, keyed by query name.
+func backend(answers map[string][]dns.RR) test.HandlerFunc {
+	return test.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = answers[r.Question[0].Name]
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+}
+
+func TestRebind(t *testing.T) {
+	answers := map[string][]dns.RR{
+		"public.example.org.": {test.A("public.example.org. 300 IN A 192.0.2.1")},
+		"evil.example.org.": {
+			test.CNAME("evil.example.org. 300 IN CNAME target.example.org."),
+			test.A("target.example.org. 300 IN A 10.0.0.1"),
+			test.A("target.example.org. 300 IN A 192.0.2.2"),
+		},
+		"loopback.example.org.":  {test.AAAA("loopback.example.org. 300 IN AAAA ::1")},
+		"mapped.example.org.":    {test.AAAA("mapped.example.org. 300 IN AAAA ::ffff:192.168.1.1")},
+		"link.example.org.":      {test.A("link.example.org. 300 IN A 169.254.169.254")},
+		"cgnat.example.org.":     {test.A("cgnat.example.org. 300 IN A 100.64.1.1")},
+		"this.example.org.":      {test.A("this.example.org. 300 IN A 0.0.0.1")},
+		"ula.example.org.":       {test.AAAA("ula.example.org. 300 IN AAAA fd00::1")},
+		"mapped10.example.org.":  {test.AAAA("mapped10.example.org. 300 IN AAAA ::ffff:10.0.0.1")},
+		"mappedcg.example.org.":  {test.AAAA("mappedcg.example.org. 300 IN AAAA ::ffff:100.64.0.1")},
+		"mappedpub.example.org.": {test.AAAA("mappedpub.example.org. 300 IN AAAA ::ffff:192.0.2.1")},
+		"listed.example.org.":    {test.A("listed.example.org. 300 IN A 198.51.100.7")},
+		"host.corp.example.":     {test.A("host.corp.example. 300 IN A 10.1.2.3")},
+	}
+
+	rb := New()
+	rb.allow = []string{"corp.example."}
+	_, n, _ := net.ParseCIDR("198.51.100.0/24")
+	rb.networks.InplaceInsertNet(n, struct{}{})
+	rb.Next = backend(answers)
+
+	for _, tc := range []struct {
+		qname  string
+		qtype  uint16
+		answer int
+	}{
+		{"public.example.org.", dns.TypeA, 1},
+		{"evil.example.org.", dns.TypeA, 2},
+		{"loopback.example.org.", dns.TypeAAAA, 0},
+		{"mapped.example.org.", dns.TypeAAAA, 0},
+		{"link.example.org.", dns.TypeA, 0},
+		{"cgnat.example.org.", dns.TypeA, 0},
+		{"this.example.org.", dns.TypeA, 0},
+		{"ula.example.org.", dns.TypeAAAA, 0},
+		{"mapped10.example.org.", dns.TypeAAAA, 0},
+		{"mappedcg.example.org.", dns.TypeAAAA, 0},
+		{"mappedpub.example.org.", dns.TypeAAAA, 1},
+		{"listed.example.org.", dns.TypeA, 0},
+		{"host.corp.example.", dns.TypeA, 1},
+	} {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, tc.qtype)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		rb.ServeDNS(context.TODO(), rec, m)
+
+		if rec.Msg.Rcode != dns.RcodeSuccess {
+			t.Errorf("Expected rcode success for %s, got %d", tc.qname, rec.Msg.Rcode)
+		}
+		if len(rec.Msg.Answer) != tc.answer {
+			t.Errorf("Expected %d answers for %s, got %d: %v", tc.answer, tc.qname, len(rec.Msg.Answer), rec.Msg.Answer)
+		}
+	}
+}
+
+func TestRebindRefuse(t *testing.T) {
+	answers := map[string][]dns.RR{
+		"public.example.org.": {test.A("public.example.org. 300 IN A 192.0.2.1")},
+		"evil.example.org.":   {test.A("evil.example.org. 300 IN A 192.168.0.1")},
+	}
+	rb := New()
+	rb.action = actionRefuse
+	rb.Next = backend(answers)
+
+	for _, tc := range []struct {
+		qname string
+		rcode int
+	}{
+		{"public.example.org.", dns.RcodeSuccess},
+		{"evil.example.org.", dns.RcodeRefused},
+	} {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, dns.TypeA)
+		m.SetEdns0(4096, false)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		rb.ServeDNS(context.TODO(), rec, m)
+
+		if rec.Msg.Rcode != tc.rcode {
+			t.Errorf("Expected rcode %d for %s, got %d", tc.rcode, tc.qname, rec.Msg.Rcode)
+		}
+		if tc.rcode == dns.RcodeRefused && len(rec.Msg.Answer) != 0 {
+			t.Errorf("Expected no answers for %s, got %v", tc.qname, rec.Msg.Answer)
+		}
+	}
+}
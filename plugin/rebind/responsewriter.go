@@ -0,0 +1,61 @@
+package rebind
+
+import (
+	"github.com/miekg/dns"
+)
+
+// ResponseWriter is a response writer that removes private addresses from the response, or refuses
+// the response when it holds any.
+type ResponseWriter struct {
+	dns.ResponseWriter
+	rb     *Rebind
+	server string
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (r *ResponseWriter) WriteMsg(res *dns.Msg) error {
+	if res.Rcode != dns.RcodeSuccess {
+		return r.ResponseWriter.WriteMsg(res)
+	}
+
+	answer, stripped := r.filter(res.Answer)
+	extra, strippedExtra := r.filter(res.Extra)
+	if !stripped && !strippedExtra {
+		return r.ResponseWriter.WriteMsg(res)
+	}
+
+	filtered.WithLabelValues(r.server, r.rb.action.String()).Inc()
+	log.Debugf("Private addresses in response for %s, action %s", res.Question[0].Name, r.rb.action)
+
+	if r.rb.action == actionRefuse {
+		m := new(dns.Msg)
+		m.SetRcode(res, dns.RcodeRefused)
+		if opt := res.IsEdns0(); opt != nil {
+			m.Extra = []dns.RR{opt}
+		}
+		return r.ResponseWriter.WriteMsg(m)
+	}
+
+	res.Answer = answer
+	res.Extra = extra
+	return r.ResponseWriter.WriteMsg(res)
+}
+
+// filter returns rrs without the records for private addresses, and whether any were removed.
+func (r *ResponseWriter) filter(rrs []dns.RR) ([]dns.RR, bool) {
+	out := make([]dns.RR, 0, len(rrs))
+	for _, rr := range rrs {
+		if r.rb.private(rr) {
+			continue
+		}
+		out = append(out, rr)
+	}
+	return out, len(out) != len(rrs)
+}
+
+// Write implements the dns.ResponseWriter interface.
+func (r *ResponseWriter) Write(buf []byte) (int, error) {
+	log.Warning("Rebind called with Write: not filtering response")
+	n, err := r.ResponseWriter.Write(buf)
+	return n, err
+}
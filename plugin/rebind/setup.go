@@ -0,0 +1,95 @@
+package rebind
+
+import (
+	"net"
+	"strings"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+)
+
+var log = clog.NewWithPlugin("rebind")
+
+func init() { plugin.Register("rebind", setup) }
+
+func setup(c *caddy.Controller) error {
+	rb, err := parse(c)
+	if err != nil {
+		return plugin.Error("rebind", err)
+	}
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		rb.Next = next
+		return rb
+	})
+
+	return nil
+}
+
+func parse(c *caddy.Controller) (*Rebind, error) {
+	rb := New()
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		rb.Zones = plugin.OriginsFromArgsOrServerBlock(c.RemainingArgs(), c.ServerBlockKeys)
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "allow":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				for _, a := range args {
+					rb.allow = append(rb.allow, plugin.Name(a).Normalize())
+				}
+			case "deny":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				for _, a := range args {
+					_, n, err := net.ParseCIDR(normalize(a))
+					if err != nil {
+						return nil, c.Errf("invalid network %q", a)
+					}
+					rb.networks.InplaceInsertNet(n, struct{}{})
+				}
+			case "action":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				switch args[0] {
+				case "strip":
+					rb.action = actionStrip
+				case "refuse":
+					rb.action = actionRefuse
+				default:
+					return nil, c.Errf("unknown action %q", args[0])
+				}
+			default:
+				return nil, c.Errf("unknown property %q", c.Val())
+			}
+		}
+	}
+	return rb, nil
+}
+
+// normalize appends '/32' for any single IPv4 address and '/128' for IPv6.
+func normalize(s string) string {
+	if strings.Contains(s, "/") {
+		return s
+	}
+	if strings.Contains(s, ":") {
+		return s + "/128"
+	}
+	return s + "/32"
+}
@@ -0,0 +1,68 @@
+package rebind
+
+import (
+	"net"
+	"testing"
+
+	"github.com/coredns/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		action    action
+		allow     int
+		private   string
+	}{
+		{`rebind`, false, actionStrip, 0, "10.0.0.1"},
+		{`rebind {
+			allow corp.example home.arpa
+			deny 198.51.100.0/24 2001:db8::1
+			action refuse
+		}`, false, actionRefuse, 2, "2001:db8::1"},
+		{`rebind example.org {
+			action strip
+		}`, false, actionStrip, 0, "fd00::1"},
+
+		// fails
+		{`rebind {
+			allow
+		}`, true, 0, 0, ""},
+		{`rebind {
+			deny 300.0.0.0/8
+		}`, true, 0, 0, ""},
+		{`rebind {
+			action drop
+		}`, true, 0, 0, ""},
+		{`rebind {
+			unknown
+		}`, true, 0, 0, ""},
+		{`rebind
+		rebind`, true, 0, 0, ""},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		rb, err := parse(c)
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error but found none for input %s", i, test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error but found one for input %s, got: %v", i, test.input, err)
+			continue
+		}
+		if rb.action != test.action {
+			t.Errorf("Test %d: expected action %s, got %s", i, test.action, rb.action)
+		}
+		if len(rb.allow) != test.allow {
+			t.Errorf("Test %d: expected %d allowed zones, got %d", i, test.allow, len(rb.allow))
+		}
+		if _, ok := rb.networks.GetByIP(net.ParseIP(test.private)); !ok {
+			t.Errorf("Test %d: expected %s to be private", i, test.private)
+		}
+	}
+}
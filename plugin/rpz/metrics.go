@@ -0,0 +1,16 @@
+package rpz
+
+import (
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// hits is the number of queries a policy was applied to.
+var hits = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: plugin.Namespace,
+	Subsystem: "rpz",
+	Name:      "hits_total",
+	Help:      "Counter of queries that matched a policy rule, per policy zone, trigger and action.",
+}, []string{"server", "zone", "trigger", "action"})
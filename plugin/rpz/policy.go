@@ -0,0 +1,276 @@
+package rpz
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/coredns/coredns/plugin/file"
+	"github.com/coredns/coredns/plugin/file/tree"
+
+	"github.com/infobloxopen/go-trees/iptree"
+	"github.com/miekg/dns"
+)
+
+// action is the policy action of a rule.
+type action int
+
+const (
+	actionNXDomain action = iota
+	actionNodata
+	actionPassthru
+	actionDrop
+	actionTCPOnly
+	actionLocal
+)
+
+func (a action) String() string {
+	switch a {
+	case actionNXDomain:
+		return "nxdomain"
+	case actionNodata:
+		return "nodata"
+	case actionPassthru:
+		return "passthru"
+	case actionDrop:
+		return "drop"
+	case actionTCPOnly:
+		return "tcp-only"
+	}
+	return "local-data"
+}
+
+// Trigger kinds, as used in logging and metrics.
+const (
+	triggerQName      = "qname"
+	triggerClientIP   = "client-ip"
+	triggerResponseIP = "response-ip"
+	triggerNSDName    = "nsdname"
+)
+
+// rule is a single policy rule: a trigger and the action to take when it matches.
+type rule struct {
+	zone    string // name of the policy zone.
+	trigger string // kind of trigger.
+	owner   string // owner name of the rule in the policy zone.
+	action  action
+	data    []dns.RR // local data, for actionLocal.
+}
+
+// policyZone is a policy zone, the records are held in a file.Zone, so it can be loaded from disk or
+// transferred in.
+type policyZone struct {
+	*file.Zone
+	name string
+
+	compiled atomic.Pointer[compiled]
+	mu       sync.Mutex // serializes compiling.
+	warned   bool
+}
+
+// compiled is a policy with the tree it was compiled from. It is never modified, a reload of the zone
+// replaces it.
+type compiled struct {
+	tree *tree.Tree
+	p    *policy
+}
+
+// policy holds the compiled rules of a policy zone.
+type policy struct {
+	qname            map[string]*rule
+	qnameWildcard    map[string]*rule // keyed by the parent of the wildcard.
+	nsdname          map[string]*rule
+	nsdnameWildcard  map[string]*rule
+	clientIP         *iptree.Tree
+	responseIP       *iptree.Tree
+	responseTriggers bool
+}
+
+// policy returns the compiled policy of z. The policy is compiled again when the zone was reloaded
+// or transferred, as that replaces the tree. It returns nil if the zone isn't loaded or expired.
+func (z *policyZone) policy() *policy {
+	z.Zone.RLock()
+	t, expired := z.Zone.Tree, z.Zone.Expired
+	z.Zone.RUnlock()
+	if expired {
+		return nil
+	}
+
+	if c := z.compiled.Load(); c != nil && c.tree == t {
+		return c.p
+	}
+
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	// Another query may have compiled t while we waited.
+	if c := z.compiled.Load(); c != nil && c.tree == t {
+		return c.p
+	}
+	c := &compiled{tree: t, p: z.compile(t)}
+	z.compiled.Store(c)
+	return c.p
+}
+
+// compile compiles the rules in t.
+func (z *policyZone) compile(t *tree.Tree) *policy {
+	p := &policy{
+		qname:           map[string]*rule{},
+		qnameWildcard:   map[string]*rule{},
+		nsdname:         map[string]*rule{},
+		nsdnameWildcard: map[string]*rule{},
+		clientIP:        iptree.NewTree(),
+		responseIP:      iptree.NewTree(),
+	}
+	if t == nil {
+		return p
+	}
+
+	suffix := "." + z.name
+	for _, e := range t.All() {
+		owner := strings.TrimSuffix(e.Name(), suffix)
+		if owner == e.Name() {
+			continue // apex, or not in the zone.
+		}
+		r := newRule(z.name, e)
+
+		switch {
+		case strings.HasSuffix(owner, ".rpz-client-ip"):
+			n, err := ipTrigger(strings.TrimSuffix(owner, ".rpz-client-ip"))
+			if err != nil {
+				log.Warningf("Ignoring %s in %s: %s", e.Name(), z.name, err)
+				continue
+			}
+			r.trigger = triggerClientIP
+			p.clientIP.InplaceInsertNet(n, r)
+		case strings.HasSuffix(owner, ".rpz-ip"):
+			n, err := ipTrigger(strings.TrimSuffix(owner, ".rpz-ip"))
+			if err != nil {
+				log.Warningf("Ignoring %s in %s: %s", e.Name(), z.name, err)
+				continue
+			}
+			r.trigger = triggerResponseIP
+			p.responseIP.InplaceInsertNet(n, r)
+			p.responseTriggers = true
+		case strings.HasSuffix(owner, ".rpz-nsdname"):
+			r.trigger = triggerNSDName
+			insertName(p.nsdname, p.nsdnameWildcard, strings.TrimSuffix(owner, ".rpz-nsdname"), r)
+			p.responseTriggers = true
+		case strings.HasSuffix(owner, ".rpz-nsip"):
+			if !z.warned {
+				log.Warningf("NSIP triggers are not supported, ignoring them in %s", z.name)
+				z.warned = true
+			}
+		default:
+			r.trigger = triggerQName
+			insertName(p.qname, p.qnameWildcard, owner, r)
+		}
+	}
+	return p
+}
+
+// newRule returns the rule for the records in e.
+func newRule(zone string, e *tree.Elem) *rule {
+	r := &rule{zone: zone, owner: e.Name(), action: actionLocal}
+	if cname := e.Type(dns.TypeCNAME); len(cname) > 0 {
+		switch cname[0].(*dns.CNAME).Target {
+		case ".":
+			r.action = actionNXDomain
+		case "*.":
+			r.action = actionNodata
+		case "rpz-passthru.":
+			r.action = actionPassthru
+		case "rpz-drop.":
+			r.action = actionDrop
+		case "rpz-tcp-only.":
+			r.action = actionTCPOnly
+		default:
+			r.data = cname
+		}
+		return r
+	}
+	r.data = e.All()
+	return r
+}
+
+// insertName adds r for name, a name relative to the policy zone, to exact or, if name is a wildcard,
+// to wildcard.
+func insertName(exact, wildcard map[string]*rule, name string, r *rule) {
+	if strings.HasPrefix(name, "*.") {
+		wildcard[name[2:]+"."] = r
+		return
+	}
+	exact[name+"."] = r
+}
+
+// matchName returns the rule matching name in exact or wildcard. An exact match has precedence, then
+// the wildcard of the closest parent.
+func matchName(exact, wildcard map[string]*rule, name string) *rule {
+	if r, ok := exact[name]; ok {
+		return r
+	}
+	if len(wildcard) == 0 {
+		return nil
+	}
+	for off, end := dns.NextLabel(name, 0); !end; off, end = dns.NextLabel(name, off) {
+		if r, ok := wildcard[name[off:]]; ok {
+			return r
+		}
+	}
+	return nil
+}
+
+// ipTrigger parses the IP address part of a rpz-ip or rpz-client-ip owner name. That is the prefix length
+// followed by the address with its labels reversed, "24.0.2.0.192" is 192.0.2.0/24. For IPv6 "zz" stands
+// for the "::" in an address: "48.zz.db8.2001" is 2001:db8::/48.
+func ipTrigger(s string) (*net.IPNet, error) {
+	labels := strings.Split(s, ".")
+	if len(labels) < 2 {
+		return nil, fmt.Errorf("invalid IP trigger")
+	}
+	prefix, err := strconv.Atoi(labels[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid prefix length %q", labels[0])
+	}
+	parts := make([]string, 0, len(labels)-1)
+	for i := len(labels) - 1; i > 0; i-- {
+		parts = append(parts, labels[i])
+	}
+
+	bits := 32
+	addr := strings.Join(parts, ".")
+	if len(parts) != 4 || strings.Contains(s, "zz") {
+		bits = 128
+		addr = strings.Join(parts, ":")
+		addr = strings.Replace(addr, "zz", "", 1)
+		if strings.HasPrefix(addr, ":") {
+			addr = ":" + addr
+		}
+		if strings.HasSuffix(addr, ":") {
+			addr += ":"
+		}
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil || (bits == 32 && ip.To4() == nil) || (bits == 128 && ip.To4() != nil) {
+		return nil, fmt.Errorf("invalid address %q", addr)
+	}
+	if prefix < 1 || prefix > bits {
+		return nil, fmt.Errorf("invalid prefix length %d", prefix)
+	}
+	mask := net.CIDRMask(prefix, bits)
+	return &net.IPNet{IP: ip.Mask(mask), Mask: mask}, nil
+}
+
+// matchIP returns the rule for the longest prefix in t containing ip.
+func matchIP(t *iptree.Tree, ip net.IP) *rule {
+	if ip == nil {
+		return nil
+	}
+	v, ok := t.GetByIP(ip)
+	if !ok {
+		return nil
+	}
+	return v.(*rule)
+}
@@ -0,0 +1,145 @@
+package rpz
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/file"
+)
+
+func TestIPTrigger(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		net  string
+		fail bool
+	}{
+		{"32.1.2.0.192", "192.0.2.1/32", false},
+		{"24.0.2.0.192", "192.0.2.0/24", false},
+		{"24.10.2.0.192", "192.0.2.0/24", false},
+		{"128.1.zz.db8.2001", "2001:db8::1/128", false},
+		{"48.zz.db8.2001", "2001:db8::/48", false},
+		{"128.1.zz", "::1/128", false},
+		{"33.1.2.0.192", "", true},
+		{"0.1.2.0.192", "", true},
+		{"x.1.2.0.192", "", true},
+		{"32.1.2.0.300", "", true},
+		{"32", "", true},
+	} {
+		n, err := ipTrigger(tc.in)
+		if tc.fail {
+			if err == nil {
+				t.Errorf("Expected error for %s, got %s", tc.in, n)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Expected no error for %s, got %s", tc.in, err)
+			continue
+		}
+		if n.String() != tc.net {
+			t.Errorf("Expected %s for %s, got %s", tc.net, tc.in, n)
+		}
+	}
+}
+
+const policyZoneData = `$ORIGIN rpz.example.
+@                               3600 IN SOA ns.rpz.example. admin.rpz.example. 1 3600 600 86400 60
+@                               3600 IN NS  ns.rpz.example.
+bad.example.org                 60   IN CNAME .
+*.bad.example.org               60   IN CNAME .
+empty.example.org               60   IN CNAME *.
+ok.bad.example.org              60   IN CNAME rpz-passthru.
+drop.example.org                60   IN CNAME rpz-drop.
+tcp.example.org                 60   IN CNAME rpz-tcp-only.
+walled.example.org              60   IN CNAME garden.example.net.
+local.example.org               60   IN A     192.0.2.80
+local.example.org               60   IN AAAA  2001:db8::80
+32.1.2.0.192.rpz-client-ip      60   IN CNAME rpz-drop.
+24.0.113.0.203.rpz-ip           60   IN CNAME .
+ns.evil.example.rpz-nsdname     60   IN CNAME .
+*.evil.example.net.rpz-nsdname  60   IN CNAME *.
+32.1.2.0.192.rpz-nsip           60   IN CNAME .
+`
+
+func newPolicyZone(t *testing.T, name, data string) *policyZone {
+	z, err := file.Parse(strings.NewReader(data), name, "stdin", 0)
+	if err != nil {
+		t.Fatalf("Failed to parse policy zone: %s", err)
+	}
+	return &policyZone{Zone: z, name: name}
+}
+
+func TestCompile(t *testing.T) {
+	pz := newPolicyZone(t, "rpz.example.", policyZoneData)
+	p := pz.policy()
+
+	for _, tc := range []struct {
+		qname  string
+		action action
+		match  bool
+	}{
+		{"bad.example.org.", actionNXDomain, true},
+		{"www.bad.example.org.", actionNXDomain, true},
+		{"ok.bad.example.org.", actionPassthru, true},
+		{"empty.example.org.", actionNodata, true},
+		{"drop.example.org.", actionDrop, true},
+		{"tcp.example.org.", actionTCPOnly, true},
+		{"walled.example.org.", actionLocal, true},
+		{"local.example.org.", actionLocal, true},
+		{"www.empty.example.org.", 0, false},
+		{"example.org.", 0, false},
+	} {
+		ru := matchName(p.qname, p.qnameWildcard, tc.qname)
+		if (ru != nil) != tc.match {
+			t.Errorf("Expected match for %s to be %t", tc.qname, tc.match)
+			continue
+		}
+		if ru != nil && ru.action != tc.action {
+			t.Errorf("Expected action %s for %s, got %s", tc.action, tc.qname, ru.action)
+		}
+	}
+
+	if ru := matchIP(p.clientIP, net.ParseIP("192.0.2.1")); ru == nil || ru.action != actionDrop {
+		t.Errorf("Expected client IP 192.0.2.1 to be dropped")
+	}
+	if ru := matchIP(p.clientIP, net.ParseIP("192.0.2.2")); ru != nil {
+		t.Errorf("Expected no match for client IP 192.0.2.2")
+	}
+	if ru := matchIP(p.responseIP, net.ParseIP("203.0.113.9")); ru == nil || ru.trigger != triggerResponseIP {
+		t.Errorf("Expected response IP 203.0.113.9 to match")
+	}
+	if ru := matchName(p.nsdname, p.nsdnameWildcard, "ns.evil.example."); ru == nil || ru.action != actionNXDomain {
+		t.Errorf("Expected NSDNAME ns.evil.example. to match")
+	}
+	if ru := matchName(p.nsdname, p.nsdnameWildcard, "ns1.evil.example.net."); ru == nil || ru.action != actionNodata {
+		t.Errorf("Expected NSDNAME ns1.evil.example.net. to match")
+	}
+	if !p.responseTriggers {
+		t.Errorf("Expected policy to have response triggers")
+	}
+
+	if p1 := pz.policy(); p1 != p {
+		t.Errorf("Expected policy not to be compiled again for the same tree")
+	}
+}
+
+func TestPolicyRecompile(t *testing.T) {
+	pz := newPolicyZone(t, "rpz.example.", policyZoneData)
+	p := pz.policy()
+	if pz.policy() != p {
+		t.Errorf("Expected the policy to be compiled once")
+	}
+
+	// A reload replaces the tree, which gets a new policy.
+	z, err := file.Parse(strings.NewReader(policyZoneData), "rpz.example.", "stdin", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pz.Zone.Lock()
+	pz.Zone.Tree = z.Tree
+	pz.Zone.Unlock()
+	if pz.policy() == p {
+		t.Errorf("Expected the policy to be compiled again after a reload")
+	}
+}
@@ -0,0 +1,200 @@
+// Package rpz implements Response Policy Zones.
+package rpz
+
+import (
+	"context"
+	"net"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+	"github.com/coredns/coredns/plugin/pkg/nonwriter"
+	"github.com/coredns/coredns/plugin/pkg/upstream"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// RPZ is a plugin that rewrites responses according to response policy zones.
+type RPZ struct {
+	Next  plugin.Handler
+	Zones []string
+
+	policies []*policyZone // in order of precedence.
+	upstream *upstream.Upstream
+}
+
+// New returns a new RPZ.
+func New() *RPZ { return &RPZ{upstream: upstream.New()} }
+
+// ServeDNS implements the plugin.Handler interface.
+func (p *RPZ) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+	if plugin.Zones(p.Zones).Matches(state.Name()) == "" {
+		return plugin.NextOrFailure(p.Name(), p.Next, ctx, w, r)
+	}
+
+	policies := make([]*policy, len(p.policies))
+	for i, z := range p.policies {
+		policies[i] = z.policy()
+	}
+
+	// Client IP and QNAME triggers are checked before resolving; an earlier zone has precedence,
+	// but its response triggers can only be checked after resolving.
+	pre, k := p.matchQuery(policies, state)
+	if !hasResponseTriggers(policies[:k]) {
+		if pre == nil {
+			return plugin.NextOrFailure(p.Name(), p.Next, ctx, w, r)
+		}
+		return p.apply(ctx, state, pre, nil)
+	}
+
+	nw := nonwriter.New(w)
+	rcode, err := plugin.NextOrFailure(p.Name(), p.Next, ctx, nw, r)
+	if nw.Msg == nil {
+		return rcode, err
+	}
+	ru := p.matchResponse(policies[:k], nw.Msg)
+	if ru == nil {
+		ru = pre
+	}
+	if ru == nil {
+		w.WriteMsg(nw.Msg)
+		return rcode, err
+	}
+	return p.apply(ctx, state, ru, nw.Msg)
+}
+
+// Name implements the Handler interface.
+func (p *RPZ) Name() string { return "rpz" }
+
+// matchQuery returns the first rule whose client IP or QNAME trigger matches the query, and the index of
+// the policy it was found in. If there is no match, nil and len(policies) are returned.
+func (p *RPZ) matchQuery(policies []*policy, state request.Request) (*rule, int) {
+	ip := net.ParseIP(state.IP())
+	qname := state.Name()
+	for i, pol := range policies {
+		if pol == nil {
+			continue
+		}
+		if ru := matchIP(pol.clientIP, ip); ru != nil {
+			return ru, i
+		}
+		if ru := matchName(pol.qname, pol.qnameWildcard, qname); ru != nil {
+			return ru, i
+		}
+	}
+	return nil, len(policies)
+}
+
+// matchResponse returns the first rule whose response IP or NSDNAME trigger matches the response.
+// NSDNAME triggers are matched against the name servers in the authority section of the response.
+func (p *RPZ) matchResponse(policies []*policy, resp *dns.Msg) *rule {
+	for _, pol := range policies {
+		if pol == nil || !pol.responseTriggers {
+			continue
+		}
+		for _, rr := range resp.Answer {
+			var ru *rule
+			switch rr := rr.(type) {
+			case *dns.A:
+				ru = matchIP(pol.responseIP, rr.A)
+			case *dns.AAAA:
+				ru = matchIP(pol.responseIP, rr.AAAA)
+			}
+			if ru != nil {
+				return ru
+			}
+		}
+		for _, rr := range resp.Ns {
+			if ns, ok := rr.(*dns.NS); ok {
+				if ru := matchName(pol.nsdname, pol.nsdnameWildcard, ns.Ns); ru != nil {
+					return ru
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// apply applies the action of ru. If the query was already resolved, resp holds the response.
+func (p *RPZ) apply(ctx context.Context, state request.Request, ru *rule, resp *dns.Msg) (int, error) {
+	a := ru.action
+	if a == actionTCPOnly && state.Proto() == "tcp" {
+		a = actionPassthru
+	}
+
+	hits.WithLabelValues(metrics.WithServer(ctx), ru.zone, ru.trigger, ru.action.String()).Inc()
+	log.Infof("%s %s from %s: %s trigger %s, %s", state.Name(), state.Type(), state.IP(), ru.trigger, ru.owner, ru.action)
+
+	switch a {
+	case actionPassthru:
+		if resp != nil {
+			state.W.WriteMsg(resp)
+			return dns.RcodeSuccess, nil
+		}
+		return plugin.NextOrFailure(p.Name(), p.Next, ctx, state.W, state.Req)
+	case actionDrop:
+		return dns.RcodeSuccess, nil
+	}
+
+	m := new(dns.Msg)
+	m.SetReply(state.Req)
+	m.RecursionAvailable = true
+
+	switch a {
+	case actionNXDomain:
+		m.Rcode = dns.RcodeNameError
+	case actionTCPOnly:
+		m.Truncated = true
+	case actionLocal:
+		m.Answer = p.localData(ctx, state, ru)
+	}
+
+	state.W.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
+// localData returns the answer for the local data of ru. A CNAME is followed, through the server itself.
+func (p *RPZ) localData(ctx context.Context, state request.Request, ru *rule) []dns.RR {
+	qname, qtype := state.QName(), state.QType()
+
+	for _, rr := range ru.data {
+		cname, ok := rr.(*dns.CNAME)
+		if !ok {
+			continue
+		}
+		c := dns.Copy(cname)
+		c.Header().Name = qname
+		answer := []dns.RR{c}
+		if qtype == dns.TypeCNAME {
+			return answer
+		}
+		m, err := p.upstream.Lookup(ctx, state, cname.Target, qtype)
+		if err != nil || m == nil {
+			log.Warningf("Failed to resolve %s for %s: %v", cname.Target, qname, err)
+			return answer
+		}
+		return append(answer, m.Answer...)
+	}
+
+	var answer []dns.RR
+	for _, rr := range ru.data {
+		if rr.Header().Rrtype != qtype {
+			continue
+		}
+		c := dns.Copy(rr)
+		c.Header().Name = qname
+		answer = append(answer, c)
+	}
+	return answer
+}
+
+// hasResponseTriggers returns true if any of policies has response IP or NSDNAME triggers.
+func hasResponseTriggers(policies []*policy) bool {
+	for _, pol := range policies {
+		if pol != nil && pol.responseTriggers {
+			return true
+		}
+	}
+	return false
+}
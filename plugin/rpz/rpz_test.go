@@ -0,0 +1,143 @@
+package rpz
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+// backend answers every query with 192.0.2.53, except resp.example.org. (203.0.113.9), and adds
+// ns.evil.example. as name server for delegated.example.org.
+func backend() test.HandlerFunc {
+	return test.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		qname := r.Question[0].Name
+		switch qname {
+		case "resp.example.org.":
+			m.Answer = []dns.RR{test.A(qname + " 300 IN A 203.0.113.9")}
+		case "delegated.example.org.":
+			m.Answer = []dns.RR{test.A(qname + " 300 IN A 192.0.2.53")}
+			m.Ns = []dns.RR{test.NS("delegated.example.org. 300 IN NS ns.evil.example.")}
+		default:
+			m.Answer = []dns.RR{test.A(qname + " 300 IN A 192.0.2.53")}
+		}
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+}
+
+func newTestRPZ(t *testing.T) *RPZ {
+	p := New()
+	p.Zones = []string{"."}
+	p.policies = []*policyZone{newPolicyZone(t, "rpz.example.", policyZoneData)}
+	p.Next = backend()
+	return p
+}
+
+func TestRPZ(t *testing.T) {
+	p := newTestRPZ(t)
+
+	for _, tc := range []struct {
+		qname    string
+		qtype    uint16
+		tcp      bool
+		remote   string
+		written  bool
+		rcode    int
+		answer   int
+		truncate bool
+	}{
+		{qname: "example.org.", qtype: dns.TypeA, written: true, rcode: dns.RcodeSuccess, answer: 1},
+		{qname: "bad.example.org.", qtype: dns.TypeA, written: true, rcode: dns.RcodeNameError},
+		{qname: "www.bad.example.org.", qtype: dns.TypeA, written: true, rcode: dns.RcodeNameError},
+		{qname: "ok.bad.example.org.", qtype: dns.TypeA, written: true, rcode: dns.RcodeSuccess, answer: 1},
+		{qname: "empty.example.org.", qtype: dns.TypeA, written: true, rcode: dns.RcodeSuccess},
+		{qname: "drop.example.org.", qtype: dns.TypeA, written: false},
+		{qname: "tcp.example.org.", qtype: dns.TypeA, written: true, rcode: dns.RcodeSuccess, truncate: true},
+		{qname: "tcp.example.org.", qtype: dns.TypeA, tcp: true, written: true, rcode: dns.RcodeSuccess, answer: 1},
+		{qname: "local.example.org.", qtype: dns.TypeAAAA, written: true, rcode: dns.RcodeSuccess, answer: 1},
+		{qname: "local.example.org.", qtype: dns.TypeMX, written: true, rcode: dns.RcodeSuccess},
+		{qname: "walled.example.org.", qtype: dns.TypeCNAME, written: true, rcode: dns.RcodeSuccess, answer: 1},
+		{qname: "example.org.", qtype: dns.TypeA, remote: "192.0.2.1", written: false},
+		{qname: "resp.example.org.", qtype: dns.TypeA, written: true, rcode: dns.RcodeNameError},
+		{qname: "delegated.example.org.", qtype: dns.TypeA, written: true, rcode: dns.RcodeNameError},
+	} {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, tc.qtype)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{TCP: tc.tcp, RemoteIP: tc.remote})
+		if _, err := p.ServeDNS(context.TODO(), rec, m); err != nil {
+			t.Fatalf("Expected no error for %s, got %s", tc.qname, err)
+		}
+
+		if !tc.written {
+			if rec.Msg != nil {
+				t.Errorf("Expected no reply for %s from %s, got %s", tc.qname, tc.remote, rec.Msg)
+			}
+			continue
+		}
+		if rec.Msg == nil {
+			t.Errorf("Expected a reply for %s", tc.qname)
+			continue
+		}
+		if rec.Msg.Rcode != tc.rcode {
+			t.Errorf("Expected rcode %d for %s, got %d", tc.rcode, tc.qname, rec.Msg.Rcode)
+		}
+		if len(rec.Msg.Answer) != tc.answer {
+			t.Errorf("Expected %d answers for %s, got %d", tc.answer, tc.qname, len(rec.Msg.Answer))
+		}
+		if rec.Msg.Truncated != tc.truncate {
+			t.Errorf("Expected truncated %t for %s, got %t", tc.truncate, tc.qname, rec.Msg.Truncated)
+		}
+		for _, rr := range rec.Msg.Answer {
+			if rr.Header().Name != tc.qname {
+				t.Errorf("Expected owner %s, got %s", tc.qname, rr.Header().Name)
+			}
+		}
+	}
+}
+
+func TestRPZPrecedence(t *testing.T) {
+	p := New()
+	p.Zones = []string{"."}
+	p.Next = backend()
+
+	first := `$ORIGIN first.example.
+@                      3600 IN SOA ns.first.example. admin.first.example. 1 3600 600 86400 60
+allowed.example.org    60   IN CNAME rpz-passthru.
+24.0.113.0.203.rpz-ip  60   IN CNAME *.
+`
+	second := `$ORIGIN second.example.
+@                      3600 IN SOA ns.second.example. admin.second.example. 1 3600 600 86400 60
+allowed.example.org    60   IN CNAME .
+resp.example.org       60   IN CNAME .
+`
+	p.policies = []*policyZone{newPolicyZone(t, "first.example.", first), newPolicyZone(t, "second.example.", second)}
+
+	for _, tc := range []struct {
+		qname  string
+		rcode  int
+		answer int
+	}{
+		// The passthru in the first zone wins from the nxdomain in the second.
+		{"allowed.example.org.", dns.RcodeSuccess, 1},
+		// The response IP trigger in the first zone wins from the QNAME trigger in the second.
+		{"resp.example.org.", dns.RcodeSuccess, 0},
+	} {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		p.ServeDNS(context.TODO(), rec, m)
+
+		if rec.Msg.Rcode != tc.rcode {
+			t.Errorf("Expected rcode %d for %s, got %d", tc.rcode, tc.qname, rec.Msg.Rcode)
+		}
+		if len(rec.Msg.Answer) != tc.answer {
+			t.Errorf("Expected %d answers for %s, got %d", tc.answer, tc.qname, len(rec.Msg.Answer))
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package rpz
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/file"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/pkg/parse"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+)
+
+var log = clog.NewWithPlugin("rpz")
+
+func init() { plugin.Register("rpz", setup) }
+
+func setup(c *caddy.Controller) error {
+	p, err := rpzParse(c)
+	if err != nil {
+		return plugin.Error("rpz", err)
+	}
+
+	for _, pz := range p.policies {
+		z := pz.Zone
+		name := pz.name
+		if len(z.TransferFrom) == 0 {
+			c.OnStartup(func() error {
+				z.StartupOnce.Do(func() { z.Reload(nil) })
+				return nil
+			})
+			c.OnShutdown(z.OnShutdown)
+			continue
+		}
+		stop := make(chan struct{})
+		c.OnStartup(func() error {
+			z.StartupOnce.Do(func() { go transfer(z, name, stop) })
+			return nil
+		})
+		// This also runs for the old instance after a reload, its transfers must not keep running.
+		c.OnShutdown(func() error {
+			close(stop)
+			return nil
+		})
+	}
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		p.Next = next
+		return p
+	})
+
+	return nil
+}
+
+// transfer transfers in z, retrying until it succeeds, and then keeps it up to date until stop is
+// closed.
+func transfer(z *file.Zone, name string, stop <-chan struct{}) {
+	dur := 250 * time.Millisecond
+	max := 10 * time.Second
+	for {
+		err := z.TransferIn()
+		if err == nil {
+			break
+		}
+		log.Warningf("All '%s' primaries failed to transfer, retrying in %s: %s", name, dur, err)
+		select {
+		case <-stop:
+			return
+		case <-time.After(dur):
+		}
+		if dur *= 2; dur > max {
+			dur = max
+		}
+	}
+	z.UpdateUntil(stop)
+}
+
+func rpzParse(c *caddy.Controller) (*RPZ, error) {
+	p := New()
+	config := dnsserver.GetConfig(c)
+	reload := time.Minute
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		p.Zones = plugin.OriginsFromArgsOrServerBlock(c.RemainingArgs(), c.ServerBlockKeys)
+		names := map[string]bool{}
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "file":
+				args := c.RemainingArgs()
+				if len(args) != 2 {
+					return nil, c.ArgErr()
+				}
+				name := plugin.Name(args[0]).Normalize()
+				if names[name] {
+					return nil, c.Errf("duplicate policy zone %q", name)
+				}
+				names[name] = true
+
+				path := args[1]
+				if !filepath.IsAbs(path) && config.Root != "" {
+					path = filepath.Join(config.Root, path)
+				}
+				reader, err := os.Open(path)
+				if err != nil {
+					return nil, err
+				}
+				z, err := file.Parse(reader, name, path, 0)
+				reader.Close()
+				if err != nil {
+					return nil, err
+				}
+				p.policies = append(p.policies, &policyZone{Zone: z, name: name})
+			case "transfer":
+				args := c.RemainingArgs()
+				if len(args) < 2 {
+					return nil, c.ArgErr()
+				}
+				name := plugin.Name(args[0]).Normalize()
+				if names[name] {
+					return nil, c.Errf("duplicate policy zone %q", name)
+				}
+				names[name] = true
+
+				z := file.NewZone(name, "stdin")
+				for _, a := range args[1:] {
+					addr, err := parse.HostPort(a, transport.Port)
+					if err != nil {
+						return nil, err
+					}
+					z.TransferFrom = append(z.TransferFrom, addr)
+				}
+				p.policies = append(p.policies, &policyZone{Zone: z, name: name})
+			case "reload":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				d, err := time.ParseDuration(args[0])
+				if err != nil {
+					return nil, c.Errf("invalid duration %q", args[0])
+				}
+				if d < 0 {
+					return nil, c.Errf("invalid negative duration %q", args[0])
+				}
+				reload = d
+			default:
+				return nil, c.Errf("unknown property %q", c.Val())
+			}
+		}
+	}
+
+	if len(p.policies) == 0 {
+		return nil, fmt.Errorf("at least one policy zone is required")
+	}
+	for _, pz := range p.policies {
+		if len(pz.TransferFrom) == 0 {
+			pz.ReloadInterval = reload
+		}
+	}
+	return p, nil
+}
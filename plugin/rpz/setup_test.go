@@ -0,0 +1,113 @@
+package rpz
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/plugin/file"
+)
+
+func TestSetup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rpz.example")
+	if err := os.WriteFile(path, []byte(policyZoneData), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input     string
+		shouldErr bool
+		policies  []string
+		transfer  int
+		reload    time.Duration
+	}{
+		{`rpz {
+			file rpz.example ` + path + `
+		}`, false, []string{"rpz.example."}, 0, time.Minute},
+		{`rpz example.org {
+			transfer feed.example 192.0.2.1 192.0.2.2:5353
+			file rpz.example ` + path + `
+			reload 10s
+		}`, false, []string{"feed.example.", "rpz.example."}, 2, 10 * time.Second},
+
+		// fails
+		{`rpz`, true, nil, 0, 0},
+		{`rpz {
+			file rpz.example
+		}`, true, nil, 0, 0},
+		{`rpz {
+			file rpz.example /does/not/exist
+		}`, true, nil, 0, 0},
+		{`rpz {
+			transfer feed.example
+		}`, true, nil, 0, 0},
+		{`rpz {
+			file rpz.example ` + path + `
+			transfer rpz.example 192.0.2.1
+		}`, true, nil, 0, 0},
+		{`rpz {
+			file rpz.example ` + path + `
+			reload -1s
+		}`, true, nil, 0, 0},
+		{`rpz {
+			file rpz.example ` + path + `
+			unknown
+		}`, true, nil, 0, 0},
+		{`rpz {
+			file rpz.example ` + path + `
+		}
+		rpz`, true, nil, 0, 0},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		p, err := rpzParse(c)
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error but found none for input %s", i, test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error but found one for input %s, got: %v", i, test.input, err)
+			continue
+		}
+		if len(p.policies) != len(test.policies) {
+			t.Fatalf("Test %d: expected %d policy zones, got %d", i, len(test.policies), len(p.policies))
+		}
+		transfer := 0
+		for j, pz := range p.policies {
+			if pz.name != test.policies[j] {
+				t.Errorf("Test %d: expected policy zone %d to be %s, got %s", i, j, test.policies[j], pz.name)
+			}
+			transfer += len(pz.TransferFrom)
+			if len(pz.TransferFrom) == 0 && pz.ReloadInterval != test.reload {
+				t.Errorf("Test %d: expected reload %s, got %s", i, test.reload, pz.ReloadInterval)
+			}
+		}
+		if transfer != test.transfer {
+			t.Errorf("Test %d: expected %d primaries, got %d", i, test.transfer, transfer)
+		}
+	}
+}
+
+func TestTransferStop(t *testing.T) {
+	z := file.NewZone("rpz.example.", "stdin")
+	z.TransferFrom = []string{"127.0.0.1:1"} // nothing listens here, every transfer fails.
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		transfer(z, "rpz.example.", stop)
+		close(done)
+	}()
+	close(stop)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the transfer to stop")
+	}
+}
@@ -0,0 +1,32 @@
+package rrl
+
+import (
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// exceeded is the number of responses that were over the limit, including those in log-only mode.
+	exceeded = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "rrl",
+		Name:      "responses_exceeded_total",
+		Help:      "Counter of responses that exceeded the rate limit.",
+	}, []string{"server", "class"})
+	// dropped is the number of responses that were dropped.
+	dropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "rrl",
+		Name:      "responses_dropped_total",
+		Help:      "Counter of responses that were dropped.",
+	}, []string{"server", "class"})
+	// slipped is the number of responses that were replaced by a truncated response.
+	slipped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "rrl",
+		Name:      "responses_slipped_total",
+		Help:      "Counter of responses that were replaced by a truncated response.",
+	}, []string{"server", "class"})
+)
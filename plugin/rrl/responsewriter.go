@@ -0,0 +1,65 @@
+package rrl
+
+import (
+	"net"
+
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// ResponseWriter accounts every response and drops, truncates or logs those over the limit.
+type ResponseWriter struct {
+	dns.ResponseWriter
+	rrl    *RRL
+	state  request.Request
+	server string
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w *ResponseWriter) WriteMsg(res *dns.Msg) error {
+	rl := w.rrl
+	now := rl.now()
+
+	class, name := classify(w.state, res, now)
+	allowance := rl.allowances[class]
+	if allowance == 0 {
+		return w.ResponseWriter.WriteMsg(res)
+	}
+
+	ip := net.ParseIP(w.state.IP())
+	if ip == nil {
+		return w.ResponseWriter.WriteMsg(res)
+	}
+	prefix := rl.prefix(ip)
+	token := prefix + "/" + classNames[class] + "/" + name
+
+	balance, b := rl.table.debit(token, allowance, rl.window, now)
+	if balance >= 0 {
+		return w.ResponseWriter.WriteMsg(res)
+	}
+
+	exceeded.WithLabelValues(w.server, classNames[class]).Inc()
+	if rl.logOnly {
+		log.Infof("Rate limit exceeded for %s/%s: %s (%s)", prefix, classNames[class], w.state.Name(), w.state.Type())
+		return w.ResponseWriter.WriteMsg(res)
+	}
+
+	if b.slip(rl.slipRatio) {
+		slipped.WithLabelValues(w.server, classNames[class]).Inc()
+		m := new(dns.Msg)
+		m.SetReply(w.state.Req)
+		m.Truncated = true
+		m.Rcode = res.Rcode
+		return w.ResponseWriter.WriteMsg(m)
+	}
+
+	dropped.WithLabelValues(w.server, classNames[class]).Inc()
+	return nil
+}
+
+// Write implements the dns.ResponseWriter interface.
+func (w *ResponseWriter) Write(buf []byte) (int, error) {
+	log.Warning("RRL called with Write: not rate limiting reply")
+	return w.ResponseWriter.Write(buf)
+}
@@ -0,0 +1,121 @@
+// Package rrl implements response rate limiting.
+package rrl
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+	"github.com/coredns/coredns/plugin/pkg/response"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// RRL is a plugin that limits the rate of responses sent to a client network.
+type RRL struct {
+	Next  plugin.Handler
+	Zones []string
+
+	window     time.Duration
+	ipv4Mask   net.IPMask
+	ipv6Mask   net.IPMask
+	allowances [classes]float64 // responses per second per class, 0 means unlimited.
+	slipRatio  int
+	logOnly    bool
+
+	table *table
+
+	// Testing.
+	now func() time.Time
+}
+
+// Response classes, each is accounted separately.
+const (
+	classResponse = iota
+	classNodata
+	classNXDomain
+	classReferral
+	classError
+	classes
+)
+
+var classNames = [classes]string{"response", "nodata", "nxdomain", "referral", "error"}
+
+// New returns an RRL with default settings, this does not limit any responses.
+func New() *RRL {
+	return &RRL{
+		window:    defaultWindow,
+		ipv4Mask:  net.CIDRMask(defaultIPv4Prefix, 32),
+		ipv6Mask:  net.CIDRMask(defaultIPv6Prefix, 128),
+		slipRatio: defaultSlipRatio,
+		table:     newTable(defaultTableSize),
+		now:       time.Now,
+	}
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (rl *RRL) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+	// TCP proves the client isn't spoofing its address, these are never limited.
+	if state.Proto() == "tcp" {
+		return plugin.NextOrFailure(rl.Name(), rl.Next, ctx, w, r)
+	}
+	zone := plugin.Zones(rl.Zones).Matches(state.Name())
+	if zone == "" {
+		return plugin.NextOrFailure(rl.Name(), rl.Next, ctx, w, r)
+	}
+
+	rw := &ResponseWriter{ResponseWriter: w, rrl: rl, state: state, server: metrics.WithServer(ctx)}
+	return plugin.NextOrFailure(rl.Name(), rl.Next, ctx, rw, r)
+}
+
+// Name implements the Handler interface.
+func (rl *RRL) Name() string { return "rrl" }
+
+// prefix returns the client network of ip as a string.
+func (rl *RRL) prefix(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(rl.ipv4Mask).String()
+	}
+	return ip.Mask(rl.ipv6Mask).String()
+}
+
+// classify returns the class of the response m to the question in state and the name the
+// response is accounted under.
+func classify(state request.Request, m *dns.Msg, now time.Time) (int, string) {
+	mt, _ := response.Typify(m, now)
+	switch mt {
+	case response.NoError:
+		return classResponse, state.Name() + "/" + dns.Type(state.QType()).String()
+	case response.NoData:
+		return classNodata, authority(m, state.Name())
+	case response.NameError:
+		return classNXDomain, authority(m, state.Name())
+	case response.Delegation:
+		return classReferral, authority(m, state.Name())
+	}
+	// Errors are accounted per client network only.
+	return classError, ""
+}
+
+// authority returns the owner name of the first record in the authority section, this is the zone
+// for negative answers and the delegation point for referrals. Accounting on this name instead of
+// on the query name prevents an attacker from evading the limit by using random names.
+func authority(m *dns.Msg, qname string) string {
+	if len(m.Ns) > 0 {
+		return strings.ToLower(m.Ns[0].Header().Name)
+	}
+	return qname
+}
+
+const (
+	defaultWindow     = 15 * time.Second
+	defaultIPv4Prefix = 24
+	defaultIPv6Prefix = 56
+	defaultSlipRatio  = 2
+	defaultTableSize  = 100000
+)
@@ -0,0 +1,154 @@
+package rrl
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+func TestRRL(t *testing.T) {
+	now := time.Now()
+	rl := New()
+	rl.Zones = []string{"."}
+	rl.Next = answerHandler()
+	rl.allowances[classResponse] = 2
+	rl.slipRatio = 2
+	rl.now = func() time.Time { return now }
+
+	req := new(dns.Msg)
+	req.SetQuestion("example.org.", dns.TypeA)
+
+	// Two responses are allowed, after that every other one is dropped or slipped.
+	expected := []string{"ok", "ok", "drop", "slip", "drop", "slip"}
+	for i, e := range expected {
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		rl.ServeDNS(context.TODO(), rec, req)
+
+		got := "ok"
+		switch {
+		case rec.Msg == nil:
+			got = "drop"
+		case rec.Msg.Truncated:
+			got = "slip"
+		}
+		if got != e {
+			t.Errorf("Test %d: expected %s, got %s", i, e, got)
+		}
+	}
+
+	// After a second, the balance is credited again.
+	now = now.Add(time.Second)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	rl.ServeDNS(context.TODO(), rec, req)
+	if rec.Msg != nil && !rec.Msg.Truncated {
+		t.Errorf("Expected still limited after 1s, got a response")
+	}
+	now = now.Add(rl.window)
+	rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	rl.ServeDNS(context.TODO(), rec, req)
+	if rec.Msg == nil || rec.Msg.Truncated {
+		t.Errorf("Expected a response after the window passed")
+	}
+
+	// Other names are accounted separately.
+	req.SetQuestion("example.net.", dns.TypeA)
+	rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	rl.ServeDNS(context.TODO(), rec, req)
+	if rec.Msg == nil || rec.Msg.Truncated {
+		t.Errorf("Expected a response for another name")
+	}
+}
+
+func TestRRLLogOnly(t *testing.T) {
+	rl := New()
+	rl.Zones = []string{"."}
+	rl.Next = answerHandler()
+	rl.allowances[classResponse] = 1
+	rl.logOnly = true
+
+	req := new(dns.Msg)
+	req.SetQuestion("example.org.", dns.TypeA)
+	for i := 0; i < 5; i++ {
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		rl.ServeDNS(context.TODO(), rec, req)
+		if rec.Msg == nil || rec.Msg.Truncated {
+			t.Errorf("Test %d: expected a response in log-only mode", i)
+		}
+	}
+}
+
+func TestRRLTCP(t *testing.T) {
+	rl := New()
+	rl.Zones = []string{"."}
+	rl.Next = answerHandler()
+	rl.allowances[classResponse] = 1
+	rl.slipRatio = 0
+
+	req := new(dns.Msg)
+	req.SetQuestion("example.org.", dns.TypeA)
+	for i := 0; i < 5; i++ {
+		rec := dnstest.NewRecorder(&test.ResponseWriter{TCP: true})
+		rl.ServeDNS(context.TODO(), rec, req)
+		if rec.Msg == nil {
+			t.Errorf("Test %d: expected TCP responses not to be limited", i)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	req := new(dns.Msg)
+	req.SetQuestion("www.example.org.", dns.TypeA)
+	state := request.Request{W: &test.ResponseWriter{}, Req: req}
+
+	nx := new(dns.Msg)
+	nx.SetRcode(req, dns.RcodeNameError)
+	nx.Ns = []dns.RR{test.SOA("example.org. 300 IN SOA ns.example.org. admin.example.org. 1 3600 600 86400 300")}
+
+	servfail := new(dns.Msg)
+	servfail.SetRcode(req, dns.RcodeServerFailure)
+
+	tests := []struct {
+		m     *dns.Msg
+		class int
+		name  string
+	}{
+		{nx, classNXDomain, "example.org."},
+		{servfail, classError, ""},
+	}
+	for i, tc := range tests {
+		class, name := classify(state, tc.m, time.Now())
+		if class != tc.class || name != tc.name {
+			t.Errorf("Test %d: expected %s %q, got %s %q", i, classNames[tc.class], tc.name, classNames[class], name)
+		}
+	}
+}
+
+func TestTableBounded(t *testing.T) {
+	tb := newTable(1024)
+	now := time.Now()
+	for i := 0; i < 100000; i++ {
+		ip := net.IPv4(10, byte(i>>16), byte(i>>8), byte(i)).String()
+		tb.debit(ip, 1, defaultWindow, now)
+	}
+	if tb.len() > 1024 {
+		t.Errorf("Expected table to hold at most %d buckets, got %d", 1024, tb.len())
+	}
+}
+
+func answerHandler() plugin.Handler {
+	return plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = []dns.RR{test.A(r.Question[0].Name + " 300 IN A 127.0.0.1")}
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+}
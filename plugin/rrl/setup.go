@@ -0,0 +1,146 @@
+package rrl
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+)
+
+var log = clog.NewWithPlugin("rrl")
+
+func init() { plugin.Register("rrl", setup) }
+
+func setup(c *caddy.Controller) error {
+	rl, err := parse(c)
+	if err != nil {
+		return plugin.Error("rrl", err)
+	}
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		rl.Next = next
+		return rl
+	})
+
+	return nil
+}
+
+func parse(c *caddy.Controller) (*RRL, error) {
+	rl := New()
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		rl.Zones = plugin.OriginsFromArgsOrServerBlock(c.RemainingArgs(), c.ServerBlockKeys)
+		size := defaultTableSize
+		var set [classes]bool
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "window":
+				n, err := intArg(c)
+				if err != nil {
+					return nil, err
+				}
+				if n < 1 || n > 3600 {
+					return nil, fmt.Errorf("window should fall in range [1, 3600]: %d", n)
+				}
+				rl.window = time.Duration(n) * time.Second
+			case "ipv4-prefix-length":
+				n, err := intArg(c)
+				if err != nil {
+					return nil, err
+				}
+				if n < 1 || n > 32 {
+					return nil, fmt.Errorf("ipv4-prefix-length should fall in range [1, 32]: %d", n)
+				}
+				rl.ipv4Mask = net.CIDRMask(n, 32)
+			case "ipv6-prefix-length":
+				n, err := intArg(c)
+				if err != nil {
+					return nil, err
+				}
+				if n < 1 || n > 128 {
+					return nil, fmt.Errorf("ipv6-prefix-length should fall in range [1, 128]: %d", n)
+				}
+				rl.ipv6Mask = net.CIDRMask(n, 128)
+			case "responses-per-second", "nodata-per-second", "nxdomains-per-second", "referrals-per-second", "errors-per-second":
+				what := c.Val()
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				f, err := strconv.ParseFloat(args[0], 64)
+				if err != nil {
+					return nil, err
+				}
+				if f < 0 {
+					return nil, fmt.Errorf("%s can not be negative: %s", what, args[0])
+				}
+				switch what {
+				case "responses-per-second":
+					rl.allowances[classResponse], set[classResponse] = f, true
+				case "nodata-per-second":
+					rl.allowances[classNodata], set[classNodata] = f, true
+				case "nxdomains-per-second":
+					rl.allowances[classNXDomain], set[classNXDomain] = f, true
+				case "referrals-per-second":
+					rl.allowances[classReferral], set[classReferral] = f, true
+				case "errors-per-second":
+					rl.allowances[classError], set[classError] = f, true
+				}
+			case "slip-ratio":
+				n, err := intArg(c)
+				if err != nil {
+					return nil, err
+				}
+				if n < 0 || n > 10 {
+					return nil, fmt.Errorf("slip-ratio should fall in range [0, 10]: %d", n)
+				}
+				rl.slipRatio = n
+			case "log-only":
+				if len(c.RemainingArgs()) != 0 {
+					return nil, c.ArgErr()
+				}
+				rl.logOnly = true
+			case "max-table-size":
+				n, err := intArg(c)
+				if err != nil {
+					return nil, err
+				}
+				if n < 1 {
+					return nil, fmt.Errorf("max-table-size must be positive: %d", n)
+				}
+				size = n
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+
+		// The default for nodata, nxdomains and referrals is the responses allowance.
+		for _, cl := range []int{classNodata, classNXDomain, classReferral} {
+			if !set[cl] {
+				rl.allowances[cl] = rl.allowances[classResponse]
+			}
+		}
+		rl.table = newTable(size)
+	}
+	return rl, nil
+}
+
+func intArg(c *caddy.Controller) (int, error) {
+	args := c.RemainingArgs()
+	if len(args) != 1 {
+		return 0, c.ArgErr()
+	}
+	return strconv.Atoi(args[0])
+}
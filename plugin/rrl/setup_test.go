@@ -0,0 +1,99 @@
+package rrl
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/coredns/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input      string
+		shouldErr  bool
+		window     time.Duration
+		ipv4Prefix int
+		allowances [classes]float64
+		slipRatio  int
+		logOnly    bool
+	}{
+		{`rrl`, false, defaultWindow, defaultIPv4Prefix, [classes]float64{}, defaultSlipRatio, false},
+		{`rrl example.org {
+			responses-per-second 10
+		}`, false, defaultWindow, defaultIPv4Prefix, [classes]float64{10, 10, 10, 10, 0}, defaultSlipRatio, false},
+		{`rrl {
+			window 5
+			ipv4-prefix-length 16
+			ipv6-prefix-length 48
+			responses-per-second 10
+			nodata-per-second 0
+			nxdomains-per-second 5
+			errors-per-second 2.5
+			slip-ratio 0
+			log-only
+			max-table-size 1000
+		}`, false, 5 * time.Second, 16, [classes]float64{10, 0, 5, 10, 2.5}, 0, true},
+		// fails
+		{`rrl {
+			window 0
+		}`, true, 0, 0, [classes]float64{}, 0, false},
+		{`rrl {
+			ipv4-prefix-length 33
+		}`, true, 0, 0, [classes]float64{}, 0, false},
+		{`rrl {
+			responses-per-second -1
+		}`, true, 0, 0, [classes]float64{}, 0, false},
+		{`rrl {
+			slip-ratio 11
+		}`, true, 0, 0, [classes]float64{}, 0, false},
+		{`rrl {
+			log-only yes
+		}`, true, 0, 0, [classes]float64{}, 0, false},
+		{`rrl {
+			blah
+		}`, true, 0, 0, [classes]float64{}, 0, false},
+		{`rrl
+		rrl`, true, 0, 0, [classes]float64{}, 0, false},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		rl, err := parse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found nil", i)
+			continue
+		} else if !test.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		if rl.window != test.window {
+			t.Errorf("Test %d: Expected window %v, got %v", i, test.window, rl.window)
+		}
+		if ones, _ := rl.ipv4Mask.Size(); ones != test.ipv4Prefix {
+			t.Errorf("Test %d: Expected IPv4 prefix %d, got %d", i, test.ipv4Prefix, ones)
+		}
+		if rl.allowances != test.allowances {
+			t.Errorf("Test %d: Expected allowances %v, got %v", i, test.allowances, rl.allowances)
+		}
+		if rl.slipRatio != test.slipRatio {
+			t.Errorf("Test %d: Expected slip ratio %d, got %d", i, test.slipRatio, rl.slipRatio)
+		}
+		if rl.logOnly != test.logOnly {
+			t.Errorf("Test %d: Expected log-only %t, got %t", i, test.logOnly, rl.logOnly)
+		}
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	rl := New()
+	if p := rl.prefix(net.ParseIP("192.0.2.77")); p != "192.0.2.0" {
+		t.Errorf("Expected %s, got %s", "192.0.2.0", p)
+	}
+	if p := rl.prefix(net.ParseIP("2001:db8:1:2:3::1")); p != "2001:db8:1::" {
+		t.Errorf("Expected %s, got %s", "2001:db8:1::", p)
+	}
+}
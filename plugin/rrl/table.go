@@ -0,0 +1,69 @@
+package rrl
+
+import (
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/cache"
+)
+
+// bucket is the account of a single token. Its balance is credited with the allowance every
+// second, up to one second worth of responses, and debited for each response. A response is over
+// the limit when the balance is negative. The balance never drops below -window*allowance, so a
+// client that stops sending is forgiven after at most window seconds.
+type bucket struct {
+	sync.Mutex
+	balance float64
+	last    time.Time
+	slipped int // number of limited responses, used to compute the slip ratio.
+}
+
+// table holds the buckets, it is bounded in size: when full, random buckets are evicted.
+type table struct {
+	c *cache.Cache
+}
+
+func newTable(size int) *table { return &table{c: cache.New(size)} }
+
+// debit debits the bucket for token with one response and returns the new balance. A bucket
+// that doesn't exist yet is created with a full balance.
+func (t *table) debit(token string, allowance float64, window time.Duration, now time.Time) (float64, *bucket) {
+	k := cache.Hash([]byte(token))
+	var b *bucket
+	if v, ok := t.c.Get(k); ok {
+		b = v.(*bucket)
+	} else {
+		b = &bucket{balance: allowance, last: now}
+		t.c.Add(k, b)
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	b.balance += now.Sub(b.last).Seconds() * allowance
+	if b.balance > allowance {
+		b.balance = allowance
+	}
+	b.last = now
+
+	b.balance--
+	if min := -window.Seconds() * allowance; b.balance < min {
+		b.balance = min
+	}
+	return b.balance, b
+}
+
+// slip returns true when the limited response for b should be answered with a truncated response
+// instead of being dropped. With ratio N this is true for every N-th limited response.
+func (b *bucket) slip(ratio int) bool {
+	if ratio == 0 {
+		return false
+	}
+	b.Lock()
+	defer b.Unlock()
+	b.slipped++
+	return b.slipped%ratio == 0
+}
+
+// len returns the number of buckets in the table.
+func (t *table) len() int { return t.c.Len() }
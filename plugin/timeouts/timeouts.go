@@ -0,0 +1,146 @@
+// Package timeouts implements a plugin that configures the connection and query timeouts of a server.
+package timeouts
+
+import (
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+)
+
+func init() { plugin.Register("timeouts", setup) }
+
+func setup(c *caddy.Controller) error {
+	if err := parse(c); err != nil {
+		return plugin.Error("timeouts", err)
+	}
+	return nil
+}
+
+func parse(c *caddy.Controller) error {
+	config := dnsserver.GetConfig(c)
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return plugin.ErrOnce
+		}
+		i++
+
+		if len(c.RemainingArgs()) != 0 {
+			return c.ArgErr()
+		}
+		set := false
+		for c.NextBlock() {
+			set = true
+			switch c.Val() {
+			case "read":
+				d, err := duration(c)
+				if err != nil {
+					return err
+				}
+				config.ReadTimeout = d
+			case "write":
+				d, err := duration(c)
+				if err != nil {
+					return err
+				}
+				config.WriteTimeout = d
+			case "idle":
+				d, err := duration(c)
+				if err != nil {
+					return err
+				}
+				config.IdleTimeout = d
+			case "grace":
+				d, err := duration(c)
+				if err != nil {
+					return err
+				}
+				config.GraceTimeout = d
+			case "query":
+				d, err := duration(c)
+				if err != nil {
+					return err
+				}
+				config.QueryTimeout = d
+			case "plugin":
+				name, b, err := budget(c)
+				if err != nil {
+					return err
+				}
+				if config.Budgets == nil {
+					config.Budgets = make(map[string]dnsserver.Budget)
+				}
+				if _, ok := config.Budgets[name]; ok {
+					return c.Errf("budget of plugin %q already set", name)
+				}
+				config.Budgets[name] = b
+			case "keepalive":
+				if c.NextArg() {
+					return c.ArgErr()
+				}
+				config.TCPKeepalive = true
+			default:
+				return c.Errf("unknown property %q", c.Val())
+			}
+		}
+		if !set {
+			return c.Err("timeouts requires at least one property")
+		}
+	}
+	return nil
+}
+
+// duration parses the single duration argument of a property, it must be between a second and a day.
+func duration(c *caddy.Controller) (time.Duration, error) {
+	name := c.Val()
+	args := c.RemainingArgs()
+	if len(args) != 1 {
+		return 0, c.ArgErr()
+	}
+	d, err := time.ParseDuration(args[0])
+	if err != nil {
+		return 0, c.Errf("invalid duration %q", args[0])
+	}
+	if d < time.Second || d > 24*time.Hour {
+		return 0, c.Errf("%s must be between 1s and 24h, got %s", name, d)
+	}
+	return d, nil
+}
+
+// budget parses the arguments of the plugin property: the name of the plugin, its budget, between a
+// millisecond and a day, and optionally fallthrough.
+func budget(c *caddy.Controller) (string, dnsserver.Budget, error) {
+	args := c.RemainingArgs()
+	if len(args) < 2 || len(args) > 3 {
+		return "", dnsserver.Budget{}, c.ArgErr()
+	}
+	name := args[0]
+	known := false
+	for _, d := range dnsserver.Directives {
+		if d == name {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return "", dnsserver.Budget{}, c.Errf("unknown plugin %q", name)
+	}
+	d, err := time.ParseDuration(args[1])
+	if err != nil {
+		return "", dnsserver.Budget{}, c.Errf("invalid duration %q", args[1])
+	}
+	if d < time.Millisecond || d > 24*time.Hour {
+		return "", dnsserver.Budget{}, c.Errf("budget of plugin %q must be between 1ms and 24h, got %s", name, d)
+	}
+	b := dnsserver.Budget{Timeout: d}
+	if len(args) == 3 {
+		if args[2] != "fallthrough" {
+			return "", dnsserver.Budget{}, c.Errf("unknown argument %q", args[2])
+		}
+		b.Fallthrough = true
+	}
+	return name, b, nil
+}
@@ -0,0 +1,116 @@
+package timeouts
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input             string
+		shouldErr         bool
+		expectedRead      time.Duration
+		expectedWrite     time.Duration
+		expectedIdle      time.Duration
+		expectedGrace     time.Duration
+		expectedKeepalive bool
+		expectedErr       string
+	}{
+		// positive
+		{"timeouts {\nread 3s\n}", false, 3 * time.Second, 0, 0, 0, false, ""},
+		{"timeouts {\nread 3s\nwrite 5s\nidle 30s\ngrace 10s\nkeepalive\n}", false, 3 * time.Second, 5 * time.Second, 30 * time.Second, 10 * time.Second, true, ""},
+		// negative
+		{"timeouts", true, 0, 0, 0, 0, false, "at least one property"},
+		{"timeouts 5s", true, 0, 0, 0, 0, false, "Wrong argument count"},
+		{"timeouts {\nread\n}", true, 0, 0, 0, 0, false, "Wrong argument count"},
+		{"timeouts {\nread abc\n}", true, 0, 0, 0, 0, false, "invalid duration"},
+		{"timeouts {\nidle 100ms\n}", true, 0, 0, 0, 0, false, "idle must be between 1s and 24h"},
+		{"timeouts {\nwrite 48h\n}", true, 0, 0, 0, 0, false, "write must be between 1s and 24h"},
+		{"timeouts {\nkeepalive yes\n}", true, 0, 0, 0, 0, false, "Wrong argument count"},
+		{"timeouts {\nlinger 5s\n}", true, 0, 0, 0, 0, false, "unknown property"},
+		{"timeouts {\nread 3s\n}\ntimeouts {\nread 3s\n}", true, 0, 0, 0, 0, false, "plugin"},
+		{"timeouts {\nquery 10ms\n}", true, 0, 0, 0, 0, false, "query must be between 1s and 24h"},
+		{"timeouts {\nplugin forward\n}", true, 0, 0, 0, 0, false, "Wrong argument count"},
+		{"timeouts {\nplugin forward 2s fallthrough extra\n}", true, 0, 0, 0, 0, false, "Wrong argument count"},
+		{"timeouts {\nplugin bogus 2s\n}", true, 0, 0, 0, 0, false, "unknown plugin"},
+		{"timeouts {\nplugin forward abc\n}", true, 0, 0, 0, 0, false, "invalid duration"},
+		{"timeouts {\nplugin forward 100us\n}", true, 0, 0, 0, 0, false, "must be between 1ms and 24h"},
+		{"timeouts {\nplugin forward 2s through\n}", true, 0, 0, 0, 0, false, "unknown argument"},
+		{"timeouts {\nplugin forward 2s\nplugin forward 3s\n}", true, 0, 0, 0, 0, false, "already set"},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		err := setup(c)
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error but found none for input %s", i, test.input)
+			} else if !strings.Contains(err.Error(), test.expectedErr) {
+				t.Errorf("Test %d: expected error to contain: %v, found error: %v", i, test.expectedErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Test %d: expected no error but found one for input %s, got: %v", i, test.input, err)
+		}
+
+		config := dnsserver.GetConfig(c)
+		if config.ReadTimeout != test.expectedRead {
+			t.Errorf("Test %d: expected read timeout %s, got %s", i, test.expectedRead, config.ReadTimeout)
+		}
+		if config.WriteTimeout != test.expectedWrite {
+			t.Errorf("Test %d: expected write timeout %s, got %s", i, test.expectedWrite, config.WriteTimeout)
+		}
+		if config.IdleTimeout != test.expectedIdle {
+			t.Errorf("Test %d: expected idle timeout %s, got %s", i, test.expectedIdle, config.IdleTimeout)
+		}
+		if config.GraceTimeout != test.expectedGrace {
+			t.Errorf("Test %d: expected grace timeout %s, got %s", i, test.expectedGrace, config.GraceTimeout)
+		}
+		if config.TCPKeepalive != test.expectedKeepalive {
+			t.Errorf("Test %d: expected keepalive %t, got %t", i, test.expectedKeepalive, config.TCPKeepalive)
+		}
+	}
+}
+
+func TestSetupQuery(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected map[string]dnsserver.Budget
+	}{
+		{
+			"timeouts {\nquery 5s\nplugin forward 2s fallthrough\n}",
+			map[string]dnsserver.Budget{"forward": {Timeout: 2 * time.Second, Fallthrough: true}},
+		},
+		{
+			"timeouts {\nquery 5s\nplugin kubernetes 200ms fallthrough\nplugin forward 2s\n}",
+			map[string]dnsserver.Budget{
+				"kubernetes": {Timeout: 200 * time.Millisecond, Fallthrough: true},
+				"forward":    {Timeout: 2 * time.Second},
+			},
+		},
+	}
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		if err := setup(c); err != nil {
+			t.Fatalf("Test %d: expected no error, got: %v", i, err)
+		}
+
+		config := dnsserver.GetConfig(c)
+		if config.QueryTimeout != 5*time.Second {
+			t.Errorf("Test %d: expected query timeout %s, got %s", i, 5*time.Second, config.QueryTimeout)
+		}
+		if len(config.Budgets) != len(tc.expected) {
+			t.Fatalf("Test %d: expected %d budgets, got %d", i, len(tc.expected), len(config.Budgets))
+		}
+		for name, b := range tc.expected {
+			if config.Budgets[name] != b {
+				t.Errorf("Test %d: expected budget %v for %s, got %v", i, b, name, config.Budgets[name])
+			}
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package tls
+
+import (
+	"context"
+	ctls "crypto/tls"
+	"crypto/x509"
+
+	"github.com/caddyserver/certmagic"
+)
+
+// acme gets certificates for the domains from an ACME CA, like Let's Encrypt, and renews them
+// before they expire.
+type acme struct {
+	domains []string
+	storage string // where the account and certificates are kept, empty is certmagic's default
+
+	issuer certmagic.ACMEIssuer
+	staple bool
+
+	cache  *certmagic.Cache
+	magic  *certmagic.Config
+	cancel context.CancelFunc
+}
+
+func newACME(domains []string) *acme {
+	return &acme{
+		domains: domains,
+		issuer:  certmagic.ACMEIssuer{CA: certmagic.LetsEncryptProductionCA, Agreed: true},
+		staple:  true,
+	}
+}
+
+// setup sets config up to serve the certificates of a.
+func (a *acme) setup(config *ctls.Config, roots *x509.CertPool) {
+	var magic *certmagic.Config
+	a.cache = certmagic.NewCache(certmagic.CacheOptions{
+		GetConfigForCert: func(certmagic.Certificate) (*certmagic.Config, error) { return magic, nil },
+	})
+	template := certmagic.Config{
+		DefaultServerName: a.domains[0],
+		OCSP:              certmagic.OCSPConfig{DisableStapling: !a.staple},
+	}
+	if a.storage != "" {
+		template.Storage = &certmagic.FileStorage{Path: a.storage}
+	}
+	magic = certmagic.New(a.cache, template)
+
+	issuer := a.issuer
+	issuer.TrustedRoots = roots
+	magic.Issuers = []certmagic.Issuer{certmagic.NewACMEIssuer(magic, issuer)}
+	a.magic = magic
+
+	config.Certificates = nil
+	config.GetCertificate = magic.GetCertificate
+	config.GetConfigForClient = func(hello *ctls.ClientHelloInfo) (*ctls.Config, error) {
+		// The TLS-ALPN challenge must be answered with its own protocol, which the servers don't
+		// list.
+		for _, p := range hello.SupportedProtos {
+			if p == acmeTLS1Protocol {
+				c := config.Clone()
+				c.GetConfigForClient = nil
+				c.NextProtos = []string{acmeTLS1Protocol}
+				return c, nil
+			}
+		}
+		return nil, nil
+	}
+}
+
+// start gets the certificates, or loads them from storage, and keeps them renewed.
+func (a *acme) start() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancel = cancel
+	return a.magic.ManageAsync(ctx, a.domains)
+}
+
+// shutdown stops renewing the certificates.
+func (a *acme) shutdown() error {
+	if a.cancel != nil {
+		a.cancel()
+	}
+	a.cache.Stop()
+	return nil
+}
+
+const acmeTLS1Protocol = "acme-tls/1"
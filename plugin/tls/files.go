@@ -0,0 +1,184 @@
+package tls
+
+import (
+	ctls "crypto/tls"
+	"crypto/x509"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/tls"
+)
+
+// certFiles keeps the certificate, key and CA of a server up to date with the files they are
+// loaded from, so a renewed certificate is used without a reload. It also staples OCSP responses
+// to the certificate.
+type certFiles struct {
+	cert, key, ca string
+	interval      time.Duration // how often the files are checked, zero is never
+	staple        bool          // staple OCSP responses
+	config        *ctls.Config  // the config the certificate is served with
+
+	mu         sync.RWMutex
+	current    *ctls.Certificate
+	clientCAs  *x509.CertPool
+	modified   time.Time // the latest modification time of the files
+	nextStaple time.Time // when the OCSP response must be refreshed
+
+	stop chan struct{}
+}
+
+// newCertFiles loads the certificate, key and CA from their files, and sets config up to serve them.
+func newCertFiles(cert, key, ca string, config *ctls.Config) (*certFiles, error) {
+	f := &certFiles{cert: cert, key: key, ca: ca, config: config, interval: defaultInterval, staple: true}
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+	// With no certificates in the config the server always asks GetCertificate.
+	config.Certificates = nil
+	config.GetCertificate = f.getCertificate
+	if ca != "" {
+		config.GetConfigForClient = f.getConfigForClient
+	}
+	return f, nil
+}
+
+// load reads the files.
+func (f *certFiles) load() error {
+	modified := f.lastModified()
+	c, err := tls.NewTLSConfig(f.cert, f.key, f.ca)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.current = &c.Certificates[0]
+	f.clientCAs = c.RootCAs
+	f.modified = modified
+	f.nextStaple = time.Time{}
+	return nil
+}
+
+// lastModified returns the latest modification time of the files.
+func (f *certFiles) lastModified() time.Time {
+	var last time.Time
+	for _, name := range []string{f.cert, f.key, f.ca} {
+		if name == "" {
+			continue
+		}
+		fi, err := os.Stat(name)
+		if err != nil {
+			continue
+		}
+		if fi.ModTime().After(last) {
+			last = fi.ModTime()
+		}
+	}
+	return last
+}
+
+// check loads the files again when they changed, and refreshes the OCSP response when needed.
+func (f *certFiles) check() {
+	f.mu.RLock()
+	modified, nextStaple := f.modified, f.nextStaple
+	f.mu.RUnlock()
+
+	if f.interval != 0 && f.lastModified().After(modified) {
+		// The files may be written one after the other; if they don't match yet, the next check
+		// tries again.
+		if err := f.load(); err != nil {
+			log.Warningf("Failed to reload certificate %s: %s", f.cert, err)
+			return
+		}
+		log.Infof("Reloaded certificate %s", f.cert)
+		nextStaple = time.Time{}
+	}
+
+	if !f.staple || time.Now().Before(nextStaple) {
+		return
+	}
+	f.mu.RLock()
+	current := f.current
+	f.mu.RUnlock()
+
+	cert := *current
+	next, err := staple(&cert)
+	if err != nil {
+		log.Warningf("Failed to staple OCSP response to certificate %s: %s", f.cert, err)
+		next = time.Now().Add(ocspRetry)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// Don't replace a certificate that was reloaded in the meantime.
+	if f.current == current {
+		f.current = &cert
+		f.nextStaple = next
+	}
+}
+
+// start checks the files every interval, and the OCSP response every minute, until shutdown is
+// called.
+func (f *certFiles) start() error {
+	if f.interval == 0 && !f.staple {
+		return nil
+	}
+	interval := f.interval
+	if interval == 0 || interval > time.Minute {
+		interval = time.Minute
+	}
+	stop := make(chan struct{})
+	f.stop = stop
+	go func() {
+		f.check()
+		tick := time.NewTicker(interval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-tick.C:
+				f.check()
+			}
+		}
+	}()
+	return nil
+}
+
+// shutdown stops checking the files.
+func (f *certFiles) shutdown() error {
+	if f.stop != nil {
+		close(f.stop)
+		f.stop = nil
+	}
+	return nil
+}
+
+func (f *certFiles) getCertificate(*ctls.ClientHelloInfo) (*ctls.Certificate, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.current, nil
+}
+
+// getConfigForClient returns the config with the current CA to verify the client certificate.
+func (f *certFiles) getConfigForClient(hello *ctls.ClientHelloInfo) (*ctls.Config, error) {
+	f.mu.RLock()
+	clientCAs := f.clientCAs
+	f.mu.RUnlock()
+
+	c := f.config.Clone()
+	c.GetConfigForClient = nil
+	c.ClientCAs = clientCAs
+	if len(c.NextProtos) == 0 {
+		// gRPC adds h2 to its own copy of the config, which this one isn't; accept what the client
+		// offers instead.
+		c.NextProtos = hello.SupportedProtos
+	}
+	return c, nil
+}
+
+const (
+	defaultInterval = time.Minute
+	ocspRetry       = 5 * time.Minute // how soon a failed OCSP request is retried
+)
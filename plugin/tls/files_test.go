@@ -0,0 +1,151 @@
+package tls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	ctls "crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCert writes a self-signed certificate for name, and its key, to dir.
+func writeCert(t *testing.T, dir, name string, modified time.Time) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{certFile, keyFile} {
+		if err := os.Chtimes(f, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return certFile, keyFile
+}
+
+func commonName(t *testing.T, f *certFiles) string {
+	t.Helper()
+	cert, err := f.getCertificate(&ctls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestCertFilesReload(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	certFile, keyFile := writeCert(t, dir, "old.example.org", now.Add(-time.Minute))
+
+	config := &ctls.Config{}
+	f, err := newCertFiles(certFile, keyFile, "", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Certificates != nil || config.GetCertificate == nil {
+		t.Fatal("Expected the certificate to be served with GetCertificate")
+	}
+	if config.GetConfigForClient != nil {
+		t.Error("Expected no GetConfigForClient without a CA")
+	}
+	if name := commonName(t, f); name != "old.example.org" {
+		t.Fatalf("Expected certificate for %s, got %s", "old.example.org", name)
+	}
+
+	// Unchanged files are not loaded again.
+	f.check()
+	if name := commonName(t, f); name != "old.example.org" {
+		t.Fatalf("Expected certificate for %s, got %s", "old.example.org", name)
+	}
+
+	writeCert(t, dir, "new.example.org", now)
+	f.check()
+	if name := commonName(t, f); name != "new.example.org" {
+		t.Errorf("Expected certificate for %s, got %s", "new.example.org", name)
+	}
+
+	// A broken file keeps the current certificate.
+	if err := ioutil.WriteFile(keyFile, []byte("garbage"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(keyFile, now.Add(time.Minute), now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	f.check()
+	if name := commonName(t, f); name != "new.example.org" {
+		t.Errorf("Expected certificate for %s, got %s", "new.example.org", name)
+	}
+}
+
+func TestCertFilesReloadOff(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	certFile, keyFile := writeCert(t, dir, "old.example.org", now.Add(-time.Minute))
+
+	f, err := newCertFiles(certFile, keyFile, "", &ctls.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.interval = 0
+
+	writeCert(t, dir, "new.example.org", now)
+	f.check()
+	if name := commonName(t, f); name != "old.example.org" {
+		t.Errorf("Expected certificate for %s, got %s", "old.example.org", name)
+	}
+}
+
+func TestCertFilesClientCAs(t *testing.T) {
+	config := &ctls.Config{}
+	if _, err := newCertFiles("test_cert.pem", "test_key.pem", "test_ca.pem", config); err != nil {
+		t.Fatal(err)
+	}
+	if config.GetConfigForClient == nil {
+		t.Fatal("Expected GetConfigForClient with a CA")
+	}
+	c, err := config.GetConfigForClient(&ctls.ClientHelloInfo{SupportedProtos: []string{"dot"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ClientCAs == nil {
+		t.Error("Expected client CAs")
+	}
+	if len(c.NextProtos) != 1 || c.NextProtos[0] != "dot" {
+		t.Errorf("Expected the protocols of the client, got %v", c.NextProtos)
+	}
+}
@@ -0,0 +1,72 @@
+package tls
+
+import (
+	"context"
+	"crypto/x509"
+	"strings"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// Handler publishes the identity in the verified certificate of a TLS client as metadata. It doesn't
+// handle queries itself.
+type Handler struct {
+	Next plugin.Handler
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (h Handler) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	return plugin.NextOrFailure(h.Name(), h.Next, ctx, w, r)
+}
+
+// Name implements the Handler interface.
+func (h Handler) Name() string { return "tls" }
+
+// Metadata implements the metadata.Provider interface.
+func (h Handler) Metadata(ctx context.Context, state request.Request) context.Context {
+	cert := clientCertificate(state.W)
+	if cert == nil {
+		return ctx
+	}
+	metadata.SetValueFunc(ctx, "tls/subject", func() string { return cert.Subject.String() })
+	metadata.SetValueFunc(ctx, "tls/san", func() string { return strings.Join(subjectAltNames(cert), ",") })
+	metadata.SetValueFunc(ctx, "tls/spiffe_id", func() string { return spiffeID(cert) })
+	return ctx
+}
+
+// clientCertificate returns the certificate of the client, if it sent one and it was verified.
+func clientCertificate(w dns.ResponseWriter) *x509.Certificate {
+	cs := dnsserver.ConnectionState(w)
+	if cs == nil || len(cs.VerifiedChains) == 0 || len(cs.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return cs.VerifiedChains[0][0]
+}
+
+// subjectAltNames returns the DNS names, email addresses, IP addresses and URIs in cert.
+func subjectAltNames(cert *x509.Certificate) []string {
+	sans := append([]string{}, cert.DNSNames...)
+	sans = append(sans, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	for _, u := range cert.URIs {
+		sans = append(sans, u.String())
+	}
+	return sans
+}
+
+// spiffeID returns the SPIFFE ID in cert, the URI SAN with the spiffe scheme.
+func spiffeID(cert *x509.Certificate) string {
+	for _, u := range cert.URIs {
+		if strings.EqualFold(u.Scheme, "spiffe") {
+			return u.String()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,71 @@
+package tls
+
+import (
+	"context"
+	ctls "crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// tlsResponseWriter is a test.ResponseWriter that received the query over TLS.
+type tlsResponseWriter struct {
+	test.ResponseWriter
+	state *ctls.ConnectionState
+}
+
+func (w *tlsResponseWriter) ConnectionState() *ctls.ConnectionState { return w.state }
+
+func TestMetadata(t *testing.T) {
+	spiffe, _ := url.Parse("spiffe://example.org/ns/prod/sa/client")
+	cert := &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "client", Organization: []string{"Example"}},
+		DNSNames:       []string{"client.example.org"},
+		EmailAddresses: []string{"ops@example.org"},
+		IPAddresses:    []net.IP{net.ParseIP("192.0.2.1")},
+		URIs:           []*url.URL{spiffe},
+	}
+
+	tests := []struct {
+		w        dns.ResponseWriter
+		subject  string
+		san      string
+		spiffeID string
+	}{
+		{
+			w:        &tlsResponseWriter{state: &ctls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}},
+			subject:  "CN=client,O=Example",
+			san:      "client.example.org,ops@example.org,192.0.2.1,spiffe://example.org/ns/prod/sa/client",
+			spiffeID: "spiffe://example.org/ns/prod/sa/client",
+		},
+		// Not verified.
+		{w: &tlsResponseWriter{state: &ctls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}}},
+		// Not TLS.
+		{w: &test.ResponseWriter{}},
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	for i, tc := range tests {
+		ctx := metadata.ContextWithMetadata(context.TODO())
+		ctx = Handler{}.Metadata(ctx, request.Request{W: tc.w, Req: m})
+
+		for label, expected := range map[string]string{"tls/subject": tc.subject, "tls/san": tc.san, "tls/spiffe_id": tc.spiffeID} {
+			got := ""
+			if f := metadata.ValueFunc(ctx, label); f != nil {
+				got = f()
+			}
+			if got != expected {
+				t.Errorf("Test %d: expected %s to be %q, got %q", i, label, expected, got)
+			}
+		}
+	}
+}
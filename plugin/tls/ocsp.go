@@ -0,0 +1,83 @@
+package tls
+
+import (
+	"bytes"
+	ctls "crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// staple gets an OCSP response for the certificate from its issuer, and staples it to the
+// certificate. It returns when the response should be refreshed. A certificate without an issuer in
+// its chain or without an OCSP server is left alone until it expires.
+func staple(cert *ctls.Certificate) (time.Time, error) {
+	if len(cert.Certificate) == 0 {
+		return time.Time{}, fmt.Errorf("no certificate")
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(cert.Certificate) < 2 || len(leaf.OCSPServer) == 0 {
+		return leaf.NotAfter, nil
+	}
+	issuer, err := x509.ParseCertificate(cert.Certificate[1])
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	req, err := ocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	resp, err := ocspClient.Post(leaf.OCSPServer[0], "application/ocsp-request", bytes.NewReader(req))
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return time.Time{}, fmt.Errorf("OCSP server %s returned %d", leaf.OCSPServer[0], resp.StatusCode)
+	}
+	raw, err := ioutil.ReadAll(http.MaxBytesReader(nil, resp.Body, maxOCSPSize))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	r, err := ocsp.ParseResponseForCert(raw, leaf, issuer)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if r.Status != ocsp.Good {
+		// Stapling a revoked status won't help anybody; keep the old response until it expires.
+		return time.Time{}, fmt.Errorf("OCSP status of certificate is %s", ocspStatus(r.Status))
+	}
+	cert.OCSPStaple = raw
+
+	// Refresh halfway through the validity of the response.
+	if r.NextUpdate.IsZero() {
+		return time.Now().Add(ocspRefresh), nil
+	}
+	return r.ThisUpdate.Add(r.NextUpdate.Sub(r.ThisUpdate) / 2), nil
+}
+
+func ocspStatus(status int) string {
+	switch status {
+	case ocsp.Good:
+		return "good"
+	case ocsp.Revoked:
+		return "revoked"
+	}
+	return "unknown"
+}
+
+var ocspClient = &http.Client{Timeout: 10 * time.Second}
+
+const (
+	maxOCSPSize = 64 * 1024     // the largest OCSP response read
+	ocspRefresh = 1 * time.Hour // refresh of a response without a next update
+)
@@ -0,0 +1,131 @@
+package tls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	ctls "crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	ptls "github.com/coredns/coredns/plugin/pkg/tls"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// newOCSPChain returns a certificate, issued by a CA that answers OCSP requests for it with status.
+func newOCSPChain(t *testing.T, status int) (*ctls.Certificate, *httptest.Server) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	caDer, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDer)
+
+	var leaf *x509.Certificate
+	responder := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := ioutil.ReadAll(r.Body)
+		req, err := ocsp.ParseRequest(raw)
+		if err != nil || req.SerialNumber.Cmp(leaf.SerialNumber) != 0 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		now := time.Now().Truncate(time.Minute)
+		resp, err := ocsp.CreateResponse(ca, ca, ocsp.Response{
+			Status:       status,
+			SerialNumber: leaf.SerialNumber,
+			ThisUpdate:   now,
+			NextUpdate:   now.Add(2 * time.Hour),
+		}, caKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		w.Write(resp)
+	}))
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "example.org"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		OCSPServer:   []string{responder.URL},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ = x509.ParseCertificate(der)
+
+	return &ctls.Certificate{Certificate: [][]byte{der, caDer}, PrivateKey: key}, responder
+}
+
+func TestStaple(t *testing.T) {
+	cert, responder := newOCSPChain(t, ocsp.Good)
+	defer responder.Close()
+
+	next, err := staple(cert)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if len(cert.OCSPStaple) == 0 {
+		t.Error("Expected an OCSP response to be stapled")
+	}
+	if d := time.Until(next); d < 30*time.Minute || d > time.Hour {
+		t.Errorf("Expected a refresh in about an hour, got %s", d)
+	}
+}
+
+func TestStapleRevoked(t *testing.T) {
+	cert, responder := newOCSPChain(t, ocsp.Revoked)
+	defer responder.Close()
+
+	if _, err := staple(cert); err == nil {
+		t.Error("Expected an error for a revoked certificate")
+	}
+	if len(cert.OCSPStaple) != 0 {
+		t.Error("Expected no OCSP response to be stapled")
+	}
+}
+
+func TestStapleNoOCSPServer(t *testing.T) {
+	c, err := ptls.NewTLSConfig("test_cert.pem", "test_key.pem", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := &c.Certificates[0]
+	next, err := staple(cert)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if len(cert.OCSPStaple) != 0 {
+		t.Error("Expected no OCSP response to be stapled")
+	}
+	if next.IsZero() {
+		t.Error("Expected a time to check the certificate again")
+	}
+}
@@ -2,13 +2,17 @@ package tls
 
 import (
 	ctls "crypto/tls"
+	"time"
 
 	"github.com/coredns/caddy"
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
-	"github.com/coredns/coredns/plugin/pkg/tls"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+	ptls "github.com/coredns/coredns/plugin/pkg/tls"
 )
 
+var log = clog.NewWithPlugin("tls")
+
 func init() { plugin.Register("tls", setup) }
 
 func setup(c *caddy.Controller) error {
@@ -16,6 +20,11 @@ func setup(c *caddy.Controller) error {
 	if err != nil {
 		return plugin.Error("tls", err)
 	}
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		return Handler{Next: next}
+	})
+
 	return nil
 }
 
@@ -45,10 +54,19 @@ func parseTLS(c *caddy.Controller) error {
 
 	for c.Next() {
 		args := c.RemainingArgs()
-		if len(args) < 2 || len(args) > 3 {
+		var a *acme
+		if len(args) > 0 && args[0] == "acme" {
+			if len(args) < 2 {
+				return plugin.Error("tls", c.ArgErr())
+			}
+			a = newACME(args[1:])
+		} else if len(args) < 2 || len(args) > 3 {
 			return plugin.Error("tls", c.ArgErr())
 		}
 		clientAuth := ctls.NoClientCert
+		interval := defaultInterval
+		staple := true
+		caRoot := ""
 		for c.NextBlock() {
 			switch c.Val() {
 			case "client_auth":
@@ -70,17 +88,90 @@ func parseTLS(c *caddy.Controller) error {
 				default:
 					return c.Errf("unknown authentication type '%s'", authTypeArgs[0])
 				}
+			case "reload":
+				if a != nil {
+					return c.Errf("reload is not used with acme, certificates are renewed automatically")
+				}
+				reloadArgs := c.RemainingArgs()
+				if len(reloadArgs) != 1 {
+					return c.ArgErr()
+				}
+				if reloadArgs[0] == "off" {
+					interval = 0
+					continue
+				}
+				d, err := time.ParseDuration(reloadArgs[0])
+				if err != nil {
+					return c.Errf("invalid reload interval '%s': %v", reloadArgs[0], err)
+				}
+				if d <= 0 {
+					return c.Errf("reload interval must be greater than zero: %s", d)
+				}
+				interval = d
+			case "ocsp":
+				ocspArgs := c.RemainingArgs()
+				if len(ocspArgs) != 1 || ocspArgs[0] != "off" {
+					return c.ArgErr()
+				}
+				staple = false
+			case "email", "ca", "ca_root", "storage":
+				if a == nil {
+					return c.Errf("%s is only used with acme", c.Val())
+				}
+				option := c.Val()
+				optionArgs := c.RemainingArgs()
+				if len(optionArgs) != 1 {
+					return c.ArgErr()
+				}
+				switch option {
+				case "email":
+					a.issuer.Email = optionArgs[0]
+				case "ca":
+					a.issuer.CA = optionArgs[0]
+				case "ca_root":
+					caRoot = optionArgs[0]
+				case "storage":
+					a.storage = optionArgs[0]
+				}
 			default:
 				return c.Errf("unknown option '%s'", c.Val())
 			}
 		}
-		tls, err := tls.NewTLSConfigFromArgs(args...)
-		if err != nil {
-			return err
+
+		var tls *ctls.Config
+		if a != nil {
+			roots, err := ptls.NewTLSClientConfig(caRoot)
+			if err != nil {
+				return err
+			}
+			tls = &ctls.Config{}
+			a.staple = staple
+			a.setup(tls, roots.RootCAs)
+			c.OnStartup(a.start)
+			c.OnShutdown(a.shutdown)
+		} else {
+			var err error
+			tls, err = ptls.NewTLSConfigFromArgs(args...)
+			if err != nil {
+				return err
+			}
+			// NewTLSConfigFromArgs only sets RootCAs, so we need to let ClientCAs refer to it.
+			tls.ClientCAs = tls.RootCAs
+
+			ca := ""
+			if len(args) == 3 {
+				ca = args[2]
+			}
+			f, err := newCertFiles(args[0], args[1], ca, tls)
+			if err != nil {
+				return err
+			}
+			f.interval = interval
+			f.staple = staple
+			c.OnStartup(f.start)
+			c.OnShutdown(f.shutdown)
 		}
 		tls.ClientAuth = clientAuth
-		// NewTLSConfigFromArgs only sets RootCAs, so we need to let ClientCAs refer to it.
-		tls.ClientCAs = tls.RootCAs
 
 		setTLSDefaults(tls)
 
@@ -17,7 +17,20 @@ func TestTLS(t *testing.T) {
 		expectedErrContent string // substring from the expected error. Empty for positive cases.
 	}{
 		// positive
+		{"tls test_cert.pem test_key.pem", false, "", ""},
+		{"tls test_cert.pem test_key.pem {\nreload 10s\n}", false, "", ""},
+		{"tls test_cert.pem test_key.pem {\nreload off\nocsp off\n}", false, "", ""},
+		{"tls acme example.org {\nemail admin@example.org\nca https://localhost:14000/dir\nca_root test_ca.pem\nstorage /var/lib/coredns\n}", false, "", ""},
 		// negative
+		{"tls test_cert.pem", true, "", "Wrong argument"},
+		{"tls acme", true, "", "Wrong argument"},
+		{"tls test_cert.pem test_key.pem {\nreload\n}", true, "", "Wrong argument"},
+		{"tls test_cert.pem test_key.pem {\nreload bogus\n}", true, "", "invalid reload interval"},
+		{"tls test_cert.pem test_key.pem {\nreload 0s\n}", true, "", "greater than zero"},
+		{"tls test_cert.pem test_key.pem {\nocsp on\n}", true, "", "Wrong argument"},
+		{"tls test_cert.pem test_key.pem {\nemail admin@example.org\n}", true, "", "only used with acme"},
+		{"tls acme example.org {\nreload 10s\n}", true, "", "not used with acme"},
+		{"tls acme example.org {\nca_root missing.pem\n}", true, "", "missing.pem"},
 		{"tls test_cert.pem test_key.pem test_ca.pem {\nunknown\n}", true, "", "unknown option"},
 		// client_auth takes exactly one parameter, which must be one of known keywords.
 		{"tls test_cert.pem test_key.pem test_ca.pem {\nclient_auth\n}", true, "", "Wrong argument"},
@@ -0,0 +1,367 @@
+package view
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/plugin/pkg/parse"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+	"github.com/coredns/coredns/request"
+)
+
+// boolExpr and strExpr are compiled expressions, evaluated for each query.
+type (
+	boolExpr func(ctx context.Context, state *request.Request) bool
+	strExpr  func(ctx context.Context, state *request.Request) string
+)
+
+// node is a parsed (sub) expression, either b or s is set.
+type node struct {
+	b boolExpr
+	s strExpr
+}
+
+// strFuncs are the functions, without arguments, that return a property of the query.
+var strFuncs = map[string]strExpr{
+	"client_ip":   func(_ context.Context, state *request.Request) string { return state.IP() },
+	"client_port": func(_ context.Context, state *request.Request) string { return state.Port() },
+	"server_ip":   func(_ context.Context, state *request.Request) string { return state.LocalIP() },
+	"server_port": func(_ context.Context, state *request.Request) string { return state.LocalPort() },
+	"name":        func(_ context.Context, state *request.Request) string { return state.Name() },
+	"type":        func(_ context.Context, state *request.Request) string { return state.Type() },
+	"transport":   transportOf,
+	"sni":         sni,
+}
+
+// transportOf returns the transport the query was received on: "udp", "tcp", "tls", "https" or "grpc".
+func transportOf(ctx context.Context, state *request.Request) string {
+	if s, ok := ctx.Value(dnsserver.Key{}).(*dnsserver.Server); ok {
+		if t, _ := parse.Transport(s.Addr); t != transport.DNS {
+			return t
+		}
+	}
+	return state.Proto()
+}
+
+// sni returns the server name the client sent in the TLS handshake, if any.
+func sni(_ context.Context, state *request.Request) string {
+	if cs := dnsserver.ConnectionState(state.W); cs != nil {
+		return strings.ToLower(cs.ServerName)
+	}
+	return ""
+}
+
+// compile compiles the expression in s.
+func compile(s string) (boolExpr, error) {
+	toks, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	n, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, fmt.Errorf("unexpected %q", p.toks[p.pos].val)
+	}
+	if n.b == nil {
+		return nil, fmt.Errorf("expression does not evaluate to a boolean")
+	}
+	return n.b, nil
+}
+
+type tokenKind int
+
+const (
+	tokIdent tokenKind = iota
+	tokString
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	val  string
+}
+
+// lex splits s into tokens. Strings are quoted with single quotes, as double quotes are removed
+// by the Corefile parser.
+func lex(s string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '\'':
+			j := strings.IndexByte(s[i+1:], '\'')
+			if j < 0 {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			toks = append(toks, token{tokString, s[i+1 : i+1+j]})
+			i += j + 2
+		case c == '(' || c == ')' || c == ',':
+			toks = append(toks, token{tokOp, string(c)})
+			i++
+		case strings.HasPrefix(s[i:], "=="), strings.HasPrefix(s[i:], "!="), strings.HasPrefix(s[i:], "=~"),
+			strings.HasPrefix(s[i:], "&&"), strings.HasPrefix(s[i:], "||"):
+			toks = append(toks, token{tokOp, s[i : i+2]})
+			i += 2
+		case c == '!':
+			toks = append(toks, token{tokOp, "!"})
+			i++
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i + 1
+			for j < len(s) && (s[j] == '_' || s[j] >= 'a' && s[j] <= 'z' || s[j] >= 'A' && s[j] <= 'Z' || s[j] >= '0' && s[j] <= '9') {
+				j++
+			}
+			toks = append(toks, token{tokIdent, s[i:j]})
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected character %q", c)
+		}
+	}
+	return toks, nil
+}
+
+// parser is a recursive descent parser for:
+//
+//	or      = and { "||" and }
+//	and     = unary { "&&" unary }
+//	unary   = "!" unary | compare
+//	compare = primary [ ( "==" | "!=" | "=~" ) primary ]
+//	primary = "(" or ")" | STRING | "true" | "false" | IDENT "(" [ args ] ")"
+type parser struct {
+	toks []token
+	pos  int
+}
+
+// accept consumes the next token if it is the operator op.
+func (p *parser) accept(op string) bool {
+	if p.pos < len(p.toks) && p.toks[p.pos].kind == tokOp && p.toks[p.pos].val == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if p.accept(op) {
+		return nil
+	}
+	if p.pos < len(p.toks) {
+		return fmt.Errorf("expected %q, got %q", op, p.toks[p.pos].val)
+	}
+	return fmt.Errorf("expected %q at end of expression", op)
+}
+
+func (p *parser) or() (node, error) {
+	l, err := p.and()
+	if err != nil {
+		return l, err
+	}
+	for p.accept("||") {
+		r, err := p.and()
+		if err != nil {
+			return r, err
+		}
+		if l.b == nil || r.b == nil {
+			return node{}, fmt.Errorf("operands of || must be booleans")
+		}
+		a, b := l.b, r.b
+		l = node{b: func(ctx context.Context, state *request.Request) bool { return a(ctx, state) || b(ctx, state) }}
+	}
+	return l, nil
+}
+
+func (p *parser) and() (node, error) {
+	l, err := p.unary()
+	if err != nil {
+		return l, err
+	}
+	for p.accept("&&") {
+		r, err := p.unary()
+		if err != nil {
+			return r, err
+		}
+		if l.b == nil || r.b == nil {
+			return node{}, fmt.Errorf("operands of && must be booleans")
+		}
+		a, b := l.b, r.b
+		l = node{b: func(ctx context.Context, state *request.Request) bool { return a(ctx, state) && b(ctx, state) }}
+	}
+	return l, nil
+}
+
+func (p *parser) unary() (node, error) {
+	if p.accept("!") {
+		n, err := p.unary()
+		if err != nil {
+			return n, err
+		}
+		if n.b == nil {
+			return node{}, fmt.Errorf("operand of ! must be a boolean")
+		}
+		a := n.b
+		return node{b: func(ctx context.Context, state *request.Request) bool { return !a(ctx, state) }}, nil
+	}
+	return p.compare()
+}
+
+func (p *parser) compare() (node, error) {
+	l, err := p.primary()
+	if err != nil {
+		return l, err
+	}
+	var op string
+	for _, o := range []string{"==", "!=", "=~"} {
+		if p.accept(o) {
+			op = o
+			break
+		}
+	}
+	if op == "" {
+		return l, nil
+	}
+
+	start := p.pos
+	r, err := p.primary()
+	if err != nil {
+		return r, err
+	}
+	if l.s == nil || r.s == nil {
+		return node{}, fmt.Errorf("operands of %s must be strings", op)
+	}
+	a, b := l.s, r.s
+	switch op {
+	case "==":
+		return node{b: func(ctx context.Context, state *request.Request) bool { return a(ctx, state) == b(ctx, state) }}, nil
+	case "!=":
+		return node{b: func(ctx context.Context, state *request.Request) bool { return a(ctx, state) != b(ctx, state) }}, nil
+	}
+	if p.toks[start].kind != tokString || p.pos != start+1 {
+		return node{}, fmt.Errorf("the right operand of =~ must be a string")
+	}
+	re, err := regexp.Compile(p.toks[start].val)
+	if err != nil {
+		return node{}, err
+	}
+	return node{b: func(ctx context.Context, state *request.Request) bool { return re.MatchString(a(ctx, state)) }}, nil
+}
+
+func (p *parser) primary() (node, error) {
+	if p.pos >= len(p.toks) {
+		return node{}, fmt.Errorf("unexpected end of expression")
+	}
+	if p.accept("(") {
+		n, err := p.or()
+		if err != nil {
+			return n, err
+		}
+		return n, p.expect(")")
+	}
+
+	t := p.toks[p.pos]
+	p.pos++
+	switch t.kind {
+	case tokString:
+		return node{s: func(context.Context, *request.Request) string { return t.val }}, nil
+	case tokOp:
+		return node{}, fmt.Errorf("unexpected %q", t.val)
+	}
+
+	switch t.val {
+	case "true", "false":
+		v := t.val == "true"
+		return node{b: func(context.Context, *request.Request) bool { return v }}, nil
+	}
+	if err := p.expect("("); err != nil {
+		return node{}, err
+	}
+	return p.call(t.val)
+}
+
+// call parses the arguments, and the closing parenthesis, of the function name.
+func (p *parser) call(name string) (node, error) {
+	var (
+		args []node
+		lits []*string // the string literal for each argument, nil if it isn't one.
+	)
+	if !p.accept(")") {
+		for {
+			start := p.pos
+			n, err := p.or()
+			if err != nil {
+				return n, err
+			}
+			args = append(args, n)
+			var lit *string
+			if p.toks[start].kind == tokString && p.pos == start+1 {
+				lit = &p.toks[start].val
+			}
+			lits = append(lits, lit)
+			if p.accept(")") {
+				break
+			}
+			if err := p.expect(","); err != nil {
+				return node{}, err
+			}
+		}
+	}
+
+	if f, ok := strFuncs[name]; ok {
+		if len(args) != 0 {
+			return node{}, fmt.Errorf("%s() takes no arguments", name)
+		}
+		return node{s: f}, nil
+	}
+
+	switch name {
+	case "metadata":
+		if len(args) != 1 || lits[0] == nil {
+			return node{}, fmt.Errorf("metadata() takes one string argument")
+		}
+		label := *lits[0]
+		return node{s: func(ctx context.Context, _ *request.Request) string {
+			if f := metadata.ValueFunc(ctx, label); f != nil {
+				return f()
+			}
+			return ""
+		}}, nil
+
+	case "incidr":
+		if len(args) < 2 || args[0].s == nil {
+			return node{}, fmt.Errorf("incidr() takes an address and one or more networks")
+		}
+		nets := make([]*net.IPNet, len(args)-1)
+		for i, l := range lits[1:] {
+			if l == nil {
+				return node{}, fmt.Errorf("incidr() takes networks as strings")
+			}
+			_, n, err := net.ParseCIDR(*l)
+			if err != nil {
+				return node{}, fmt.Errorf("invalid network %q", *l)
+			}
+			nets[i] = n
+		}
+		addr := args[0].s
+		return node{b: func(ctx context.Context, state *request.Request) bool {
+			ip := net.ParseIP(addr(ctx, state))
+			if ip == nil {
+				return false
+			}
+			for _, n := range nets {
+				if n.Contains(ip) {
+					return true
+				}
+			}
+			return false
+		}}, nil
+	}
+	return node{}, fmt.Errorf("unknown function %q", name)
+}
@@ -0,0 +1,73 @@
+package view
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		expr      string
+		expected  bool
+		expectErr bool
+	}{
+		{expr: "incidr(client_ip(), '10.0.0.0/8')", expected: true},
+		{expr: "incidr(client_ip(), '192.168.0.0/16', '10.240.0.0/16')", expected: true},
+		{expr: "incidr(client_ip(), '192.168.0.0/16')", expected: false},
+		{expr: "!incidr(client_ip(), '192.168.0.0/16')", expected: true},
+		{expr: "transport() == 'udp'", expected: true},
+		{expr: "transport() == 'tcp' || name() == 'example.org.'", expected: true},
+		{expr: "transport() == 'tcp' && name() == 'example.org.'", expected: false},
+		{expr: "(transport() == 'tcp' || type() == 'A') && name() =~ '^example\\.'", expected: true},
+		{expr: "sni() == ''", expected: true},
+		{expr: "metadata('test/label') == 'value'", expected: true},
+		{expr: "metadata('test/other') != ''", expected: false},
+		{expr: "server_port() == '53'", expected: true},
+		{expr: "true && !false", expected: true},
+
+		{expr: "client_ip()", expectErr: true},
+		{expr: "client_ip() == ", expectErr: true},
+		{expr: "incidr(client_ip(), 'not-a-network')", expectErr: true},
+		{expr: "incidr(client_ip(), name())", expectErr: true},
+		{expr: "metadata(name()) == 'x'", expectErr: true},
+		{expr: "name() =~ name()", expectErr: true},
+		{expr: "name() =~ '('", expectErr: true},
+		{expr: "unknown() == 'x'", expectErr: true},
+		{expr: "name('x') == 'x'", expectErr: true},
+		{expr: "true || 'x'", expectErr: true},
+		{expr: "(true", expectErr: true},
+		{expr: "name() == 'x", expectErr: true},
+		{expr: "name() == \"x\"", expectErr: true},
+		{expr: "true true", expectErr: true},
+	}
+
+	ctx := metadata.ContextWithMetadata(context.TODO())
+	metadata.SetValueFunc(ctx, "test/label", func() string { return "value" })
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	state := &request.Request{W: &test.ResponseWriter{}, Req: m}
+
+	for i, tc := range tests {
+		e, err := compile(tc.expr)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("Test %d: expected an error for %q, got none", i, tc.expr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error for %q, got %s", i, tc.expr, err)
+			continue
+		}
+		if got := e(ctx, state); got != tc.expected {
+			t.Errorf("Test %d: expected %q to be %t, got %t", i, tc.expr, tc.expected, got)
+		}
+	}
+}
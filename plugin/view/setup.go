@@ -0,0 +1,68 @@
+package view
+
+import (
+	"strings"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+)
+
+func init() { plugin.Register("view", setup) }
+
+func setup(c *caddy.Controller) error {
+	v, err := parseView(c)
+	if err != nil {
+		return plugin.Error("view", err)
+	}
+
+	config := dnsserver.GetConfig(c)
+	config.ViewName = v.viewName
+	config.FilterFuncs = append(config.FilterFuncs, v.Filter)
+
+	config.AddPlugin(func(next plugin.Handler) plugin.Handler {
+		v.Next = next
+		return v
+	})
+
+	return nil
+}
+
+func parseView(c *caddy.Controller) (*View, error) {
+	v := new(View)
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		args := c.RemainingArgs()
+		if len(args) != 1 {
+			return nil, c.ArgErr()
+		}
+		v.viewName = args[0]
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "expr":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				e, err := compile(strings.Join(args, " "))
+				if err != nil {
+					return nil, c.Errf("invalid expression %q: %s", strings.Join(args, " "), err)
+				}
+				v.exprs = append(v.exprs, e)
+			default:
+				return nil, c.Errf("unknown property %q", c.Val())
+			}
+		}
+		if len(v.exprs) == 0 {
+			return nil, c.Errf("view %q has no expressions", v.viewName)
+		}
+	}
+	return v, nil
+}
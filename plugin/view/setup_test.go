@@ -0,0 +1,52 @@
+package view
+
+import (
+	"testing"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input     string
+		exprs     int
+		expectErr bool
+	}{
+		{input: "view internal {\n expr incidr(client_ip(), '10.0.0.0/8')\n}", exprs: 1},
+		{input: "view internal {\n expr incidr(client_ip(), '10.0.0.0/8')\n expr transport() == 'tls'\n}", exprs: 2},
+		{input: "view internal", expectErr: true},
+		{input: "view {\n expr true\n}", expectErr: true},
+		{input: "view a b {\n expr true\n}", expectErr: true},
+		{input: "view internal {\n expr\n}", expectErr: true},
+		{input: "view internal {\n expr client_ip(\n}", expectErr: true},
+		{input: "view internal {\n unknown true\n}", expectErr: true},
+		{input: "view a {\n expr true\n}\nview b {\n expr true\n}", expectErr: true},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		err := setup(c)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("Test %d: expected an error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		config := dnsserver.GetConfig(c)
+		if config.ViewName != "internal" {
+			t.Errorf("Test %d: expected view name %q, got %q", i, "internal", config.ViewName)
+		}
+		if len(config.FilterFuncs) != 1 {
+			t.Errorf("Test %d: expected 1 filter func, got %d", i, len(config.FilterFuncs))
+		}
+		v, _ := parseView(caddy.NewTestController("dns", tc.input))
+		if len(v.exprs) != tc.exprs {
+			t.Errorf("Test %d: expected %d expressions, got %d", i, tc.exprs, len(v.exprs))
+		}
+	}
+}
@@ -0,0 +1,44 @@
+// Package view implements a plugin that selects the server block handling a query by the
+// properties of the query and its client.
+package view
+
+import (
+	"context"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// View is a plugin that filters the queries a server block handles with one or more expressions.
+type View struct {
+	Next     plugin.Handler
+	viewName string
+	exprs    []boolExpr
+}
+
+// Filter implements dnsserver.FilterFunc. It returns true when all expressions evaluate to true.
+func (v *View) Filter(ctx context.Context, state *request.Request) bool {
+	for _, e := range v.exprs {
+		if !e(ctx, state) {
+			return false
+		}
+	}
+	return true
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (v *View) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	return plugin.NextOrFailure(v.Name(), v.Next, ctx, w, r)
+}
+
+// Metadata implements the metadata.Provider interface.
+func (v *View) Metadata(ctx context.Context, state request.Request) context.Context {
+	metadata.SetValueFunc(ctx, "view/name", func() string { return v.viewName })
+	return ctx
+}
+
+// Name implements the Handler interface.
+func (v *View) Name() string { return "view" }
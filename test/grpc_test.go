@@ -2,6 +2,7 @@ package test
 
 import (
 	"context"
+	"io"
 	"testing"
 	"time"
 
@@ -9,6 +10,8 @@ import (
 
 	"github.com/miekg/dns"
 	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	reflectpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
 )
 
 func TestGrpc(t *testing.T) {
@@ -55,3 +58,114 @@ func TestGrpc(t *testing.T) {
 		t.Errorf("Expected 2 RRs in additional section, but got %d", len(d.Extra))
 	}
 }
+
+func TestGrpcStream(t *testing.T) {
+	corefile := `grpc://.:0 {
+		whoami
+	}`
+
+	g, _, tcp, err := CoreDNSServerAndPorts(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer g.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, tcp, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		t.Fatalf("Expected no error but got: %s", err)
+	}
+	defer conn.Close()
+
+	stream, err := pb.NewDnsServiceClient(conn).Stream(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error but got: %s", err)
+	}
+
+	const n = 10
+	for i := 0; i < n; i++ {
+		m := new(dns.Msg)
+		m.SetQuestion("whoami.example.org.", dns.TypeA)
+		m.Id = uint16(i)
+		msg, _ := m.Pack()
+		if err := stream.Send(&pb.DnsPacket{Msg: msg}); err != nil {
+			t.Fatalf("Expected no error but got: %s", err)
+		}
+	}
+	stream.CloseSend()
+
+	seen := map[uint16]bool{}
+	for i := 0; i < n; i++ {
+		reply, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Expected no error but got: %s", err)
+		}
+		d := new(dns.Msg)
+		if err := d.Unpack(reply.Msg); err != nil {
+			t.Fatalf("Expected no error but got: %s", err)
+		}
+		if d.Rcode != dns.RcodeSuccess {
+			t.Errorf("Expected success but got %d", d.Rcode)
+		}
+		seen[d.Id] = true
+	}
+	if len(seen) != n {
+		t.Errorf("Expected %d different responses, got %d", n, len(seen))
+	}
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Errorf("Expected the stream to end, got: %v", err)
+	}
+}
+
+func TestGrpcHealthAndReflection(t *testing.T) {
+	corefile := `grpc://.:0 {
+		whoami
+	}`
+
+	g, _, tcp, err := CoreDNSServerAndPorts(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer g.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, tcp, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		t.Fatalf("Expected no error but got: %s", err)
+	}
+	defer conn.Close()
+
+	for _, service := range []string{"", "coredns.dns.DnsService"} {
+		resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			t.Fatalf("Expected no error but got: %s", err)
+		}
+		if resp.Status != healthpb.HealthCheckResponse_SERVING {
+			t.Errorf("Expected %q to be serving, got %s", service, resp.Status)
+		}
+	}
+
+	stream, err := reflectpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error but got: %s", err)
+	}
+	req := &reflectpb.ServerReflectionRequest{MessageRequest: &reflectpb.ServerReflectionRequest_ListServices{}}
+	if err := stream.Send(req); err != nil {
+		t.Fatalf("Expected no error but got: %s", err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Expected no error but got: %s", err)
+	}
+	found := false
+	for _, s := range resp.GetListServicesResponse().GetService() {
+		if s.Name == "coredns.dns.DnsService" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected coredns.dns.DnsService to be listed, got %v", resp.GetListServicesResponse().GetService())
+	}
+}
@@ -0,0 +1,102 @@
+package test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestMultisocket(t *testing.T) {
+	corefile := `.:0 {
+		multisocket 4 {
+			batch 8
+		}
+		whoami
+	}`
+	i, udp, _, err := CoreDNSServerAndPorts(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer i.Stop()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+
+	// Every client has its own source port, so the queries are spread over the sockets.
+	var wg sync.WaitGroup
+	for j := 0; j < 16; j++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := &dns.Client{Timeout: 2 * time.Second}
+			for k := 0; k < 10; k++ {
+				resp, _, err := c.Exchange(m, udp)
+				if err != nil {
+					t.Errorf("Expected to receive reply, but didn't: %s", err)
+					return
+				}
+				if len(resp.Extra) == 0 {
+					t.Errorf("Expected the whoami records in the response")
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// BenchmarkUDPSockets measures the queries per second of a single UDP socket, a socket per CPU and a
+// socket per CPU with batched reads and writes. Run it with -cpu to see how it scales, e.g.
+// go test -run XXX -bench UDPSockets -cpu 1,2,4,8 ./test
+func BenchmarkUDPSockets(b *testing.B) {
+	for _, bc := range []struct {
+		name   string
+		plugin string
+	}{
+		{"single", ""},
+		{"per-cpu", "multisocket"},
+		{"per-cpu-batch", "multisocket {\nbatch\n}"},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			corefile := `.:0 {
+				` + bc.plugin + `
+				whoami
+			}`
+			i, udp, _, err := CoreDNSServerAndPorts(corefile)
+			if err != nil {
+				b.Fatalf("Could not get CoreDNS serving instance: %s", err)
+			}
+			defer i.Stop()
+
+			m := new(dns.Msg)
+			m.SetQuestion("example.org.", dns.TypeA)
+
+			// Several clients per CPU keep the server busy while they wait for their responses.
+			b.SetParallelism(8)
+			b.ResetTimer()
+			start := time.Now()
+			b.RunParallel(func(pb *testing.PB) {
+				co, err := dns.Dial("udp", udp)
+				if err != nil {
+					b.Error(err)
+					return
+				}
+				defer co.Close()
+				for pb.Next() {
+					co.SetDeadline(time.Now().Add(2 * time.Second))
+					if err := co.WriteMsg(m); err != nil {
+						b.Error(err)
+						return
+					}
+					if _, err := co.ReadMsg(); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+			b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "qps")
+		})
+	}
+}
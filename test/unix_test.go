@@ -0,0 +1,51 @@
+package test
+
+import (
+	"net"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestUnixSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix sockets are not supported on windows")
+	}
+	path := filepath.Join(t.TempDir(), "coredns.sock")
+	corefile := `unix://` + path + ` {
+		whoami
+	}`
+	i, err := CoreDNSServer(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer i.Stop()
+
+	c, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("Could not connect to %s: %s", path, err)
+	}
+	// A stream connection, the dns package treats a *net.UnixConn as a packet conn.
+	co := &dns.Conn{Conn: struct{ net.Conn }{c}}
+	defer co.Close()
+	co.SetDeadline(time.Now().Add(5 * time.Second))
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	if err := co.WriteMsg(m); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := co.ReadMsg()
+	if err != nil {
+		t.Fatalf("Expected to receive reply, but didn't: %s", err)
+	}
+	if len(resp.Extra) == 0 {
+		t.Fatalf("Expected the whoami records in the response")
+	}
+	if a, ok := resp.Extra[0].(*dns.A); !ok || a.A.String() != "127.0.0.1" {
+		t.Errorf("Expected the client to be 127.0.0.1, got %s", resp.Extra[0])
+	}
+}